@@ -78,6 +78,8 @@ If one or more IMAGE_NAME parameters specified, werf will build only these image
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevStagesOptions(&commonCmdData, cmd)
+	common.SetupStrictWerfLock(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
@@ -205,6 +207,10 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface,
 		return fmt.Errorf("unable to load werf config: %s", err)
 	}
 
+	if err := common.CheckWerfLock(ctx, &commonCmdData, giterminismManager, werfConfig, ""); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
 	for _, imageToProcess := range imagesToProcess {
@@ -21,10 +21,12 @@ import (
 
 	"github.com/werf/werf/pkg/werf/global_warnings"
 
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/deploy/helm"
 
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 
 	"github.com/spf13/cobra"
@@ -39,6 +41,8 @@ var cmdData struct {
 	Tag          string
 	Timeout      int
 	AutoRollback bool
+	Verify       bool
+	VerifyKey    string
 }
 
 var commonCmdData common.CmdData
@@ -100,6 +104,7 @@ func NewCmd() *cobra.Command {
 	common.SetupNamespace(&commonCmdData, cmd)
 	common.SetupStatusProgressPeriod(&commonCmdData, cmd)
 	common.SetupHooksStatusProgressPeriod(&commonCmdData, cmd)
+	common.SetupStatusFormat(&commonCmdData, cmd)
 	common.SetupReleasesHistoryMax(&commonCmdData, cmd)
 
 	defaultTag := os.Getenv("WERF_TAG")
@@ -108,6 +113,11 @@ func NewCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Provide exact tag version or semver-based pattern, werf will install or upgrade to the latest version of the specified bundle ($WERF_TAG or latest by default)")
 
+	cmd.Flags().IntVarP(&cmdData.Timeout, "timeout", "t", 0, "Resources tracking timeout in seconds")
+	common.SetupAutoRollbackP(&cmdData.AutoRollback, cmd)
+	cmd.Flags().BoolVarP(&cmdData.Verify, "verify", "", common.GetBoolEnvironmentDefaultFalse("WERF_VERIFY"), "Verify bundle signature made by the \"werf bundle publish --sign-key\" with the --verify-key public key and check that digests of all images referenced in the bundle values have not been changed since signing before applying ($WERF_VERIFY by default)")
+	cmd.Flags().StringVarP(&cmdData.VerifyKey, "verify-key", "", os.Getenv("WERF_VERIFY_KEY"), "Path to the PEM encoded ed25519, ECDSA or RSA public key to verify bundle signature ($WERF_VERIFY_KEY by default)")
+
 	return cmd
 }
 
@@ -149,6 +159,17 @@ func runApply() error {
 		return fmt.Errorf("unable to create helm registry client: %s", err)
 	}
 
+	statusFormat, err := common.GetStatusFormat(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	statusEventsOutput, closeStatusEventsOutput, err := common.GetStatusEventsOutput(ctx, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	defer closeStatusEventsOutput()
+
 	actionConfig := new(action.Configuration)
 	if err := helm.InitActionConfig(ctx, common.GetOndemandKubeInitializer(), *commonCmdData.Namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
 		StatusProgressPeriod:      time.Duration(*commonCmdData.StatusProgressPeriodSeconds) * time.Second,
@@ -159,36 +180,69 @@ func runApply() error {
 			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
 		},
 		ReleasesHistoryMax: *commonCmdData.ReleasesHistoryMax,
+		StatusFormat:       statusFormat,
+		StatusEventsOutput: statusEventsOutput,
 	}); err != nil {
 		return err
 	}
 
 	loader.GlobalLoadOptions = &loader.LoadOptions{}
 
-	// FIXME: support semver-pattern
-	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+	tag, err := bundles.ResolveTag(ctx, repoAddress, cmdData.Tag)
+	if err != nil {
+		return err
+	}
+	bundleRef := fmt.Sprintf("%s:%s", repoAddress, tag)
+
+	var signedPayload *bundles.SignedPayload
+	if cmdData.Verify {
+		if cmdData.VerifyKey == "" {
+			return fmt.Errorf("--verify-key=PATH param required to verify bundle signature")
+		}
+
+		if err := logboek.Context(ctx).LogProcess("Verifying bundle %q", bundleRef).DoError(func() error {
+			key, err := bundles.LoadVerificationKey(cmdData.VerifyKey)
+			if err != nil {
+				return err
+			}
+
+			signedPayload, err = bundles.Verify(ctx, bundleRef, key)
+			if err != nil {
+				return err
+			}
+
+			return bundles.VerifyImages(ctx, signedPayload)
+		}); err != nil {
+			return err
+		}
+	}
 
 	bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
 	defer os.RemoveAll(bundleTmpDir)
 
-	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
-		if cmd := cmd_helm.NewChartPullCmd(actionConfig, logboek.Context(ctx).OutStream()); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
-			}
+	if signedPayload != nil {
+		if err := bundles.PullVerified(ctx, bundleRef, signedPayload, bundleTmpDir); err != nil {
+			return err
 		}
-		return nil
-	}); err != nil {
+	} else if err := bundles.Pull(ctx, bundleRef, bundleTmpDir, actionConfig); err != nil {
 		return err
 	}
 
-	if err := logboek.Context(ctx).LogProcess("Exporting bundle %q", bundleRef).DoError(func() error {
-		if cmd := cmd_helm.NewChartExportCmd(actionConfig, logboek.Context(ctx).OutStream(), cmd_helm.ChartExportCmdOptions{Destination: bundleTmpDir}); cmd != nil {
-			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error pushing bundle %q: %s", bundleRef, err)
-			}
+	bundleInfo, err := bundles.GetInfo(ctx, bundleRef)
+	if err != nil {
+		return err
+	} else if bundleInfo == nil {
+		return fmt.Errorf("%q is not a bundle", bundleRef)
+	}
+
+	if err := bundles.UpdateChartMetadata(bundleTmpDir, func(metadata *chart.Metadata) {
+		if metadata.Annotations == nil {
+			metadata.Annotations = map[string]string{}
 		}
-		return nil
+		for k, v := range bundleInfo.Annotations() {
+			metadata.Annotations[k] = v
+		}
+		metadata.Annotations[bundles.BundleRefAnnoName] = bundleRef
 	}); err != nil {
 		return err
 	}
@@ -244,11 +298,21 @@ func runApply() error {
 		CreateNamespace: common.NewBool(true),
 		Install:         common.NewBool(true),
 		Wait:            common.NewBool(true),
-		Atomic:          common.NewBool(cmdData.AutoRollback),
 		Timeout:         common.NewDuration(time.Duration(cmdData.Timeout) * time.Second),
 	})
 
+	if kubeClient := helm.GetOrderedKubeClient(actionConfig); kubeClient != nil {
+		kubeClient.GroupWaitTimeout = time.Duration(cmdData.Timeout) * time.Second
+	}
+
 	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
-		return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, bundle.Dir})
+		upgradeFunc := func() error {
+			return helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, bundle.Dir})
+		}
+
+		if cmdData.AutoRollback {
+			return helm.RunWithAutoRollback(ctx, actionConfig, releaseName, helm.AutoRollbackOptions{Timeout: time.Duration(cmdData.Timeout) * time.Second}, upgradeFunc)
+		}
+		return upgradeFunc()
 	})
 }
@@ -0,0 +1,108 @@
+package copy
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	From    string
+	To      string
+	SignKey string
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "copy",
+		Short:                 "Copy published bundle into another container registry",
+		Long:                  common.GetLongCommandDescription(`Copy published bundle into another container registry along with all images referenced in the bundle service values. Images are copied without changes (image digests stay the same), references to the images in the bundle values are rewritten to the destination repo, so the copied bundle does not depend on the source container registry.`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			common.LogVersion()
+
+			return common.LogRunningTime(func() error {
+				return runCopy()
+			})
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().StringVarP(&cmdData.From, "from", "", os.Getenv("WERF_FROM"), "Source bundle address REPO:TAG ($WERF_FROM by default)")
+	cmd.Flags().StringVarP(&cmdData.To, "to", "", os.Getenv("WERF_TO"), "Destination bundle address REPO:TAG, images are copied into REPO ($WERF_TO by default)")
+
+	cmd.Flags().StringVarP(&cmdData.SignKey, "sign-key", "", os.Getenv("WERF_SIGN_KEY"), "Sign the copied bundle with the PEM encoded ed25519, ECDSA or RSA private key by the provided path, signature is verified by the \"werf bundle apply --verify\" ($WERF_SIGN_KEY by default)")
+	return cmd
+}
+
+func runCopy() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if cmdData.From == "" {
+		return fmt.Errorf("--from=REPO:TAG param required")
+	}
+	if cmdData.To == "" {
+		return fmt.Errorf("--to=REPO:TAG param required")
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
+
+	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
+		return err
+	}
+
+	loader.GlobalLoadOptions = &loader.LoadOptions{}
+
+	var signKey crypto.Signer
+	if cmdData.SignKey != "" {
+		if signKey, err = bundles.LoadSigningKey(cmdData.SignKey); err != nil {
+			return err
+		}
+	}
+
+	return bundles.Copy(ctx, cmdData.From, cmdData.To, actionConfig, signKey)
+}
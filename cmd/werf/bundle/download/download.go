@@ -17,12 +17,15 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/werf"
 )
 
 var cmdData struct {
 	Tag         string
 	Destination string
+	Verify      bool
+	VerifyKey   string
 }
 
 var commonCmdData common.CmdData
@@ -70,6 +73,8 @@ func NewCmd() *cobra.Command {
 	}
 	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Provide exact tag version or semver-based pattern, werf will install or upgrade to the latest version of the specified bundle ($WERF_TAG or latest by default)")
 	cmd.Flags().StringVarP(&cmdData.Destination, "destination", "d", os.Getenv("WERF_DESTINATION"), "Download bundle into the provided directory ($WERF_DESTINATION or chart-name by default)")
+	cmd.Flags().BoolVarP(&cmdData.Verify, "verify", "", common.GetBoolEnvironmentDefaultFalse("WERF_VERIFY"), "Verify bundle signature made by the \"werf bundle publish --sign-key\" with the --verify-key public key and check that digests of all images referenced in the bundle values have not been changed since signing before downloading ($WERF_VERIFY by default)")
+	cmd.Flags().StringVarP(&cmdData.VerifyKey, "verify-key", "", os.Getenv("WERF_VERIFY_KEY"), "Path to the PEM encoded ed25519, ECDSA or RSA public key to verify bundle signature ($WERF_VERIFY_KEY by default)")
 
 	return cmd
 }
@@ -104,8 +109,38 @@ func runApply() error {
 
 	loader.GlobalLoadOptions = &loader.LoadOptions{}
 
-	// FIXME: support semver-pattern
-	bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+	tag, err := bundles.ResolveTag(ctx, repoAddress, cmdData.Tag)
+	if err != nil {
+		return err
+	}
+	bundleRef := fmt.Sprintf("%s:%s", repoAddress, tag)
+
+	var signedPayload *bundles.SignedPayload
+	if cmdData.Verify {
+		if cmdData.VerifyKey == "" {
+			return fmt.Errorf("--verify-key=PATH param required to verify bundle signature")
+		}
+
+		if err := logboek.Context(ctx).LogProcess("Verifying bundle %q", bundleRef).DoError(func() error {
+			key, err := bundles.LoadVerificationKey(cmdData.VerifyKey)
+			if err != nil {
+				return err
+			}
+
+			signedPayload, err = bundles.Verify(ctx, bundleRef, key)
+			if err != nil {
+				return err
+			}
+
+			return bundles.VerifyImages(ctx, signedPayload)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if signedPayload != nil {
+		return bundles.PullVerified(ctx, bundleRef, signedPayload, cmdData.Destination)
+	}
 
 	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
 		if cmd := cmd_helm.NewChartPullCmd(actionConfig, logboek.Context(ctx).OutStream()); cmd != nil {
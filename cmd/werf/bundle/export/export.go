@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	uuid "github.com/satori/go.uuid"
 	"github.com/spf13/cobra"
 	"github.com/werf/logboek"
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
@@ -17,6 +20,7 @@ import (
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender/helpers"
 	"github.com/werf/werf/pkg/docker"
@@ -34,6 +38,7 @@ import (
 
 var cmdData struct {
 	Destination string
+	WithImages  bool
 }
 
 var commonCmdData common.CmdData
@@ -68,6 +73,7 @@ func NewCmd() *cobra.Command {
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupGitWorkTree(&commonCmdData, cmd)
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevStagesOptions(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupEnvironment(&commonCmdData, cmd)
@@ -121,7 +127,8 @@ func NewCmd() *cobra.Command {
 	common.SetupAllowedLocalCacheVolumeUsageMargin(&commonCmdData, cmd)
 	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
 
-	cmd.Flags().StringVarP(&cmdData.Destination, "destination", "d", os.Getenv("WERF_DESTINATION"), "Export bundle into the provided directory ($WERF_DESTINATION or chart-name by default)")
+	cmd.Flags().StringVarP(&cmdData.Destination, "destination", "d", os.Getenv("WERF_DESTINATION"), "Export bundle into the provided directory or into the provided archive path with --with-images ($WERF_DESTINATION or chart-name by default)")
+	cmd.Flags().BoolVarP(&cmdData.WithImages, "with-images", "", common.GetBoolEnvironmentDefaultFalse("WERF_WITH_IMAGES"), "Export bundle into the tar.gz archive (chart-name.tar.gz by default) along with all images referenced in the bundle values, the archive can be published by the werf bundle import command, the archive does not contain the bundle signature ($WERF_WITH_IMAGES by default)")
 
 	return cmd
 }
@@ -179,6 +186,13 @@ func runExport(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
+	if err := common.InspectHelmFlagsGiterminism(giterminismManager, &commonCmdData); err != nil {
+		return err
+	}
+	if err := common.InspectHelmEnvGiterminism(giterminismManager); err != nil {
+		return err
+	}
+
 	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
@@ -333,11 +347,41 @@ func runExport(ctx context.Context) error {
 		FileValues:   common.GetSetFile(&commonCmdData),
 	}
 
+	headCommit, err := giterminismManager.LocalGitRepo().HeadCommit(ctx)
+	if err != nil {
+		return err
+	}
+
+	bundleInfo := bundles.Info{
+		Version:   wc.HelmChart.Metadata.Version,
+		Revision:  headCommit,
+		CreatedAt: time.Now(),
+	}
+
 	p := getter.All(cmd_helm.Settings)
 	if vals, err := valueOpts.MergeValues(p, wc); err != nil {
 		return err
-	} else if _, err := wc.CreateNewBundle(ctx, cmdData.Destination, vals); err != nil {
-		return fmt.Errorf("unable to create bundle: %s", err)
+	} else if !cmdData.WithImages {
+		if _, err := wc.CreateNewBundle(ctx, cmdData.Destination, vals); err != nil {
+			return fmt.Errorf("unable to create bundle: %s", err)
+		}
+	} else {
+		bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+		defer os.RemoveAll(bundleTmpDir)
+
+		bundle, err := wc.CreateNewBundle(ctx, bundleTmpDir, vals)
+		if err != nil {
+			return fmt.Errorf("unable to create bundle: %s", err)
+		}
+
+		archivePath := cmdData.Destination
+		if archivePath == "" {
+			archivePath = fmt.Sprintf("%s.tar.gz", wc.HelmChart.Metadata.Name)
+		}
+
+		if err := bundles.ExportArchive(ctx, bundle.Dir, archivePath, bundleInfo); err != nil {
+			return fmt.Errorf("unable to export bundle archive: %s", err)
+		}
 	}
 
 	return nil
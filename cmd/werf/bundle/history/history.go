@@ -0,0 +1,139 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/releaseutil"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var cmdData struct {
+	Max int
+}
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "history RELEASE",
+		Short:                 "Show bundles applied to the release",
+		Long:                  common.GetLongCommandDescription(`Show release revisions with the bundle applied by the "werf bundle apply" command in each revision, newest first.`),
+		DisableFlagsInUseLine: true,
+		Args:                  cobra.ExactArgs(1),
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runHistory(args[0])
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupKubeConfig(&commonCmdData, cmd)
+	common.SetupKubeConfigBase64(&commonCmdData, cmd)
+	common.SetupKubeContext(&commonCmdData, cmd)
+
+	common.SetupNamespace(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	cmd.Flags().IntVarP(&cmdData.Max, "max", "", 256, "Maximum number of revisions to show")
+
+	return cmd
+}
+
+func runHistory(releaseName string) error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	common.SetupOndemandKubeInitializer(*commonCmdData.KubeContext, *commonCmdData.KubeConfig, *commonCmdData.KubeConfigBase64, *commonCmdData.KubeConfigPathMergeList)
+	if err := common.GetOndemandKubeInitializer().Init(ctx); err != nil {
+		return err
+	}
+
+	cmd_helm.Settings.Debug = *commonCmdData.LogDebug
+
+	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	actionConfig := new(action.Configuration)
+	if err := helm.InitActionConfig(ctx, common.GetOndemandKubeInitializer(), *commonCmdData.Namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
+		KubeConfigOptions: kube.KubeConfigOptions{
+			Context:          *commonCmdData.KubeContext,
+			ConfigPath:       *commonCmdData.KubeConfig,
+			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
+		},
+	}); err != nil {
+		return err
+	}
+
+	historyAction := action.NewHistory(actionConfig)
+	historyAction.Max = cmdData.Max
+
+	releases, err := historyAction.Run(releaseName)
+	if err != nil {
+		return fmt.Errorf("unable to get release %q history: %s", releaseName, err)
+	}
+	releaseutil.Reverse(releases, releaseutil.SortByRevision)
+
+	tbl := table.New("Revision", "Updated", "Status", "Chart version", "Bundle", "Commit")
+	tbl.WithWriter(logboek.Context(ctx).OutStream())
+	tbl.WithHeaderFormatter(func(format string, a ...interface{}) string {
+		return logboek.ColorizeF(color.New(color.OpUnderscore), format, a...)
+	})
+
+	for _, rel := range releases {
+		var chartVersion, bundleRef, bundleRevision string
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			chartVersion = rel.Chart.Metadata.Version
+			bundleRef = rel.Chart.Metadata.Annotations[bundles.BundleRefAnnoName]
+			bundleRevision = rel.Chart.Metadata.Annotations[bundles.RevisionAnnoName]
+		}
+
+		var updated string
+		if rel.Info != nil {
+			updated = rel.Info.LastDeployed.Local().Format(time.RFC3339)
+		}
+
+		var status string
+		if rel.Info != nil {
+			status = rel.Info.Status.String()
+		}
+
+		tbl.AddRow(rel.Version, updated, status, chartVersion, bundleRef, bundleRevision)
+	}
+	tbl.Print()
+
+	return nil
+}
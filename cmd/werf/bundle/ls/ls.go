@@ -0,0 +1,93 @@
+package ls
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/deploy/bundles"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf/global_warnings"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "ls",
+		Short:                 "List bundles published into the container registry",
+		Long:                  common.GetLongCommandDescription(`List bundles published into the container registry repo with the bundle chart version, creation time and source git commit, newest first.`),
+		DisableFlagsInUseLine: true,
+		Annotations: map[string]string{
+			common.CmdEnvAnno: common.EnvsDescription(),
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer global_warnings.PrintGlobalWarnings(common.BackgroundContext())
+
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runLs()
+		},
+	}
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupStagesStorageOptions(&commonCmdData, cmd)
+	common.SetupFinalStagesStorageOptions(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func runLs() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	repoAddress, err := common.GetStagesStorageAddress(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	infos, err := bundles.List(ctx, repoAddress)
+	if err != nil {
+		return err
+	}
+
+	tbl := table.New("Tag", "Version", "Created", "Commit")
+	tbl.WithWriter(logboek.Context(ctx).OutStream())
+	tbl.WithHeaderFormatter(func(format string, a ...interface{}) string {
+		return logboek.ColorizeF(color.New(color.OpUnderscore), format, a...)
+	})
+
+	for _, info := range infos {
+		var createdAt string
+		if !info.CreatedAt.IsZero() {
+			createdAt = info.CreatedAt.Local().Format(time.RFC3339)
+		}
+		tbl.AddRow(info.Tag, info.Version, createdAt, info.Revision)
+	}
+	tbl.Print()
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"helm.sh/helm/v3/pkg/getter"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/werf/werf/pkg/git_repo/gitdata"
 	"github.com/werf/werf/pkg/werf/global_warnings"
 
+	"github.com/werf/werf/pkg/deploy/bundles"
 	"github.com/werf/werf/pkg/deploy/helm"
 
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
@@ -43,7 +45,9 @@ import (
 )
 
 var cmdData struct {
-	Tag string
+	Tag              string
+	SemverFromGitTag bool
+	SignKey          string
 }
 
 var commonCmdData common.CmdData
@@ -79,6 +83,8 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 	common.SetupDir(&commonCmdData, cmd)
 	common.SetupGitWorkTree(&commonCmdData, cmd)
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevStagesOptions(&commonCmdData, cmd)
+	common.SetupStrictWerfLock(&commonCmdData, cmd)
 	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
 	common.SetupConfigPath(&commonCmdData, cmd)
 	common.SetupEnvironment(&commonCmdData, cmd)
@@ -137,6 +143,8 @@ Published into container registry bundle can be rolled out by the "werf bundle"
 		defaultTag = "latest"
 	}
 	cmd.Flags().StringVarP(&cmdData.Tag, "tag", "", defaultTag, "Publish bundle into container registry repo by the provided tag ($WERF_TAG or latest by default)")
+	cmd.Flags().BoolVarP(&cmdData.SemverFromGitTag, "semver-from-git-tag", "", common.GetBoolEnvironmentDefaultFalse("WERF_SEMVER_FROM_GIT_TAG"), `Publish bundle by the semver version calculated from the nearest semver git tag instead of --tag: the tag version when HEAD is tagged, otherwise the tag version with the bumped patch and the prerelease <number of commits since the tag>.g<short HEAD commit>, e.g. 1.2.4-3.g1a2b3c4. The version is also used as the bundle chart version (default $WERF_SEMVER_FROM_GIT_TAG)`)
+	cmd.Flags().StringVarP(&cmdData.SignKey, "sign-key", "", os.Getenv("WERF_SIGN_KEY"), "Sign published bundle chart and digests of all images referenced in the bundle values with the PEM encoded ed25519, ECDSA or RSA private key by the provided path, signature is verified by the \"werf bundle apply --verify\" ($WERF_SIGN_KEY by default)")
 
 	return cmd
 }
@@ -194,6 +202,14 @@ func runPublish(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
+	if err := common.InspectHelmFlagsGiterminism(giterminismManager, &commonCmdData); err != nil {
+		return err
+	}
+
+	if err := common.InspectHelmEnvGiterminism(giterminismManager); err != nil {
+		return err
+	}
+
 	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, config.WerfConfigOptions{LogRenderedFilePath: true, Env: *commonCmdData.Environment})
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
@@ -222,6 +238,10 @@ func runPublish(ctx context.Context) error {
 		}
 	}()
 
+	if err := common.CheckWerfLock(ctx, &commonCmdData, giterminismManager, werfConfig, chartDir); err != nil {
+		return err
+	}
+
 	userExtraAnnotations, err := common.GetUserExtraAnnotations(&commonCmdData)
 	if err != nil {
 		return err
@@ -358,36 +378,56 @@ func runPublish(ctx context.Context) error {
 	} else {
 		loader.GlobalLoadOptions = &loader.LoadOptions{}
 
-		bundleRef := fmt.Sprintf("%s:%s", repoAddress, cmdData.Tag)
+		tag := cmdData.Tag
+		chartVersion := wc.HelmChart.Metadata.Version
+		if cmdData.SemverFromGitTag {
+			version, err := bundles.GetVersionFromGit(ctx, giterminismManager.LocalGitRepo())
+			if err != nil {
+				return fmt.Errorf("unable to calculate bundle version from git tags: %s", err)
+			}
+			tag = version
+			chartVersion = version
 
-		if err := logboek.Context(ctx).LogProcess("Saving bundle to the local chart helm cache").DoError(func() error {
-			actionConfig := new(action.Configuration)
-			if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
+			if err := bundles.UpdateChartMetadata(bundle.Dir, func(metadata *chart.Metadata) {
+				metadata.Version = version
+			}); err != nil {
 				return err
 			}
+		}
 
-			helmChartSaveCmd := cmd_helm.NewChartSaveCmd(actionConfig, logboek.Context(ctx).OutStream())
-			if err := helmChartSaveCmd.RunE(helmChartSaveCmd, []string{bundle.Dir, bundleRef}); err != nil {
-				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
-			}
-			return nil
+		headCommit, err := giterminismManager.LocalGitRepo().HeadCommit(ctx)
+		if err != nil {
+			return err
+		}
+
+		bundleRef := fmt.Sprintf("%s:%s", repoAddress, tag)
+
+		actionConfig := new(action.Configuration)
+		if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
+			return err
+		}
+
+		if err := bundles.Push(ctx, bundle.Dir, bundleRef, actionConfig); err != nil {
+			return err
+		}
+
+		if err := bundles.SetPublishedInfo(ctx, bundleRef, bundles.Info{
+			Version:   chartVersion,
+			Revision:  headCommit,
+			CreatedAt: time.Now(),
 		}); err != nil {
 			return err
 		}
 
-		if err := logboek.Context(ctx).LogProcess("Pushing bundle %q", bundleRef).DoError(func() error {
-			actionConfig := new(action.Configuration)
-			if err := helm.InitActionConfig(ctx, nil, "", cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{}); err != nil {
+		if cmdData.SignKey != "" {
+			key, err := bundles.LoadSigningKey(cmdData.SignKey)
+			if err != nil {
 				return err
 			}
 
-			helmChartPushCmd := cmd_helm.NewChartPushCmd(actionConfig, logboek.Context(ctx).OutStream())
-			if err := helmChartPushCmd.RunE(helmChartPushCmd, []string{bundleRef}); err != nil {
-				return fmt.Errorf("error pushing bundle %q: %s", bundleRef, err)
+			if err := bundles.SignPublished(ctx, bundleRef, bundle.Dir, key); err != nil {
+				return err
 			}
-			return nil
-		}); err != nil {
-			return err
 		}
 	}
 
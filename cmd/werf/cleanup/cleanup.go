@@ -60,6 +60,7 @@ It is safe to run this command periodically (daily is enough) by automated clean
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevRepo(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
@@ -228,6 +229,10 @@ It is worth noting that auto-cleaning is enabled by default, and manual use is u
 	if err != nil {
 		return err
 	}
+	devStagesStorage, err := common.GetDevStagesStorage(containerRuntime, &commonCmdData)
+	if err != nil {
+		return err
+	}
 
 	storageManager := manager.NewStorageManager(projectName, stagesStorage, finalStagesStorage, secondaryStagesStorageList, cacheStagesStorageList, storageLockManager, stagesStorageCache)
 
@@ -255,6 +260,7 @@ It is worth noting that auto-cleaning is enabled by default, and manual use is u
 		GitHistoryBasedCleanupOptions:           werfConfig.Meta.Cleanup,
 		KeepStagesBuiltWithinLastNHours:         *commonCmdData.KeepStagesBuiltWithinLastNHours,
 		DryRun:                                  *commonCmdData.DryRun,
+		DevStagesStorage:                        devStagesStorage,
 	}
 
 	logboek.LogOptionalLn()
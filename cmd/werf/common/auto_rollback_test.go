@@ -0,0 +1,38 @@
+package common
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+)
+
+var _ = DescribeTable("SetupAutoRollbackP enables the auto rollback by --auto-rollback, --atomic and their environment variables",
+	func(env map[string]string, args []string, expected bool) {
+		for name, value := range env {
+			Ω(os.Setenv(name, value)).Should(Succeed())
+		}
+		defer func() {
+			for name := range env {
+				Ω(os.Unsetenv(name)).Should(Succeed())
+			}
+		}()
+
+		var autoRollback bool
+		cmd := &cobra.Command{}
+		SetupAutoRollbackP(&autoRollback, cmd)
+
+		Ω(cmd.ParseFlags(args)).Should(Succeed())
+		Ω(autoRollback).Should(Equal(expected))
+	},
+	Entry("disabled by default", nil, nil, false),
+	Entry("--auto-rollback", nil, []string{"--auto-rollback"}, true),
+	Entry("-R", nil, []string{"-R"}, true),
+	Entry("--atomic", nil, []string{"--atomic"}, true),
+	Entry("--auto-rollback and --atomic", nil, []string{"--auto-rollback", "--atomic"}, true),
+	Entry("$WERF_AUTO_ROLLBACK", map[string]string{"WERF_AUTO_ROLLBACK": "1"}, nil, true),
+	Entry("$WERF_ATOMIC", map[string]string{"WERF_ATOMIC": "true"}, nil, true),
+	Entry("$WERF_AUTO_ROLLBACK and --atomic", map[string]string{"WERF_AUTO_ROLLBACK": "1"}, []string{"--atomic"}, true),
+	Entry("disabled explicitly", map[string]string{"WERF_ATOMIC": "1"}, []string{"--atomic=false"}, false),
+)
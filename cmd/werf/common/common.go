@@ -3,6 +3,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
@@ -21,6 +22,7 @@ import (
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/deploy/helm"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -53,6 +55,8 @@ type CmdData struct {
 	KubeConfigPathMergeList          *[]string
 	StatusProgressPeriodSeconds      *int64
 	HooksStatusProgressPeriodSeconds *int64
+	StatusFormat                     *string
+	StatusEventsFile                 *string
 	ReleasesHistoryMax               *int
 
 	SetDockerConfigJsonValue *bool
@@ -90,6 +94,11 @@ type CmdData struct {
 	Dev              *bool
 	DevIgnore        *[]string
 	DevBranchPrefix  *string
+	UseDevRepo       *bool
+	DevRepo          *string
+	DevStagesTTL     *string
+
+	StrictWerfLock *bool
 
 	IntrospectBeforeError *bool
 	IntrospectAfterError  *bool
@@ -448,6 +457,70 @@ func SetupStatusProgressPeriodP(destination *int64, cmd *cobra.Command) {
 	)
 }
 
+func SetupStatusFormat(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.StatusFormat = new(string)
+
+	defaultValue := os.Getenv("WERF_STATUS_FORMAT")
+	if defaultValue == "" {
+		defaultValue = string(helm.StatusFormatText)
+	}
+
+	cmd.Flags().StringVarP(cmdData.StatusFormat, "status-format", "", defaultValue, fmt.Sprintf(`Resources tracking output format: %[1]s or %[2]s (%[1]s or $WERF_STATUS_FORMAT by default).
+%[2]s format prints one JSON object per line for each resource state transition, log line, kubernetes event, failure and werf.io/fail-mode decision`, string(helm.StatusFormatText), string(helm.StatusFormatJSON)))
+
+	cmdData.StatusEventsFile = new(string)
+	cmd.Flags().StringVarP(cmdData.StatusEventsFile, "status-events-file", "", os.Getenv("WERF_STATUS_EVENTS_FILE"), fmt.Sprintf(`Write %[1]s status events into the specified file instead of stdout ($WERF_STATUS_EVENTS_FILE by default).
+When %[1]s status events are written to stdout werf log is suppressed except warnings and errors, which go to stderr`, string(helm.StatusFormatJSON)))
+}
+
+func GetStatusFormat(cmdData *CmdData) (helm.StatusFormat, error) {
+	if cmdData.StatusFormat == nil {
+		return helm.StatusFormatText, nil
+	}
+
+	switch format := helm.StatusFormat(*cmdData.StatusFormat); format {
+	case helm.StatusFormatText, helm.StatusFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("bad --status-format given %q, expected: \"%s\"", format, strings.Join([]string{string(helm.StatusFormatText), string(helm.StatusFormatJSON)}, "\", \""))
+	}
+}
+
+// GetStatusEventsOutput returns the writer for the json status events or nil when the text status format is used.
+// The file given with --status-events-file is truncated, the returned close function should be called when the command is done
+// and all action configs of the command should share the returned writer.
+func GetStatusEventsOutput(ctx context.Context, cmdData *CmdData) (io.Writer, func() error, error) {
+	noClose := func() error { return nil }
+
+	statusFormat, err := GetStatusFormat(cmdData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if statusFormat != helm.StatusFormatJSON {
+		return nil, noClose, nil
+	}
+
+	if cmdData.StatusEventsFile == nil || *cmdData.StatusEventsFile == "" {
+		logboek.Context(ctx).SetAcceptedLevel(level.Warn)
+		return os.Stdout, noClose, nil
+	}
+
+	f, err := os.OpenFile(*cmdData.StatusEventsFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open status events file %q: %s", *cmdData.StatusEventsFile, err)
+	}
+
+	return f, f.Close, nil
+}
+
+// SetupAutoRollbackP sets up --auto-rollback and its --atomic alias, any of them or of their environment variables enables the auto rollback.
+func SetupAutoRollbackP(destination *bool, cmd *cobra.Command) {
+	defaultValue := GetBoolEnvironmentDefaultFalse("WERF_AUTO_ROLLBACK") || GetBoolEnvironmentDefaultFalse("WERF_ATOMIC")
+	cmd.Flags().BoolVarP(destination, "auto-rollback", "R", defaultValue, "Enable auto rollback of the failed release to the last successfully deployed release revision when current deploy process have failed. Rollback is performed under the same release lock and its resources are tracked ($WERF_AUTO_ROLLBACK by default)")
+	cmd.Flags().BoolVarP(destination, "atomic", "", defaultValue, "Enable auto rollback of the failed release to the last successfully deployed release revision when current deploy process have failed. Rollback is performed under the same release lock and its resources are tracked ($WERF_ATOMIC by default)")
+}
+
 func SetupReleasesHistoryMax(cmdData *CmdData, cmd *cobra.Command) {
 	cmdData.ReleasesHistoryMax = new(int)
 
@@ -936,7 +1009,20 @@ func GetLocalStagesStorage(containerRuntime container_runtime.ContainerRuntime)
 	)
 }
 
+// GetStagesStorage returns the dev stages storage instead of the --repo for the commands which build stages in development mode.
 func GetStagesStorage(stagesStorageAddress string, containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) (storage.StagesStorage, error) {
+	if useDevStagesNamespace(cmdData) {
+		if _, err := getDevStagesTTL(cmdData); err != nil {
+			return nil, err
+		}
+
+		stagesStorageAddress = GetDevStagesStorageAddress(cmdData)
+	}
+
+	return newStagesStorage(stagesStorageAddress, containerRuntime, cmdData)
+}
+
+func newStagesStorage(stagesStorageAddress string, containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) (storage.StagesStorage, error) {
 	if err := ValidateRepoContainerRegistry(cmdData.CommonRepoData.GetContainerRegistry()); err != nil {
 		return nil, err
 	}
@@ -969,6 +1055,10 @@ func GetOptionalFinalStagesStorage(containerRuntime container_runtime.ContainerR
 		return nil, nil
 	}
 
+	if useDevStagesNamespace(cmdData) {
+		return nil, fmt.Errorf("--final-repo cannot be used with the dev repo in development mode: stages built in development mode are stored only in the dev repo")
+	}
+
 	if err := ValidateRepoContainerRegistry(cmdData.CommonFinalRepoData.GetContainerRegistry()); err != nil {
 		return nil, err
 	}
@@ -1019,6 +1109,14 @@ func GetSecondaryStagesStorageList(stagesStorage storage.StagesStorage, containe
 		res = append(res, localStagesStorage)
 	}
 
+	if useDevStagesNamespace(cmdData) {
+		repoStagesStorage, err := newStagesStorage(*cmdData.StagesStorage, containerRuntime, cmdData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create secondary stages storage at %s: %s", *cmdData.StagesStorage, err)
+		}
+		res = append(res, repoStagesStorage)
+	}
+
 	for _, address := range GetSecondaryStagesStorage(cmdData) {
 		repoStagesStorage, err := storage.NewStagesStorage(address, containerRuntime, storage.StagesStorageOptions{})
 		if err != nil {
@@ -1113,6 +1211,15 @@ func GetWerfConfigOptions(cmdData *CmdData, LogRenderedFilePath bool) config.Wer
 }
 
 func GetGiterminismManager(cmdData *CmdData) (giterminism_manager.Interface, error) {
+	return getGiterminismManager(cmdData, false)
+}
+
+// GetGiterminismManagerCollectingViolations returns the giterminism manager that collects all giterminism violations instead of failing on the first one.
+func GetGiterminismManagerCollectingViolations(cmdData *CmdData) (giterminism_manager.Interface, error) {
+	return getGiterminismManager(cmdData, true)
+}
+
+func getGiterminismManager(cmdData *CmdData, collectViolations bool) (giterminism_manager.Interface, error) {
 	workingDir := GetWorkingDir(cmdData)
 
 	gitWorkTree, err := GetGitWorkTree(cmdData, workingDir)
@@ -1144,8 +1251,9 @@ func GetGiterminismManager(cmdData *CmdData) (giterminism_manager.Interface, err
 	}
 
 	return giterminism_manager.NewManager(BackgroundContext(), workingDir, localGitRepo, headCommit, giterminism_manager.NewManagerOptions{
-		LooseGiterminism: *cmdData.LooseGiterminism,
-		Dev:              *cmdData.Dev,
+		LooseGiterminism:  *cmdData.LooseGiterminism,
+		Dev:               *cmdData.Dev,
+		CollectViolations: collectViolations,
 	})
 }
 
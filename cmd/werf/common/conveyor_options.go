@@ -10,13 +10,19 @@ import (
 )
 
 func GetConveyorOptions(commonCmdData *CmdData) build.ConveyorOptions {
-	return build.ConveyorOptions{
+	conveyorOptions := build.ConveyorOptions{
 		LocalGitRepoVirtualMergeOptions: stage.VirtualMergeOptions{
 			VirtualMerge:           *commonCmdData.VirtualMerge,
 			VirtualMergeFromCommit: *commonCmdData.VirtualMergeFromCommit,
 			VirtualMergeIntoCommit: *commonCmdData.VirtualMergeIntoCommit,
 		},
 	}
+
+	if useDevStagesNamespace(commonCmdData) {
+		conveyorOptions.DevStagesTTL = *commonCmdData.DevStagesTTL
+	}
+
+	return conveyorOptions
 }
 
 func GetConveyorOptionsWithParallel(commonCmdData *CmdData, buildStagesOptions build.BuildOptions) (build.ConveyorOptions, error) {
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// SetupDevStagesOptions sets up the dev stages namespace options for the commands which build stages.
+func SetupDevStagesOptions(cmdData *CmdData, cmd *cobra.Command) {
+	SetupDevRepo(cmdData, cmd)
+	setupDevStagesTTL(cmdData, cmd)
+}
+
+func SetupDevRepo(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.UseDevRepo = new(bool)
+	cmd.Flags().BoolVarP(cmdData.UseDevRepo, "use-dev-repo", "", GetBoolEnvironmentDefaultFalse("WERF_USE_DEV_REPO"), `Use the dev repo for stages built in development mode: new stages are stored only in the dev repo and werf cleanup deletes them by TTL (default $WERF_USE_DEV_REPO).
+The option is enabled if --dev-repo is set`)
+
+	cmdData.DevRepo = new(string)
+	cmd.Flags().StringVarP(cmdData.DevRepo, "dev-repo", "", os.Getenv("WERF_DEV_REPO"), fmt.Sprintf(`Docker repo to store stages built in development mode (default $WERF_DEV_REPO or --repo with %q suffix if --use-dev-repo is set).
+Stages of the --repo are reused, but new stages are stored only in the dev repo`, storage.DevStagesStorageAddressSuffix))
+}
+
+func setupDevStagesTTL(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.DevStagesTTL = new(string)
+
+	defaultValue := os.Getenv("WERF_DEV_STAGES_TTL")
+	if defaultValue == "" {
+		defaultValue = "24h"
+	}
+
+	cmd.Flags().StringVarP(cmdData.DevStagesTTL, "dev-stages-ttl", "", defaultValue, `Time to live of stages built in development mode, werf cleanup deletes the dev repo stages older than TTL (default $WERF_DEV_STAGES_TTL or 24h)`)
+}
+
+// isDevRepoEnabled returns true if the dev repo is enabled explicitly by --use-dev-repo or --dev-repo.
+func isDevRepoEnabled(cmdData *CmdData) bool {
+	return (cmdData.UseDevRepo != nil && *cmdData.UseDevRepo) || (cmdData.DevRepo != nil && *cmdData.DevRepo != "")
+}
+
+// useDevStagesNamespace returns true when the command builds stages in development mode with the --repo and the dev repo is enabled.
+// Local stages storage is not shared, thus dev stages are stored there as usual.
+func useDevStagesNamespace(cmdData *CmdData) bool {
+	if cmdData.DevStagesTTL == nil || cmdData.Dev == nil || !*cmdData.Dev || !isDevRepoEnabled(cmdData) {
+		return false
+	}
+
+	return *cmdData.StagesStorage != "" && *cmdData.StagesStorage != storage.LocalStorageAddress
+}
+
+func GetDevStagesStorageAddress(cmdData *CmdData) string {
+	if cmdData.DevRepo != nil && *cmdData.DevRepo != "" {
+		return *cmdData.DevRepo
+	}
+
+	return *cmdData.StagesStorage + storage.DevStagesStorageAddressSuffix
+}
+
+func getDevStagesTTL(cmdData *CmdData) (time.Duration, error) {
+	ttl, err := time.ParseDuration(*cmdData.DevStagesTTL)
+	if err != nil {
+		return 0, fmt.Errorf("bad --dev-stages-ttl value %q: %s", *cmdData.DevStagesTTL, err)
+	}
+
+	return ttl, nil
+}
+
+// GetDevStagesStorage returns the dev stages storage of the --repo, which is cleaned up by the stages TTL, nil if the dev repo is not enabled.
+func GetDevStagesStorage(containerRuntime container_runtime.ContainerRuntime, cmdData *CmdData) (storage.StagesStorage, error) {
+	if !isDevRepoEnabled(cmdData) || *cmdData.StagesStorage == "" || *cmdData.StagesStorage == storage.LocalStorageAddress {
+		return nil, nil
+	}
+
+	return newStagesStorage(GetDevStagesStorageAddress(cmdData), containerRuntime, cmdData)
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type devStagesCmdDataOptions struct {
+	dev          bool
+	useDevRepo   bool
+	devRepo      string
+	repo         string
+	noTTLOptions bool
+}
+
+func newDevStagesCmdData(opts devStagesCmdDataOptions) *CmdData {
+	cmdData := &CmdData{
+		Dev:           &opts.dev,
+		UseDevRepo:    &opts.useDevRepo,
+		DevRepo:       &opts.devRepo,
+		StagesStorage: &opts.repo,
+	}
+
+	if !opts.noTTLOptions {
+		ttl := "24h"
+		cmdData.DevStagesTTL = &ttl
+	}
+
+	return cmdData
+}
+
+var _ = Describe("dev stages", func() {
+	DescribeTable("useDevStagesNamespace is enabled only in development mode with the remote --repo and the dev repo enabled explicitly",
+		func(opts devStagesCmdDataOptions, expected bool) {
+			Ω(useDevStagesNamespace(newDevStagesCmdData(opts))).Should(Equal(expected))
+		},
+		Entry("dev mode without the dev repo", devStagesCmdDataOptions{dev: true, repo: "registry.example.com/app"}, false),
+		Entry("--use-dev-repo", devStagesCmdDataOptions{dev: true, useDevRepo: true, repo: "registry.example.com/app"}, true),
+		Entry("--dev-repo", devStagesCmdDataOptions{dev: true, devRepo: "registry.example.com/app-dev", repo: "registry.example.com/app"}, true),
+		Entry("not dev mode", devStagesCmdDataOptions{useDevRepo: true, repo: "registry.example.com/app"}, false),
+		Entry("local stages storage", devStagesCmdDataOptions{dev: true, useDevRepo: true, repo: ":local"}, false),
+		Entry("no --repo", devStagesCmdDataOptions{dev: true, useDevRepo: true}, false),
+		Entry("the command does not build stages", devStagesCmdDataOptions{dev: true, useDevRepo: true, repo: "registry.example.com/app", noTTLOptions: true}, false),
+	)
+
+	DescribeTable("GetDevStagesStorageAddress",
+		func(opts devStagesCmdDataOptions, expected string) {
+			Ω(GetDevStagesStorageAddress(newDevStagesCmdData(opts))).Should(Equal(expected))
+		},
+		Entry("--repo with the suffix", devStagesCmdDataOptions{useDevRepo: true, repo: "registry.example.com/app"}, "registry.example.com/app-dev"),
+		Entry("--dev-repo", devStagesCmdDataOptions{devRepo: "registry.example.com/dev/app", repo: "registry.example.com/app"}, "registry.example.com/dev/app"),
+	)
+
+	DescribeTable("getDevStagesTTL",
+		func(value string, expectErr bool) {
+			cmdData := newDevStagesCmdData(devStagesCmdDataOptions{})
+			cmdData.DevStagesTTL = &value
+
+			_, err := getDevStagesTTL(cmdData)
+			if expectErr {
+				Ω(err).Should(HaveOccurred())
+			} else {
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+		},
+		Entry("duration", "12h30m", false),
+		Entry("days are not supported", "1d", true),
+		Entry("empty", "", true),
+	)
+})
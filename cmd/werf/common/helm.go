@@ -2,12 +2,19 @@ package common
 
 import (
 	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/werf/kubedog/pkg/kube"
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/deploy/helm"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/util"
 	cmd_helm "helm.sh/helm/v3/cmd/helm"
 	helm_v3 "helm.sh/helm/v3/cmd/helm"
 	"helm.sh/helm/v3/pkg/action"
@@ -21,9 +28,15 @@ func NewHelmRegistryClientHandle(ctx context.Context, commonCmdData *CmdData) (*
 	}
 }
 
-func NewActionConfig(ctx context.Context, kubeInitializer helm.KubeInitializer, namespace string, commonCmdData *CmdData, registryClientHandle *helm_v3.RegistryClientHandle) (*action.Configuration, error) {
+// NewActionConfig returns the action config which writes the json status events into statusEventsOutput returned by GetStatusEventsOutput.
+func NewActionConfig(ctx context.Context, kubeInitializer helm.KubeInitializer, namespace string, commonCmdData *CmdData, registryClientHandle *helm_v3.RegistryClientHandle, statusEventsOutput io.Writer) (*action.Configuration, error) {
 	actionConfig := new(action.Configuration)
 
+	statusFormat, err := GetStatusFormat(commonCmdData)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := helm.InitActionConfig(ctx, kubeInitializer, namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
 		StatusProgressPeriod:      time.Duration(*commonCmdData.StatusProgressPeriodSeconds) * time.Second,
 		HooksStatusProgressPeriod: time.Duration(*commonCmdData.HooksStatusProgressPeriodSeconds) * time.Second,
@@ -33,9 +46,66 @@ func NewActionConfig(ctx context.Context, kubeInitializer helm.KubeInitializer,
 			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
 		},
 		ReleasesHistoryMax: *commonCmdData.ReleasesHistoryMax,
+		StatusFormat:       statusFormat,
+		StatusEventsOutput: statusEventsOutput,
 	}); err != nil {
 		return nil, err
 	}
 
 	return actionConfig, nil
 }
+
+// InspectHelmFlagsGiterminism checks that helm values passed with the command line options are allowed by giterminism.
+func InspectHelmFlagsGiterminism(giterminismManager giterminism_manager.Interface, cmdData *CmdData) error {
+	inspector := giterminismManager.Inspector()
+
+	for _, setOption := range []*[]string{cmdData.Set, cmdData.SetString, cmdData.SetFile} {
+		if setOption != nil && len(*setOption) != 0 {
+			if err := inspector.InspectHelmSetFlags(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for _, valuesOption := range []*[]string{cmdData.Values, cmdData.SecretValues} {
+		if valuesOption == nil {
+			continue
+		}
+
+		for _, valuesFile := range *valuesOption {
+			if err := inspector.InspectHelmValuesFile(getValuesFileRelPath(giterminismManager.ProjectDir(), valuesFile)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// InspectHelmEnvGiterminism checks that helm values passed with the $WERF_SET_*, $WERF_VALUES_* and $WERF_SECRET_VALUES_* environment variables are allowed by giterminism.
+func InspectHelmEnvGiterminism(giterminismManager giterminism_manager.Interface) error {
+	env := os.Environ()
+	sort.Strings(env)
+
+	for _, keyValue := range env {
+		envName := strings.SplitN(keyValue, "=", 2)[0]
+		for _, prefix := range []string{"WERF_SET_", "WERF_VALUES_", "WERF_SECRET_VALUES_"} {
+			if strings.HasPrefix(envName, prefix) {
+				if err := giterminismManager.Inspector().InspectHelmEnv(envName); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func getValuesFileRelPath(projectDir, valuesFile string) string {
+	if filepath.IsAbs(valuesFile) {
+		return util.GetRelativeToBaseFilepath(projectDir, valuesFile)
+	}
+	return filepath.Clean(valuesFile)
+}
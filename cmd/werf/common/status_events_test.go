@@ -0,0 +1,53 @@
+package common
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GetStatusEventsOutput", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-status-events-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	newCmdData := func(statusFormat, statusEventsFile string) *CmdData {
+		return &CmdData{StatusFormat: &statusFormat, StatusEventsFile: &statusEventsFile}
+	}
+
+	It("returns no output for the text status format", func() {
+		out, closeFunc, err := GetStatusEventsOutput(context.Background(), newCmdData("text", filepath.Join(tmpDir, "events.json")))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(out).Should(BeNil())
+		Ω(closeFunc()).Should(Succeed())
+		Ω(filepath.Join(tmpDir, "events.json")).ShouldNot(BeAnExistingFile())
+	})
+
+	It("truncates the existing status events file and closes it", func() {
+		path := filepath.Join(tmpDir, "events.json")
+		Ω(ioutil.WriteFile(path, []byte("the events of the previous run\n"), 0644)).Should(Succeed())
+
+		for _, line := range []string{"first\n", "second\n"} {
+			out, closeFunc, err := GetStatusEventsOutput(context.Background(), newCmdData("json", path))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			_, err = out.Write([]byte(line))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(closeFunc()).Should(Succeed())
+
+			Ω(ioutil.ReadFile(path)).Should(Equal([]byte(line)))
+		}
+	})
+})
@@ -54,6 +54,9 @@ type SynchronizationParams struct {
 	Address             string
 	SynchronizationType SynchronizationType
 	KubeParams          *storage.KubernetesSynchronizationParams
+
+	// DevStagesNamespace separates the stages storage cache of the dev stages storage
+	DevStagesNamespace bool
 }
 
 func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
@@ -108,6 +111,17 @@ func checkSynchronizationKubernetesParamsForWarnings(cmdData *CmdData) {
 }
 
 func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName string, stagesStorage storage.StagesStorage) (*SynchronizationParams, error) {
+	synchronization, err := getSynchronization(ctx, cmdData, projectName, stagesStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	synchronization.DevStagesNamespace = useDevStagesNamespace(cmdData)
+
+	return synchronization, nil
+}
+
+func getSynchronization(ctx context.Context, cmdData *CmdData, projectName string, stagesStorage storage.StagesStorage) (*SynchronizationParams, error) {
 	getKubeParamsFunc := func(address string) (*SynchronizationParams, error) {
 		res := &SynchronizationParams{}
 		res.SynchronizationType = KubernetesSynchronization
@@ -158,6 +172,19 @@ func GetSynchronization(ctx context.Context, cmdData *CmdData, projectName strin
 }
 
 func GetStagesStorageCache(synchronization *SynchronizationParams) (storage.StagesStorageCache, error) {
+	stagesStorageCache, err := getStagesStorageCache(synchronization)
+	if err != nil {
+		return nil, err
+	}
+
+	if synchronization.DevStagesNamespace {
+		return storage.NewDevStagesStorageCache(stagesStorageCache), nil
+	}
+
+	return stagesStorageCache, nil
+}
+
+func getStagesStorageCache(synchronization *SynchronizationParams) (storage.StagesStorageCache, error) {
 	switch synchronization.SynchronizationType {
 	case LocalSynchronization:
 		return storage.NewFileStagesStorageCache(werf.GetStagesStorageCacheDir()), nil
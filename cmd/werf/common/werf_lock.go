@@ -0,0 +1,54 @@
+package common
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	helm_v3 "helm.sh/helm/v3/cmd/helm"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/werf_lock"
+	"github.com/werf/werf/pkg/werf_lock/resolver"
+)
+
+func SetupStrictWerfLock(cmdData *CmdData, cmd *cobra.Command) {
+	cmdData.StrictWerfLock = new(bool)
+	cmd.Flags().BoolVarP(cmdData.StrictWerfLock, "strict-werf-lock", "", GetBoolEnvironmentDefaultFalse("WERF_STRICT_WERF_LOCK"), `Require werf.lock and fail if base images, remote git repositories or helm dependencies do not match it (default $WERF_STRICT_WERF_LOCK).
+Without the option the inputs are not resolved and the values locked in werf.lock are used for the build`)
+}
+
+// CheckWerfLock compares werf.lock with the actual external inputs of the project in the strict mode, helm dependencies are checked only if chartDir is not empty.
+// Without the strict mode there is nothing to check: the build uses the locked values.
+func CheckWerfLock(ctx context.Context, cmdData *CmdData, giterminismManager giterminism_manager.Interface, werfConfig *config.WerfConfig, chartDir string) error {
+	if cmdData.StrictWerfLock == nil || !*cmdData.StrictWerfLock {
+		return nil
+	}
+
+	locked, err := werf_lock.Load(ctx, giterminismManager)
+	if err != nil {
+		return err
+	} else if locked == nil {
+		return werf_lock.Check(nil, nil)
+	}
+
+	opts := resolver.Options{WerfConfig: werfConfig}
+	if chartDir != "" {
+		registryClientHandle, err := NewHelmRegistryClientHandle(ctx, cmdData)
+		if err != nil {
+			return err
+		}
+
+		opts.HelmChartDir = filepath.Join(giterminismManager.ProjectDir(), chartDir)
+		opts.HelmEnvSettings = helm_v3.Settings
+		opts.RegistryClientHandle = registryClientHandle
+	}
+
+	actual, err := resolver.Resolve(ctx, giterminismManager, opts)
+	if err != nil {
+		return err
+	}
+
+	return werf_lock.Check(locked, actual)
+}
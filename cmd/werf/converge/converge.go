@@ -1,8 +1,10 @@
 package converge
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -25,6 +27,7 @@ import (
 	"helm.sh/helm/v3/pkg/cli/values"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/werf/kubedog/pkg/kube"
 	"github.com/werf/logboek"
@@ -32,6 +35,7 @@ import (
 	"github.com/werf/werf/cmd/werf/common"
 	"github.com/werf/werf/pkg/build"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/deploy/helm"
 	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
 	"github.com/werf/werf/pkg/deploy/lock_manager"
 	"github.com/werf/werf/pkg/deploy/secrets_manager"
@@ -48,8 +52,10 @@ import (
 )
 
 var cmdData struct {
-	Timeout      int
-	AutoRollback bool
+	Timeout         int
+	AutoRollback    bool
+	Diff            bool
+	RequireApproval bool
 }
 
 var commonCmdData common.CmdData
@@ -96,6 +102,8 @@ werf converge --repo registry.mydomain.com/web --env production`,
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevStagesOptions(&commonCmdData, cmd)
+	common.SetupStrictWerfLock(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
@@ -125,6 +133,7 @@ werf converge --repo registry.mydomain.com/web --env production`,
 
 	common.SetupStatusProgressPeriod(&commonCmdData, cmd)
 	common.SetupHooksStatusProgressPeriod(&commonCmdData, cmd)
+	common.SetupStatusFormat(&commonCmdData, cmd)
 	common.SetupReleasesHistoryMax(&commonCmdData, cmd)
 
 	common.SetupRelease(&commonCmdData, cmd)
@@ -160,8 +169,9 @@ werf converge --repo registry.mydomain.com/web --env production`,
 	common.SetupDockerServerStoragePath(&commonCmdData, cmd)
 
 	cmd.Flags().IntVarP(&cmdData.Timeout, "timeout", "t", 0, "Resources tracking timeout in seconds")
-	cmd.Flags().BoolVarP(&cmdData.AutoRollback, "auto-rollback", "R", common.GetBoolEnvironmentDefaultFalse("WERF_AUTO_ROLLBACK"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_AUTO_ROLLBACK by default)")
-	cmd.Flags().BoolVarP(&cmdData.AutoRollback, "atomic", "", common.GetBoolEnvironmentDefaultFalse("WERF_ATOMIC"), "Enable auto rollback of the failed release to the previous deployed release version when current deploy process have failed ($WERF_ATOMIC by default)")
+	common.SetupAutoRollbackP(&cmdData.AutoRollback, cmd)
+	cmd.Flags().BoolVarP(&cmdData.Diff, "diff", "", common.GetBoolEnvironmentDefaultFalse("WERF_DIFF"), "Print the diff between live release resources and the rendered chart before applying ($WERF_DIFF by default)")
+	cmd.Flags().BoolVarP(&cmdData.RequireApproval, "require-approval", "", common.GetBoolEnvironmentDefaultFalse("WERF_REQUIRE_APPROVAL"), "Ask for confirmation before applying when release resources are to be deleted or replaced, fail when stdin is not a terminal ($WERF_REQUIRE_APPROVAL by default)")
 
 	return cmd
 }
@@ -219,6 +229,13 @@ func runMain(ctx context.Context) error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
+	if err := common.InspectHelmFlagsGiterminism(giterminismManager, &commonCmdData); err != nil {
+		return err
+	}
+	if err := common.InspectHelmEnvGiterminism(giterminismManager); err != nil {
+		return err
+	}
+
 	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
 		return fmt.Errorf("cannot initialize ssh agent: %s", err)
 	}
@@ -255,6 +272,10 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 		return fmt.Errorf("getting helm chart dir failed: %s", err)
 	}
 
+	if err := common.CheckWerfLock(ctx, &commonCmdData, giterminismManager, werfConfig, chartDir); err != nil {
+		return err
+	}
+
 	projectName := werfConfig.Meta.Project
 
 	projectTmpDir, err := tmp_manager.CreateProjectDir(ctx)
@@ -379,6 +400,12 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 		return fmt.Errorf("unable to create helm registry client: %s", err)
 	}
 
+	statusEventsOutput, closeStatusEventsOutput, err := common.GetStatusEventsOutput(ctx, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	defer closeStatusEventsOutput()
+
 	wc := chart_extender.NewWerfChart(ctx, giterminismManager, secretsManager, chartDir, cmd_helm.Settings, registryClientHandle, chart_extender.WerfChartOptions{
 		SecretValueFiles: common.GetSecretValues(&commonCmdData),
 		ExtraAnnotations: userExtraAnnotations,
@@ -420,39 +447,179 @@ func run(ctx context.Context, giterminismManager giterminism_manager.Interface)
 		return err
 	}
 
-	actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
+	actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle, statusEventsOutput)
 	if err != nil {
 		return err
 	}
 	maintenanceHelper := createMaintenanceHelper(ctx, actionConfig, kubeConfigOptions)
 
-	if err := migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, postRenderer, valueOpts, filepath.Join(giterminismManager.ProjectDir(), chartDir), registryClientHandle); err != nil {
+	if err := migrateHelm2ToHelm3(ctx, releaseName, namespace, maintenanceHelper, postRenderer, valueOpts, filepath.Join(giterminismManager.ProjectDir(), chartDir), registryClientHandle, statusEventsOutput); err != nil {
 		return err
 	}
 
-	actionConfig, err = common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
+	actionConfig, err = common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle, statusEventsOutput)
 	if err != nil {
 		return err
 	}
 
+	var rolloutOrchestrator *helm.RolloutOrchestrator
+	if waiter := helm.GetResourcesWaiter(actionConfig); waiter != nil {
+		rolloutOrchestrator = helm.NewRolloutOrchestrator(waiter)
+	}
+
+	// diff and rollouts use the manifests rendered by the upgrade itself before these manifests are applied
+	upgradePostRenderer := &releaseManifestsHookPostRenderer{
+		postRenderer: postRenderer,
+		hook: func(manifests string) error {
+			if cmdData.Diff || cmdData.RequireApproval {
+				if err := checkReleaseDiff(ctx, actionConfig, releaseName, manifests); err != nil {
+					return err
+				}
+			}
+
+			if rolloutOrchestrator == nil {
+				return nil
+			}
+
+			rolloutSpecs, err := helm.GetRolloutSpecs(manifests, namespace)
+			if err != nil {
+				return err
+			}
+
+			return rolloutOrchestrator.Run(ctx, rolloutSpecs, time.Duration(cmdData.Timeout)*time.Second)
+		},
+	}
+
 	helmUpgradeCmd, _ := cmd_helm.NewUpgradeCmd(actionConfig, logboek.OutStream(), cmd_helm.UpgradeCmdOptions{
-		PostRenderer:    postRenderer,
+		PostRenderer:    upgradePostRenderer,
 		ValueOpts:       valueOpts,
 		CreateNamespace: common.NewBool(true),
 		Install:         common.NewBool(true),
 		Wait:            common.NewBool(true),
-		Atomic:          common.NewBool(cmdData.AutoRollback),
 		Timeout:         common.NewDuration(time.Duration(cmdData.Timeout) * time.Second),
 	})
 
+	if kubeClient := helm.GetOrderedKubeClient(actionConfig); kubeClient != nil {
+		kubeClient.GroupWaitTimeout = time.Duration(cmdData.Timeout) * time.Second
+	}
+
 	return command_helpers.LockReleaseWrapper(ctx, releaseName, lockManager, func() error {
-		if err := helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, filepath.Join(giterminismManager.ProjectDir(), chartDir)}); err != nil {
-			return fmt.Errorf("helm upgrade have failed: %s", err)
+		upgradeFunc := func() error {
+			if err := helmUpgradeCmd.RunE(helmUpgradeCmd, []string{releaseName, filepath.Join(giterminismManager.ProjectDir(), chartDir)}); err != nil {
+				return fmt.Errorf("helm upgrade have failed: %s", err)
+			}
+
+			if rolloutOrchestrator != nil {
+				return rolloutOrchestrator.Promote(ctx)
+			}
+			return nil
 		}
-		return nil
+
+		var deployErr error
+		if cmdData.AutoRollback || rolloutOrchestrator != nil {
+			deployErr = helm.RunWithAutoRollback(ctx, actionConfig, releaseName, helm.AutoRollbackOptions{
+				Timeout: time.Duration(cmdData.Timeout) * time.Second,
+				IsRollbackRequired: func() bool {
+					// the release with verified rollouts is rolled back when the upgrade or the promoted Deployments check failed
+					return cmdData.AutoRollback || rolloutOrchestrator.HasVerifiedRollouts()
+				},
+			}, upgradeFunc)
+		} else {
+			deployErr = upgradeFunc()
+		}
+
+		if rolloutOrchestrator != nil {
+			if err := rolloutOrchestrator.Complete(ctx, deployErr == nil); err != nil {
+				if deployErr != nil {
+					logboek.Context(ctx).Warn().LogF("WARNING: %s\n", err)
+				} else {
+					return err
+				}
+			}
+		}
+
+		return deployErr
 	})
 }
 
+// releaseManifestsHookPostRenderer passes the release manifests to the hook after the post rendering,
+// the hook error prevents the release manifests from being applied.
+type releaseManifestsHookPostRenderer struct {
+	postRenderer postrender.PostRenderer
+	hook         func(manifests string) error
+}
+
+func (r *releaseManifestsHookPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	manifests := renderedManifests
+	if r.postRenderer != nil {
+		var err error
+		if manifests, err = r.postRenderer.Run(renderedManifests); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.hook(manifests.String()); err != nil {
+		return nil, err
+	}
+
+	return manifests, nil
+}
+
+func checkReleaseDiff(ctx context.Context, actionConfig *action.Configuration, releaseName, manifests string) error {
+	changes, err := helm.GetReleaseDiff(actionConfig, releaseName, manifests)
+	if err != nil {
+		return fmt.Errorf("unable to get release %q diff: %s", releaseName, err)
+	}
+
+	var destructiveChanges []*helm.ResourceChange
+	for _, change := range changes {
+		if change.IsDestructive() {
+			destructiveChanges = append(destructiveChanges, change)
+		}
+	}
+
+	if cmdData.Diff || len(destructiveChanges) > 0 {
+		helm.LogReleaseDiff(ctx, changes)
+	}
+
+	if !cmdData.RequireApproval || len(destructiveChanges) == 0 {
+		return nil
+	}
+
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("approval required: %d release resources are to be deleted or replaced, but stdin is not a terminal", len(destructiveChanges))
+	}
+
+	approved, err := askForApproval(ctx, fmt.Sprintf("%d release resources are to be deleted or replaced. Continue? (y/N)", len(destructiveChanges)))
+	if err != nil {
+		return fmt.Errorf("unable to read approval: %s", err)
+	} else if !approved {
+		return fmt.Errorf("release %q changes have not been approved", releaseName)
+	}
+
+	return nil
+}
+
+func askForApproval(ctx context.Context, question string) (bool, error) {
+	logboek.Context(ctx).Default().LogFHighlight("%s\n", question)
+
+	fd := int(os.Stdin.Fd())
+	if oldState, err := terminal.MakeRaw(fd); err != nil {
+		return false, err
+	} else {
+		defer terminal.Restore(fd, oldState)
+	}
+
+	var buf [1]byte
+	if n, err := os.Stdin.Read(buf[:]); err != nil && err != io.EOF {
+		return false, err
+	} else if n == 0 {
+		return false, nil
+	}
+
+	return buf[0] == 'y' || buf[0] == 'Y', nil
+}
+
 func createMaintenanceHelper(ctx context.Context, actionConfig *action.Configuration, kubeConfigOptions kube.KubeConfigOptions) *maintenance_helper.MaintenanceHelper {
 	maintenanceOpts := maintenance_helper.MaintenanceHelperOptions{
 		KubeConfigOptions: kubeConfigOptions,
@@ -482,7 +649,7 @@ func createMaintenanceHelper(ctx context.Context, actionConfig *action.Configura
 	return maintenance_helper.NewMaintenanceHelper(actionConfig, maintenanceOpts)
 }
 
-func migrateHelm2ToHelm3(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper, postRenderer postrender.PostRenderer, valueOpts *values.Options, fullChartDir string, registryClientHandle *helm_v3.RegistryClientHandle) error {
+func migrateHelm2ToHelm3(ctx context.Context, releaseName, namespace string, maintenanceHelper *maintenance_helper.MaintenanceHelper, postRenderer postrender.PostRenderer, valueOpts *values.Options, fullChartDir string, registryClientHandle *helm_v3.RegistryClientHandle, statusEventsOutput io.Writer) error {
 	if helm2Exists, err := checkHelm2AvailableAndReleaseExists(ctx, releaseName, namespace, maintenanceHelper); err != nil {
 		return fmt.Errorf("error checking availability of helm 2 and existance of helm 2 release %q: %s", releaseName, err)
 	} else if !helm2Exists {
@@ -509,7 +676,7 @@ func migrateHelm2ToHelm3(ctx context.Context, releaseName, namespace string, mai
 
 	logboek.Context(ctx).Default().LogOptionalLn()
 	if err := logboek.Context(ctx).LogProcess("Rendering helm 3 templates for the current project state").DoError(func() error {
-		actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle)
+		actionConfig, err := common.NewActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, &commonCmdData, registryClientHandle, statusEventsOutput)
 		if err != nil {
 			return err
 		}
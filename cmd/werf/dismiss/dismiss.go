@@ -99,6 +99,7 @@ Read more info about Helm Release name, Kubernetes Namespace and how to change i
 
 	common.SetupStatusProgressPeriod(&commonCmdData, cmd)
 	common.SetupHooksStatusProgressPeriod(&commonCmdData, cmd)
+	common.SetupStatusFormat(&commonCmdData, cmd)
 	common.SetupReleasesHistoryMax(&commonCmdData, cmd)
 
 	common.SetupDockerConfig(&commonCmdData, cmd, "")
@@ -213,6 +214,17 @@ func runDismiss(ctx context.Context) error {
 		return err
 	}
 
+	statusFormat, err := common.GetStatusFormat(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	statusEventsOutput, closeStatusEventsOutput, err := common.GetStatusEventsOutput(ctx, &commonCmdData)
+	if err != nil {
+		return err
+	}
+	defer closeStatusEventsOutput()
+
 	actionConfig := new(action.Configuration)
 	if err := helm.InitActionConfig(ctx, common.GetOndemandKubeInitializer(), namespace, cmd_helm.Settings, registryClientHandle, actionConfig, helm.InitActionConfigOptions{
 		StatusProgressPeriod:      time.Duration(*commonCmdData.StatusProgressPeriodSeconds) * time.Second,
@@ -223,6 +235,8 @@ func runDismiss(ctx context.Context) error {
 			ConfigDataBase64: *commonCmdData.KubeConfigBase64,
 		},
 		ReleasesHistoryMax: *commonCmdData.ReleasesHistoryMax,
+		StatusFormat:       statusFormat,
+		StatusEventsOutput: statusEventsOutput,
 	}); err != nil {
 		return err
 	}
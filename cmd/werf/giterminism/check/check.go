@@ -0,0 +1,362 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/dockerignore"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/spf13/cobra"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "check",
+		DisableFlagsInUseLine: true,
+		Short:                 "Check the project for giterminism violations without building images",
+		Long: common.GetLongCommandDescription(`Check the project for giterminism violations without building images.
+
+The command renders werf.yaml, reads Dockerfiles and .dockerignore files, checks build contexts of Dockerfile images, remote git repository references of Dockerfile build contexts and ADD instructions, local git mappings of stapel images, loads the helm chart and checks passed helm values options and environment variables. All found violations are reported at once along with the werf-giterminism.yaml snippets that allow them. The command exits with a non-zero code if any violation is found.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runCheck()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupSet(&commonCmdData, cmd)
+	common.SetupSetString(&commonCmdData, cmd)
+	common.SetupSetFile(&commonCmdData, cmd)
+	common.SetupValues(&commonCmdData, cmd)
+	common.SetupSecretValues(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func runCheck() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManagerCollectingViolations(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+
+	if err := common.InspectHelmFlagsGiterminism(giterminismManager, &commonCmdData); err != nil {
+		return err
+	}
+	if err := common.InspectHelmEnvGiterminism(giterminismManager); err != nil {
+		return err
+	}
+
+	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, false))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	remoteGitRepos := map[string]*git_repo.Remote{}
+	for _, imageConfig := range werfConfig.ImagesFromDockerfile {
+		if err := checkImageFromDockerfile(ctx, giterminismManager, remoteGitRepos, imageConfig); err != nil {
+			return fmt.Errorf("image %q check failed: %s", imageConfig.Name, err)
+		}
+	}
+
+	var stapelImages []*config.StapelImageBase
+	for _, imageConfig := range werfConfig.StapelImages {
+		stapelImages = append(stapelImages, imageConfig.StapelImageBase)
+	}
+	for _, artifactConfig := range werfConfig.Artifacts {
+		stapelImages = append(stapelImages, artifactConfig.StapelImageBase)
+	}
+
+	for _, imageConfig := range stapelImages {
+		if err := checkStapelImage(ctx, giterminismManager, imageConfig); err != nil {
+			return fmt.Errorf("image %q check failed: %s", imageConfig.Name, err)
+		}
+	}
+
+	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	if err != nil {
+		return fmt.Errorf("getting helm chart dir failed: %s", err)
+	}
+
+	if _, err := giterminismManager.FileReader().LoadChartDir(ctx, filepath.Join(giterminismManager.ProjectDir(), chartDir)); err != nil {
+		return err
+	}
+
+	return printViolations(ctx, giterminismManager)
+}
+
+func checkImageFromDockerfile(ctx context.Context, giterminismManager giterminism_manager.Interface, remoteGitRepos map[string]*git_repo.Remote, imageConfig *config.ImageFromDockerfile) error {
+	if imageConfig.RemoteContext != nil {
+		return checkImageFromDockerfileRemoteContext(ctx, giterminismManager, remoteGitRepos, imageConfig)
+	}
+
+	dockerfileData, err := giterminismManager.FileReader().ReadDockerfile(ctx, filepath.Join(imageConfig.Context, imageConfig.Dockerfile))
+	if err != nil {
+		return err
+	}
+
+	if err := checkDockerfileAddGitSources(ctx, giterminismManager, remoteGitRepos, imageConfig, dockerfileData); err != nil {
+		return err
+	}
+
+	var dockerignorePatterns []string
+	for _, relContextDockerignorePath := range []string{
+		imageConfig.Dockerfile + ".dockerignore",
+		".dockerignore",
+	} {
+		relDockerignorePath := filepath.Join(imageConfig.Context, relContextDockerignorePath)
+		if exist, err := giterminismManager.FileReader().IsDockerignoreExistAnywhere(ctx, relDockerignorePath); err != nil {
+			return err
+		} else if exist {
+			dockerignoreData, err := giterminismManager.FileReader().ReadDockerignore(ctx, relDockerignorePath)
+			if err != nil {
+				return err
+			}
+
+			dockerignorePatterns, err = dockerignore.ReadAll(bytes.NewReader(dockerignoreData))
+			if err != nil {
+				return fmt.Errorf("unable to read %q file: %s", relContextDockerignorePath, err)
+			}
+
+			break
+		}
+	}
+
+	contextRelativeToGitWorkTree := filepath.Join(giterminismManager.RelativeToGitProjectDir(), imageConfig.Context)
+
+	// The Dockerfile is checked above, contextAddFiles are not read from the git repository.
+	excludeGlobs := []string{filepath.Join(contextRelativeToGitWorkTree, imageConfig.Dockerfile)}
+	for _, contextAddFile := range imageConfig.ContextAddFiles {
+		excludeGlobs = append(excludeGlobs, filepath.Join(contextRelativeToGitWorkTree, contextAddFile))
+	}
+
+	return giterminismManager.Inspector().InspectBuildContextFiles(ctx, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+		BasePath:             contextRelativeToGitWorkTree,
+		DockerignorePatterns: dockerignorePatterns,
+		ExcludeGlobs:         excludeGlobs,
+	}))
+}
+
+// checkImageFromDockerfileRemoteContext checks the reference of the remote git context and the ADD instructions of the Dockerfile from the context.
+// The context files are not a part of the project, the Dockerfile is read from the current commit of the reference.
+func checkImageFromDockerfileRemoteContext(ctx context.Context, giterminismManager giterminism_manager.Interface, remoteGitRepos map[string]*git_repo.Remote, imageConfig *config.ImageFromDockerfile) error {
+	remoteContext := imageConfig.RemoteContext
+	if err := checkRemoteGitRef(ctx, giterminismManager, remoteGitRepos, remoteContext.Url, remoteContext.Ref, giterminismManager.Inspector().InspectConfigDockerfileRemoteContextBranch); err != nil {
+		return fmt.Errorf("unable to check remote context %s: %s", remoteContext.Url, err)
+	}
+
+	if giterminismManager.LooseGiterminism() {
+		return nil
+	}
+
+	remoteGitRepo, err := getRemoteGitRepo(ctx, remoteGitRepos, remoteContext.Url)
+	if err != nil {
+		return err
+	}
+
+	var commit string
+	if remoteContext.Ref == "" {
+		commit, err = remoteGitRepo.HeadCommit(ctx)
+	} else {
+		commit, _, err = remoteGitRepo.ResolveReference(ctx, remoteContext.Ref)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to resolve remote context %s commit: %s", remoteContext.Url, err)
+	}
+
+	relDockerfilePath := filepath.Join(imageConfig.Context, imageConfig.Dockerfile)
+	dockerfileData, err := remoteGitRepo.ReadCommitFile(ctx, commit, filepath.ToSlash(relDockerfilePath))
+	if err != nil {
+		return fmt.Errorf("unable to read %s from the remote git repository %s commit %s: %s", relDockerfilePath, remoteContext.Url, commit, err)
+	}
+
+	return checkDockerfileAddGitSources(ctx, giterminismManager, remoteGitRepos, imageConfig, dockerfileData)
+}
+
+// checkDockerfileAddGitSources checks the references of the remote git repositories added by the Dockerfile ADD instructions.
+func checkDockerfileAddGitSources(ctx context.Context, giterminismManager giterminism_manager.Interface, remoteGitRepos map[string]*git_repo.Remote, imageConfig *config.ImageFromDockerfile, dockerfileData []byte) error {
+	p, err := parser.Parse(bytes.NewReader(dockerfileData))
+	if err != nil {
+		return fmt.Errorf("unable to parse dockerfile: %s", err)
+	}
+
+	dockerStages, dockerMetaArgs, err := instructions.Parse(p.AST)
+	if err != nil {
+		return fmt.Errorf("unable to parse dockerfile instructions: %s", err)
+	}
+
+	ds, err := stage.NewDockerStages(dockerStages, util.MapStringInterfaceToMapStringString(imageConfig.Args), dockerMetaArgs, len(dockerStages)-1)
+	if err != nil {
+		return err
+	}
+
+	sources, err := ds.AddGitSources()
+	if err != nil {
+		return err
+	}
+
+	for _, source := range sources {
+		url := source.Url
+		if err := checkRemoteGitRef(ctx, giterminismManager, remoteGitRepos, url, source.Ref, func() error {
+			return giterminismManager.Inspector().InspectConfigDockerfileAddGitBranch(url)
+		}); err != nil {
+			return fmt.Errorf("unable to check ADD source %s: %s", url, err)
+		}
+	}
+
+	return nil
+}
+
+// checkRemoteGitRef runs inspectBranchFunc if the reference is a branch of the remote git repository (the default branch if empty).
+// Other references are resolved the same way as during the build.
+func checkRemoteGitRef(ctx context.Context, giterminismManager giterminism_manager.Interface, remoteGitRepos map[string]*git_repo.Remote, url, ref string, inspectBranchFunc func() error) error {
+	if giterminismManager.LooseGiterminism() {
+		return nil
+	}
+
+	if ref == "" {
+		return inspectBranchFunc()
+	}
+
+	remoteGitRepo, err := getRemoteGitRepo(ctx, remoteGitRepos, url)
+	if err != nil {
+		return err
+	}
+
+	_, refType, err := remoteGitRepo.ResolveReference(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	if refType == git_repo.BranchReference {
+		return inspectBranchFunc()
+	}
+
+	return nil
+}
+
+// getRemoteGitRepo returns the remote git repository by url, the repository is cloned or fetched once.
+func getRemoteGitRepo(ctx context.Context, remoteGitRepos map[string]*git_repo.Remote, url string) (*git_repo.Remote, error) {
+	if remoteGitRepo, ok := remoteGitRepos[url]; ok {
+		return remoteGitRepo, nil
+	}
+
+	remoteGitRepo, err := git_repo.OpenRemoteRepo(remoteGitRepoName(url), url, git_repo.RemoteOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open remote git repo by url %s: %s", url, err)
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Refreshing %s repository", url).DoError(func() error {
+		return remoteGitRepo.CloneAndFetch(ctx)
+	}); err != nil {
+		return nil, err
+	}
+
+	remoteGitRepos[url] = remoteGitRepo
+
+	return remoteGitRepo, nil
+}
+
+func remoteGitRepoName(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return path.Base(strings.Replace(url, ":", "/", -1))
+}
+
+func checkStapelImage(ctx context.Context, giterminismManager giterminism_manager.Interface, imageConfig *config.StapelImageBase) error {
+	if imageConfig.Git == nil {
+		return nil
+	}
+
+	for _, localGitMappingConfig := range imageConfig.Git.Local {
+		if err := giterminismManager.Inspector().InspectBuildContextFiles(ctx, path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+			BasePath:     localGitMappingConfig.GitMappingAdd(),
+			IncludeGlobs: localGitMappingConfig.IncludePaths,
+			ExcludeGlobs: localGitMappingConfig.ExcludePaths,
+		})); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func printViolations(ctx context.Context, giterminismManager giterminism_manager.Interface) error {
+	violations := giterminismManager.Violations()
+	if len(violations) == 0 {
+		logboek.Context(ctx).Default().LogLn("No giterminism violations found")
+		return nil
+	}
+
+	for ind, violation := range violations {
+		logboek.Context(ctx).Default().LogF("%d. %s\n", ind+1, violation.Message)
+
+		if violation.Snippet != "" {
+			logboek.Context(ctx).Default().LogLn()
+			logboek.Context(ctx).Default().LogLn("   To allow add to werf-giterminism.yaml:")
+			for _, line := range strings.Split(strings.TrimSuffix(violation.Snippet, "\n"), "\n") {
+				logboek.Context(ctx).Default().LogF("     %s\n", line)
+			}
+		}
+
+		logboek.Context(ctx).Default().LogLn()
+	}
+
+	return fmt.Errorf("%d giterminism violation(s) found, read more about giterminism and werf-giterminism.yaml here: https://werf.io/documentation/advanced/giterminism.html", len(violations))
+}
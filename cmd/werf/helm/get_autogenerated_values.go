@@ -55,6 +55,7 @@ These values includes project name, docker images ids and other`),
 	common.SetupEnvironment(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupGiterminismOptions(&getAutogeneratedValuedCmdData, cmd)
+	common.SetupDevStagesOptions(&getAutogeneratedValuedCmdData, cmd)
 
 	common.SetupTmpDir(&getAutogeneratedValuedCmdData, cmd)
 	common.SetupHomeDir(&getAutogeneratedValuedCmdData, cmd)
@@ -33,6 +33,7 @@ func NewCmd() *cobra.Command {
 
 The data include:
 * Lost docker containers and images from interrupted builds.
+* Least recently used cache mount volumes.
 * Old service tmp dirs, which werf creates during every build, converge and other commands.
 * Local cache:
   * Remote git clones cache.
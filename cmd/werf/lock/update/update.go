@@ -0,0 +1,137 @@
+package update
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	helm_v3 "helm.sh/helm/v3/cmd/helm"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/cmd/werf/common"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/ssh_agent"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+	"github.com/werf/werf/pkg/werf_lock"
+	"github.com/werf/werf/pkg/werf_lock/resolver"
+)
+
+var commonCmdData common.CmdData
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "update",
+		DisableFlagsInUseLine: true,
+		Short:                 "Resolve external inputs of the project and write them into werf.lock",
+		Long: common.GetLongCommandDescription(`Resolve external inputs of the project and write them into werf.lock in the project directory.
+
+The lock file pins digests of base images referenced by tag (stapel from and Dockerfile FROM instructions), commits of remote git repositories referenced by branch or tag (git mappings, ansible requirements and Dockerfile remote contexts) and digests of helm chart dependencies. The file should be committed into the project git repository. Images are built with the locked base images and commits. The werf build, werf converge and werf bundle publish commands check external inputs against werf.lock and fail on any difference with the --strict-werf-lock option.`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := common.ProcessLogOptions(&commonCmdData); err != nil {
+				common.PrintHelp(cmd)
+				return err
+			}
+
+			return runUpdate()
+		},
+	}
+
+	common.SetupDir(&commonCmdData, cmd)
+	common.SetupGitWorkTree(&commonCmdData, cmd)
+	common.SetupConfigTemplatesDir(&commonCmdData, cmd)
+	common.SetupConfigPath(&commonCmdData, cmd)
+	common.SetupEnvironment(&commonCmdData, cmd)
+
+	common.SetupGiterminismOptions(&commonCmdData, cmd)
+
+	common.SetupTmpDir(&commonCmdData, cmd)
+	common.SetupHomeDir(&commonCmdData, cmd)
+	common.SetupSSHKey(&commonCmdData, cmd)
+
+	common.SetupInsecureRegistry(&commonCmdData, cmd)
+	common.SetupSkipTlsVerifyRegistry(&commonCmdData, cmd)
+
+	common.SetupLogOptions(&commonCmdData, cmd)
+	common.SetupLogProjectDir(&commonCmdData, cmd)
+
+	return cmd
+}
+
+func runUpdate() error {
+	ctx := common.BackgroundContext()
+
+	if err := werf.Init(*commonCmdData.TmpDir, *commonCmdData.HomeDir); err != nil {
+		return fmt.Errorf("initialization error: %s", err)
+	}
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting host git data manager: %s", err)
+	}
+
+	if err := git_repo.Init(gitDataManager); err != nil {
+		return err
+	}
+
+	if err := true_git.Init(true_git.Options{LiveGitOutput: *commonCmdData.LogVerbose || *commonCmdData.LogDebug}); err != nil {
+		return err
+	}
+
+	if err := common.DockerRegistryInit(ctx, &commonCmdData); err != nil {
+		return err
+	}
+
+	giterminismManager, err := common.GetGiterminismManager(&commonCmdData)
+	if err != nil {
+		return err
+	}
+
+	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
+
+	if err := ssh_agent.Init(ctx, common.GetSSHKey(&commonCmdData)); err != nil {
+		return fmt.Errorf("cannot initialize ssh agent: %s", err)
+	}
+	defer func() {
+		err := ssh_agent.Terminate()
+		if err != nil {
+			logboek.Warn().LogF("WARNING: ssh agent termination failed: %s\n", err)
+		}
+	}()
+
+	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
+	if err != nil {
+		return fmt.Errorf("unable to load werf config: %s", err)
+	}
+
+	chartDir, err := common.GetHelmChartDir(werfConfigPath, werfConfig, giterminismManager)
+	if err != nil {
+		return fmt.Errorf("getting helm chart dir failed: %s", err)
+	}
+
+	registryClientHandle, err := common.NewHelmRegistryClientHandle(ctx, &commonCmdData)
+	if err != nil {
+		return fmt.Errorf("unable to create helm registry client: %s", err)
+	}
+
+	lock, err := resolver.Resolve(ctx, giterminismManager, resolver.Options{
+		WerfConfig:           werfConfig,
+		HelmChartDir:         filepath.Join(giterminismManager.ProjectDir(), chartDir),
+		HelmEnvSettings:      helm_v3.Settings,
+		RegistryClientHandle: registryClientHandle,
+	})
+	if err != nil {
+		return err
+	}
+
+	lockPath := filepath.Join(giterminismManager.ProjectDir(), werf_lock.FileName)
+	if err := lock.Save(lockPath); err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogFDetails("%s updated: %d image(s), %d git repo(s), %d helm dependency(ies)\n", lockPath, len(lock.Images), len(lock.GitRepos), len(lock.HelmDependencies))
+
+	return nil
+}
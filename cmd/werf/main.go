@@ -32,12 +32,18 @@ import (
 	host_purge "github.com/werf/werf/cmd/werf/host/purge"
 
 	bundle_apply "github.com/werf/werf/cmd/werf/bundle/apply"
+	bundle_copy "github.com/werf/werf/cmd/werf/bundle/copy"
 	bundle_download "github.com/werf/werf/cmd/werf/bundle/download"
 	bundle_export "github.com/werf/werf/cmd/werf/bundle/export"
+	bundle_history "github.com/werf/werf/cmd/werf/bundle/history"
+	bundle_import "github.com/werf/werf/cmd/werf/bundle/import_archive"
+	bundle_ls "github.com/werf/werf/cmd/werf/bundle/ls"
 	bundle_publish "github.com/werf/werf/cmd/werf/bundle/publish"
 
 	config_list "github.com/werf/werf/cmd/werf/config/list"
 	config_render "github.com/werf/werf/cmd/werf/config/render"
+	giterminism_check "github.com/werf/werf/cmd/werf/giterminism/check"
+	lock_update "github.com/werf/werf/cmd/werf/lock/update"
 	"github.com/werf/werf/cmd/werf/render"
 
 	"github.com/werf/werf/cmd/werf/completion"
@@ -115,6 +121,8 @@ Find more information at https://werf.io`),
 			Message: "Low-level management commands",
 			Commands: []*cobra.Command{
 				configCmd(),
+				giterminismCmd(),
+				lockCmd(),
 				managedImagesCmd(),
 				hostCmd(),
 				helm.NewCmd(),
@@ -163,6 +171,10 @@ func bundleCmd() *cobra.Command {
 		bundle_apply.NewCmd(),
 		bundle_export.NewCmd(),
 		bundle_download.NewCmd(),
+		bundle_copy.NewCmd(),
+		bundle_import.NewCmd(),
+		bundle_ls.NewCmd(),
+		bundle_history.NewCmd(),
 	)
 
 	return cmd
@@ -181,6 +193,30 @@ func configCmd() *cobra.Command {
 	return cmd
 }
 
+func giterminismCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "giterminism",
+		Short: "Work with giterminism restrictions",
+	}
+	cmd.AddCommand(
+		giterminism_check.NewCmd(),
+	)
+
+	return cmd
+}
+
+func lockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Work with werf.lock which pins external inputs of the project",
+	}
+	cmd.AddCommand(
+		lock_update.NewCmd(),
+	)
+
+	return cmd
+}
+
 func managedImagesCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "managed-images",
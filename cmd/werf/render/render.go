@@ -75,6 +75,7 @@ func NewCmd() *cobra.Command {
 	common.SetupEnvironment(&commonCmdData, cmd)
 
 	common.SetupGiterminismOptions(&commonCmdData, cmd)
+	common.SetupDevStagesOptions(&commonCmdData, cmd)
 
 	common.SetupTmpDir(&commonCmdData, cmd)
 	common.SetupHomeDir(&commonCmdData, cmd)
@@ -176,6 +177,13 @@ func runRender() error {
 
 	common.ProcessLogProjectDir(&commonCmdData, giterminismManager.ProjectDir())
 
+	if err := common.InspectHelmFlagsGiterminism(giterminismManager, &commonCmdData); err != nil {
+		return err
+	}
+	if err := common.InspectHelmEnvGiterminism(giterminismManager); err != nil {
+		return err
+	}
+
 	werfConfigPath, werfConfig, err := common.GetRequiredWerfConfig(ctx, &commonCmdData, giterminismManager, common.GetWerfConfigOptions(&commonCmdData, true))
 	if err != nil {
 		return fmt.Errorf("unable to load werf config: %s", err)
@@ -0,0 +1,73 @@
+package giterminism_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+)
+
+var _ = Describe("giterminism check", func() {
+	BeforeEach(CommonBeforeEach)
+
+	It("should succeed if there are no violations", func() {
+		output, err := utils.RunCommand(SuiteData.TestDirPath, SuiteData.WerfBinPath, "giterminism", "check")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(output)).Should(ContainSubstring("No giterminism violations found"))
+	})
+
+	It("should report all violations at once with werf-giterminism.yaml snippets", func() {
+		fileCreateOrAppend("werf.yaml", `
+image: test
+from: alpine
+fromLatest: true
+mount:
+- from: build_dir
+  to: /cache
+`)
+		gitAddAndCommit("werf.yaml")
+
+		fileCreateOrAppend(".helm/values.yaml", "key: value\n")
+
+		output, err := utils.RunCommand(SuiteData.TestDirPath, SuiteData.WerfBinPath, "giterminism", "check", "--set", "key=value")
+		Ω(err).Should(HaveOccurred())
+
+		for _, substring := range []string{
+			"fromLatest directive not allowed by giterminism",
+			"allowFromLatest: true",
+			`"mount { from: build_dir, ... }" not allowed by giterminism`,
+			"allowBuildDir: true",
+			`the untracked file ".helm/values.yaml" must be committed`,
+			"allowUncommittedFiles:",
+			"--set, --set-string and --set-file options not allowed by giterminism",
+			"allowSetFlags: true",
+			"4 giterminism violation(s) found",
+		} {
+			Ω(string(output)).Should(ContainSubstring(substring))
+		}
+	})
+
+	It("should report Dockerfile ADD of the remote git repository default branch", func() {
+		fileCreateOrAppend("werf.yaml", `
+image: test
+dockerfile: Dockerfile
+`)
+		fileCreateOrAppend("Dockerfile", `FROM alpine
+ARG REPO=https://github.com/werf/werf.git
+ADD ${REPO} /src
+`)
+		gitAddAndCommit("werf.yaml")
+		gitAddAndCommit("Dockerfile")
+
+		output, err := utils.RunCommand(SuiteData.TestDirPath, SuiteData.WerfBinPath, "giterminism", "check")
+		Ω(err).Should(HaveOccurred())
+
+		for _, substring := range []string{
+			"Dockerfile ADD of the remote git repository https://github.com/werf/werf.git branch not allowed by giterminism",
+			"allowRemoteContextBranch: true",
+			"1 giterminism violation(s) found",
+		} {
+			Ω(string(output)).Should(ContainSubstring(substring))
+		}
+	})
+})
@@ -0,0 +1,73 @@
+package giterminism_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/integration/pkg/utils"
+)
+
+var _ = Describe("helm values", func() {
+	BeforeEach(CommonBeforeEach)
+
+	type entry struct {
+		giterminismHelmConfig string
+		args                  []string
+		extraEnv              []string
+		expectedErrSubstring  string
+	}
+
+	DescribeTable("helm.allowSetFlags, helm.allowValuesFiles and helm.allowEnvVariables",
+		func(e entry) {
+			if e.giterminismHelmConfig != "" {
+				fileCreateOrAppend("werf-giterminism.yaml", fmt.Sprintf("\nhelm:\n%s\n", e.giterminismHelmConfig))
+				gitAddAndCommit("werf-giterminism.yaml")
+			}
+
+			fileCreateOrAppend("values_custom.yaml", "key: value\n")
+			gitAddAndCommit("values_custom.yaml")
+
+			output, err := utils.RunCommandWithOptions(
+				SuiteData.TestDirPath,
+				SuiteData.WerfBinPath,
+				append([]string{"render"}, e.args...),
+				utils.RunCommandOptions{ExtraEnv: e.extraEnv},
+			)
+
+			if e.expectedErrSubstring != "" {
+				Ω(err).Should(HaveOccurred())
+				Ω(string(output)).Should(ContainSubstring(e.expectedErrSubstring))
+			} else {
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+		},
+		Entry("no values options", entry{}),
+		Entry("the --set option not allowed", entry{
+			args:                 []string{"--set", "key=value"},
+			expectedErrSubstring: `--set, --set-string and --set-file options not allowed by giterminism`,
+		}),
+		Entry("helm.allowSetFlags allows the --set option", entry{
+			giterminismHelmConfig: "  allowSetFlags: true",
+			args:                  []string{"--set", "key=value"},
+		}),
+		Entry("the values file values_custom.yaml not allowed", entry{
+			args:                 []string{"--values", "values_custom.yaml"},
+			expectedErrSubstring: `values file "values_custom.yaml" not allowed by giterminism`,
+		}),
+		Entry("helm.allowValuesFiles (values_*.yaml) covers the values file values_custom.yaml", entry{
+			giterminismHelmConfig: `  allowValuesFiles: ["values_*.yaml"]`,
+			args:                  []string{"--values", "values_custom.yaml"},
+		}),
+		Entry("the env name WERF_SET_KEY not allowed", entry{
+			extraEnv:             []string{"WERF_SET_KEY=key=value"},
+			expectedErrSubstring: `env name "WERF_SET_KEY" not allowed by giterminism`,
+		}),
+		Entry("helm.allowEnvVariables (/WERF_SET_.*/) covers the env name WERF_SET_KEY", entry{
+			giterminismHelmConfig: `  allowEnvVariables: ["/WERF_SET_.*/"]`,
+			extraEnv:              []string{"WERF_SET_KEY=key=value"},
+		}),
+	)
+})
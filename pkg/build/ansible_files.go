@@ -0,0 +1,377 @@
+package build
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build/builder"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/path_matcher"
+)
+
+// resolveAnsibleFiles reads the files of ansible.roles, ansible.requirements and the task files of import_tasks and include_tasks.
+// Project files are read through the giterminism manager, roles and collections from git repositories are read from the resolved commits.
+func resolveAnsibleFiles(ctx context.Context, ansibleConfig *config.Ansible, c *Conveyor) (*builder.AnsibleFiles, error) {
+	if len(ansibleConfig.Roles) == 0 && ansibleConfig.Requirements == "" && len(ansibleConfig.TaskFiles) == 0 {
+		return nil, nil
+	}
+
+	files := &builder.AnsibleFiles{
+		Roles:       map[string]map[string][]byte{},
+		Collections: map[string]map[string][]byte{},
+		TaskFiles:   map[string][]byte{},
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Preparing ansible files").DoError(func() error {
+		for _, roleDir := range ansibleConfig.Roles {
+			roleFiles, err := readAnsibleProjectDirFiles(ctx, roleDir, c)
+			if err != nil {
+				return err
+			}
+
+			files.Roles[path.Base(path.Clean(roleDir))] = roleFiles
+		}
+
+		for _, taskFile := range ansibleConfig.TaskFiles {
+			data, err := c.giterminismManager.FileReader().ReadAnsibleFile(ctx, taskFile)
+			if err != nil {
+				return err
+			}
+
+			files.TaskFiles[path.Clean(taskFile)] = data
+		}
+
+		if ansibleConfig.Requirements != "" {
+			if err := resolveAnsibleRequirements(ctx, ansibleConfig.Requirements, files, c); err != nil {
+				return fmt.Errorf("unable to resolve ansible requirements %q: %s", ansibleConfig.Requirements, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// ansibleRequirements is the subset of the ansible-galaxy requirements file format.
+// Only git repositories and project directories are supported as sources, ansible-galaxy is not called during the build.
+type ansibleRequirements struct {
+	Roles       []ansibleRequirement
+	Collections []ansibleRequirement
+}
+
+type ansibleRequirement struct {
+	Src     string `yaml:"src"`
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Scm     string `yaml:"scm"`
+	Type    string `yaml:"type"`
+	Source  string `yaml:"source"`
+}
+
+// UnmarshalYAML supports the short form of a requirement: `SRC[,VERSION[,NAME]]`.
+func (r *ansibleRequirement) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		parts := strings.Split(value, ",")
+		r.Src = strings.TrimSpace(parts[0])
+		if len(parts) > 1 {
+			r.Version = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			r.Name = strings.TrimSpace(parts[2])
+		}
+
+		return nil
+	}
+
+	type plain ansibleRequirement
+	return unmarshal((*plain)(r))
+}
+
+// UnmarshalYAML supports both the list of roles and the object with roles and collections.
+func (r *ansibleRequirements) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var roles []ansibleRequirement
+	if err := unmarshal(&roles); err == nil {
+		r.Roles = roles
+		return nil
+	}
+
+	var requirements struct {
+		Roles       []ansibleRequirement `yaml:"roles"`
+		Collections []ansibleRequirement `yaml:"collections"`
+	}
+	if err := unmarshal(&requirements); err != nil {
+		return err
+	}
+
+	r.Roles = requirements.Roles
+	r.Collections = requirements.Collections
+
+	return nil
+}
+
+// AnsibleGitRequirement is the role or collection from the git repository in ansible.requirements.
+type AnsibleGitRequirement struct {
+	Url     string
+	Version string // tag, branch or commit, the default branch if empty
+}
+
+// GetAnsibleGitRequirements returns the roles and collections from git repositories in ansible.requirements.
+func GetAnsibleGitRequirements(ctx context.Context, giterminismManager giterminism_manager.Interface, ansibleConfig *config.Ansible) ([]*AnsibleGitRequirement, error) {
+	if ansibleConfig.Requirements == "" {
+		return nil, nil
+	}
+
+	requirements, err := readAnsibleRequirements(ctx, giterminismManager, ansibleConfig.Requirements)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ansible requirements %q: %s", ansibleConfig.Requirements, err)
+	}
+
+	var result []*AnsibleGitRequirement
+	for _, role := range requirements.Roles {
+		if url, _, ok := ansibleRequirementGitUrl(role.source(), role.Scm == "git"); ok {
+			result = append(result, &AnsibleGitRequirement{Url: url, Version: role.Version})
+		}
+	}
+
+	for _, collection := range requirements.Collections {
+		if url, _, ok := ansibleRequirementGitUrl(collection.collectionSource(), collection.Type == "git"); ok {
+			result = append(result, &AnsibleGitRequirement{Url: url, Version: collection.Version})
+		}
+	}
+
+	return result, nil
+}
+
+func readAnsibleRequirements(ctx context.Context, giterminismManager giterminism_manager.Interface, requirementsPath string) (*ansibleRequirements, error) {
+	data, err := giterminismManager.FileReader().ReadAnsibleFile(ctx, requirementsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := &ansibleRequirements{}
+	if err := yaml.Unmarshal(data, requirements); err != nil {
+		return nil, fmt.Errorf("unable to parse requirements: %s", err)
+	}
+
+	return requirements, nil
+}
+
+func resolveAnsibleRequirements(ctx context.Context, requirementsPath string, files *builder.AnsibleFiles, c *Conveyor) error {
+	requirements, err := readAnsibleRequirements(ctx, c.giterminismManager, requirementsPath)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range requirements.Roles {
+		src := role.source()
+
+		roleFiles, err := readAnsibleRequirementFiles(ctx, src, role.Version, role.Scm == "git", role.Scm == "", c)
+		if err != nil {
+			return fmt.Errorf("role %q: %s", src, err)
+		}
+
+		roleName := role.Name
+		if roleName == "" || roleName == src {
+			roleName = ansibleRequirementBaseName(src)
+		}
+
+		if _, exist := files.Roles[roleName]; exist {
+			return fmt.Errorf("role %q is defined more than once", roleName)
+		}
+
+		files.Roles[roleName] = roleFiles
+	}
+
+	for _, collection := range requirements.Collections {
+		src := collection.collectionSource()
+
+		collectionFiles, err := readAnsibleRequirementFiles(ctx, src, collection.Version, collection.Type == "git", collection.Type == "" || collection.Type == "dir", c)
+		if err != nil {
+			return fmt.Errorf("collection %q: %s", src, err)
+		}
+
+		collectionName, err := ansibleCollectionName(collectionFiles)
+		if err != nil {
+			return fmt.Errorf("collection %q: %s", src, err)
+		}
+
+		if _, exist := files.Collections[collectionName]; exist {
+			return fmt.Errorf("collection %q is defined more than once", collectionName)
+		}
+
+		files.Collections[collectionName] = collectionFiles
+	}
+
+	return nil
+}
+
+func (r ansibleRequirement) source() string {
+	if r.Src != "" {
+		return r.Src
+	}
+
+	return r.Name
+}
+
+func (r ansibleRequirement) collectionSource() string {
+	if r.Source != "" {
+		return r.Source
+	}
+
+	return r.Name
+}
+
+// ansibleRequirementGitUrl parses the git repository source of the role or collection: `[git+]URL[#SUBDIR]`.
+func ansibleRequirementGitUrl(src string, isGit bool) (url, subDir string, ok bool) {
+	if !isGit && !strings.HasPrefix(src, "git+") && !strings.HasSuffix(strings.SplitN(src, "#", 2)[0], ".git") {
+		return "", "", false
+	}
+
+	url = strings.TrimPrefix(src, "git+")
+	if parts := strings.SplitN(url, "#", 2); len(parts) == 2 {
+		url, subDir = parts[0], strings.Trim(parts[1], "/")
+	}
+
+	return url, subDir, true
+}
+
+// readAnsibleRequirementFiles reads the files of the role or collection from the git repository (`[git+]URL[#SUBDIR]`) or the project directory.
+func readAnsibleRequirementFiles(ctx context.Context, src, version string, isGit, mayBeDir bool, c *Conveyor) (map[string][]byte, error) {
+	if url, subDir, ok := ansibleRequirementGitUrl(src, isGit); ok {
+		return readAnsibleGitRepoFiles(ctx, url, version, subDir, c)
+	}
+
+	switch {
+	case mayBeDir && !strings.Contains(src, "://") && strings.Contains(src, "/"):
+		if path.IsAbs(src) || strings.HasPrefix(path.Clean(src), "..") {
+			return nil, fmt.Errorf("the directory should be relative to the project directory")
+		}
+
+		return readAnsibleProjectDirFiles(ctx, src, c)
+	default:
+		return nil, fmt.Errorf("only git repositories and project directories are supported, Ansible Galaxy server is not used during the build")
+	}
+}
+
+func readAnsibleProjectDirFiles(ctx context.Context, relDirPath string, c *Conveyor) (map[string][]byte, error) {
+	files, err := c.giterminismManager.FileReader().ReadAnsibleDirFiles(ctx, relDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("directory %q not found in the project directory or empty", relDirPath)
+	}
+
+	return files, nil
+}
+
+func readAnsibleGitRepoFiles(ctx context.Context, url, version, subDir string, c *Conveyor) (map[string][]byte, error) {
+	remoteGitRepo, err := getOrOpenRemoteGitRepoByUrl(ctx, ansibleRequirementBaseName(url), url, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// branches are subject to the same giterminism check as branches of remote git mappings
+	commit, err := resolveRemoteGitRepoCommit(ctx, remoteGitRepo, version, c.giterminismManager.Inspector().InspectConfigStapelGitBranch, c)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := remoteGitRepo.GetOrCreateArchive(ctx, git_repo.ArchiveOptions{
+		Commit:      commit,
+		PathScope:   subDir,
+		PathMatcher: path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{BasePath: subDir}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create archive for commit %s: %s", commit, err)
+	}
+
+	files, err := readAnsibleArchiveFiles(archive.GetFilePath())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found in commit %s", commit)
+	}
+
+	return files, nil
+}
+
+func readAnsibleArchiveFiles(archivePath string) (map[string][]byte, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive %s: %s", archivePath, err)
+	}
+	defer f.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %s", archivePath, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %s", archivePath, err)
+		}
+
+		files[path.Clean(hdr.Name)] = data
+	}
+
+	return files, nil
+}
+
+// ansibleCollectionName returns namespace.name of the collection from galaxy.yml.
+func ansibleCollectionName(files map[string][]byte) (string, error) {
+	data, ok := files["galaxy.yml"]
+	if !ok {
+		return "", fmt.Errorf("galaxy.yml not found")
+	}
+
+	var galaxy struct {
+		Namespace string `yaml:"namespace"`
+		Name      string `yaml:"name"`
+	}
+	if err := yaml.Unmarshal(data, &galaxy); err != nil {
+		return "", fmt.Errorf("unable to parse galaxy.yml: %s", err)
+	}
+
+	if galaxy.Namespace == "" || galaxy.Name == "" {
+		return "", fmt.Errorf("namespace and name should be defined in galaxy.yml")
+	}
+
+	return fmt.Sprintf("%s.%s", galaxy.Namespace, galaxy.Name), nil
+}
+
+// ansibleRequirementBaseName returns the default role name by the source (e.g. nginx for git+https://github.com/org/nginx.git).
+func ansibleRequirementBaseName(src string) string {
+	src = strings.SplitN(strings.TrimPrefix(src, "git+"), "#", 2)[0]
+	src = strings.TrimSuffix(strings.TrimSuffix(src, "/"), ".git")
+	return path.Base(strings.Replace(src, ":", "/", -1))
+}
@@ -0,0 +1,62 @@
+package build
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+)
+
+var _ = DescribeTable("ansibleRequirements.UnmarshalYAML parses the requirements file",
+	func(data string, expected ansibleRequirements) {
+		var requirements ansibleRequirements
+		Ω(yaml.Unmarshal([]byte(data), &requirements)).Should(Succeed())
+		Ω(requirements).Should(Equal(expected))
+	},
+	Entry("list of roles",
+		"- src: git+https://github.com/org/nginx.git\n  version: v1.0.0\n- name: users\n  src: ./ansible/users\n",
+		ansibleRequirements{Roles: []ansibleRequirement{
+			{Src: "git+https://github.com/org/nginx.git", Version: "v1.0.0"},
+			{Src: "./ansible/users", Name: "users"},
+		}},
+	),
+	Entry("roles and collections",
+		"roles:\n- src: https://github.com/org/nginx.git\n  scm: git\ncollections:\n- name: https://github.com/org/tools.git\n  type: git\n  version: main\n",
+		ansibleRequirements{
+			Roles:       []ansibleRequirement{{Src: "https://github.com/org/nginx.git", Scm: "git"}},
+			Collections: []ansibleRequirement{{Name: "https://github.com/org/tools.git", Type: "git", Version: "main"}},
+		},
+	),
+	Entry("short form",
+		"- git+https://github.com/org/nginx.git, v1.0.0, web\n- git+https://github.com/org/users.git\n",
+		ansibleRequirements{Roles: []ansibleRequirement{
+			{Src: "git+https://github.com/org/nginx.git", Version: "v1.0.0", Name: "web"},
+			{Src: "git+https://github.com/org/users.git"},
+		}},
+	),
+)
+
+var _ = DescribeTable("ansibleRequirementBaseName returns the default role name",
+	func(src, expected string) {
+		Ω(ansibleRequirementBaseName(src)).Should(Equal(expected))
+	},
+	Entry("git+https url", "git+https://github.com/org/nginx.git", "nginx"),
+	Entry("url with the subdirectory", "https://github.com/org/roles.git#/nginx", "roles"),
+	Entry("url with the trailing slash", "https://github.com/org/nginx/", "nginx"),
+	Entry("scp-like url", "git@github.com:nginx.git", "nginx"),
+	Entry("project directory", "./ansible/roles/users", "users"),
+)
+
+var _ = DescribeTable("ansibleRequirementGitUrl parses the git repository source",
+	func(src string, isGit bool, expectedUrl, expectedSubDir string, expectedOk bool) {
+		url, subDir, ok := ansibleRequirementGitUrl(src, isGit)
+		Ω(ok).Should(Equal(expectedOk))
+		Ω(url).Should(Equal(expectedUrl))
+		Ω(subDir).Should(Equal(expectedSubDir))
+	},
+	Entry("git+ prefix", "git+https://github.com/org/nginx", false, "https://github.com/org/nginx", "", true),
+	Entry(".git suffix", "https://github.com/org/nginx.git", false, "https://github.com/org/nginx.git", "", true),
+	Entry("git scm", "https://github.com/org/nginx", true, "https://github.com/org/nginx", "", true),
+	Entry("subdirectory", "git+https://github.com/org/roles.git#/nginx/", false, "https://github.com/org/roles.git", "nginx", true),
+	Entry("project directory", "./ansible/roles/nginx", false, "", "", false),
+	Entry("galaxy role", "org.nginx", false, "", "", false),
+)
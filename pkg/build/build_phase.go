@@ -307,7 +307,7 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		return fmt.Errorf("unable to fetch dependencies for stage %s: %s", stg.LogDetailedName(), err)
 	}
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if stg.Name() != "from" && !isDockerfileStage(stg) {
 		if phase.StagesIterator.PrevNonEmptyStage == nil {
 			panic(fmt.Sprintf("expected PrevNonEmptyStage to be set for image %q stage %s", img.GetName(), stg.Name()))
 		}
@@ -358,6 +358,12 @@ func (phase *BuildPhase) onImageStage(ctx context.Context, img *Image, stg stage
 		i := phase.Conveyor.GetOrCreateStageImage(castToStageImage(phase.StagesIterator.GetPrevImage(img, stg)), uuid.New().String())
 		stg.SetImage(i)
 
+		defer func() {
+			if err := stg.PostRunHook(ctx, phase.Conveyor); err != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: %s postRunHook failed: %s\n", stg.LogDetailedName(), err)
+			}
+		}()
+
 		if err := phase.fetchBaseImageForStage(ctx, img, stg); err != nil {
 			return err
 		}
@@ -449,8 +455,11 @@ func (phase *BuildPhase) findAndFetchStageFromSecondaryStagesStorage(ctx context
 
 			unlockStage()
 
-			if err := phase.Conveyor.StorageManager.CopyStageIntoCache(ctx, stg, phase.Conveyor.ContainerRuntime); err != nil {
-				return fmt.Errorf("unable to copy stage %s into cache storages: %s", stg.GetImage().GetStageDescription().StageID.String(), err)
+			// stages of development mode builds are not shared through cache storages
+			if !phase.Conveyor.GiterminismManager().Dev() {
+				if err := phase.Conveyor.StorageManager.CopyStageIntoCache(ctx, stg, phase.Conveyor.ContainerRuntime); err != nil {
+					return fmt.Errorf("unable to copy stage %s into cache storages: %s", stg.GetImage().GetStageDescription().StageID.String(), err)
+				}
 			}
 
 			return nil
@@ -482,8 +491,13 @@ func (phase *BuildPhase) fetchBaseImageForStage(ctx context.Context, img *Image,
 		if err := img.FetchBaseImage(ctx, phase.Conveyor); err != nil {
 			return fmt.Errorf("unable to fetch base image %s for stage %s: %s", img.GetBaseImage().Name(), stg.LogDetailedName(), err)
 		}
-	} else if stg.Name() == "dockerfile" {
-		return nil
+	} else if dockerfileStage, ok := stg.(*stage.DockerfileStage); ok {
+		// the Dockerfile stage is built by docker from the context and the stages it depends on
+		for _, dependencyStage := range dockerfileStage.DependencyStages() {
+			if err := phase.Conveyor.StorageManager.FetchStage(ctx, phase.Conveyor.ContainerRuntime, dependencyStage); err != nil {
+				return err
+			}
+		}
 	} else {
 		return phase.Conveyor.StorageManager.FetchStage(ctx, phase.Conveyor.ContainerRuntime, phase.StagesIterator.PrevBuiltStage)
 	}
@@ -504,7 +518,13 @@ func (phase *BuildPhase) calculateStage(ctx context.Context, img *Image, stg sta
 		return false, nil, err
 	}
 
-	stageDigest, err := calculateDigest(ctx, string(stg.Name()), stageDependencies, phase.StagesIterator.PrevNonEmptyStage, phase.Conveyor)
+	// the Dockerfile stage is not based on the previous stage, the staged Dockerfile stage digest depends only on the Dockerfile stages it is built from
+	prevNonEmptyStage := phase.StagesIterator.PrevNonEmptyStage
+	if isDockerfileStage(stg) {
+		prevNonEmptyStage = nil
+	}
+
+	stageDigest, err := calculateDigest(ctx, string(stg.Name()), stageDependencies, prevNonEmptyStage, phase.Conveyor)
 	if err != nil {
 		return false, nil, err
 	}
@@ -558,6 +578,10 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 		imagePkg.WerfStageContentDigestLabel: stg.GetContentDigest(),
 	}
 
+	if phase.Conveyor.DevStagesTTL != "" {
+		serviceLabels[imagePkg.WerfDevStagesTTLLabel] = phase.Conveyor.DevStagesTTL
+	}
+
 	switch stg.(type) {
 	case *stage.DockerfileStage:
 		var buildArgs []string
@@ -573,6 +597,10 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 		})
 
 	default:
+		if img.disableStapelToolchain {
+			stageImage.Container().DisableStapelToolchain()
+		}
+
 		imageServiceCommitChangeOptions := stageImage.Container().ServiceCommitChangeOptions()
 		imageServiceCommitChangeOptions.AddLabel(serviceLabels)
 
@@ -598,7 +626,7 @@ func (phase *BuildPhase) prepareStageInstructions(ctx context.Context, img *Imag
 }
 
 func (phase *BuildPhase) buildStage(ctx context.Context, img *Image, stg stage.Interface) error {
-	if !img.isDockerfileImage {
+	if !img.isDockerfileImage && !img.disableStapelToolchain {
 		_, err := stapel.GetOrCreateContainer(ctx)
 		if err != nil {
 			return fmt.Errorf("get or create stapel container failed: %s", err)
@@ -647,7 +675,9 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 	}
 
 	if err := logboek.Context(ctx).Streams().DoErrorWithTag(fmt.Sprintf("%s/%s", img.LogName(), stg.Name()), img.LogTagStyle(), func() error {
-		return stageImage.Build(ctx, phase.ImageBuildOptions)
+		return stg.WithCacheMountsLock(ctx, func() error {
+			return stageImage.Build(ctx, phase.ImageBuildOptions)
+		})
 	}); err != nil {
 		return fmt.Errorf("failed to build image for stage %s with digest %s: %s", stg.Name(), stg.GetDigest(), err)
 	}
@@ -722,8 +752,11 @@ func (phase *BuildPhase) atomicBuildStageImage(ctx context.Context, img *Image,
 
 			unlockStage()
 
-			if err := phase.Conveyor.StorageManager.CopyStageIntoCache(ctx, stg, phase.Conveyor.ContainerRuntime); err != nil {
-				return fmt.Errorf("unable to copy stage %s into cache storages: %s", stageImage.GetStageDescription().StageID.String(), err)
+			// stages of development mode builds are not shared through cache storages
+			if !phase.Conveyor.GiterminismManager().Dev() {
+				if err := phase.Conveyor.StorageManager.CopyStageIntoCache(ctx, stg, phase.Conveyor.ContainerRuntime); err != nil {
+					return fmt.Errorf("unable to copy stage %s into cache storages: %s", stageImage.GetStageDescription().StageID.String(), err)
+				}
 			}
 
 			return nil
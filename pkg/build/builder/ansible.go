@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	ghodssYaml "github.com/ghodss/yaml"
@@ -27,8 +28,17 @@ type Ansible struct {
 }
 
 type Extra struct {
-	ContainerWerfPath string
-	TmpPath           string
+	ContainerWerfPath      string
+	TmpPath                string
+	DisableStapelToolchain bool
+	AnsibleFiles           *AnsibleFiles
+}
+
+// AnsibleFiles are the files of ansible roles, collections and task files prepared on the host.
+type AnsibleFiles struct {
+	Roles       map[string]map[string][]byte // role name => role directory relative path => data
+	Collections map[string]map[string][]byte // collection namespace.name => collection directory relative path => data
+	TaskFiles   map[string][]byte            // project directory relative path => data
 }
 
 func NewAnsibleBuilder(config *config.Ansible, extra *Extra) *Ansible {
@@ -117,41 +127,65 @@ func (b *Ansible) stage(ctx context.Context, userStageName string, container Con
 	}
 	container.AddVolumeFrom(fmt.Sprintf("%s:ro", containerName))
 
+	if b.config.FineGrained {
+		// each task is run and cached separately, the step checksum does not depend on the other tasks
+		checksumArgs := []string{b.ansibleFilesChecksum(userStageName), b.stageVersionChecksum(userStageName)}
+		for ind, task := range b.stageTasks(userStageName) {
+			taskChecksum := util.Sha256Hash(append([]string{taskConfigChecksumArg(task)}, checksumArgs...)...)
+			container.AddRunStep(runStepName(taskStepName(task)), taskChecksum, b.playbookRunCommand(taskPlaybookFileName(ind)))
+		}
+
+		return nil
+	}
+
+	container.AddServiceRunCommands(b.playbookRunCommand("playbook.yml"))
+
+	return nil
+}
+
+func (b *Ansible) playbookRunCommand(playbookFileName string) string {
 	commandParts := []string{
 		path.Join(b.containerWorkDir(), "ansible-playbook"),
-		path.Join(b.containerWorkDir(), "playbook.yml"),
+		path.Join(b.containerWorkDir(), playbookFileName),
 	}
 
 	if value, exist := os.LookupEnv("WERF_DEBUG_ANSIBLE_ARGS"); exist {
 		commandParts = append(commandParts, value)
 	}
 
-	command := strings.Join(commandParts, " ")
-	container.AddServiceRunCommands(command)
+	return strings.Join(commandParts, " ")
+}
 
-	return nil
+// taskStepName returns the task name if any, the task module otherwise.
+func taskStepName(task *config.AnsibleTask) string {
+	if name, ok := task.Config.(map[string]interface{})["name"].(string); ok && name != "" {
+		return name
+	}
+
+	return task.Module()
 }
 
 func (b *Ansible) stageChecksum(ctx context.Context, userStageName string) string {
 	var checksumArgs []string
 
 	for _, task := range b.stageTasks(userStageName) {
-		output, err := yaml.Marshal(task.Config)
-		if err != nil {
-			panic(fmt.Sprintf("runtime err: %s", err))
-		}
-
-		jsonOutput, err := ghodssYaml.YAMLToJSON(output)
-		if err != nil {
-			panic(fmt.Sprintf("runtime err: %s", err))
-		}
-		checksumArgs = append(checksumArgs, string(jsonOutput))
+		checksumArgs = append(checksumArgs, taskConfigChecksumArg(task))
 	}
 
 	if debugUserStageChecksum() {
 		logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage tasks checksum dependencies %v\n", userStageName, checksumArgs)
 	}
 
+	if len(checksumArgs) != 0 {
+		if filesChecksum := b.ansibleFilesChecksum(userStageName); filesChecksum != "" {
+			if debugUserStageChecksum() {
+				logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage ansible files checksum %v\n", userStageName, filesChecksum)
+			}
+
+			checksumArgs = append(checksumArgs, filesChecksum)
+		}
+	}
+
 	if stageVersionChecksum := b.stageVersionChecksum(userStageName); stageVersionChecksum != "" {
 		if debugUserStageChecksum() {
 			logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage version checksum %v\n", userStageName, stageVersionChecksum)
@@ -167,6 +201,83 @@ func (b *Ansible) stageChecksum(ctx context.Context, userStageName string) strin
 	}
 }
 
+func taskConfigChecksumArg(task *config.AnsibleTask) string {
+	output, err := yaml.Marshal(task.Config)
+	if err != nil {
+		panic(fmt.Sprintf("runtime err: %s", err))
+	}
+
+	jsonOutput, err := ghodssYaml.YAMLToJSON(output)
+	if err != nil {
+		panic(fmt.Sprintf("runtime err: %s", err))
+	}
+
+	return string(jsonOutput)
+}
+
+func (b *Ansible) ansibleFilesChecksum(userStageName string) string {
+	files := b.stageWorkDirAnsibleFiles(userStageName)
+	if len(files) == 0 {
+		return ""
+	}
+
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var args []string
+	for _, p := range paths {
+		args = append(args, p, util.Sha256Hash(string(files[p])))
+	}
+
+	return util.Sha256Hash(args...)
+}
+
+// stageWorkDirAnsibleFiles returns the ansible files used by the stage tasks by the work dir relative paths.
+// Roles and collections are scoped to the ones referenced by the tasks, task files and roles of the stage,
+// all files are returned if some reference cannot be resolved statically.
+func (b *Ansible) stageWorkDirAnsibleFiles(userStageName string) map[string][]byte {
+	files := map[string][]byte{}
+	if b.extra.AnsibleFiles == nil {
+		return files
+	}
+
+	refs := getAnsibleTaskRefs(b.extra.AnsibleFiles, b.stageTasks(userStageName))
+
+	for roleName, roleFiles := range b.extra.AnsibleFiles.Roles {
+		if !refs.dynamic && !refs.roles[roleName] {
+			continue
+		}
+
+		for p, data := range roleFiles {
+			files[path.Join("roles", roleName, p)] = data
+		}
+	}
+
+	for collectionName, collectionFiles := range b.extra.AnsibleFiles.Collections {
+		if !refs.dynamic && !refs.collections[collectionName] {
+			continue
+		}
+
+		collectionDir := path.Join(append([]string{"collections", "ansible_collections"}, strings.SplitN(collectionName, ".", 2)...)...)
+		for p, data := range collectionFiles {
+			files[path.Join(collectionDir, p)] = data
+		}
+	}
+
+	for p, data := range b.extra.AnsibleFiles.TaskFiles {
+		if !refs.dynamic && !refs.taskFiles[p] {
+			continue
+		}
+
+		files[path.Join("tasks", p)] = data
+	}
+
+	return files
+}
+
 func (b *Ansible) stageVersionChecksum(userStageName string) string {
 	var stageVersionChecksumArgs []string
 
@@ -31,6 +31,22 @@ func (b *Ansible) createStageWorkDirStructure(userStageName string) error {
 	}
 	writeFile(filepath.Join(stageWorkDir, "playbook.yml"), string(data))
 
+	// playbook for each task of a fine-grained stage
+	if b.config.FineGrained {
+		stageConfig, err := b.stageConfig(userStageName)
+		if err != nil {
+			return err
+		}
+
+		for ind, task := range stageConfig["tasks"].([]interface{}) {
+			data, err := yaml.Marshal(playbookWithTasks([]interface{}{task}))
+			if err != nil {
+				return err
+			}
+			writeFile(filepath.Join(stageWorkDir, taskPlaybookFileName(ind)), string(data))
+		}
+	}
+
 	// generate inventory with localhost and python in stapel
 	writeFile(filepath.Join(stageWorkDir, "hosts"), b.assetsHosts())
 
@@ -125,21 +141,40 @@ execfile("%s")
 	writeFile(filepath.Join(werfPackageDir, "live_stdout.py"), b.assetsWerfLiveStdoutPy())
 	writeFile(filepath.Join(werfPackageDir, "tee_popen.py"), b.assetsWerfTeePopenPy())
 
+	// roles, collections and task files
+	for p, data := range b.stageWorkDirAnsibleFiles(userStageName) {
+		filePath := filepath.Join(stageWorkDir, filepath.FromSlash(p))
+		if err := mkdirP(filepath.Dir(filePath)); err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(filePath, data, os.FileMode(0664)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (b *Ansible) stagePlaybook(userStageName string) ([]map[string]interface{}, error) {
-	playbook := map[string]interface{}{
-		"hosts":        "all",
-		"gather_facts": "no",
-	}
 	stageConfig, err := b.stageConfig(userStageName)
 	if err != nil {
 		return nil, err
 	}
-	playbook["tasks"] = stageConfig["tasks"]
-	playbooks := []map[string]interface{}{playbook}
-	return playbooks, nil
+	return playbookWithTasks(stageConfig["tasks"]), nil
+}
+
+func playbookWithTasks(tasks interface{}) []map[string]interface{} {
+	playbook := map[string]interface{}{
+		"hosts":        "all",
+		"gather_facts": "no",
+		"tasks":        tasks,
+	}
+	return []map[string]interface{}{playbook}
+}
+
+func taskPlaybookFileName(taskInd int) string {
+	return fmt.Sprintf("playbook-%d.yml", taskInd)
 }
 
 //query tasks from ansible config
@@ -158,6 +193,11 @@ func (b *Ansible) stageConfig(userStageName string) (map[string]interface{}, err
 			return nil, err
 		}
 
+		task, err = b.taskWithWorkDirTaskFiles(task)
+		if err != nil {
+			return nil, err
+		}
+
 		var tags []string
 		if _, ok := task["tags"]; ok {
 			if val, ok := task["tags"].(string); ok {
@@ -188,6 +228,58 @@ func (b *Ansible) stageConfig(userStageName string) (map[string]interface{}, err
 	return result, nil
 }
 
+// taskWithWorkDirTaskFiles returns the copy of the task, where import_tasks and include_tasks refer to the task files in the work dir.
+func (b *Ansible) taskWithWorkDirTaskFiles(task map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, value := range task {
+		result[key] = value
+	}
+
+	for _, module := range []string{"import_tasks", "include_tasks"} {
+		switch value := result[module].(type) {
+		case string:
+			result[module] = path.Join(b.containerWorkDir(), "tasks", value)
+		case map[interface{}]interface{}:
+			options := map[interface{}]interface{}{}
+			for k, v := range value {
+				options[k] = v
+			}
+
+			if file, ok := options["file"].(string); ok {
+				options["file"] = path.Join(b.containerWorkDir(), "tasks", file)
+			}
+
+			result[module] = options
+		}
+	}
+
+	for _, blockKey := range []string{"block", "rescue", "always"} {
+		blockTasks, ok := result[blockKey].([]interface{})
+		if !ok {
+			continue
+		}
+
+		var resultBlockTasks []interface{}
+		for _, blockTask := range blockTasks {
+			blockTaskMap, err := util.InterfaceToMapStringInterface(blockTask)
+			if err != nil {
+				return nil, err
+			}
+
+			resultBlockTask, err := b.taskWithWorkDirTaskFiles(blockTaskMap)
+			if err != nil {
+				return nil, err
+			}
+
+			resultBlockTasks = append(resultBlockTasks, resultBlockTask)
+		}
+
+		result[blockKey] = resultBlockTasks
+	}
+
+	return result, nil
+}
+
 func (b *Ansible) stageHostTmpDir(userStageName string) (string, error) {
 	p := filepath.Join(b.extra.TmpPath, fmt.Sprintf("ansible-tmpdir-%s", userStageName))
 
@@ -14,6 +14,8 @@ func (b *Ansible) assetsAnsibleCfg() string {
 	sudoBinPath := stapel.SudoBinPath()
 	localTmpDirPath := path.Join(b.containerTmpDir(), "local")
 	remoteTmpDirPath := path.Join(b.containerTmpDir(), "remote")
+	rolesPath := path.Join(b.containerWorkDir(), "roles")
+	collectionsPath := path.Join(b.containerWorkDir(), "collections")
 
 	format := `[defaults]
 inventory = %[1]s
@@ -29,6 +31,9 @@ force_color = 1
 module_compression = 'ZIP_STORED'
 local_tmp = %[3]s
 remote_tmp = %[4]s
+; roles and collections from ansible.roles and ansible.requirements
+roles_path = %[6]s
+collections_paths = %[7]s
 ; keep ansiballz for debug
 ;keep_remote_files = 1
 [privilege_escalation]
@@ -37,7 +42,7 @@ become_method = sudo
 become_exe = %[5]s
 become_flags = -E -H`
 
-	return fmt.Sprintf(format, hostsPath, callbackPluginsPath, localTmpDirPath, remoteTmpDirPath, sudoBinPath)
+	return fmt.Sprintf(format, hostsPath, callbackPluginsPath, localTmpDirPath, remoteTmpDirPath, sudoBinPath, rolesPath, collectionsPath)
 }
 
 func (b *Ansible) assetsHosts() string {
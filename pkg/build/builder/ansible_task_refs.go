@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/util"
+)
+
+// ansibleTaskRefs are the roles, collections and task files referenced by the ansible tasks.
+type ansibleTaskRefs struct {
+	roles       map[string]bool
+	collections map[string]bool
+	taskFiles   map[string]bool
+
+	// dynamic is set if some reference cannot be resolved statically (e.g. templated role name)
+	dynamic bool
+}
+
+func newAnsibleTaskRefs() *ansibleTaskRefs {
+	return &ansibleTaskRefs{
+		roles:       map[string]bool{},
+		collections: map[string]bool{},
+		taskFiles:   map[string]bool{},
+	}
+}
+
+// getAnsibleTaskRefs returns the references of the stage tasks, the referenced task files and roles (including role dependencies).
+func getAnsibleTaskRefs(files *AnsibleFiles, tasks []*config.AnsibleTask) *ansibleTaskRefs {
+	refs := newAnsibleTaskRefs()
+	for _, task := range tasks {
+		refs.addTask(task.Config)
+	}
+
+	for _, taskFile := range sortedKeys(refs.taskFiles) {
+		if data, ok := files.TaskFiles[taskFile]; ok {
+			refs.addTasksData(data)
+		}
+	}
+
+	processedRoles := map[string]bool{}
+	for {
+		var roleName string
+		for _, name := range sortedKeys(refs.roles) {
+			if !processedRoles[name] {
+				roleName = name
+				break
+			}
+		}
+
+		if roleName == "" {
+			break
+		}
+		processedRoles[roleName] = true
+
+		for p, data := range files.Roles[roleName] {
+			switch {
+			case p == "meta/main.yml" || p == "meta/main.yaml":
+				refs.addRoleMetaData(data)
+			case strings.HasPrefix(p, "tasks/") || strings.HasPrefix(p, "handlers/"):
+				if ext := path.Ext(p); ext == ".yml" || ext == ".yaml" {
+					refs.addTasksData(data)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+func (refs *ansibleTaskRefs) addTasksData(data []byte) {
+	var tasks interface{}
+	if err := yaml.Unmarshal(data, &tasks); err != nil {
+		refs.dynamic = true
+		return
+	}
+
+	refs.addTasks(tasks)
+}
+
+func (refs *ansibleTaskRefs) addTasks(tasks interface{}) {
+	list, ok := tasks.([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, task := range list {
+		refs.addTask(task)
+	}
+}
+
+func (refs *ansibleTaskRefs) addTask(task interface{}) {
+	taskMap, err := util.InterfaceToMapStringInterface(task)
+	if err != nil {
+		return
+	}
+
+	for key, value := range taskMap {
+		module := strings.TrimPrefix(strings.TrimPrefix(key, "ansible.builtin."), "ansible.legacy.")
+		switch module {
+		case "block", "rescue", "always":
+			refs.addTasks(value)
+		case "include_role", "import_role":
+			refs.addRole(ansibleRefValue(value, "name"))
+		case "import_tasks", "include_tasks":
+			if taskFile := ansibleRefValue(value, "file"); taskFile != "" {
+				refs.taskFiles[path.Clean(taskFile)] = true
+			}
+		default:
+			// the module of the collection: namespace.collection.module
+			if parts := strings.Split(module, "."); len(parts) == 3 {
+				refs.collections[strings.Join(parts[:2], ".")] = true
+			}
+		}
+	}
+}
+
+// addRoleMetaData adds the role dependencies and the collections from the role meta/main.yml.
+func (refs *ansibleTaskRefs) addRoleMetaData(data []byte) {
+	var meta struct {
+		Dependencies []interface{} `yaml:"dependencies"`
+		Collections  []string      `yaml:"collections"`
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		refs.dynamic = true
+		return
+	}
+
+	for _, dependency := range meta.Dependencies {
+		if name := ansibleRefValue(dependency, "role"); name != "" {
+			refs.addRole(name)
+		} else {
+			refs.addRole(ansibleRefValue(dependency, "name"))
+		}
+	}
+
+	for _, collection := range meta.Collections {
+		refs.collections[collection] = true
+	}
+}
+
+// addRole adds the role (`NAME`) or the collection of the role (`NAMESPACE.COLLECTION.NAME`).
+func (refs *ansibleTaskRefs) addRole(name string) {
+	if name == "" || strings.Contains(name, "{{") {
+		refs.dynamic = true
+		return
+	}
+
+	if parts := strings.Split(name, "."); len(parts) == 3 {
+		refs.collections[strings.Join(parts[:2], ".")] = true
+		return
+	}
+
+	refs.roles[name] = true
+}
+
+// ansibleRefValue returns the value of the short (`MODULE: VALUE`) or the full form (`MODULE: {KEY: VALUE}`).
+func ansibleRefValue(value interface{}, key string) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[interface{}]interface{}:
+		if res, ok := v[key].(string); ok {
+			return res
+		}
+	case map[string]interface{}:
+		if res, ok := v[key].(string); ok {
+			return res
+		}
+	}
+
+	return ""
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
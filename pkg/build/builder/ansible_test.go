@@ -0,0 +1,121 @@
+package builder
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+func newTestAnsibleBuilder(files *AnsibleFiles, installTasks ...map[string]interface{}) *Ansible {
+	ansibleConfig := &config.Ansible{}
+	for _, task := range installTasks {
+		ansibleConfig.Install = append(ansibleConfig.Install, &config.AnsibleTask{Config: task})
+	}
+
+	return NewAnsibleBuilder(ansibleConfig, &Extra{ContainerWerfPath: "/.werf", AnsibleFiles: files})
+}
+
+var _ = DescribeTable("taskWithWorkDirTaskFiles makes import_tasks and include_tasks refer to the task files in the work dir",
+	func(task, expected map[string]interface{}) {
+		b := newTestAnsibleBuilder(nil)
+
+		result, err := b.taskWithWorkDirTaskFiles(task)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result).Should(Equal(expected))
+	},
+	Entry("other module",
+		map[string]interface{}{"shell": "true"},
+		map[string]interface{}{"shell": "true"},
+	),
+	Entry("import_tasks",
+		map[string]interface{}{"name": "common", "import_tasks": "ansible/common.yml"},
+		map[string]interface{}{"name": "common", "import_tasks": "/.werf/ansible-workdir/tasks/ansible/common.yml"},
+	),
+	Entry("include_tasks with file",
+		map[string]interface{}{"include_tasks": map[interface{}]interface{}{"file": "ansible/common.yml", "apply": "x"}},
+		map[string]interface{}{"include_tasks": map[interface{}]interface{}{"file": "/.werf/ansible-workdir/tasks/ansible/common.yml", "apply": "x"}},
+	),
+	Entry("block tasks",
+		map[string]interface{}{"block": []interface{}{
+			map[interface{}]interface{}{"import_tasks": "a.yml"},
+			map[interface{}]interface{}{"shell": "true"},
+		}},
+		map[string]interface{}{"block": []interface{}{
+			map[string]interface{}{"import_tasks": "/.werf/ansible-workdir/tasks/a.yml"},
+			map[string]interface{}{"shell": "true"},
+		}},
+	),
+)
+
+var _ = DescribeTable("stageWorkDirAnsibleFiles returns the files referenced by the stage tasks",
+	func(installTasks []map[string]interface{}, expected []string) {
+		files := &AnsibleFiles{
+			Roles: map[string]map[string][]byte{
+				"nginx":  {"tasks/main.yml": []byte("- include_role:\n    name: common\n")},
+				"common": {"tasks/main.yml": []byte("- shell: true\n"), "meta/main.yml": []byte("dependencies:\n- role: base\ncollections:\n- org.tools\n")},
+				"base":   {"tasks/main.yml": []byte("- shell: true\n")},
+				"users":  {"tasks/main.yml": []byte("- org.utils.sync: {}\n")},
+				"unused": {"tasks/main.yml": []byte("- shell: true\n")},
+			},
+			Collections: map[string]map[string][]byte{
+				"org.tools": {"galaxy.yml": []byte("")},
+				"org.utils": {"galaxy.yml": []byte("")},
+				"org.web":   {"galaxy.yml": []byte("")},
+			},
+			TaskFiles: map[string][]byte{
+				"ansible/users.yml":  []byte("- import_role:\n    name: users\n"),
+				"ansible/unused.yml": []byte("- shell: true\n"),
+			},
+		}
+
+		var paths []string
+		for p := range newTestAnsibleBuilder(files, installTasks...).stageWorkDirAnsibleFiles("Install") {
+			paths = append(paths, p)
+		}
+
+		Ω(paths).Should(ConsistOf(expected))
+	},
+	Entry("no references",
+		[]map[string]interface{}{{"shell": "true"}},
+		[]string{},
+	),
+	Entry("role with the dependencies and the collections",
+		[]map[string]interface{}{{"include_role": map[interface{}]interface{}{"name": "nginx"}}},
+		[]string{
+			"roles/nginx/tasks/main.yml",
+			"roles/common/tasks/main.yml",
+			"roles/common/meta/main.yml",
+			"roles/base/tasks/main.yml",
+			"collections/ansible_collections/org/tools/galaxy.yml",
+		},
+	),
+	Entry("role of the task file in the block",
+		[]map[string]interface{}{{"block": []interface{}{map[interface{}]interface{}{"import_tasks": "./ansible/users.yml"}}}},
+		[]string{
+			"tasks/ansible/users.yml",
+			"roles/users/tasks/main.yml",
+			"collections/ansible_collections/org/utils/galaxy.yml",
+		},
+	),
+	Entry("role of the collection",
+		[]map[string]interface{}{{"ansible.builtin.import_role": "org.web.server"}},
+		[]string{"collections/ansible_collections/org/web/galaxy.yml"},
+	),
+	Entry("templated role name",
+		[]map[string]interface{}{{"include_role": map[interface{}]interface{}{"name": "{{ role }}"}}},
+		[]string{
+			"roles/nginx/tasks/main.yml",
+			"roles/common/tasks/main.yml",
+			"roles/common/meta/main.yml",
+			"roles/base/tasks/main.yml",
+			"roles/users/tasks/main.yml",
+			"roles/unused/tasks/main.yml",
+			"collections/ansible_collections/org/tools/galaxy.yml",
+			"collections/ansible_collections/org/utils/galaxy.yml",
+			"collections/ansible_collections/org/web/galaxy.yml",
+			"tasks/ansible/users.yml",
+			"tasks/ansible/unused.yml",
+		},
+	),
+)
@@ -23,6 +23,7 @@ type Builder interface {
 type Container interface {
 	AddRunCommands(commands ...string)
 	AddServiceRunCommands(commands ...string)
+	AddRunStep(name, checksum string, commands ...string)
 	AddVolumeFrom(volumesFrom ...string)
 	AddVolume(volumes ...string)
 	AddExpose(exposes ...string)
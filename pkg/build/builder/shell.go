@@ -12,6 +12,7 @@ import (
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/util"
 )
@@ -72,6 +73,21 @@ func (b *Shell) stage(userStageName string, container Container) error {
 		fmt.Sprintf("%s:%s:rw", stageHostTmpDir, b.containerTmpDir()),
 	)
 
+	if b.config.FineGrained {
+		// each command is run and cached separately, the step checksum does not depend on the other commands
+		stageVersionChecksum := b.stageVersionChecksum(userStageName)
+		for ind, command := range b.stageCommands(userStageName) {
+			scriptName := fmt.Sprintf("script-%d.sh", ind)
+			if err := stapel.CreateScript(filepath.Join(stageHostTmpDir, scriptName), []string{command}); err != nil {
+				return err
+			}
+
+			container.AddRunStep(runStepName(command), util.Sha256Hash(command, stageVersionChecksum), b.scriptRunCommand(path.Join(b.containerTmpDir(), scriptName)))
+		}
+
+		return nil
+	}
+
 	stageHostTmpScriptFilePath := filepath.Join(stageHostTmpDir, scriptFileName)
 	containerTmpScriptFilePath := path.Join(b.containerTmpDir(), scriptFileName)
 
@@ -79,10 +95,30 @@ func (b *Shell) stage(userStageName string, container Container) error {
 		return err
 	}
 
-	container.AddServiceRunCommands(containerTmpScriptFilePath)
+	container.AddServiceRunCommands(b.scriptRunCommand(containerTmpScriptFilePath))
+
 	return nil
 }
 
+func (b *Shell) scriptRunCommand(containerScriptFilePath string) string {
+	if b.extra.DisableStapelToolchain {
+		// the script shebang points to the stapel bash that is not available
+		return fmt.Sprintf("%s -e %s", container_runtime.ImageShellBinPath, containerScriptFilePath)
+	}
+
+	return containerScriptFilePath
+}
+
+// runStepName returns the first line of the command shortened to be shown in the build log.
+func runStepName(command string) string {
+	name := strings.TrimSpace(strings.SplitN(strings.TrimSpace(command), "\n", 2)[0])
+	if runes := []rune(name); len(runes) > 60 {
+		return string(runes[:57]) + "..."
+	}
+
+	return name
+}
+
 func (b *Shell) stageChecksum(ctx context.Context, userStageName string) string {
 	var checksumArgs []string
 
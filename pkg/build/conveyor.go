@@ -9,8 +9,6 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
-	"strconv"
-	"strings"
 	"sync"
 
 	"github.com/gookit/color"
@@ -36,6 +34,7 @@ import (
 	"github.com/werf/werf/pkg/storage/manager"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/util/parallel"
+	"github.com/werf/werf/pkg/werf_lock"
 )
 
 type Conveyor struct {
@@ -57,6 +56,7 @@ type Conveyor struct {
 	stageImages        map[string]*container_runtime.StageImage
 	giterminismManager giterminism_manager.Interface
 	remoteGitRepos     map[string]*git_repo.Remote
+	werfLock           *werf_lock.Lock
 
 	tmpDir string
 
@@ -79,6 +79,9 @@ type ConveyorOptions struct {
 	Parallel                        bool
 	ParallelTasksLimit              int64
 	LocalGitRepoVirtualMergeOptions stage.VirtualMergeOptions
+
+	// DevStagesTTL is set when stages built in development mode are stored in the dev stages storage
+	DevStagesTTL string
 }
 
 func NewConveyor(werfConfig *config.WerfConfig, giterminismManager giterminism_manager.Interface, imageNamesToProcess []string, projectDir, baseTmpDir, sshAuthSock string, containerRuntime container_runtime.ContainerRuntime, storageManager manager.StorageManagerInterface, storageLockManager storage.LockManager, opts ConveyorOptions) *Conveyor {
@@ -200,7 +203,7 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 		return srv, nil
 	}
 
-	var srv *import_server.RsyncServer
+	var srv *import_server.LayersServer
 
 	var stg stage.Interface
 
@@ -210,11 +213,13 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 		stg = c.GetImage(imageName).GetLastNonEmptyStage()
 	}
 
-	if err := c.StorageManager.FetchStage(ctx, c.ContainerRuntime, stg); err != nil {
-		return nil, fmt.Errorf("unable to fetch stage %s: %s", stg.GetImage().Name(), err)
+	dockerImageName := stg.GetImage().Name()
+	layersSource, err := c.getImportLayersSource(ctx, stg, dockerImageName)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Firing up import rsync server for image %s", imageName)).
+	if err := logboek.Context(ctx).Info().LogProcess(fmt.Sprintf("Reading import source image %s layers", importServerName)).
 		DoError(func() error {
 			var tmpDir string
 			if stageName == "" {
@@ -227,25 +232,10 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 				return fmt.Errorf("unable to create dir %s: %s", tmpDir, err)
 			}
 
-			var dockerImageName string
-			if stageName == "" {
-				dockerImageName = c.GetImageNameForLastImageStage(imageName)
-			} else {
-				dockerImageName = c.GetImageNameForImageStage(imageName, stageName)
-			}
-
 			var err error
-			srv, err = import_server.RunRsyncServer(ctx, dockerImageName, tmpDir)
-			if srv != nil {
-				c.AppendOnTerminateFunc(func() error {
-					if err := srv.Shutdown(ctx); err != nil {
-						return fmt.Errorf("unable to shutdown import server %s: %s", srv.DockerContainerName, err)
-					}
-					return nil
-				})
-			}
+			srv, err = import_server.NewLayersServer(ctx, dockerImageName, tmpDir, layersSource)
 			if err != nil {
-				return fmt.Errorf("unable to run rsync import server: %s", err)
+				return fmt.Errorf("unable to prepare import server: %s", err)
 			}
 			return nil
 		}); err != nil {
@@ -257,6 +247,31 @@ func (c *Conveyor) GetImportServer(ctx context.Context, imageName, stageName str
 	return srv, nil
 }
 
+// getImportLayersSource reads the import source stage layers from the local docker server if the stage image exists there,
+// otherwise the layers blobs are read from the stages storage repo without pulling the image.
+func (c *Conveyor) getImportLayersSource(ctx context.Context, stg stage.Interface, dockerImageName string) (import_server.LayersSource, error) {
+	if localDockerServerRuntime, ok := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime); ok {
+		inspect, err := localDockerServerRuntime.GetImageInspect(ctx, dockerImageName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to inspect image %s: %s", dockerImageName, err)
+		}
+
+		if inspect != nil {
+			return import_server.NewDockerLayersSource(dockerImageName), nil
+		}
+	}
+
+	if repoStagesStorage, ok := c.StorageManager.GetStagesStorage().(*storage.RepoStagesStorage); ok {
+		return import_server.NewRegistryLayersSource(repoStagesStorage.DockerRegistry, dockerImageName), nil
+	}
+
+	if err := c.StorageManager.FetchStage(ctx, c.ContainerRuntime, stg); err != nil {
+		return nil, fmt.Errorf("unable to fetch stage %s: %s", stg.GetImage().Name(), err)
+	}
+
+	return import_server.NewDockerLayersSource(dockerImageName), nil
+}
+
 func (c *Conveyor) AppendOnTerminateFunc(f func() error) {
 	c.onTerminateFuncs = append(c.onTerminateFuncs, f)
 }
@@ -725,6 +740,7 @@ func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceCon
 	}
 
 	image.isArtifact = imageArtifact
+	image.disableStapelToolchain = imageBaseConfig.DisableStapelToolchain
 
 	err := initStages(ctx, image, imageInterfaceConfig, c)
 	if err != nil {
@@ -737,7 +753,13 @@ func prepareImageBasedOnStapelImageConfig(ctx context.Context, imageInterfaceCon
 func handleImageFromName(ctx context.Context, from string, fromLatest bool, image *Image, c *Conveyor) error {
 	image.baseImageName = from
 
-	if fromLatest {
+	if lockedReference, err := getLockedBaseImageReference(ctx, from, c); err != nil {
+		return err
+	} else if lockedReference != "" {
+		image.baseImageName = lockedReference
+	}
+
+	if fromLatest && from != "scratch" {
 		if _, err := image.getFromBaseImageIdFromRegistry(ctx, c, image.baseImageName); err != nil {
 			return err
 		}
@@ -794,11 +816,22 @@ func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.S
 	imageArtifact := imageInterfaceConfig.IsArtifact()
 
 	baseStageOptions := &stage.NewBaseStageOptions{
-		ImageName:        imageName,
-		ConfigMounts:     imageBaseConfig.Mount,
-		ImageTmpDir:      c.GetImageTmpDir(imageBaseConfig.Name),
-		ContainerWerfDir: c.containerWerfDir,
-		ProjectName:      c.werfConfig.Meta.Project,
+		ImageName:               imageName,
+		ConfigMounts:            imageBaseConfig.Mount,
+		ConfigSecrets:           imageBaseConfig.Secrets,
+		ConfigStageDependencies: imageBaseConfig.StageDependencies,
+		ImageTmpDir:             c.GetImageTmpDir(imageBaseConfig.Name),
+		ContainerWerfDir:        c.containerWerfDir,
+		ProjectName:             c.werfConfig.Meta.Project,
+		DisableStapelToolchain:  imageBaseConfig.DisableStapelToolchain,
+	}
+
+	if imageBaseConfig.Ansible != nil {
+		ansibleFiles, err := resolveAnsibleFiles(ctx, imageBaseConfig.Ansible, c)
+		if err != nil {
+			return fmt.Errorf("unable to prepare ansible files for image %q: %s", imageName, err)
+		}
+		baseStageOptions.AnsibleFiles = ansibleFiles
 	}
 
 	gitArchiveStageOptions := &stage.NewGitArchiveStageOptions{
@@ -819,6 +852,10 @@ func initStages(ctx context.Context, image *Image, imageInterfaceConfig config.S
 		return err
 	}
 
+	for _, gitMapping := range gitMappings {
+		gitMapping.DisableStapelToolchain = imageBaseConfig.DisableStapelToolchain
+	}
+
 	gitMappingsExist := len(gitMappings) != 0
 
 	stages = appendIfExist(ctx, stages, stage.GenerateFromStage(imageBaseConfig, image.baseImageRepoId, baseStageOptions))
@@ -897,8 +934,12 @@ func generateGitMappings(ctx context.Context, imageBaseConfig *config.StapelImag
 	for _, remoteGitMappingConfig := range imageBaseConfig.Git.Remote {
 		remoteGitRepo := c.GetRemoteGitRepo(remoteGitMappingConfig.Name)
 		if remoteGitRepo == nil {
-			var err error
-			remoteGitRepo, err = git_repo.OpenRemoteRepo(remoteGitMappingConfig.Name, remoteGitMappingConfig.Url)
+			remoteGitRepoOptions, err := GetRemoteGitRepoOptions(c.werfConfig, remoteGitMappingConfig.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			remoteGitRepo, err = git_repo.OpenRemoteRepo(remoteGitMappingConfig.Name, remoteGitMappingConfig.Url, remoteGitRepoOptions)
 			if err != nil {
 				return nil, fmt.Errorf("unable to open remote git repo %s by url %s: %s", remoteGitMappingConfig.Name, remoteGitMappingConfig.Url, err)
 			}
@@ -1033,6 +1074,22 @@ func gitRemoteArtifactInit(ctx context.Context, remoteGitMappingConfig *config.G
 	gitMapping.Commit = remoteGitMappingConfig.Commit
 	gitMapping.Branch = remoteGitMappingConfig.Branch
 
+	if gitMapping.Commit == "" {
+		lockedCommit, err := getLockedRemoteGitRepoCommit(ctx, remoteGitMappingConfig.Url, gitMapping.Branch, gitMapping.Tag, c)
+		if err != nil {
+			return nil, err
+		}
+
+		if lockedCommit == "" {
+			ref := (&werf_lock.GitRepo{Branch: gitMapping.Branch, Tag: gitMapping.Tag}).Ref()
+			if err := warnAboutNotLockedRemoteGitRepoRef(ctx, remoteGitMappingConfig.Url, ref, c); err != nil {
+				return nil, err
+			}
+		}
+
+		gitMapping.Commit = lockedCommit
+	}
+
 	gitMapping.Name = remoteGitMappingConfig.Name
 	gitMapping.RemoteGitRepo = remoteGitRepo
 
@@ -1072,6 +1129,7 @@ func baseGitMappingInit(local *config.GitLocalExport, imageName string, c *Conve
 	gitMapping.ContainerArchivesDir = getImageArchivesContainerDir(c)
 	gitMapping.ScriptsDir = getImageScriptsDir(imageName, c)
 	gitMapping.ContainerScriptsDir = getImageScriptsContainerDir(c)
+	gitMapping.LayersDir = getImageLayersDir(imageName, c)
 
 	gitMapping.Add = local.GitMappingAdd()
 	gitMapping.ExcludePaths = local.ExcludePaths
@@ -1115,6 +1173,10 @@ func getImageScriptsDir(imageName string, c *Conveyor) string {
 	return filepath.Join(c.tmpDir, imageName, "scripts")
 }
 
+func getImageLayersDir(imageName string, c *Conveyor) string {
+	return filepath.Join(c.tmpDir, imageName, "layers")
+}
+
 func getImageScriptsContainerDir(c *Conveyor) string {
 	return path.Join(c.containerWerfDir, "scripts")
 }
@@ -1156,8 +1218,29 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		}
 	}
 
+	var remoteContext *dockerfileRemoteContext
+	if imageFromDockerfileConfig.RemoteContext != nil {
+		var err error
+		if remoteContext, err = openDockerfileRemoteContext(ctx, imageFromDockerfileConfig.RemoteContext, c); err != nil {
+			return nil, err
+		}
+	}
+
+	// the paths are relative to the project git repository or to the remote git repository of the context
+	contextBasePath := filepath.Join(c.GiterminismManager().RelativeToGitProjectDir(), imageFromDockerfileConfig.Context)
+	if remoteContext != nil {
+		contextBasePath = filepath.Join(imageFromDockerfileConfig.Context)
+	}
+
 	relDockerfilePath := filepath.Join(imageFromDockerfileConfig.Context, imageFromDockerfileConfig.Dockerfile)
-	dockerfileData, err := c.giterminismManager.FileReader().ReadDockerfile(ctx, relDockerfilePath)
+
+	var dockerfileData []byte
+	var err error
+	if remoteContext != nil {
+		dockerfileData, err = remoteContext.readFile(ctx, relDockerfilePath)
+	} else {
+		dockerfileData, err = c.giterminismManager.FileReader().ReadDockerfile(ctx, relDockerfilePath)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1169,10 +1252,23 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		".dockerignore",
 	} {
 		relDockerignorePath = filepath.Join(imageFromDockerfileConfig.Context, relContextDockerignorePath)
-		if exist, err := c.giterminismManager.FileReader().IsDockerignoreExistAnywhere(ctx, relDockerignorePath); err != nil {
+
+		var exist bool
+		if remoteContext != nil {
+			exist, err = remoteContext.isFileExist(ctx, relDockerignorePath)
+		} else {
+			exist, err = c.giterminismManager.FileReader().IsDockerignoreExistAnywhere(ctx, relDockerignorePath)
+		}
+
+		if err != nil {
 			return nil, err
 		} else if exist {
-			dockerignoreData, err := c.giterminismManager.FileReader().ReadDockerignore(ctx, relDockerignorePath)
+			var dockerignoreData []byte
+			if remoteContext != nil {
+				dockerignoreData, err = remoteContext.readFile(ctx, relDockerignorePath)
+			} else {
+				dockerignoreData, err = c.giterminismManager.FileReader().ReadDockerignore(ctx, relDockerignorePath)
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -1188,7 +1284,7 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 	}
 
 	dockerignorePathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
-		BasePath:             filepath.Join(c.GiterminismManager().RelativeToGitProjectDir(), imageFromDockerfileConfig.Context),
+		BasePath:             contextBasePath,
 		DockerignorePatterns: dockerignorePatterns,
 	})
 
@@ -1199,7 +1295,7 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 
 		dockerignorePatterns = append(dockerignorePatterns, exceptionRule)
 		dockerignorePathMatcher = path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
-			BasePath:             filepath.Join(c.GiterminismManager().RelativeToGitProjectDir(), imageFromDockerfileConfig.Context),
+			BasePath:             contextBasePath,
 			DockerignorePatterns: dockerignorePatterns,
 		})
 	}
@@ -1214,8 +1310,6 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		return nil, err
 	}
 
-	resolveDockerStagesFromValue(dockerStages)
-
 	dockerTargetIndex, err := getDockerTargetStageIndex(dockerStages, imageFromDockerfileConfig.Target)
 	if err != nil {
 		return nil, err
@@ -1242,55 +1336,54 @@ func prepareImageBasedOnImageFromDockerfile(ctx context.Context, imageFromDocker
 		return nil, err
 	}
 
+	baseImageNames, err := ds.BaseImageNames()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, baseImageName := range baseImageNames {
+		if lockedReference, err := getLockedBaseImageReference(ctx, baseImageName, c); err != nil {
+			return nil, err
+		} else if lockedReference != "" {
+			ds.SetLockedBaseImage(baseImageName, lockedReference)
+		}
+	}
+
 	baseStageOptions := &stage.NewBaseStageOptions{
 		ImageName:   imageFromDockerfileConfig.Name,
 		ProjectName: c.werfConfig.Meta.Project,
 	}
 
-	dockerfileStage := stage.GenerateDockerfileStage(
-		stage.NewDockerRunArgs(
-			imageFromDockerfileConfig.Dockerfile,
-			imageFromDockerfileConfig.Target,
-			imageFromDockerfileConfig.Context,
-			imageFromDockerfileConfig.ContextAddFiles,
-			imageFromDockerfileConfig.Args,
-			imageFromDockerfileConfig.AddHost,
-			imageFromDockerfileConfig.Network,
-			imageFromDockerfileConfig.SSH,
-		),
-		ds,
-		stage.NewContextChecksum(dockerignorePathMatcher),
-		baseStageOptions,
+	dockerRunArgs := stage.NewDockerRunArgs(
+		imageFromDockerfileConfig.Dockerfile,
+		imageFromDockerfileConfig.Target,
+		imageFromDockerfileConfig.Context,
+		imageFromDockerfileConfig.ContextAddFiles,
+		imageFromDockerfileConfig.Args,
+		imageFromDockerfileConfig.AddHost,
+		imageFromDockerfileConfig.Network,
+		imageFromDockerfileConfig.SSH,
+		imageFromDockerfileConfig.Secrets,
 	)
 
-	img.stages = append(img.stages, dockerfileStage)
-
-	logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
+	if remoteContext != nil {
+		dockerRunArgs.SetRemoteContext(remoteContext.gitRepo, remoteContext.commit)
+	}
 
-	return img, nil
-}
+	var dockerfileStages []*stage.DockerfileStage
+	if imageFromDockerfileConfig.Staged {
+		dockerfileStages = stage.GenerateStagedDockerfileStages(dockerRunArgs, ds, stage.NewContextChecksum(dockerignorePathMatcher), dockerfileData, baseStageOptions)
+	} else {
+		dockerfileStages = append(dockerfileStages, stage.GenerateDockerfileStage(dockerRunArgs, ds, stage.NewContextChecksum(dockerignorePathMatcher), baseStageOptions))
+	}
 
-func resolveDockerStagesFromValue(stages []instructions.Stage) {
-	nameToIndex := make(map[string]string)
-	for i, s := range stages {
-		name := strings.ToLower(s.Name)
-		index := strconv.Itoa(i)
-		if name != index {
-			nameToIndex[name] = index
-		}
+	for _, dockerfileStage := range dockerfileStages {
+		img.stages = append(img.stages, dockerfileStage)
 
-		for _, cmd := range s.Commands {
-			switch c := cmd.(type) {
-			case *instructions.CopyCommand:
-				if c.From != "" {
-					from := strings.ToLower(c.From)
-					if val, ok := nameToIndex[from]; ok {
-						c.From = val
-					}
-				}
-			}
-		}
+		logboek.Context(ctx).Info().LogFDetails("Using stage %s\n", dockerfileStage.Name())
 	}
+
+	return img, nil
 }
 
 func getDockerTargetStageIndex(dockerStages []instructions.Stage, dockerTargetStage string) (int, error) {
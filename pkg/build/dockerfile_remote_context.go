@@ -0,0 +1,54 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+)
+
+// dockerfileRemoteContext is the commit of the remote git repository, which is the build context of the Dockerfile image.
+type dockerfileRemoteContext struct {
+	gitRepo *git_repo.Remote
+	commit  string
+}
+
+func openDockerfileRemoteContext(ctx context.Context, remoteContextConfig *config.DockerfileRemoteContext, c *Conveyor) (*dockerfileRemoteContext, error) {
+	remoteGitRepo, err := getOrOpenRemoteGitRepoByUrl(ctx, dockerfileRemoteContextBaseName(remoteContextConfig.Url), remoteContextConfig.Url, c)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := resolveRemoteGitRepoCommit(ctx, remoteGitRepo, remoteContextConfig.Ref, c.giterminismManager.Inspector().InspectConfigDockerfileRemoteContextBranch, c)
+	if err != nil {
+		return nil, err
+	}
+
+	logboek.Context(ctx).Info().LogF("Using remote git repository %s commit %s as the build context\n", remoteContextConfig.Url, commit)
+
+	return &dockerfileRemoteContext{gitRepo: remoteGitRepo, commit: commit}, nil
+}
+
+func (rc *dockerfileRemoteContext) isFileExist(ctx context.Context, relPath string) (bool, error) {
+	return rc.gitRepo.IsCommitFileExist(ctx, rc.commit, relPath)
+}
+
+func (rc *dockerfileRemoteContext) readFile(ctx context.Context, relPath string) ([]byte, error) {
+	if exist, err := rc.isFileExist(ctx, relPath); err != nil {
+		return nil, err
+	} else if !exist {
+		return nil, fmt.Errorf("the file %q not found in the remote git repository %s commit %s", relPath, rc.gitRepo.Url, rc.commit)
+	}
+
+	return rc.gitRepo.ReadCommitFile(ctx, rc.commit, relPath)
+}
+
+func dockerfileRemoteContextBaseName(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return path.Base(strings.Replace(url, ":", "/", -1))
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/werf/werf/pkg/build/stage"
 	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/logging"
@@ -40,6 +41,8 @@ type Image struct {
 	baseImageType    BaseImageType
 	stageAsBaseImage stage.Interface
 	baseImage        *container_runtime.StageImage
+
+	disableStapelToolchain bool
 }
 
 func (i *Image) LogName() string {
@@ -141,6 +144,10 @@ func (i *Image) GetBaseImage() *container_runtime.StageImage {
 func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	switch i.baseImageType {
 	case ImageFromRegistryAsBaseImage:
+		if i.baseImageName == "scratch" {
+			return i.fetchScratchBaseImage(ctx, c)
+		}
+
 		containerRuntime := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime)
 
 		if inspect, err := containerRuntime.GetImageInspect(ctx, i.baseImage.Name()); err != nil {
@@ -198,6 +205,35 @@ func (i *Image) FetchBaseImage(ctx context.Context, c *Conveyor) error {
 	return nil
 }
 
+// fetchScratchBaseImage uses the local empty image as the base image, because scratch cannot be pulled or inspected.
+func (i *Image) fetchScratchBaseImage(ctx context.Context, c *Conveyor) error {
+	containerRuntime := c.ContainerRuntime.(*container_runtime.LocalDockerServerRuntime)
+
+	inspect, err := containerRuntime.GetImageInspect(ctx, image.ScratchImageName)
+	if err != nil {
+		return fmt.Errorf("unable to inspect local image %s: %s", image.ScratchImageName, err)
+	}
+
+	if inspect == nil {
+		if err := docker.CreateImage(ctx, image.ScratchImageName, nil); err != nil {
+			return fmt.Errorf("unable to create image %s: %s", image.ScratchImageName, err)
+		}
+
+		if inspect, err = containerRuntime.GetImageInspect(ctx, image.ScratchImageName); err != nil {
+			return fmt.Errorf("unable to inspect local image %s: %s", image.ScratchImageName, err)
+		} else if inspect == nil {
+			return fmt.Errorf("unable to inspect local image %s after successful creation: image is not exists", image.ScratchImageName)
+		}
+	}
+
+	i.baseImage.SetStageDescription(&image.StageDescription{
+		StageID: nil, // this is not a stage actually, TODO
+		Info:    image.NewInfoFromInspect(i.baseImage.Name(), inspect),
+	})
+
+	return nil
+}
+
 func (i *Image) getFromBaseImageIdFromRegistry(ctx context.Context, c *Conveyor, baseImageName string) (string, error) {
 	c.getServiceRWMutex("baseImagesRepoIdsCache" + baseImageName).Lock()
 	defer c.getServiceRWMutex("baseImagesRepoIdsCache" + baseImageName).Unlock()
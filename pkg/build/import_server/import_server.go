@@ -7,5 +7,7 @@ import (
 )
 
 type ImportServer interface {
-	GetCopyCommand(ctx context.Context, importConfig *config.Import) string
+	// PrepareImportArchive returns the host path of the tar archive with the import files which should be unpacked into the root of the target stage container.
+	PrepareImportArchive(ctx context.Context, importConfig *config.Import) (string, error)
+	GetImportChecksum(ctx context.Context, importConfig *config.Import) (string, error)
 }
@@ -0,0 +1,511 @@
+package import_server
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/util"
+)
+
+const (
+	maxSymlinkHops = 255
+
+	whiteoutPrefix       = ".wh."
+	whiteoutOpaqueMarker = ".wh..wh..opq"
+)
+
+// LayersServer reads the import files from the layers of the source image without running any containers.
+// The uncompressed layers are stored on the host once and indexed as the flattened filesystem, all imports and checksums are assembled from the index reading only the selected files content.
+type LayersServer struct {
+	DockerImageName string
+	TmpDir          string
+
+	layersPath string
+	// entries of the flattened filesystem sorted by path so that every directory precedes its content
+	entries       []*layerEntry
+	entriesByName map[string]*layerEntry
+
+	mutex sync.Mutex
+}
+
+type layerEntry struct {
+	name   string
+	hdr    *tar.Header
+	layer  int
+	offset int64
+	// linkTarget is the entry with the content of the hard link from the same layer
+	linkTarget *layerEntry
+}
+
+type layerRegion struct {
+	offset, size int64
+}
+
+func NewLayersServer(ctx context.Context, dockerImageName string, tmpDir string, source LayersSource) (*LayersServer, error) {
+	logboek.Context(ctx).Debug().LogF("NewLayersServer for docker image %q\n", dockerImageName)
+
+	srv := &LayersServer{
+		DockerImageName: dockerImageName,
+		TmpDir:          tmpDir,
+		layersPath:      filepath.Join(tmpDir, "layers"),
+		entriesByName:   map[string]*layerEntry{},
+	}
+
+	diffIDs, err := source.GetDiffIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	regions, err := srv.storeLayers(ctx, diffIDs, source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to store docker image %s layers: %s", dockerImageName, err)
+	}
+
+	if err := srv.indexLayers(diffIDs, regions); err != nil {
+		return nil, fmt.Errorf("unable to read docker image %s layers: %s", dockerImageName, err)
+	}
+
+	return srv, nil
+}
+
+// storeLayers writes the uncompressed layers one after another into the layers file and returns their regions by diff id.
+func (srv *LayersServer) storeLayers(ctx context.Context, diffIDs []string, source LayersSource) (map[string]layerRegion, error) {
+	regions := map[string]layerRegion{}
+	for _, diffID := range diffIDs {
+		regions[diffID] = layerRegion{offset: -1}
+	}
+
+	f, err := os.Create(srv.layersPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int64
+	if err := source.ReadLayers(ctx, func(r io.Reader) error {
+		h := sha256.New()
+		size, err := io.Copy(io.MultiWriter(f, h), r)
+		if err != nil {
+			return err
+		}
+
+		digest := fmt.Sprintf("sha256:%x", h.Sum(nil))
+		if region, ok := regions[digest]; ok && region.offset == -1 {
+			regions[digest] = layerRegion{offset: offset, size: size}
+			offset += size
+			return nil
+		}
+
+		if err := f.Truncate(offset); err != nil {
+			return err
+		}
+
+		_, err = f.Seek(offset, io.SeekStart)
+		return err
+	}); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	for diffID, region := range regions {
+		if region.offset == -1 {
+			return nil, fmt.Errorf("layer %s not found", diffID)
+		}
+	}
+
+	return regions, nil
+}
+
+// indexLayers applies the layers from the base one to the top one taking into account the whiteout files.
+func (srv *LayersServer) indexLayers(diffIDs []string, regions map[string]layerRegion) error {
+	f, err := os.Open(srv.layersPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for ind, diffID := range diffIDs {
+		region := regions[diffID]
+		cr := &countingReader{r: io.NewSectionReader(f, region.offset, region.size)}
+		tr := tar.NewReader(cr)
+
+		layerEntries := map[string]*layerEntry{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("unable to read layer %s: %s", diffID, err)
+			}
+
+			name := normalizeName(hdr.Name)
+			if name == "" {
+				continue
+			}
+
+			dir, base := path.Split(name)
+			dir = strings.TrimSuffix(dir, "/")
+
+			switch {
+			case base == whiteoutOpaqueMarker:
+				srv.removeEntries(dir, ind)
+			case strings.HasPrefix(base, whiteoutPrefix):
+				srv.removeEntry(path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), ind)
+			default:
+				entry := &layerEntry{name: name, hdr: hdr, layer: ind, offset: region.offset + cr.n}
+
+				if hdr.Typeflag == tar.TypeLink {
+					entry.linkTarget = layerEntries[normalizeName(hdr.Linkname)]
+					if entry.linkTarget == nil {
+						return fmt.Errorf("hard link %s target %s not found in layer %s", hdr.Name, hdr.Linkname, diffID)
+					}
+				}
+
+				if hdr.Typeflag != tar.TypeDir {
+					srv.removeEntry(name, ind)
+				}
+
+				srv.entriesByName[name] = entry
+				layerEntries[name] = entry
+			}
+		}
+	}
+
+	for _, entry := range srv.entriesByName {
+		srv.entries = append(srv.entries, entry)
+	}
+
+	sort.Slice(srv.entries, func(i, j int) bool {
+		return lessName(srv.entries[i].name, srv.entries[j].name)
+	})
+
+	return nil
+}
+
+// removeEntry removes the entry and the directory content added by the layers below the layer if the entry is a directory.
+func (srv *LayersServer) removeEntry(name string, layer int) {
+	entry, ok := srv.entriesByName[name]
+	if !ok {
+		return
+	}
+
+	delete(srv.entriesByName, name)
+	if entry.hdr.Typeflag == tar.TypeDir {
+		srv.removeEntries(name, layer)
+	}
+}
+
+// removeEntries removes the entries inside the dir added by the layers below beforeLayer.
+func (srv *LayersServer) removeEntries(dir string, beforeLayer int) {
+	for name, entry := range srv.entriesByName {
+		if entry.layer < beforeLayer && (dir == "" || strings.HasPrefix(name, dir+"/")) {
+			delete(srv.entriesByName, name)
+		}
+	}
+}
+
+// PrepareImportArchive builds the archive with the files selected by add, includePaths and excludePaths, renamed to the import destination and owned by the import owner and group.
+// Owner and group names are resolved against the target stage container users when the archive is unpacked.
+func (srv *LayersServer) PrepareImportArchive(ctx context.Context, importConfig *config.Import) (string, error) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	archivePath := filepath.Join(srv.TmpDir, "archives", fmt.Sprintf("%s.tar", getImportArchiveID(importConfig)))
+	if exist, err := util.FileExists(archivePath); err != nil {
+		return "", err
+	} else if exist {
+		return archivePath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), os.ModePerm); err != nil {
+		return "", fmt.Errorf("unable to create dir %s: %s", filepath.Dir(archivePath), err)
+	}
+
+	tmpArchivePath := archivePath + ".tmp"
+	f, err := os.Create(tmpArchivePath)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmpArchivePath)
+
+	if err := srv.writeImportArchive(importConfig, f); err != nil {
+		f.Close()
+		return "", fmt.Errorf("unable to prepare import archive for add %s: %s", importConfig.Add, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmpArchivePath, archivePath); err != nil {
+		return "", err
+	}
+
+	logboek.Context(ctx).Debug().LogF("Import archive for image %q add=%s to=%s includePaths=%v excludePaths=%v: %s\n", srv.DockerImageName, importConfig.Add, importConfig.To, importConfig.IncludePaths, importConfig.ExcludePaths, archivePath)
+
+	return archivePath, nil
+}
+
+func (srv *LayersServer) writeImportArchive(importConfig *config.Import, w io.Writer) error {
+	layers, err := os.Open(srv.layersPath)
+	if err != nil {
+		return err
+	}
+	defer layers.Close()
+
+	tw := tar.NewWriter(w)
+
+	writeEntry := func(entry *layerEntry, hdr *tar.Header) error {
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, io.NewSectionReader(layers, entry.offset, entry.hdr.Size)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// entry -> archive name of the imported entries for rewriting hard links
+	importedNames := map[*layerEntry]string{}
+	var linkEntries []*layerEntry
+	var linkHeaders []*tar.Header
+
+	if err := srv.walkImport(importConfig, func(entry *layerEntry, relPath string) error {
+		importHdr := newImportHeader(entry.hdr, importConfig, path.Join(importConfig.To, relPath))
+
+		if entry.hdr.Typeflag == tar.TypeLink {
+			linkEntries = append(linkEntries, entry)
+			linkHeaders = append(linkHeaders, importHdr)
+			return nil
+		}
+
+		importedNames[entry] = importHdr.Name
+
+		return writeEntry(entry, importHdr)
+	}); err != nil {
+		return err
+	}
+
+	// Hard links may precede their targets or point to the files which are not imported, so they are written after all other entries.
+	// The first link to the file which is not imported is turned into the regular file, the rest ones are linked to it.
+	for ind, linkEntry := range linkEntries {
+		linkHdr := linkHeaders[ind]
+
+		if archiveName, ok := importedNames[linkEntry.linkTarget]; ok {
+			linkHdr.Linkname = archiveName
+			if err := tw.WriteHeader(linkHdr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fileHdr := newImportHeader(linkEntry.linkTarget.hdr, importConfig, linkHdr.Name)
+		if err := writeEntry(linkEntry.linkTarget, fileHdr); err != nil {
+			return err
+		}
+
+		importedNames[linkEntry.linkTarget] = fileHdr.Name
+	}
+
+	return tw.Close()
+}
+
+// GetImportChecksum calculates the checksum of the import files content, symlinks and hard links.
+func (srv *LayersServer) GetImportChecksum(ctx context.Context, importConfig *config.Import) (string, error) {
+	layers, err := os.Open(srv.layersPath)
+	if err != nil {
+		return "", err
+	}
+	defer layers.Close()
+
+	var records []string
+	if err := srv.walkImport(importConfig, func(entry *layerEntry, relPath string) error {
+		switch entry.hdr.Typeflag {
+		case tar.TypeReg:
+			h := sha256.New()
+			if _, err := io.Copy(h, io.NewSectionReader(layers, entry.offset, entry.hdr.Size)); err != nil {
+				return err
+			}
+			records = append(records, fmt.Sprintf("%s:%x", relPath, h.Sum(nil)))
+		case tar.TypeSymlink:
+			records = append(records, fmt.Sprintf("%s:symlink:%s", relPath, entry.hdr.Linkname))
+		case tar.TypeLink:
+			records = append(records, fmt.Sprintf("%s:link:%s", relPath, entry.linkTarget.name))
+		}
+
+		return nil
+	}); err != nil {
+		return "", fmt.Errorf("unable to calculate import checksum for add %s: %s", importConfig.Add, err)
+	}
+
+	sort.Strings(records)
+
+	checksum := util.Sha256Hash(records...)
+	logboek.Context(ctx).Debug().LogF("Import checksum for image %q add=%s includePaths=%v excludePaths=%v: %s\n", srv.DockerImageName, importConfig.Add, importConfig.IncludePaths, importConfig.ExcludePaths, checksum)
+
+	return checksum, nil
+}
+
+// walkImport calls f for the add entry and the matched entries inside the add directory, relPath is relative to the add path.
+func (srv *LayersServer) walkImport(importConfig *config.Import, f func(entry *layerEntry, relPath string) error) error {
+	addName, err := srv.resolveName(importConfig.Add)
+	if err != nil {
+		return err
+	}
+
+	matcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
+		IncludeGlobs: importConfig.IncludePaths,
+		ExcludeGlobs: importConfig.ExcludePaths,
+	})
+
+	if addName != "" {
+		entry, ok := srv.entriesByName[addName]
+		if !ok {
+			return fmt.Errorf("path %s not found in image %s", importConfig.Add, srv.DockerImageName)
+		}
+
+		if err := f(entry, ""); err != nil {
+			return err
+		}
+	}
+
+	// the entries inside the add directory follow it in the sorted list
+	for ind := sort.Search(len(srv.entries), func(i int) bool { return !lessName(srv.entries[i].name, addName) }); ind < len(srv.entries); ind++ {
+		entry := srv.entries[ind]
+		if entry.name == addName {
+			continue
+		}
+
+		if addName != "" && !strings.HasPrefix(entry.name, addName+"/") {
+			break
+		}
+
+		relPath := strings.TrimPrefix(strings.TrimPrefix(entry.name, addName), "/")
+		if entry.hdr.Typeflag == tar.TypeDir {
+			if !matcher.IsDirOrSubmodulePathMatched(relPath) {
+				continue
+			}
+		} else if !matcher.IsPathMatched(relPath) {
+			continue
+		}
+
+		if err := f(entry, relPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveName resolves symlinks in all components of the absolute path within the image filesystem.
+func (srv *LayersServer) resolveName(p string) (string, error) {
+	parts := strings.Split(normalizeName(p), "/")
+
+	var resolved string
+	var hops int
+	for ind := 0; ind < len(parts); ind++ {
+		if parts[ind] == "" {
+			continue
+		}
+
+		candidate := path.Join(resolved, parts[ind])
+		entry, ok := srv.entriesByName[candidate]
+		if !ok || entry.hdr.Typeflag != tar.TypeSymlink {
+			resolved = candidate
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("too many levels of symbolic links in path %s", p)
+		}
+
+		linkname := entry.hdr.Linkname
+		if !path.IsAbs(linkname) {
+			linkname = path.Join("/", resolved, linkname)
+		}
+
+		parts = append(strings.Split(normalizeName(linkname), "/"), parts[ind+1:]...)
+		resolved = ""
+		ind = -1
+	}
+
+	return resolved, nil
+}
+
+func newImportHeader(hdr *tar.Header, importConfig *config.Import, name string) *tar.Header {
+	importHdr := *hdr
+	importHdr.Name = normalizeName(name)
+	importHdr.Format = tar.FormatUnknown
+
+	if importConfig.Owner != "" {
+		if uid, err := strconv.Atoi(importConfig.Owner); err == nil {
+			importHdr.Uid, importHdr.Uname = uid, ""
+		} else {
+			importHdr.Uname = importConfig.Owner
+		}
+	}
+
+	if importConfig.Group != "" {
+		if gid, err := strconv.Atoi(importConfig.Group); err == nil {
+			importHdr.Gid, importHdr.Gname = gid, ""
+		} else {
+			importHdr.Gname = importConfig.Group
+		}
+	}
+
+	return &importHdr
+}
+
+func normalizeName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// lessName compares the names by path components so that the directory precedes its content.
+func lessName(a, b string) bool {
+	return strings.ReplaceAll(a, "/", "\x00") < strings.ReplaceAll(b, "/", "\x00")
+}
+
+func getImportArchiveID(importConfig *config.Import) string {
+	return util.Sha256Hash(
+		"Add", importConfig.Add,
+		"To", importConfig.To,
+		"Group", importConfig.Group,
+		"Owner", importConfig.Owner,
+		"IncludePaths", strings.Join(importConfig.IncludePaths, "///"),
+		"ExcludePaths", strings.Join(importConfig.ExcludePaths, "///"),
+	)
+}
+
+// countingReader counts the bytes read, tar.Reader reads the archive by blocks without buffering, so the count is the current entry content offset.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
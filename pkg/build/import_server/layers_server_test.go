@@ -0,0 +1,364 @@
+package import_server
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+var _ = Describe("LayersServer", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-import-server-test-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	newServer := func(layers ...[]testEntry) *LayersServer {
+		srv, err := NewLayersServer(context.Background(), "image", tmpDir, newLayersSourceStub(layers...))
+		Ω(err).ShouldNot(HaveOccurred())
+		return srv
+	}
+
+	importFiles := func(srv *LayersServer, importConfig *config.Import) map[string]string {
+		archivePath, err := srv.PrepareImportArchive(context.Background(), importConfig)
+		Ω(err).ShouldNot(HaveOccurred())
+		return readTestArchive(archivePath)
+	}
+
+	baseLayer := []testEntry{
+		{name: "usr/"},
+		{name: "usr/lib/"},
+		{name: "usr/lib/app/"},
+		{name: "usr/lib/app/main", content: "main"},
+		{name: "usr/lib/app/README.md", content: "readme"},
+		{name: "usr/lib/app/docs/"},
+		{name: "usr/lib/app/docs/index.md", content: "index"},
+		{name: "lib", linkname: "usr/lib", typeflag: tar.TypeSymlink},
+		{name: "app", linkname: "/lib/app", typeflag: tar.TypeSymlink},
+		{name: "current", linkname: "app", typeflag: tar.TypeSymlink},
+		{name: "loop", linkname: "loop", typeflag: tar.TypeSymlink},
+	}
+
+	DescribeTable("resolveName resolves symlinks in all path components",
+		func(p, expected string) {
+			srv := newServer(baseLayer)
+
+			resolved, err := srv.resolveName(p)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(resolved).Should(Equal(expected))
+		},
+		Entry("regular path", "/usr/lib/app", "usr/lib/app"),
+		Entry("relative symlink", "/lib/app/main", "usr/lib/app/main"),
+		Entry("absolute symlink to the path with symlink", "/app/docs", "usr/lib/app/docs"),
+		Entry("symlink to symlink", "/current", "usr/lib/app"),
+		Entry("not existing path", "/usr/share", "usr/share"),
+		Entry("root", "/", ""),
+	)
+
+	It("fails resolving the symlinks loop", func() {
+		srv := newServer(baseLayer)
+
+		_, err := srv.resolveName("/loop/file")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	DescribeTable("PrepareImportArchive selects files by add, includePaths and excludePaths",
+		func(importConfig *config.Import, expected map[string]string) {
+			srv := newServer(baseLayer)
+			Ω(importFiles(srv, importConfig)).Should(Equal(expected))
+		},
+		Entry("directory",
+			newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app"}),
+			map[string]string{
+				"app/":              "dir",
+				"app/main":          "main",
+				"app/README.md":     "readme",
+				"app/docs/":         "dir",
+				"app/docs/index.md": "index",
+			},
+		),
+		Entry("add through symlink",
+			newTestImport(config.ExportBase{Add: "/current/docs", To: "/docs"}),
+			map[string]string{
+				"docs/":         "dir",
+				"docs/index.md": "index",
+			},
+		),
+		Entry("include paths",
+			newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app", IncludePaths: []string{"**/*.md"}}),
+			map[string]string{
+				"app/":              "dir",
+				"app/README.md":     "readme",
+				"app/docs/":         "dir",
+				"app/docs/index.md": "index",
+			},
+		),
+		Entry("exclude paths",
+			newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app", ExcludePaths: []string{"docs", "*.md"}}),
+			map[string]string{
+				"app/":     "dir",
+				"app/main": "main",
+			},
+		),
+		Entry("include and exclude paths",
+			newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app", IncludePaths: []string{"docs"}, ExcludePaths: []string{"docs/index.md"}}),
+			map[string]string{
+				"app/":      "dir",
+				"app/docs/": "dir",
+			},
+		),
+		Entry("file",
+			newTestImport(config.ExportBase{Add: "/usr/lib/app/main", To: "/bin/main"}),
+			map[string]string{
+				"bin/main": "main",
+			},
+		),
+	)
+
+	It("fails if add path does not exist", func() {
+		srv := newServer(baseLayer)
+
+		_, err := srv.PrepareImportArchive(context.Background(), newTestImport(config.ExportBase{Add: "/usr/share", To: "/share"}))
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("sets owner and group", func() {
+		srv := newServer(baseLayer)
+
+		archivePath, err := srv.PrepareImportArchive(context.Background(), newTestImport(config.ExportBase{Add: "/usr/lib/app/main", To: "/main", Owner: "app", Group: "1000"}))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		hdrs := readTestArchiveHeaders(archivePath)
+		Ω(hdrs).Should(HaveLen(1))
+		Ω(hdrs[0].Uname).Should(Equal("app"))
+		Ω(hdrs[0].Gid).Should(Equal(1000))
+		Ω(hdrs[0].Gname).Should(BeEmpty())
+	})
+
+	Context("hard links", func() {
+		layer := []testEntry{
+			{name: "data/"},
+			{name: "data/a/"},
+			{name: "data/a/file", content: "content"},
+			{name: "data/b/"},
+			{name: "data/b/link1", linkname: "data/a/file", typeflag: tar.TypeLink},
+			{name: "data/b/link2", linkname: "data/a/file", typeflag: tar.TypeLink},
+		}
+
+		It("keeps the link to the imported file", func() {
+			srv := newServer(layer)
+
+			Ω(importFiles(srv, newTestImport(config.ExportBase{Add: "/data", To: "/data"}))).Should(Equal(map[string]string{
+				"data/":        "dir",
+				"data/a/":      "dir",
+				"data/a/file":  "content",
+				"data/b/":      "dir",
+				"data/b/link1": "link:data/a/file",
+				"data/b/link2": "link:data/a/file",
+			}))
+		})
+
+		It("turns the first link to the file which is not imported into the regular file", func() {
+			srv := newServer(layer)
+
+			Ω(importFiles(srv, newTestImport(config.ExportBase{Add: "/data/b", To: "/b"}))).Should(Equal(map[string]string{
+				"b/":      "dir",
+				"b/link1": "content",
+				"b/link2": "link:b/link1",
+			}))
+		})
+
+		It("takes the links into account in the checksum", func() {
+			srv := newServer(layer)
+			checksum, err := srv.GetImportChecksum(context.Background(), newTestImport(config.ExportBase{Add: "/data"}))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+			Ω(os.MkdirAll(tmpDir, os.ModePerm)).Should(Succeed())
+
+			srv = newServer(append(layer[:len(layer)-1:len(layer)-1], testEntry{name: "data/b/link2", content: "content"}))
+			otherChecksum, err := srv.GetImportChecksum(context.Background(), newTestImport(config.ExportBase{Add: "/data"}))
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(otherChecksum).ShouldNot(Equal(checksum))
+		})
+	})
+
+	It("applies the upper layers and whiteouts", func() {
+		srv := newServer(
+			baseLayer,
+			[]testEntry{
+				{name: "usr/lib/app/main", content: "main v2"},
+				{name: "usr/lib/app/.wh.README.md"},
+				{name: "usr/lib/app/docs/.wh..wh..opq"},
+				{name: "usr/lib/app/docs/new.md", content: "new"},
+			},
+		)
+
+		Ω(importFiles(srv, newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app"}))).Should(Equal(map[string]string{
+			"app/":            "dir",
+			"app/main":        "main v2",
+			"app/docs/":       "dir",
+			"app/docs/new.md": "new",
+		}))
+	})
+
+	It("removes the directory content replaced with the file", func() {
+		srv := newServer(baseLayer, []testEntry{{name: "usr/lib/app/docs", content: "docs"}})
+
+		Ω(importFiles(srv, newTestImport(config.ExportBase{Add: "/usr/lib/app", To: "/app", IncludePaths: []string{"docs*"}}))).Should(Equal(map[string]string{
+			"app/":     "dir",
+			"app/docs": "docs",
+		}))
+	})
+
+	It("calculates the same checksum for the same files in different layers", func() {
+		srv := newServer(baseLayer)
+		checksum, err := srv.GetImportChecksum(context.Background(), newTestImport(config.ExportBase{Add: "/usr/lib/app"}))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+		Ω(os.MkdirAll(tmpDir, os.ModePerm)).Should(Succeed())
+
+		srv = newServer(baseLayer[:4], baseLayer[4:])
+		otherChecksum, err := srv.GetImportChecksum(context.Background(), newTestImport(config.ExportBase{Add: "/usr/lib/app"}))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(otherChecksum).Should(Equal(checksum))
+	})
+})
+
+type testEntry struct {
+	name     string
+	content  string
+	linkname string
+	typeflag byte
+}
+
+type layersSourceStub struct {
+	layers [][]byte
+}
+
+// newLayersSourceStub returns the layers in the reverse order along with the stream which is not a layer.
+func newLayersSourceStub(layers ...[]testEntry) *layersSourceStub {
+	stub := &layersSourceStub{}
+	for _, entries := range layers {
+		stub.layers = append(stub.layers, newTestLayer(entries))
+	}
+
+	return stub
+}
+
+func (s *layersSourceStub) GetDiffIDs(_ context.Context) ([]string, error) {
+	var diffIDs []string
+	for _, layer := range s.layers {
+		diffIDs = append(diffIDs, fmt.Sprintf("sha256:%x", sha256.Sum256(layer)))
+	}
+
+	return diffIDs, nil
+}
+
+func (s *layersSourceStub) ReadLayers(_ context.Context, f func(r io.Reader) error) error {
+	if err := f(bytes.NewReader([]byte(`{"config": "json"}`))); err != nil {
+		return err
+	}
+
+	for ind := len(s.layers) - 1; ind >= 0; ind-- {
+		if err := f(bytes.NewReader(s.layers[ind])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newTestLayer(entries []testEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Linkname: entry.linkname, Typeflag: entry.typeflag, Mode: 0o644}
+		switch {
+		case entry.typeflag != 0:
+		case entry.name[len(entry.name)-1] == '/':
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0o755
+		default:
+			hdr.Typeflag, hdr.Size = tar.TypeReg, int64(len(entry.content))
+		}
+
+		Ω(tw.WriteHeader(hdr)).Should(Succeed())
+		_, err := tw.Write([]byte(entry.content))
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+
+	Ω(tw.Close()).Should(Succeed())
+
+	return buf.Bytes()
+}
+
+func readTestArchiveHeaders(archivePath string) []*tar.Header {
+	f, err := os.Open(archivePath)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+
+	var hdrs []*tar.Header
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return hdrs
+		}
+		Ω(err).ShouldNot(HaveOccurred())
+
+		hdrs = append(hdrs, hdr)
+	}
+}
+
+// readTestArchive returns the archive entries content, "dir" for directories and "link:<linkname>" for hard links.
+func readTestArchive(archivePath string) map[string]string {
+	f, err := os.Open(archivePath)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+
+	res := map[string]string{}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return res
+		}
+		Ω(err).ShouldNot(HaveOccurred())
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			res[hdr.Name+"/"] = "dir"
+		case tar.TypeLink:
+			res[hdr.Name] = "link:" + hdr.Linkname
+		default:
+			content, err := ioutil.ReadAll(tr)
+			Ω(err).ShouldNot(HaveOccurred())
+			res[hdr.Name] = string(content)
+		}
+	}
+}
+
+func newTestImport(exportBase config.ExportBase) *config.Import {
+	return &config.Import{ArtifactExport: &config.ArtifactExport{ExportBase: &exportBase}}
+}
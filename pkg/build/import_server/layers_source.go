@@ -0,0 +1,134 @@
+package import_server
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+// LayersSource provides the uncompressed layers of the import source image.
+type LayersSource interface {
+	// GetDiffIDs returns the uncompressed layers digests from the base layer to the top one.
+	GetDiffIDs(ctx context.Context) ([]string, error)
+	// ReadLayers calls f for the uncompressed layers in any order, the streams which are not the image layers are skipped by the digest.
+	ReadLayers(ctx context.Context, f func(r io.Reader) error) error
+}
+
+// DockerLayersSource reads the layers of the image stored in the local docker server.
+type DockerLayersSource struct {
+	DockerImageName string
+}
+
+func NewDockerLayersSource(dockerImageName string) *DockerLayersSource {
+	return &DockerLayersSource{DockerImageName: dockerImageName}
+}
+
+func (s *DockerLayersSource) GetDiffIDs(ctx context.Context) ([]string, error) {
+	inspect, err := docker.ImageInspect(ctx, s.DockerImageName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to inspect image %s: %s", s.DockerImageName, err)
+	}
+
+	return inspect.RootFS.Layers, nil
+}
+
+// ReadLayers streams the image in the docker save format and passes all regular files of the archive to f.
+// Both the legacy layout (<id>/layer.tar) and the OCI layout (blobs/sha256/<digest>) keep the layers uncompressed.
+func (s *DockerLayersSource) ReadLayers(ctx context.Context, f func(r io.Reader) error) error {
+	rc, err := docker.ImageSaveReader(ctx, s.DockerImageName)
+	if err != nil {
+		return fmt.Errorf("unable to save image %s: %s", s.DockerImageName, err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read image %s archive: %s", s.DockerImageName, err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err := f(tr); err != nil {
+			return err
+		}
+	}
+}
+
+// RegistryLayersSource reads the layers blobs of the image from the container registry without pulling the image.
+type RegistryLayersSource struct {
+	DockerRegistry docker_registry.DockerRegistry
+	Reference      string
+
+	layers []v1.Layer
+}
+
+func NewRegistryLayersSource(dockerRegistry docker_registry.DockerRegistry, reference string) *RegistryLayersSource {
+	return &RegistryLayersSource{DockerRegistry: dockerRegistry, Reference: reference}
+}
+
+func (s *RegistryLayersSource) GetDiffIDs(ctx context.Context) ([]string, error) {
+	layers, err := s.getLayers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffIDs []string
+	for _, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get image %s layer diff id: %s", s.Reference, err)
+		}
+
+		diffIDs = append(diffIDs, diffID.String())
+	}
+
+	return diffIDs, nil
+}
+
+func (s *RegistryLayersSource) ReadLayers(ctx context.Context, f func(r io.Reader) error) error {
+	layers, err := s.getLayers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range layers {
+		if err := func() error {
+			rc, err := layer.Uncompressed()
+			if err != nil {
+				return fmt.Errorf("unable to read image %s layer: %s", s.Reference, err)
+			}
+			defer rc.Close()
+
+			return f(rc)
+		}(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *RegistryLayersSource) getLayers(ctx context.Context) ([]v1.Layer, error) {
+	if s.layers == nil {
+		layers, err := s.DockerRegistry.GetRepoImageLayers(ctx, s.Reference)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get image %s layers: %s", s.Reference, err)
+		}
+
+		s.layers = layers
+	}
+
+	return s.layers, nil
+}
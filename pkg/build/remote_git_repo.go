@@ -0,0 +1,184 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/util"
+)
+
+// GetRemoteGitRepoOptions returns options of the remote git repository shared by all remote git mappings with the name.
+// Sparse checkout patterns of the partial clone cover the files of all these mappings.
+func GetRemoteGitRepoOptions(werfConfig *config.WerfConfig, name string) (git_repo.RemoteOptions, error) {
+	var opts git_repo.RemoteOptions
+	var gitConfigs []*config.GitRemote
+
+	for _, imageBaseConfig := range getStapelImageBaseConfigs(werfConfig) {
+		if imageBaseConfig.Git == nil {
+			continue
+		}
+
+		for _, gitConfig := range imageBaseConfig.Git.Remote {
+			if gitConfig.Name == name {
+				gitConfigs = append(gitConfigs, gitConfig)
+			}
+		}
+	}
+
+	for ind, gitConfig := range gitConfigs {
+		if ind == 0 {
+			opts.PartialClone = gitConfig.PartialClone
+		} else if opts.PartialClone != gitConfig.PartialClone {
+			return git_repo.RemoteOptions{}, fmt.Errorf("all remote git mappings of repository %s should have the same partialClone value", name)
+		}
+
+		if gitConfig.Auth != nil && opts.Token == "" {
+			token, err := gitConfig.Auth.GetToken()
+			if err != nil {
+				return git_repo.RemoteOptions{}, fmt.Errorf("unable to get remote git repository %s credentials: %s", name, err)
+			}
+
+			opts.Username = gitConfig.Auth.Username
+			opts.Token = token
+		}
+
+		if gitConfig.PartialClone {
+			opts.SparseCheckoutPatterns = util.AddNewStringsToStringArray(opts.SparseCheckoutPatterns, getSparseCheckoutPatterns(gitConfig)...)
+		}
+	}
+
+	// the whole work tree is checked out anyway
+	if util.IsStringsContainValue(opts.SparseCheckoutPatterns, "/*") {
+		opts.SparseCheckoutPatterns = []string{"/*"}
+	}
+
+	return opts, nil
+}
+
+func getSparseCheckoutPatterns(gitConfig *config.GitRemote) []string {
+	add := strings.Trim(path.Clean("/"+gitConfig.Add), "/")
+
+	addPattern := "/*"
+	if add != "" {
+		addPattern = "/" + add
+	}
+
+	var patterns []string
+	for _, includePath := range gitConfig.IncludePaths {
+		// werf globs do not match gitignore-like sparse checkout patterns
+		if strings.ContainsAny(includePath, "*?[{") {
+			return []string{addPattern}
+		}
+
+		patterns = append(patterns, "/"+strings.Trim(path.Join(add, includePath), "/"))
+	}
+
+	if len(patterns) == 0 {
+		return []string{addPattern}
+	}
+
+	return patterns
+}
+
+func getStapelImageBaseConfigs(werfConfig *config.WerfConfig) []*config.StapelImageBase {
+	var result []*config.StapelImageBase
+	for _, imageConfig := range werfConfig.StapelImages {
+		result = append(result, imageConfig.StapelImageBase)
+	}
+
+	for _, artifactConfig := range werfConfig.Artifacts {
+		result = append(result, artifactConfig.StapelImageBase)
+	}
+
+	return result
+}
+
+// getOrOpenRemoteGitRepoByUrl returns the remote git repository by url, the repository is cloned or fetched once per conveyor.
+func getOrOpenRemoteGitRepoByUrl(ctx context.Context, name, url string, c *Conveyor) (*git_repo.Remote, error) {
+	if remoteGitRepo := c.GetRemoteGitRepo(url); remoteGitRepo != nil {
+		return remoteGitRepo, nil
+	}
+
+	remoteGitRepo, err := git_repo.OpenRemoteRepo(name, url, git_repo.RemoteOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open remote git repo by url %s: %s", url, err)
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Refreshing %s repository", url).DoError(func() error {
+		return remoteGitRepo.CloneAndFetch(ctx)
+	}); err != nil {
+		return nil, err
+	}
+
+	c.SetRemoteGitRepo(url, remoteGitRepo)
+
+	return remoteGitRepo, nil
+}
+
+// ResolveRemoteGitRepoCommit opens the remote git repository by url and resolves the reference (the default branch if empty) to the commit.
+func (c *Conveyor) ResolveRemoteGitRepoCommit(ctx context.Context, url, ref string, inspectBranchFunc func() error) (string, error) {
+	remoteGitRepo, err := getOrOpenRemoteGitRepoByUrl(ctx, dockerfileRemoteContextBaseName(url), url, c)
+	if err != nil {
+		return "", err
+	}
+
+	return resolveRemoteGitRepoCommit(ctx, remoteGitRepo, ref, inspectBranchFunc, c)
+}
+
+// resolveRemoteGitRepoCommit resolves the reference, which is a tag, a branch or a full or abbreviated commit (the default branch if empty).
+// Branches are checked by inspectBranchFunc, the giterminism check of the directive.
+// Tags and branches locked in werf.lock are resolved to the locked commits.
+func resolveRemoteGitRepoCommit(ctx context.Context, remoteGitRepo *git_repo.Remote, ref string, inspectBranchFunc func() error, c *Conveyor) (string, error) {
+	if ref == "" {
+		if err := inspectBranchFunc(); err != nil {
+			return "", err
+		}
+
+		if lockedCommit, err := getLockedRemoteGitRepoCommit(ctx, remoteGitRepo.Url, "", "", c); err != nil || lockedCommit != "" {
+			return lockedCommit, err
+		}
+
+		if err := warnAboutNotLockedRemoteGitRepoRef(ctx, remoteGitRepo.Url, "HEAD", c); err != nil {
+			return "", err
+		}
+
+		return remoteGitRepo.HeadCommit(ctx)
+	}
+
+	if lockedCommit, err := getLockedRemoteGitRepoCommit(ctx, remoteGitRepo.Url, "", ref, c); err != nil || lockedCommit != "" {
+		return lockedCommit, err
+	}
+
+	if lockedCommit, err := getLockedRemoteGitRepoCommit(ctx, remoteGitRepo.Url, ref, "", c); err != nil {
+		return "", err
+	} else if lockedCommit != "" {
+		if err := inspectBranchFunc(); err != nil {
+			return "", err
+		}
+
+		return lockedCommit, nil
+	}
+
+	if err := warnAboutNotLockedRemoteGitRepoRef(ctx, remoteGitRepo.Url, ref, c); err != nil {
+		return "", err
+	}
+
+	commit, refType, err := remoteGitRepo.ResolveReference(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	if refType == git_repo.BranchReference {
+		if err := inspectBranchFunc(); err != nil {
+			return "", err
+		}
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,146 @@
+package build
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/git_repo"
+)
+
+func newGitRemoteConfig(name, add string, includePaths []string, partialClone bool) *config.GitRemote {
+	return &config.GitRemote{
+		Name:         name,
+		Url:          "https://example.com/group/" + name + ".git",
+		PartialClone: partialClone,
+		GitRemoteExport: &config.GitRemoteExport{
+			GitLocalExport: &config.GitLocalExport{
+				GitExportBase: &config.GitExportBase{
+					GitExport: &config.GitExport{
+						ExportBase: &config.ExportBase{Add: add, To: "/app", IncludePaths: includePaths},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newStapelImageBaseConfig(gitConfigs ...*config.GitRemote) *config.StapelImageBase {
+	return &config.StapelImageBase{Git: &config.GitManager{Remote: gitConfigs}}
+}
+
+var _ = Describe("getSparseCheckoutPatterns", func() {
+	DescribeTable("should convert add and includePaths into sparse checkout patterns",
+		func(add string, includePaths []string, expected []string) {
+			Expect(getSparseCheckoutPatterns(newGitRemoteConfig("repo", add, includePaths, true))).To(Equal(expected))
+		},
+		Entry("the whole repository", "/", nil, []string{"/*"}),
+		Entry("the empty add", "", nil, []string{"/*"}),
+		Entry("the subdirectory", "/src/app/", nil, []string{"/src/app"}),
+		Entry("the not clean add", "/src/../lib/./", nil, []string{"/lib"}),
+		Entry("include paths of the whole repository", "/", []string{"go.mod", "pkg/"}, []string{"/go.mod", "/pkg"}),
+		Entry("include paths of the subdirectory", "/src", []string{"main.go", "/internal/"}, []string{"/src/main.go", "/src/internal"}),
+		Entry("include paths with globs", "/src", []string{"main.go", "**/*.go"}, []string{"/src"}),
+		Entry("include paths with globs of the whole repository", "/", []string{"pkg/{a,b}"}, []string{"/*"}),
+	)
+})
+
+var _ = Describe("GetRemoteGitRepoOptions", func() {
+	const tokenEnv = "WERF_TEST_REMOTE_GIT_REPO_TOKEN"
+
+	AfterEach(func() {
+		Expect(os.Unsetenv(tokenEnv)).To(Succeed())
+	})
+
+	It("should collect sparse checkout patterns of all images and artifacts", func() {
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/src", nil, true), newGitRemoteConfig("other", "/", nil, false))},
+				{StapelImageBase: &config.StapelImageBase{}},
+			},
+			Artifacts: []*config.StapelImageArtifact{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/docs", []string{"index.md"}, true), newGitRemoteConfig("repo", "/src", nil, true))},
+			},
+		}
+
+		opts, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(Succeed())
+		Expect(opts).To(Equal(git_repo.RemoteOptions{
+			PartialClone:           true,
+			SparseCheckoutPatterns: []string{"/src", "/docs/index.md"},
+		}))
+	})
+
+	It("should check out the whole work tree when any mapping adds the repository root", func() {
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/src", nil, true), newGitRemoteConfig("repo", "/", []string{"**/*.go"}, true))},
+			},
+		}
+
+		opts, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(Succeed())
+		Expect(opts.SparseCheckoutPatterns).To(Equal([]string{"/*"}))
+	})
+
+	It("should not set sparse checkout patterns without partial clone", func() {
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/src", nil, false))},
+			},
+		}
+
+		opts, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(Succeed())
+		Expect(opts).To(Equal(git_repo.RemoteOptions{}))
+	})
+
+	It("should fail when mappings of the repository have different partialClone values", func() {
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/src", nil, true))},
+			},
+			Artifacts: []*config.StapelImageArtifact{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/docs", nil, false))},
+			},
+		}
+
+		_, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(MatchError("all remote git mappings of repository repo should have the same partialClone value"))
+	})
+
+	It("should take credentials from the first mapping with auth", func() {
+		Expect(os.Setenv(tokenEnv, "secret")).To(Succeed())
+
+		withAuth := newGitRemoteConfig("repo", "/docs", nil, false)
+		withAuth.Auth = &config.GitRemoteAuth{Username: "oauth2", TokenEnv: tokenEnv}
+
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(newGitRemoteConfig("repo", "/src", nil, false), withAuth)},
+			},
+		}
+
+		opts, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(Succeed())
+		Expect(opts.Username).To(Equal("oauth2"))
+		Expect(opts.Token).To(Equal("secret"))
+	})
+
+	It("should fail when the token environment variable is not set", func() {
+		withAuth := newGitRemoteConfig("repo", "/", nil, false)
+		withAuth.Auth = &config.GitRemoteAuth{Username: "oauth2", TokenEnv: tokenEnv}
+
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				{StapelImageBase: newStapelImageBaseConfig(withAuth)},
+			},
+		}
+
+		_, err := GetRemoteGitRepoOptions(werfConfig, "repo")
+		Expect(err).To(MatchError("unable to get remote git repository repo credentials: environment variable " + tokenEnv + " with the remote git token is not set"))
+	})
+})
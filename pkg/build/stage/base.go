@@ -9,13 +9,16 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/werf/lockgate"
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/build/builder"
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
 	imagePkg "github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/slug"
+	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/util"
 	"github.com/werf/werf/pkg/werf"
 )
@@ -61,11 +64,15 @@ var (
 )
 
 type NewBaseStageOptions struct {
-	ImageName        string
-	ConfigMounts     []*config.Mount
-	ImageTmpDir      string
-	ContainerWerfDir string
-	ProjectName      string
+	ImageName               string
+	ConfigMounts            []*config.Mount
+	ConfigSecrets           []*config.Secret
+	ConfigStageDependencies *config.ImageStageDependencies
+	ImageTmpDir             string
+	ContainerWerfDir        string
+	ProjectName             string
+	DisableStapelToolchain  bool
+	AnsibleFiles            *builder.AnsibleFiles
 }
 
 func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
@@ -73,23 +80,31 @@ func newBaseStage(name StageName, options *NewBaseStageOptions) *BaseStage {
 	s.name = name
 	s.imageName = options.ImageName
 	s.configMounts = options.ConfigMounts
+	s.configSecrets = options.ConfigSecrets
+	s.configStageDependencies = options.ConfigStageDependencies
 	s.imageTmpDir = options.ImageTmpDir
 	s.containerWerfDir = options.ContainerWerfDir
 	s.projectName = options.ProjectName
+	s.disableStapelToolchain = options.DisableStapelToolchain
 	return s
 }
 
 type BaseStage struct {
-	name             StageName
-	imageName        string
-	digest           string
-	contentDigest    string
-	image            container_runtime.ImageInterface
-	gitMappings      []*GitMapping
-	imageTmpDir      string
-	containerWerfDir string
-	configMounts     []*config.Mount
-	projectName      string
+	name                    StageName
+	imageName               string
+	digest                  string
+	contentDigest           string
+	image                   container_runtime.ImageInterface
+	gitMappings             []*GitMapping
+	imageTmpDir             string
+	containerWerfDir        string
+	configMounts            []*config.Mount
+	configSecrets           []*config.Secret
+	configStageDependencies *config.ImageStageDependencies
+	projectName             string
+	cacheMounts             map[string][]string
+
+	disableStapelToolchain bool
 }
 
 func (s *BaseStage) LogDetailedName() string {
@@ -245,13 +260,46 @@ func (s *BaseStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 		return fmt.Errorf("error adding mounts volumes: %s", err)
 	}
 
+	s.cacheMounts = s.getCacheMounts(prevBuiltImage)
+	s.addCacheMountLabels(s.cacheMounts, image)
+	s.addCacheMountVolumes(s.cacheMounts, image)
+
 	return nil
 }
 
+// WithCacheMountsLock holds the cache mount locks while running f: the exclusive lock for the locked sharing mode and the shared one otherwise.
+// Cache mount volumes are created under the lock to not race with the host cleanup.
+func (s *BaseStage) WithCacheMountsLock(ctx context.Context, f func() error) error {
+	var ids []string
+	for id := range s.cacheMounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		lockName := stapel.CacheMountLockName(id)
+		_, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{Shared: s.getCacheMountSharing(id) == config.CacheMountSharingShared})
+		if err != nil {
+			return fmt.Errorf("unable to acquire %s host lock: %s", lockName, err)
+		}
+		defer werf.ReleaseHostLock(lock)
+
+		if err := stapel.CreateCacheMountVolume(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	return f()
+}
+
 func (s *BaseStage) PreRunHook(_ context.Context, _ Conveyor) error {
 	return nil
 }
 
+func (s *BaseStage) PostRunHook(_ context.Context, _ Conveyor) error {
+	return nil
+}
+
 func (s *BaseStage) getServiceMounts(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
 	return mergeMounts(s.getServiceMountsFromLabels(prevBuiltImage), s.getServiceMountsFromConfig())
 }
@@ -448,6 +496,70 @@ func (s *BaseStage) GetGitMappings() []*GitMapping {
 	return s.gitMappings
 }
 
+func (s *BaseStage) getCacheMounts(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
+	return mergeMounts(s.getCacheMountsFromLabels(prevBuiltImage), s.getCacheMountsFromConfig())
+}
+
+func (s *BaseStage) getCacheMountsFromLabels(prevBuiltImage container_runtime.ImageInterface) map[string][]string {
+	mountpointsById := map[string][]string{}
+
+	var labels map[string]string
+	if prevBuiltImage != nil {
+		labels = prevBuiltImage.GetStageDescription().Info.Labels
+	}
+	for k, v := range labels {
+		if !strings.HasPrefix(k, imagePkg.WerfMountCacheLabelPrefix) {
+			continue
+		}
+
+		id := strings.TrimPrefix(k, imagePkg.WerfMountCacheLabelPrefix)
+		mountpointsById[id] = util.RejectEmptyStrings(util.UniqStrings(strings.Split(v, ";")))
+	}
+
+	return mountpointsById
+}
+
+func (s *BaseStage) getCacheMountsFromConfig() map[string][]string {
+	mountpointsById := map[string][]string{}
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type != "cache" {
+			continue
+		}
+
+		mountpointsById[mountCfg.Id] = util.UniqAppendString(mountpointsById[mountCfg.Id], path.Clean(mountCfg.To))
+	}
+
+	return mountpointsById
+}
+
+// getCacheMountSharing returns the sharing mode of the cache mount, the mode of the mount inherited from the base image is shared.
+func (s *BaseStage) getCacheMountSharing(id string) string {
+	for _, mountCfg := range s.configMounts {
+		if mountCfg.Type == "cache" && mountCfg.Id == id {
+			return mountCfg.Sharing
+		}
+	}
+
+	return config.CacheMountSharingShared
+}
+
+func (s *BaseStage) addCacheMountVolumes(mountpointsById map[string][]string, image container_runtime.ImageInterface) {
+	for id, mountpoints := range mountpointsById {
+		for _, mountpoint := range mountpoints {
+			absoluteMountpoint := path.Join("/", mountpoint)
+			image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s", stapel.CacheMountVolumeName(id), absoluteMountpoint))
+		}
+	}
+}
+
+func (s *BaseStage) addCacheMountLabels(mountpointsById map[string][]string, image container_runtime.ImageInterface) {
+	for id, mountpoints := range mountpointsById {
+		labelName := fmt.Sprintf("%s%s", imagePkg.WerfMountCacheLabelPrefix, id)
+		labelValue := strings.Join(mountpoints, ";")
+		image.Container().ServiceCommitChangeOptions().AddLabel(map[string]string{labelName: labelValue})
+	}
+}
+
 func mergeMounts(a, b map[string][]string) map[string][]string {
 	res := map[string][]string{}
 
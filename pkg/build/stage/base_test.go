@@ -0,0 +1,61 @@
+package stage
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/image"
+)
+
+type cacheMountsTestImage struct {
+	container_runtime.ImageInterface
+	labels map[string]string
+}
+
+func (i *cacheMountsTestImage) GetStageDescription() *image.StageDescription {
+	return &image.StageDescription{Info: &image.Info{Labels: i.labels}}
+}
+
+var _ = DescribeTable("getCacheMounts inherits the cache mounts of the previous image and adds the configured ones",
+	func(prevBuiltImageLabels map[string]string, configMounts []*config.Mount, expected map[string][]string) {
+		s := newBaseStage("test", &NewBaseStageOptions{ConfigMounts: configMounts})
+
+		var prevBuiltImage container_runtime.ImageInterface
+		if prevBuiltImageLabels != nil {
+			prevBuiltImage = &cacheMountsTestImage{labels: prevBuiltImageLabels}
+		}
+
+		Ω(s.getCacheMounts(prevBuiltImage)).Should(Equal(expected))
+	},
+	Entry("no previous image",
+		nil,
+		[]*config.Mount{{Type: "cache", Id: "apt", To: "/var/cache/apt/"}, {Type: "tmp_dir", To: "/tmp"}},
+		map[string][]string{"apt": {"/var/cache/apt"}},
+	),
+	Entry("cache mounts of the previous image",
+		map[string]string{
+			image.WerfMountCacheLabelPrefix + "apt": "/var/cache/apt;/var/lib/apt;",
+			image.WerfMountCacheLabelPrefix + "npm": "/root/.npm",
+			"werf-mount-type-tmp_dir":               "/tmp",
+			"other":                                 "label",
+		},
+		nil,
+		map[string][]string{"apt": {"/var/cache/apt", "/var/lib/apt"}, "npm": {"/root/.npm"}},
+	),
+	Entry("configured mountpoints are added to the inherited ones",
+		map[string]string{image.WerfMountCacheLabelPrefix + "apt": "/var/cache/apt"},
+		[]*config.Mount{{Type: "cache", Id: "apt", To: "/var/cache/apt"}, {Type: "cache", Id: "apt", To: "/var/lib/apt"}, {Type: "cache", Id: "go", To: "/root/go"}},
+		map[string][]string{"apt": {"/var/cache/apt", "/var/lib/apt"}, "go": {"/root/go"}},
+	),
+)
+
+var _ = DescribeTable("getCacheMountSharing returns the configured sharing mode and the shared mode for the inherited cache mounts",
+	func(id, expected string) {
+		s := newBaseStage("test", &NewBaseStageOptions{ConfigMounts: []*config.Mount{{Type: "cache", Id: "apt", To: "/var/cache/apt", Sharing: config.CacheMountSharingLocked}}})
+		Ω(s.getCacheMountSharing(id)).Should(Equal(expected))
+	},
+	Entry("configured", "apt", config.CacheMountSharingLocked),
+	Entry("inherited", "npm", config.CacheMountSharingShared),
+)
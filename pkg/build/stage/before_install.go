@@ -28,7 +28,7 @@ type BeforeInstallStage struct {
 }
 
 func (s *BeforeInstallStage) GetDependencies(ctx context.Context, _ Conveyor, _, _ container_runtime.ImageInterface) (string, error) {
-	return s.builder.BeforeInstallChecksum(ctx), nil
+	return s.withStageDependenciesChecksum(ctx, BeforeInstall, s.builder.BeforeInstallChecksum(ctx))
 }
 
 func (s *BeforeInstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -36,9 +36,21 @@ func (s *BeforeInstallStage) PrepareImage(ctx context.Context, c Conveyor, prevB
 		return err
 	}
 
+	if err := s.setRunStepsBaseChecksum(ctx, BeforeInstall, image); err != nil {
+		return err
+	}
+
+	if err := s.addSecretsVolumes(image); err != nil {
+		return err
+	}
+
 	if err := s.builder.BeforeInstall(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
 
+	if err := s.addSecretsLeakCheck(image); err != nil {
+		return err
+	}
+
 	return nil
 }
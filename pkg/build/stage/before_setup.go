@@ -34,7 +34,7 @@ func (s *BeforeSetupStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.BeforeSetupChecksum(ctx), stageDependenciesChecksum), nil
+	return s.withStageDependenciesChecksum(ctx, BeforeSetup, util.Sha256Hash(s.builder.BeforeSetupChecksum(ctx), stageDependenciesChecksum))
 }
 
 func (s *BeforeSetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -42,9 +42,17 @@ func (s *BeforeSetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBui
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image); err != nil {
+		return err
+	}
+
 	if err := s.builder.BeforeSetup(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
 
+	if err := s.addSecretsLeakCheck(image); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -24,6 +24,7 @@ type Conveyor interface {
 
 	GetImportServer(ctx context.Context, imageName, stageName string) (import_server.ImportServer, error)
 	GetLocalGitRepoVirtualMergeOptions() VirtualMergeOptions
+	ResolveRemoteGitRepoCommit(ctx context.Context, url, ref string, inspectBranchFunc func() error) (string, error)
 
 	GiterminismManager() giterminism_manager.Interface
 }
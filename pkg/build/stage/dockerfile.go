@@ -11,14 +11,17 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/docker/docker/pkg/urlutil"
 	"github.com/moby/buildkit/frontend/dockerfile/instructions"
 	"github.com/moby/buildkit/frontend/dockerfile/parser"
 	"github.com/moby/buildkit/frontend/dockerfile/shell"
 
 	"github.com/werf/logboek"
 
+	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/context_manager"
+	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/docker_registry"
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/giterminism_manager"
@@ -46,9 +49,11 @@ type DockerfileStage struct {
 	*DockerStages
 	*ContextChecksum
 	*BaseStage
+
+	staged *stagedDockerfile
 }
 
-func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []string, buildArgs map[string]interface{}, addHost []string, network, ssh string) *DockerRunArgs {
+func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []string, buildArgs map[string]interface{}, addHost []string, network, ssh string, secrets []*config.Secret) *DockerRunArgs {
 	return &DockerRunArgs{
 		dockerfilePath:  dockerfilePath,
 		target:          target,
@@ -58,6 +63,7 @@ func NewDockerRunArgs(dockerfilePath, target, context string, contextAddFiles []
 		addHost:         addHost,
 		network:         network,
 		ssh:             ssh,
+		secrets:         secrets,
 	}
 }
 
@@ -70,9 +76,31 @@ type DockerRunArgs struct {
 	addHost         []string
 	network         string
 	ssh             string
+	secrets         []*config.Secret
+
+	contextGitRepo git_repo.GitRepo // the remote git repository of the context, the project git repository if nil
+	contextCommit  string
+}
+
+// SetRemoteContext sets the commit of the remote git repository as the build context, the context is the subdirectory of the repository.
+func (d *DockerRunArgs) SetRemoteContext(gitRepo git_repo.GitRepo, commit string) {
+	d.contextGitRepo = gitRepo
+	d.contextCommit = commit
+}
+
+func (d *DockerRunArgs) contextGitRepoAndCommit(giterminismManager giterminism_manager.Interface) (git_repo.GitRepo, string) {
+	if d.contextGitRepo != nil {
+		return d.contextGitRepo, d.contextCommit
+	}
+
+	return giterminismManager.LocalGitRepo(), giterminismManager.HeadCommit()
 }
 
 func (d *DockerRunArgs) contextRelativeToGitWorkTree(giterminismManager giterminism_manager.Interface) string {
+	if d.contextGitRepo != nil {
+		return filepath.Join(d.context)
+	}
+
 	return filepath.Join(giterminismManager.RelativeToGitProjectDir(), d.context)
 }
 
@@ -94,9 +122,12 @@ type DockerStages struct {
 	dockerStageEnvs        map[int]map[string]string
 
 	imageOnBuildInstructions map[string][]string
+	lockedBaseImages         map[string]string
 }
 
 func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[string]string, dockerMetaArgs []instructions.ArgCommand, dockerTargetStageIndex int) (*DockerStages, error) {
+	resolveDockerStagesFromValue(dockerStages)
+
 	ds := &DockerStages{
 		dockerStages:             dockerStages,
 		dockerTargetStageIndex:   dockerTargetStageIndex,
@@ -104,6 +135,7 @@ func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[
 		dockerStageArgsHash:      map[int]map[string]string{},
 		dockerStageEnvs:          map[int]map[string]string{},
 		imageOnBuildInstructions: map[string][]string{},
+		lockedBaseImages:         map[string]string{},
 	}
 
 	ds.dockerMetaArgsHash = map[string]string{}
@@ -125,6 +157,82 @@ func NewDockerStages(dockerStages []instructions.Stage, dockerBuildArgsHash map[
 	return ds, nil
 }
 
+// BaseImageNames returns the resolved names of the base images, which are not the previous stages of the Dockerfile.
+func (ds *DockerStages) BaseImageNames() ([]string, error) {
+	var names []string
+	stageNames := map[string]bool{}
+	for _, dockerStage := range ds.dockerStages {
+		if !stageNames[strings.ToLower(dockerStage.BaseName)] {
+			resolvedBaseName, err := ds.ShlexProcessWordWithMetaArgs(dockerStage.BaseName)
+			if err != nil {
+				return nil, err
+			}
+
+			names = util.AddNewStringsToStringArray(names, resolvedBaseName)
+		}
+
+		if dockerStage.Name != "" {
+			stageNames[strings.ToLower(dockerStage.Name)] = true
+		}
+	}
+
+	return names, nil
+}
+
+// DockerfileAddGitSource is the remote git repository source of the ADD instruction.
+type DockerfileAddGitSource struct {
+	Url string
+	Ref string // empty for the default branch
+}
+
+// AddGitSources returns the remote git repository sources of the ADD instructions of all stages, resolved the same way as for the digest calculation.
+func (ds *DockerStages) AddGitSources() ([]*DockerfileAddGitSource, error) {
+	var sources []*DockerfileAddGitSource
+	for dockerStageID, dockerStage := range ds.dockerStages {
+		for _, cmd := range dockerStage.Commands {
+			switch c := cmd.(type) {
+			case *instructions.ArgCommand:
+				for _, keyValuePairOptional := range c.Args {
+					var value string
+					if keyValuePairOptional.Value != nil {
+						value = *keyValuePairOptional.Value
+					}
+
+					if _, _, err := ds.AddDockerStageArg(dockerStageID, keyValuePairOptional.Key, value); err != nil {
+						return nil, err
+					}
+				}
+			case *instructions.EnvCommand:
+				for _, keyValuePair := range c.Env {
+					if _, _, err := ds.AddDockerStageEnv(dockerStageID, keyValuePair.Key, keyValuePair.Value); err != nil {
+						return nil, err
+					}
+				}
+			case *instructions.AddCommand:
+				for _, source := range c.SourcesAndDest.Sources() {
+					resolvedSource, err := ds.ShlexProcessWordWithStageArgsAndEnvs(dockerStageID, source)
+					if err != nil {
+						return nil, err
+					}
+
+					if urlutil.IsGitURL(resolvedSource) {
+						url, ref := parseDockerfileAddGitSource(resolvedSource)
+						sources = append(sources, &DockerfileAddGitSource{Url: url, Ref: ref})
+					}
+				}
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// SetLockedBaseImage pins the base image by the reference with digest from werf.lock.
+// The pinned image is tagged locally with the name from the Dockerfile, which is used by docker build.
+func (ds *DockerStages) SetLockedBaseImage(name, reference string) {
+	ds.lockedBaseImages[name] = reference
+}
+
 // addDockerMetaArg function sets --build-arg value or resolved meta ARG value
 func (ds *DockerStages) addDockerMetaArg(key, value string) (string, string, error) {
 	resolvedKey, err := ds.ShlexProcessWordWithMetaArgs(key)
@@ -288,6 +396,16 @@ outerLoop:
 			continue
 		}
 
+		if lockedReference, ok := s.lockedBaseImages[resolvedBaseName]; ok {
+			onBuild, err := fetchLockedBaseImage(ctx, containerRuntime, resolvedBaseName, lockedReference)
+			if err != nil {
+				return err
+			}
+
+			s.imageOnBuildInstructions[resolvedBaseName] = onBuild
+			continue
+		}
+
 		getBaseImageOnBuildLocally := func() ([]string, error) {
 			inspect, err := containerRuntime.GetImageInspect(ctx, resolvedBaseName)
 			if err != nil {
@@ -340,6 +458,34 @@ outerLoop:
 	return nil
 }
 
+// fetchLockedBaseImage pulls the base image pinned by werf.lock and tags it with the name used in the Dockerfile.
+func fetchLockedBaseImage(ctx context.Context, containerRuntime *container_runtime.LocalDockerServerRuntime, name, lockedReference string) ([]string, error) {
+	inspect, err := containerRuntime.GetImageInspect(ctx, lockedReference)
+	if err != nil {
+		return nil, err
+	}
+
+	if inspect == nil {
+		if err := logboek.Context(ctx).Default().LogProcess("Pulling base image %s", lockedReference).DoError(func() error {
+			return containerRuntime.PullImage(ctx, lockedReference)
+		}); err != nil {
+			return nil, err
+		}
+
+		if inspect, err = containerRuntime.GetImageInspect(ctx, lockedReference); err != nil {
+			return nil, err
+		} else if inspect == nil {
+			return nil, fmt.Errorf("unable to inspect local image %s after successful pull: image is not exists", lockedReference)
+		}
+	}
+
+	if err := docker.CliTag(ctx, lockedReference, name); err != nil {
+		return nil, fmt.Errorf("unable to tag base image %s as %s: %s", lockedReference, name, err)
+	}
+
+	return inspect.Config.OnBuild, nil
+}
+
 func isUnsupportedMediaTypeError(err error) bool {
 	return strings.Contains(err.Error(), "unsupported MediaType")
 }
@@ -361,12 +507,16 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 			return "", err
 		}
 
-		dependencies = append(dependencies, resolvedBaseName)
+		if lockedReference, ok := s.lockedBaseImages[resolvedBaseName]; ok {
+			dependencies = append(dependencies, lockedReference)
+		} else {
+			dependencies = append(dependencies, resolvedBaseName)
+		}
 
 		onBuildInstructions, ok := s.imageOnBuildInstructions[resolvedBaseName]
 		if ok {
 			for _, instruction := range onBuildInstructions {
-				_, iOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, c.GiterminismManager(), ind, instruction, true)
+				_, iOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, c, ind, instruction, true)
 				if err != nil {
 					return "", err
 				}
@@ -376,7 +526,7 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 		}
 
 		for _, cmd := range stage.Commands {
-			cmdDependencies, cmdOnBuildDependencies, err := s.dockerfileInstructionDependencies(ctx, c.GiterminismManager(), ind, cmd, false, false)
+			cmdDependencies, cmdOnBuildDependencies, err := s.dockerfileInstructionDependencies(ctx, c, ind, cmd, false, false)
 			if err != nil {
 				return "", err
 			}
@@ -395,26 +545,20 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 				continue
 			}
 
-			if stage.BaseName == relatedStage.Name {
+			if isDockerStageBasedOn(stage, relatedStage) {
 				stagesDependencies[ind] = append(stagesDependencies[ind], stagesDependencies[relatedStageIndex]...)
 				stagesDependencies[ind] = append(stagesDependencies[ind], stagesOnBuildDependencies[relatedStageIndex]...)
 			}
 		}
 
-		for _, cmd := range stage.Commands {
-			switch c := cmd.(type) {
-			case *instructions.CopyCommand:
-				if c.From != "" {
-					relatedStageIndex, err := strconv.Atoi(c.From)
-					if err == nil && relatedStageIndex < len(stagesDependencies) {
-						stagesDependencies[ind] = append(stagesDependencies[ind], stagesDependencies[relatedStageIndex]...)
-					}
-				}
+		for _, relatedStageIndex := range dockerStageFromIndexes(stage) {
+			if relatedStageIndex < len(stagesDependencies) {
+				stagesDependencies[ind] = append(stagesDependencies[ind], stagesDependencies[relatedStageIndex]...)
 			}
 		}
 	}
 
-	dockerfileStageDependencies := stagesDependencies[s.dockerTargetStageIndex]
+	dockerfileStageDependencies := stagesDependencies[s.dockerStageIndex()]
 
 	if dockerfileStageDependenciesDebug() {
 		logboek.Context(ctx).LogLn(dockerfileStageDependencies)
@@ -423,7 +567,7 @@ func (s *DockerfileStage) GetDependencies(ctx context.Context, c Conveyor, _, _
 	return util.Sha256Hash(dockerfileStageDependencies...), nil
 }
 
-func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context, giterminismManager giterminism_manager.Interface, dockerStageID int, cmd interface{}, isOnbuildInstruction bool, isBaseImageOnbuildInstruction bool) ([]string, []string, error) {
+func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context, conveyor Conveyor, dockerStageID int, cmd interface{}, isOnbuildInstruction bool, isBaseImageOnbuildInstruction bool) ([]string, []string, error) {
 	var dependencies []string
 	var onBuildDependencies []string
 
@@ -545,7 +689,31 @@ func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context,
 			return nil, nil, err
 		}
 
-		checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, resolvedSources, c.String())
+		var localSources []string
+		for _, source := range resolvedSources {
+			switch {
+			case urlutil.IsGitURL(source):
+				url, ref := parseDockerfileAddGitSource(source)
+				commit, err := conveyor.ResolveRemoteGitRepoCommit(ctx, url, ref, func() error {
+					return conveyor.GiterminismManager().Inspector().InspectConfigDockerfileAddGitBranch(url)
+				})
+				if err != nil {
+					return nil, nil, fmt.Errorf("unable to resolve ADD source %s: %s", source, err)
+				}
+
+				dependencies = append(dependencies, commit)
+			case urlutil.IsURL(source):
+				// the content of the remote file is not tracked, the instruction is the only dependency
+			default:
+				localSources = append(localSources, source)
+			}
+		}
+
+		if len(localSources) == 0 {
+			break
+		}
+
+		checksum, err := s.calculateFilesChecksum(ctx, conveyor.GiterminismManager(), localSources, c.String())
 		if err != nil {
 			return nil, nil, err
 		}
@@ -558,14 +726,44 @@ func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context,
 				return nil, nil, err
 			}
 
-			checksum, err := s.calculateFilesChecksum(ctx, giterminismManager, resolvedSources, c.String())
+			checksum, err := s.calculateFilesChecksum(ctx, conveyor.GiterminismManager(), resolvedSources, c.String())
+			if err != nil {
+				return nil, nil, err
+			}
+			dependencies = append(dependencies, checksum)
+		}
+	case *instructions.RunCommand:
+		resolvedValue, err := resolveValueFunc(c.String())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		dependencies = append(dependencies, resolvedValue)
+
+		// the files of the build context bind-mounted by RUN --mount=type=bind (the whole context by default)
+		for _, mount := range instructions.GetMounts(c) {
+			if mount.Type != instructions.MountTypeBind || mount.From != "" {
+				continue
+			}
+
+			source := mount.Source
+			if source == "" {
+				source = "."
+			}
+
+			resolvedSource, err := resolveValueFunc(source)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			checksum, err := s.calculateFilesChecksum(ctx, conveyor.GiterminismManager(), []string{resolvedSource}, c.String())
 			if err != nil {
 				return nil, nil, err
 			}
 			dependencies = append(dependencies, checksum)
 		}
 	case *instructions.OnbuildCommand:
-		cDependencies, cOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, giterminismManager, dockerStageID, c.Expression, false)
+		cDependencies, cOnBuildDependencies, err := s.dockerfileOnBuildInstructionDependencies(ctx, conveyor, dockerStageID, c.Expression, false)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -586,7 +784,7 @@ func (s *DockerfileStage) dockerfileInstructionDependencies(ctx context.Context,
 	return dependencies, onBuildDependencies, nil
 }
 
-func (s *DockerfileStage) dockerfileOnBuildInstructionDependencies(ctx context.Context, giterminismManager giterminism_manager.Interface, dockerStageID int, expression string, isBaseImageOnbuildInstruction bool) ([]string, []string, error) {
+func (s *DockerfileStage) dockerfileOnBuildInstructionDependencies(ctx context.Context, conveyor Conveyor, dockerStageID int, expression string, isBaseImageOnbuildInstruction bool) ([]string, []string, error) {
 	p, err := parser.Parse(bytes.NewReader([]byte(expression)))
 	if err != nil {
 		return nil, nil, err
@@ -602,7 +800,7 @@ func (s *DockerfileStage) dockerfileOnBuildInstructionDependencies(ctx context.C
 		return nil, nil, err
 	}
 
-	onBuildDependencies, _, err := s.dockerfileInstructionDependencies(ctx, giterminismManager, dockerStageID, cmd, true, isBaseImageOnbuildInstruction)
+	onBuildDependencies, _, err := s.dockerfileInstructionDependencies(ctx, conveyor, dockerStageID, cmd, true, isBaseImageOnbuildInstruction)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -611,11 +809,21 @@ func (s *DockerfileStage) dockerfileOnBuildInstructionDependencies(ctx context.C
 }
 
 func (s *DockerfileStage) PrepareImage(ctx context.Context, c Conveyor, _, img container_runtime.ImageInterface) error {
+	if err := s.validateSecrets(); err != nil {
+		return err
+	}
+
 	archivePath, err := s.prepareContextArchive(ctx, c.GiterminismManager())
 	if err != nil {
 		return err
 	}
 
+	if s.staged != nil {
+		if archivePath, err = s.addStagedDockerfileToContextArchive(ctx, archivePath); err != nil {
+			return err
+		}
+	}
+
 	img.DockerfileImageBuilder().AppendBuildArgs(s.DockerBuildArgs()...)
 	img.DockerfileImageBuilder().AppendBuildArgs(fmt.Sprintf("--label=%s=%s", image.WerfProjectRepoCommitLabel, c.GiterminismManager().HeadCommit()))
 	img.DockerfileImageBuilder().SetFilePathToStdin(archivePath)
@@ -631,13 +839,14 @@ func (s *DockerfileStage) prepareContextArchive(ctx context.Context, giterminism
 	contextPathRelativeToGitWorkTree := s.contextRelativeToGitWorkTree(giterminismManager)
 	contextPathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{BasePath: contextPathRelativeToGitWorkTree})
 
-	archive, err := giterminismManager.LocalGitRepo().GetOrCreateArchive(ctx, git_repo.ArchiveOptions{
+	contextGitRepo, contextCommit := s.contextGitRepoAndCommit(giterminismManager)
+	archive, err := contextGitRepo.GetOrCreateArchive(ctx, git_repo.ArchiveOptions{
 		PathScope: contextPathRelativeToGitWorkTree,
 		PathMatcher: path_matcher.NewMultiPathMatcher(
 			contextPathMatcher,
 			s.dockerignorePathMatcher,
 		),
-		Commit: giterminismManager.HeadCommit(),
+		Commit: contextCommit,
 	})
 	if err != nil {
 		return "", fmt.Errorf("unable to create archive: %s", err)
@@ -665,12 +874,18 @@ func (s *DockerfileStage) prepareContextArchive(ctx context.Context, giterminism
 func (s *DockerfileStage) DockerBuildArgs() []string {
 	var result []string
 
-	if s.dockerfilePath != "" {
-		result = append(result, fmt.Sprintf("--file=%s", s.dockerfilePath))
+	dockerfilePath, target := s.dockerfilePath, s.target
+	if s.staged != nil {
+		// the generated Dockerfile ends with the stage being built
+		dockerfilePath, target = stagedDockerfilePath, ""
+	}
+
+	if dockerfilePath != "" {
+		result = append(result, fmt.Sprintf("--file=%s", dockerfilePath))
 	}
 
-	if s.target != "" {
-		result = append(result, fmt.Sprintf("--target=%s", s.target))
+	if target != "" {
+		result = append(result, fmt.Sprintf("--target=%s", target))
 	}
 
 	if len(s.buildArgs) != 0 {
@@ -691,9 +906,36 @@ func (s *DockerfileStage) DockerBuildArgs() []string {
 		result = append(result, fmt.Sprintf("--ssh=%s", s.ssh))
 	}
 
+	for _, secret := range s.secrets {
+		if secret.Env != "" {
+			result = append(result, fmt.Sprintf("--secret=id=%s,env=%s", secret.Id, secret.Env))
+		} else {
+			result = append(result, fmt.Sprintf("--secret=id=%s,src=%s", secret.Id, secret.GetSrcPath()))
+		}
+	}
+
 	return result
 }
 
+// validateSecrets checks that secrets can be passed to the build, the values are available only to RUN --mount=type=secret instructions and are never stored in the image.
+func (s *DockerfileStage) validateSecrets() error {
+	if len(s.secrets) == 0 {
+		return nil
+	}
+
+	if os.Getenv("DOCKER_BUILDKIT") != "1" {
+		return fmt.Errorf("secrets of the Dockerfile image require BuildKit: set DOCKER_BUILDKIT=1")
+	}
+
+	for _, secret := range s.secrets {
+		if _, err := secret.GetValue(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *DockerfileStage) calculateFilesChecksum(ctx context.Context, giterminismManager giterminism_manager.Interface, wildcards []string, dockerfileLine string) (string, error) {
 	var checksum string
 	var err error
@@ -745,7 +987,8 @@ func (s *DockerfileStage) calculateFilesChecksumWithGit(ctx context.Context, git
 		BasePath:     contextPathRelativeToGitWorkTree,
 		IncludeGlobs: wildcards,
 	})
-	lsTreeResultChecksum, err := giterminismManager.LocalGitRepo().GetOrCreateChecksum(ctx, git_repo.ChecksumOptions{
+	contextGitRepo, contextCommit := s.contextGitRepoAndCommit(giterminismManager)
+	lsTreeResultChecksum, err := contextGitRepo.GetOrCreateChecksum(ctx, git_repo.ChecksumOptions{
 		LsTreeOptions: git_repo.LsTreeOptions{
 			PathScope: contextPathRelativeToGitWorkTree,
 			PathMatcher: path_matcher.NewMultiPathMatcher(
@@ -754,12 +997,17 @@ func (s *DockerfileStage) calculateFilesChecksumWithGit(ctx context.Context, git
 			),
 			AllFiles: false,
 		},
-		Commit: giterminismManager.HeadCommit(),
+		Commit: contextCommit,
 	})
 	if err != nil {
 		return "", err
 	}
 
+	// the remote git repository commit has no uncommitted files
+	if s.contextGitRepo != nil {
+		return util.Sha256Hash(lsTreeResultChecksum), nil
+	}
+
 	pathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{
 		ExcludeGlobs: s.contextAddFilesRelativeToGitWorkTree(giterminismManager),
 		Matchers: []path_matcher.PathMatcher{
@@ -775,6 +1023,84 @@ func (s *DockerfileStage) calculateFilesChecksumWithGit(ctx context.Context, git
 	return util.Sha256Hash(lsTreeResultChecksum), nil
 }
 
+// resolveDockerStagesFromValue replaces the stage names in COPY --from and RUN --mount=from with the stage indexes.
+func resolveDockerStagesFromValue(stages []instructions.Stage) {
+	nameToIndex := make(map[string]string)
+	for i, s := range stages {
+		name := strings.ToLower(s.Name)
+		index := strconv.Itoa(i)
+		if name != index {
+			nameToIndex[name] = index
+		}
+
+		for _, cmd := range s.Commands {
+			switch c := cmd.(type) {
+			case *instructions.CopyCommand:
+				if c.From != "" {
+					from := strings.ToLower(c.From)
+					if val, ok := nameToIndex[from]; ok {
+						c.From = val
+					}
+				}
+			case *instructions.RunCommand:
+				for _, mount := range instructions.GetMounts(c) {
+					if mount.From != "" {
+						from := strings.ToLower(mount.From)
+						if val, ok := nameToIndex[from]; ok {
+							mount.From = val
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// dockerStageFromIndexes returns the indexes of the Dockerfile stages used by the COPY --from and RUN --mount=from instructions of the stage.
+func dockerStageFromIndexes(stage instructions.Stage) []int {
+	var froms []string
+	for _, cmd := range stage.Commands {
+		switch c := cmd.(type) {
+		case *instructions.CopyCommand:
+			froms = append(froms, c.From)
+		case *instructions.RunCommand:
+			for _, mount := range instructions.GetMounts(c) {
+				froms = append(froms, mount.From)
+			}
+		}
+	}
+
+	var result []int
+	for _, from := range froms {
+		if from == "" {
+			continue
+		}
+
+		if ind, err := strconv.Atoi(from); err == nil {
+			result = append(result, ind)
+		}
+	}
+
+	return result
+}
+
+// parseDockerfileAddGitSource splits the ADD git source (URL#REF:SUBDIR) into the repository url and the reference (the default branch if empty).
+func parseDockerfileAddGitSource(source string) (string, string) {
+	url := source
+	var ref string
+	if ind := strings.Index(source, "#"); ind != -1 {
+		url = source[:ind]
+		ref = strings.SplitN(source[ind+1:], ":", 2)[0]
+	}
+
+	// the same as docker does for the short github urls
+	if strings.HasPrefix(url, "github.com/") {
+		url = "https://" + url
+	}
+
+	return url, ref
+}
+
 func normalizeCopyAddSources(wildcards []string) []string {
 	var result []string
 	for _, wildcard := range wildcards {
@@ -0,0 +1,195 @@
+package stage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+
+	"github.com/werf/werf/pkg/context_manager"
+)
+
+// stagedDockerfilePath is the path of the generated Dockerfile in the build context archive.
+const stagedDockerfilePath = ".werf-staged.Dockerfile"
+
+type stagedDockerfile struct {
+	dockerfileData   []byte
+	dockerStageIndex int
+	dependencyStages map[int]*DockerfileStage // werf stages of the Dockerfile stages the stage depends on by index
+}
+
+// GenerateStagedDockerfileStages returns a werf stage for each Dockerfile stage that the target depends on, the target stage goes last.
+// Each stage is built separately from the generated Dockerfile, where the preceding Dockerfile stages are replaced by the already built werf stages.
+func GenerateStagedDockerfileStages(dockerRunArgs *DockerRunArgs, dockerStages *DockerStages, contextChecksum *ContextChecksum, dockerfileData []byte, baseStageOptions *NewBaseStageOptions) []*DockerfileStage {
+	var stages []*DockerfileStage
+	stagesByIndex := map[int]*DockerfileStage{}
+
+	for _, ind := range dockerStages.dependencyDockerStageIndexes(dockerStages.dockerTargetStageIndex) {
+		name := Dockerfile
+		if ind != dockerStages.dockerTargetStageIndex {
+			name = StageName(fmt.Sprintf("%s-%s", Dockerfile, dockerStages.dockerStageName(ind)))
+		}
+
+		s := &DockerfileStage{}
+		s.DockerRunArgs = dockerRunArgs
+		s.DockerStages = dockerStages
+		s.ContextChecksum = contextChecksum
+		s.BaseStage = newBaseStage(name, baseStageOptions)
+		s.staged = &stagedDockerfile{
+			dockerfileData:   dockerfileData,
+			dockerStageIndex: ind,
+			dependencyStages: map[int]*DockerfileStage{},
+		}
+
+		for _, dependencyInd := range dockerStages.dependencyDockerStageIndexes(ind) {
+			if dependencyInd != ind {
+				s.staged.dependencyStages[dependencyInd] = stagesByIndex[dependencyInd]
+			}
+		}
+
+		stagesByIndex[ind] = s
+		stages = append(stages, s)
+	}
+
+	return stages
+}
+
+// DependencyStages returns the werf stages that must be available locally to build the stage.
+func (s *DockerfileStage) DependencyStages() []*DockerfileStage {
+	if s.staged == nil {
+		return nil
+	}
+
+	var indexes []int
+	for ind := range s.staged.dependencyStages {
+		indexes = append(indexes, ind)
+	}
+	sort.Ints(indexes)
+
+	var result []*DockerfileStage
+	for _, ind := range indexes {
+		result = append(result, s.staged.dependencyStages[ind])
+	}
+
+	return result
+}
+
+func (s *DockerfileStage) dockerStageIndex() int {
+	if s.staged != nil {
+		return s.staged.dockerStageIndex
+	}
+
+	return s.dockerTargetStageIndex
+}
+
+func (s *DockerfileStage) addStagedDockerfileToContextArchive(ctx context.Context, archivePath string) (string, error) {
+	data, err := s.stagedDockerfileData()
+	if err != nil {
+		return "", err
+	}
+
+	return context_manager.AddFileToContextArchive(ctx, archivePath, stagedDockerfilePath, data)
+}
+
+// stagedDockerfileData keeps the original lines before the first FROM (parser directives and meta ARGs) and the lines of the stage being built.
+// Each preceding Dockerfile stage is replaced by a single FROM instruction to preserve the stage names and indexes:
+// the built werf stage image is used for the dependencies, scratch for the rest.
+func (s *DockerfileStage) stagedDockerfileData() ([]byte, error) {
+	lines := strings.Split(string(s.staged.dockerfileData), "\n")
+
+	stageStartLine := func(ind int) (int, error) {
+		location := s.dockerStages[ind].Location
+		if len(location) == 0 || location[0].Start.Line < 1 || location[0].Start.Line > len(lines) {
+			return 0, fmt.Errorf("unable to get location of Dockerfile stage %s", s.dockerStageName(ind))
+		}
+
+		return location[0].Start.Line - 1, nil
+	}
+
+	firstStageStartLine, err := stageStartLine(0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := append([]string{}, lines[:firstStageStartLine]...)
+	for ind := 0; ind < s.staged.dockerStageIndex; ind++ {
+		from := "scratch"
+		if dependencyStage, ok := s.staged.dependencyStages[ind]; ok {
+			from = dependencyStage.GetImage().Name()
+		}
+
+		instruction := fmt.Sprintf("FROM %s", from)
+		if name := s.dockerStages[ind].Name; name != "" {
+			instruction += fmt.Sprintf(" AS %s", name)
+		}
+
+		result = append(result, instruction)
+	}
+
+	startLine, err := stageStartLine(s.staged.dockerStageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	endLine := len(lines)
+	if s.staged.dockerStageIndex+1 < len(s.dockerStages) {
+		if endLine, err = stageStartLine(s.staged.dockerStageIndex + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	result = append(result, lines[startLine:endLine]...)
+
+	return []byte(strings.Join(result, "\n")), nil
+}
+
+// dependencyDockerStageIndexes returns the sorted indexes of the Dockerfile stage and all the stages it depends on by FROM, COPY --from and RUN --mount=from.
+func (ds *DockerStages) dependencyDockerStageIndexes(ind int) []int {
+	visited := map[int]bool{}
+
+	var visit func(ind int)
+	visit = func(ind int) {
+		if visited[ind] {
+			return
+		}
+		visited[ind] = true
+
+		stage := ds.dockerStages[ind]
+		for relatedStageIndex, relatedStage := range ds.dockerStages[:ind] {
+			if isDockerStageBasedOn(stage, relatedStage) {
+				visit(relatedStageIndex)
+			}
+		}
+
+		for _, relatedStageIndex := range dockerStageFromIndexes(stage) {
+			if relatedStageIndex < ind {
+				visit(relatedStageIndex)
+			}
+		}
+	}
+	visit(ind)
+
+	var result []int
+	for ind := range visited {
+		result = append(result, ind)
+	}
+	sort.Ints(result)
+
+	return result
+}
+
+// isDockerStageBasedOn reports whether the FROM instruction of the stage refers to the named related stage (stage names are case insensitive).
+func isDockerStageBasedOn(stage, relatedStage instructions.Stage) bool {
+	return relatedStage.Name != "" && strings.ToLower(stage.BaseName) == relatedStage.Name
+}
+
+func (ds *DockerStages) dockerStageName(ind int) string {
+	if name := ds.dockerStages[ind].Name; name != "" {
+		return name
+	}
+
+	return strconv.Itoa(ind)
+}
@@ -0,0 +1,107 @@
+package stage
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/container_runtime"
+)
+
+var _ = DescribeTable("dependencyDockerStageIndexes returns the sorted indexes of the stage and the stages it depends on",
+	func(dockerfile string, ind int, expected []int) {
+		ds := newTestDockerStages(dockerfile, nil, -1)
+		Ω(ds.dependencyDockerStageIndexes(ind)).Should(Equal(expected))
+	},
+	Entry("single stage",
+		"FROM alpine\n",
+		0, []int{0},
+	),
+	Entry("FROM the named stage",
+		"FROM golang AS builder\nFROM builder\nFROM alpine\n",
+		1, []int{0, 1},
+	),
+	Entry("stage names are case insensitive",
+		"FROM golang AS Builder\nFROM BUILDER\n",
+		1, []int{0, 1},
+	),
+	Entry("FROM the named stage with meta ARGs",
+		"ARG BASE=alpine\nFROM $BASE AS base\nFROM base\n",
+		1, []int{0, 1},
+	),
+	Entry("image with the name of the next stage",
+		"FROM base\nFROM alpine AS base\n",
+		0, []int{0},
+	),
+	Entry("transitive COPY --from of the unnamed stages",
+		"FROM alpine\nRUN a\nFROM alpine\nCOPY --from=0 /a /a\nFROM alpine\nCOPY --from=1 /a /a\n",
+		2, []int{0, 1, 2},
+	),
+	Entry("COPY --from the named stage",
+		"FROM alpine AS a\nFROM alpine\nCOPY --from=A /a /a\n",
+		1, []int{0, 1},
+	),
+	Entry("RUN --mount=from",
+		"FROM alpine\nFROM alpine AS b\nRUN --mount=type=bind,from=0,target=/a true\n",
+		1, []int{0, 1},
+	),
+	Entry("target is not the last stage",
+		"FROM alpine AS a\nFROM alpine AS b\nCOPY --from=0 /a /a\nFROM alpine\nCOPY --from=1 /b /b\n",
+		1, []int{0, 1},
+	),
+	Entry("unused stages are skipped",
+		"FROM alpine AS a\nFROM alpine AS unused\nFROM a\nCOPY --from=0 /a /a\n",
+		2, []int{0, 2},
+	),
+)
+
+var _ = DescribeTable("GenerateStagedDockerfileStages generates the stages with the Dockerfile, where the preceding stages are replaced",
+	func(dockerfile string, targetIndex int, expectedNames []string, expectedDockerfiles []string) {
+		ds := newTestDockerStages(dockerfile, nil, targetIndex)
+		stages := GenerateStagedDockerfileStages(NewDockerRunArgs("Dockerfile", "", "", nil, nil, nil, "", "", nil), ds, nil, []byte(dockerfile), &NewBaseStageOptions{})
+
+		var names []string
+		var dockerfiles []string
+		for _, s := range stages {
+			names = append(names, string(s.Name()))
+
+			data, err := s.stagedDockerfileData()
+			Ω(err).ShouldNot(HaveOccurred())
+			dockerfiles = append(dockerfiles, string(data))
+
+			s.SetImage(container_runtime.NewStageImage(nil, fmt.Sprintf("image-%s", s.Name()), nil))
+		}
+
+		Ω(names).Should(Equal(expectedNames))
+		Ω(dockerfiles).Should(Equal(expectedDockerfiles))
+	},
+	Entry("parser directives, meta ARGs and unnamed stages",
+		"# syntax=docker/dockerfile:1\nARG BASE=alpine\nFROM $BASE\nRUN a\nFROM $BASE AS app\nCOPY --from=0 /a /a\n",
+		-1,
+		[]string{"dockerfile-0", "dockerfile"},
+		[]string{
+			"# syntax=docker/dockerfile:1\nARG BASE=alpine\nFROM $BASE\nRUN a",
+			"# syntax=docker/dockerfile:1\nARG BASE=alpine\nFROM image-dockerfile-0\nFROM $BASE AS app\nCOPY --from=0 /a /a\n",
+		},
+	),
+	Entry("transitive dependencies",
+		"FROM alpine AS a\nRUN a\nFROM a AS b\nRUN b\nFROM alpine\nCOPY --from=b /b /b\n",
+		-1,
+		[]string{"dockerfile-a", "dockerfile-b", "dockerfile"},
+		[]string{
+			"FROM alpine AS a\nRUN a",
+			"FROM image-dockerfile-a AS a\nFROM a AS b\nRUN b",
+			"FROM image-dockerfile-a AS a\nFROM image-dockerfile-b AS b\nFROM alpine\nCOPY --from=b /b /b\n",
+		},
+	),
+	Entry("target is not the last stage, unused stages are replaced by scratch",
+		"FROM alpine AS a\nRUN a\nFROM alpine AS unused\nRUN b\nFROM a AS target\nCOPY --from=0 /a /b\nFROM alpine\nRUN c\n",
+		2,
+		[]string{"dockerfile-a", "dockerfile"},
+		[]string{
+			"FROM alpine AS a\nRUN a",
+			"FROM image-dockerfile-a AS a\nFROM scratch AS unused\nFROM a AS target\nCOPY --from=0 /a /b",
+		},
+	),
+)
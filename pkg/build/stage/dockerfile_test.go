@@ -0,0 +1,225 @@
+package stage
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/util"
+)
+
+var _ = Describe("DockerStages", func() {
+	DescribeTable("BaseImageNames returns the resolved base images which are not the previous stages",
+		func(dockerfile string, buildArgs map[string]string, expected []string) {
+			ds := newTestDockerStages(dockerfile, buildArgs, -1)
+
+			names, err := ds.BaseImageNames()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(names).Should(Equal(expected))
+		},
+		Entry("single stage",
+			"FROM alpine:3.14\nRUN true\n",
+			nil,
+			[]string{"alpine:3.14"},
+		),
+		Entry("named and unnamed stages",
+			"FROM golang:1.16 AS builder\nRUN true\nFROM builder\nFROM alpine:3.14\nCOPY --from=builder /app /app\n",
+			nil,
+			[]string{"golang:1.16", "alpine:3.14"},
+		),
+		Entry("stage names are case insensitive",
+			"FROM golang:1.16 AS Builder\nFROM builder\n",
+			nil,
+			[]string{"golang:1.16"},
+		),
+		Entry("image with the name of the next stage",
+			"FROM base\nFROM alpine:3.14 AS base\n",
+			nil,
+			[]string{"base", "alpine:3.14"},
+		),
+		Entry("meta args and build args",
+			"ARG BASE=alpine\nARG TAG=3.13\nFROM $BASE:${TAG}\nFROM ${BASE}:3.14\n",
+			map[string]string{"TAG": "3.12"},
+			[]string{"alpine:3.12", "alpine:3.14"},
+		),
+		Entry("duplicates",
+			"FROM alpine:3.14\nFROM alpine:3.14\n",
+			nil,
+			[]string{"alpine:3.14"},
+		),
+	)
+
+	DescribeTable("AddGitSources returns the resolved remote git repository sources of the ADD instructions",
+		func(dockerfile string, buildArgs map[string]string, expected []*DockerfileAddGitSource) {
+			sources, err := newTestDockerStages(dockerfile, buildArgs, -1).AddGitSources()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(sources).Should(Equal(expected))
+		},
+		Entry("no git sources",
+			"FROM alpine:3.14\nADD app.tar.gz https://example.com/file /app/\nCOPY . /app\n",
+			nil,
+			nil,
+		),
+		Entry("git sources of all stages",
+			"FROM alpine:3.14 AS builder\nADD https://github.com/werf/werf.git#main /src\nFROM alpine:3.14\nADD git@github.com:werf/werf.git /src\n",
+			nil,
+			[]*DockerfileAddGitSource{{Url: "https://github.com/werf/werf.git", Ref: "main"}, {Url: "git@github.com:werf/werf.git"}},
+		),
+		Entry("ARG and ENV values",
+			"FROM alpine:3.14\nARG REF=main\nENV REPO=https://github.com/werf/werf.git\nADD ${REPO}#${REF}:docs /docs\n",
+			map[string]string{"REF": "v1.2.0"},
+			[]*DockerfileAddGitSource{{Url: "https://github.com/werf/werf.git", Ref: "v1.2.0"}},
+		),
+	)
+
+	It("uses the locked base image reference in the dependencies", func() {
+		ds := newTestDockerStages("FROM alpine:3.14\n", nil, -1)
+		s := newDockerfileStage(NewDockerRunArgs("Dockerfile", "", "", nil, nil, nil, "", "", nil), ds, nil, &NewBaseStageOptions{})
+
+		digest, err := s.GetDependencies(context.Background(), nil, nil, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		ds.SetLockedBaseImage("alpine:3.14", "alpine@sha256:aaa")
+		lockedDigest, err := s.GetDependencies(context.Background(), nil, nil, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(lockedDigest).ShouldNot(Equal(digest))
+	})
+})
+
+var _ = Describe("DockerfileStage instruction dependencies", func() {
+	var conveyor *conveyorStub
+	var gitRepo *gitRepoStub
+
+	BeforeEach(func() {
+		conveyor = &conveyorStub{}
+		gitRepo = &gitRepoStub{}
+	})
+
+	// getDependencies returns the dependencies of the first stage instructions, the context is the remote git repository stub.
+	getDependencies := func(dockerfile string) [][]string {
+		ds := newTestDockerStages(dockerfile, nil, -1)
+		dockerRunArgs := NewDockerRunArgs("Dockerfile", "", "", nil, nil, nil, "", "", nil)
+		dockerRunArgs.SetRemoteContext(gitRepo, "commit")
+		s := newDockerfileStage(dockerRunArgs, ds, NewContextChecksum(path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{})), &NewBaseStageOptions{})
+
+		var result [][]string
+		for _, cmd := range ds.dockerStages[0].Commands {
+			dependencies, _, err := s.dockerfileInstructionDependencies(context.Background(), conveyor, 0, cmd, false, false)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			result = append(result, dependencies)
+		}
+
+		return result
+	}
+
+	It("resolves the ADD git sources to the commits and does not match the remote sources against the build context", func() {
+		dependencies := getDependencies(`FROM alpine
+ARG REPO=https://github.com/werf/werf.git
+ADD ${REPO}#v1.2.0:docs /docs
+ADD $REPO /werf
+ADD https://example.com/file.tar.gz /file.tar.gz
+`)
+
+		Ω(conveyor.remoteGitRepoRefs).Should(Equal([]string{"https://github.com/werf/werf.git#v1.2.0", "https://github.com/werf/werf.git#"}))
+		Ω(dependencies[1]).Should(Equal([]string{"ADD ${REPO}#v1.2.0:docs /docs", "commit"}))
+		Ω(dependencies[2]).Should(Equal([]string{"ADD $REPO /werf", "commit"}))
+		Ω(dependencies[3]).Should(Equal([]string{"ADD https://example.com/file.tar.gz /file.tar.gz"}))
+		Ω(gitRepo.pathMatchers).Should(BeEmpty())
+	})
+
+	It("matches the ADD local sources against the build context", func() {
+		dependencies := getDependencies(`FROM alpine
+ARG SRC=src
+ADD ${SRC}/app.tar.gz /app/
+`)
+
+		Ω(conveyor.remoteGitRepoRefs).Should(BeEmpty())
+		Ω(dependencies[1]).Should(Equal([]string{"ADD ${SRC}/app.tar.gz /app/", util.Sha256Hash("checksum")}))
+		Ω(gitRepo.pathMatchers).Should(HaveLen(1))
+		Ω(gitRepo.pathMatchers[0].IsPathMatched("src/app.tar.gz")).Should(BeTrue())
+		Ω(gitRepo.pathMatchers[0].IsPathMatched("src/other.tar.gz")).Should(BeFalse())
+	})
+
+	It("matches the RUN --mount=type=bind sources against the build context", func() {
+		dependencies := getDependencies(`FROM alpine
+ARG SRC=src
+RUN --mount=type=bind,source=${SRC},target=/src --mount=type=bind,from=0,target=/from --mount=type=cache,target=/cache make
+RUN --mount=type=bind,target=/context ls /context
+RUN --mount=type=cache,target=/cache true
+`)
+
+		Ω(dependencies[1]).Should(HaveLen(2))
+		Ω(dependencies[1][1]).Should(Equal(util.Sha256Hash("checksum")))
+		Ω(dependencies[2]).Should(HaveLen(2))
+		Ω(dependencies[3]).Should(HaveLen(1))
+
+		Ω(gitRepo.pathMatchers).Should(HaveLen(2))
+		Ω(gitRepo.pathMatchers[0].IsPathMatched("src/main.go")).Should(BeTrue())
+		Ω(gitRepo.pathMatchers[0].IsPathMatched("other/main.go")).Should(BeFalse())
+		Ω(gitRepo.pathMatchers[1].IsPathMatched("other/main.go")).Should(BeTrue())
+	})
+
+	DescribeTable("parseDockerfileAddGitSource returns the repository url and the reference",
+		func(source, expectedUrl, expectedRef string) {
+			url, ref := parseDockerfileAddGitSource(source)
+			Ω(url).Should(Equal(expectedUrl))
+			Ω(ref).Should(Equal(expectedRef))
+		},
+		Entry("without reference", "https://github.com/werf/werf.git", "https://github.com/werf/werf.git", ""),
+		Entry("reference", "https://github.com/werf/werf.git#main", "https://github.com/werf/werf.git", "main"),
+		Entry("reference and subdirectory", "git@github.com:werf/werf.git#v1.2.0:docs", "git@github.com:werf/werf.git", "v1.2.0"),
+		Entry("subdirectory only", "git://example.com/repo#:docs", "git://example.com/repo", ""),
+		Entry("short github url", "github.com/werf/werf#main", "https://github.com/werf/werf", "main"),
+	)
+})
+
+type conveyorStub struct {
+	Conveyor
+	remoteGitRepoRefs []string
+}
+
+func (c *conveyorStub) GiterminismManager() giterminism_manager.Interface {
+	return nil
+}
+
+func (c *conveyorStub) ResolveRemoteGitRepoCommit(_ context.Context, url, ref string, _ func() error) (string, error) {
+	c.remoteGitRepoRefs = append(c.remoteGitRepoRefs, url+"#"+ref)
+	return "commit", nil
+}
+
+type gitRepoStub struct {
+	git_repo.GitRepo
+	pathMatchers []path_matcher.PathMatcher
+}
+
+func (r *gitRepoStub) GetOrCreateChecksum(_ context.Context, opts git_repo.ChecksumOptions) (string, error) {
+	r.pathMatchers = append(r.pathMatchers, opts.PathMatcher)
+	return "checksum", nil
+}
+
+// newTestDockerStages parses the Dockerfile, the last stage is the target if targetIndex is negative.
+func newTestDockerStages(dockerfile string, buildArgs map[string]string, targetIndex int) *DockerStages {
+	p, err := parser.Parse(bytes.NewReader([]byte(dockerfile)))
+	Ω(err).ShouldNot(HaveOccurred())
+
+	dockerStages, dockerMetaArgs, err := instructions.Parse(p.AST)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	if targetIndex < 0 {
+		targetIndex = len(dockerStages) - 1
+	}
+
+	ds, err := NewDockerStages(dockerStages, buildArgs, dockerMetaArgs, targetIndex)
+	Ω(err).ShouldNot(HaveOccurred())
+
+	return ds
+}
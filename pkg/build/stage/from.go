@@ -1,6 +1,7 @@
 package stage
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
 	"path"
@@ -58,8 +59,15 @@ func (s *FromStage) GetDependencies(_ context.Context, c Conveyor, prevImage, _
 		args = append(args, s.baseImageRepoIdOrNone)
 	}
 
+	if s.disableStapelToolchain {
+		args = append(args, "disableStapelToolchain")
+	}
+
 	for _, mount := range s.configMounts {
 		args = append(args, filepath.ToSlash(filepath.Clean(mount.From)), path.Clean(mount.To), mount.Type)
+		if mount.Type == "cache" {
+			args = append(args, mount.Id)
+		}
 	}
 
 	if s.fromImageOrArtifactImageName != "" {
@@ -80,13 +88,27 @@ func (s *FromStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage
 	customMounts := s.getCustomMounts(prevBuiltImage)
 	s.addCustomMountLabels(customMounts, image)
 
+	cacheMounts := s.getCacheMounts(prevBuiltImage)
+	s.addCacheMountLabels(cacheMounts, image)
+
 	var mountpoints []string
 	for _, mountCfg := range s.configMounts {
 		mountpoints = append(mountpoints, mountCfg.To)
 	}
 	if len(mountpoints) != 0 {
-		mountpointsStr := strings.Join(mountpoints, " ")
-		image.Container().AddServiceRunCommands(fmt.Sprintf("%s -rf %s", stapel.RmBinPath(), mountpointsStr))
+		if s.disableStapelToolchain {
+			layerPath := filepath.Join(s.imageTmpDir, "layers", "from-mountpoints.tar")
+			if err := writeLayerArchive(layerPath, func(tw *tar.Writer) error {
+				return writeWhiteouts(tw, mountpoints)
+			}); err != nil {
+				return err
+			}
+
+			image.Container().AddLayerArchives(layerPath)
+		} else {
+			mountpointsStr := strings.Join(mountpoints, " ")
+			image.Container().AddServiceRunCommands(fmt.Sprintf("%s -rf %s", stapel.RmBinPath(), mountpointsStr))
+		}
 	}
 
 	return nil
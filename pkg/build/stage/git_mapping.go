@@ -1,9 +1,11 @@
 package stage
 
 import (
+	"archive/tar"
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -44,6 +46,10 @@ type GitMapping struct {
 	ScriptsDir           string
 	ContainerScriptsDir  string
 
+	// DisableStapelToolchain makes git archives and patches to be applied as image layers prepared in the LayersDir on the host
+	DisableStapelToolchain bool
+	LayersDir              string
+
 	BaseCommitByPrevBuiltImageName map[string]string
 
 	mutexes map[string]*sync.Mutex
@@ -258,13 +264,19 @@ func (gm *GitMapping) ApplyPatchCommand(ctx context.Context, c Conveyor, prevBui
 		return fmt.Errorf("unable to get latest commit info: %s", err)
 	}
 
-	commands, err := gm.baseApplyPatchCommand(ctx, fromCommit, toCommitInfo.Commit, prevBuiltImage)
-	if err != nil {
-		return err
-	}
+	if gm.DisableStapelToolchain {
+		if err := gm.baseApplyPatchLayer(ctx, fromCommit, toCommitInfo.Commit, image); err != nil {
+			return err
+		}
+	} else {
+		commands, err := gm.baseApplyPatchCommand(ctx, fromCommit, toCommitInfo.Commit, prevBuiltImage)
+		if err != nil {
+			return err
+		}
 
-	if err := gm.applyScript(image, commands); err != nil {
-		return err
+		if err := gm.applyScript(image, commands); err != nil {
+			return err
+		}
 	}
 
 	gm.AddGitCommitToImageLabels(image, toCommitInfo)
@@ -587,13 +599,19 @@ func (gm *GitMapping) ApplyArchiveCommand(ctx context.Context, c Conveyor, image
 		return fmt.Errorf("unable to get latest commit info: %s", err)
 	}
 
-	commands, err := gm.baseApplyArchiveCommand(ctx, commitInfo.Commit, image)
-	if err != nil {
-		return err
-	}
+	if gm.DisableStapelToolchain {
+		if err := gm.baseApplyArchiveLayer(ctx, commitInfo.Commit, image); err != nil {
+			return err
+		}
+	} else {
+		commands, err := gm.baseApplyArchiveCommand(ctx, commitInfo.Commit, image)
+		if err != nil {
+			return err
+		}
 
-	if err := gm.applyScript(image, commands); err != nil {
-		return err
+		if err := gm.applyScript(image, commands); err != nil {
+			return err
+		}
 	}
 
 	gm.AddGitCommitToImageLabels(image, commitInfo)
@@ -645,6 +663,179 @@ func (gm *GitMapping) baseApplyArchiveCommand(ctx context.Context, commit string
 	return commands, err
 }
 
+func (gm *GitMapping) baseApplyArchiveLayer(ctx context.Context, commit string, image container_runtime.ImageInterface) error {
+	archiveOpts, err := gm.makeArchiveOptions(ctx, commit)
+	if err != nil {
+		return err
+	}
+
+	archive, err := gm.GitRepo().GetOrCreateArchive(ctx, *archiveOpts)
+	if err != nil {
+		return fmt.Errorf("unable to create git archive for commit %s with path scope %s: %s", archiveOpts.Commit, archiveOpts.PathScope, err)
+	}
+
+	archiveType, err := gm.getArchiveType(ctx, commit)
+	if err != nil {
+		return err
+	}
+
+	layerPath := filepath.Join(gm.LayersDir, fmt.Sprintf("%s-archive-%s.tar", gm.GetParamshash(), commit))
+	if err := writeLayerArchive(layerPath, func(tw *tar.Writer) error {
+		return gm.writeArchiveLayerEntries(tw, archive, archiveType, nil)
+	}); err != nil {
+		return err
+	}
+
+	image.Container().AddLayerArchives(layerPath)
+	image.Container().ServiceCommitChangeOptions().AddLabel(map[string]string{gm.getArchiveTypeLabelName(): string(archiveType)})
+
+	return nil
+}
+
+// baseApplyPatchLayer adds the changed files from the toCommit archive and removes the deleted ones with whiteouts.
+// Unlike the patch applied in the container, directories that become empty are kept.
+func (gm *GitMapping) baseApplyPatchLayer(ctx context.Context, fromCommit, toCommit string, image container_runtime.ImageInterface) error {
+	patchOpts, err := gm.makePatchOptions(ctx, fromCommit, toCommit, false, false)
+	if err != nil {
+		return err
+	}
+
+	patch, err := gm.GitRepo().GetOrCreatePatch(ctx, *patchOpts)
+	if err != nil {
+		return err
+	}
+
+	if patch.IsEmpty() {
+		return nil
+	}
+
+	archiveOpts, err := gm.makeArchiveOptions(ctx, toCommit)
+	if err != nil {
+		return err
+	}
+
+	archive, err := gm.GitRepo().GetOrCreateArchive(ctx, *archiveOpts)
+	if err != nil {
+		return fmt.Errorf("unable to create git archive for commit %s with path scope %s: %s", archiveOpts.Commit, archiveOpts.PathScope, err)
+	}
+
+	archiveType, err := gm.getArchiveType(ctx, toCommit)
+	if err != nil {
+		return err
+	}
+
+	layerPath := filepath.Join(gm.LayersDir, fmt.Sprintf("%s-patch-%s-%s.tar", gm.GetParamshash(), fromCommit, toCommit))
+	if err := writeLayerArchive(layerPath, func(tw *tar.Writer) error {
+		if archiveType == git_repo.FileArchive {
+			return gm.writeArchiveLayerEntries(tw, archive, archiveType, nil)
+		}
+
+		archivePaths, err := getArchivePaths(archive)
+		if err != nil {
+			return err
+		}
+
+		changedPaths := map[string]bool{}
+		var removedPaths []string
+		for _, p := range patch.GetPaths() {
+			if archivePaths[p] {
+				changedPaths[p] = true
+			} else {
+				removedPaths = append(removedPaths, path.Join(gm.To, p))
+			}
+		}
+
+		if err := writeWhiteouts(tw, removedPaths); err != nil {
+			return err
+		}
+
+		return gm.writeArchiveLayerEntries(tw, archive, archiveType, changedPaths)
+	}); err != nil {
+		return err
+	}
+
+	image.Container().AddLayerArchives(layerPath)
+
+	return nil
+}
+
+// writeArchiveLayerEntries writes the archive entries (all or only the selected ones) into the unpack directory with the git mapping owner and group.
+func (gm *GitMapping) writeArchiveLayerEntries(tw *tar.Writer, archive git_repo.Archive, archiveType git_repo.ArchiveType, selectedPaths map[string]bool) error {
+	var unpackArchiveDirectory string
+	switch archiveType {
+	case git_repo.FileArchive:
+		unpackArchiveDirectory = path.Dir(gm.To)
+	case git_repo.DirectoryArchive:
+		unpackArchiveDirectory = gm.To
+	default:
+		return fmt.Errorf("unknown archive type `%s`", archiveType)
+	}
+
+	unpackDirHdr := &tar.Header{Typeflag: tar.TypeDir, Name: unpackArchiveDirectory, Mode: 0755}
+	setLayerEntryOwner(unpackDirHdr, gm.Owner, gm.Group)
+	if err := tw.WriteHeader(unpackDirHdr); err != nil {
+		return err
+	}
+
+	f, err := os.Open(archive.GetFilePath())
+	if err != nil {
+		return fmt.Errorf("unable to open archive %s: %s", archive.GetFilePath(), err)
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("unable to read archive %s: %s", archive.GetFilePath(), err)
+		}
+
+		if selectedPaths != nil && !selectedPaths[hdr.Name] {
+			continue
+		}
+
+		layerHdr := *hdr
+		layerHdr.Name = path.Join(unpackArchiveDirectory, hdr.Name)
+		setLayerEntryOwner(&layerHdr, gm.Owner, gm.Group)
+
+		if err := tw.WriteHeader(&layerHdr); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getArchivePaths(archive git_repo.Archive) (map[string]bool, error) {
+	f, err := os.Open(archive.GetFilePath())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive %s: %s", archive.GetFilePath(), err)
+	}
+	defer f.Close()
+
+	paths := map[string]bool{}
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read archive %s: %s", archive.GetFilePath(), err)
+		}
+
+		paths[hdr.Name] = true
+	}
+
+	return paths, nil
+}
+
 func (gm *GitMapping) StageDependenciesChecksum(ctx context.Context, c Conveyor, stageName StageName) (string, error) {
 	depsPaths := gm.StagesDependencies[stageName]
 	if len(depsPaths) == 0 {
@@ -84,6 +84,10 @@ func (s *GitPatchStage) prepareImage(ctx context.Context, c Conveyor, prevBuiltI
 		}
 	}
 
+	if s.disableStapelToolchain {
+		return nil
+	}
+
 	image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s:ro", git_repo.CommonGitDataManager.GetPatchesCacheDir(), s.ContainerPatchesDir))
 	image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s:ro", git_repo.CommonGitDataManager.GetArchivesCacheDir(), s.ContainerArchivesDir))
 	image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s:ro", s.ScriptsDir, s.ContainerScriptsDir))
@@ -3,17 +3,13 @@ package stage
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path"
-	"path/filepath"
 	"strings"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/config"
 	"github.com/werf/werf/pkg/container_runtime"
-	"github.com/werf/werf/pkg/docker"
 	imagePkg "github.com/werf/werf/pkg/image"
 	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/storage"
@@ -80,8 +76,18 @@ func (s *ImportsStage) PrepareImage(ctx context.Context, c Conveyor, _, image co
 			return fmt.Errorf("unable to get import server for image %q: %s", sourceImageName, err)
 		}
 
-		command := srv.GetCopyCommand(ctx, elm)
-		image.Container().AddServiceRunCommands(command)
+		archivePath, err := srv.PrepareImportArchive(ctx, elm)
+		if err != nil {
+			return fmt.Errorf("unable to prepare import archive for image %q: %s", sourceImageName, err)
+		}
+
+		if s.disableStapelToolchain {
+			image.Container().AddLayerArchives(archivePath)
+		} else {
+			containerArchivePath := path.Join(s.containerWerfDir, "imports", fmt.Sprintf("%s.tar", getImportID(elm)))
+			image.Container().RunOptions().AddVolume(fmt.Sprintf("%s:%s:ro", archivePath, containerArchivePath))
+			image.Container().AddServiceRunCommands(generateImportCommand(elm, containerArchivePath))
+		}
 
 		imageServiceCommitChangeOptions := image.Container().ServiceCommitChangeOptions()
 
@@ -131,98 +137,22 @@ func (s *ImportsStage) getImportSourceChecksum(ctx context.Context, c Conveyor,
 }
 
 func (s *ImportsStage) generateImportChecksum(ctx context.Context, c Conveyor, importElm *config.Import) (string, error) {
-	sourceImageDockerImageName := getSourceImageDockerImageName(c, importElm)
-	importSourceID := getImportSourceID(c, importElm)
-
-	stapelContainerName, err := stapel.GetOrCreateContainer(ctx)
-	if err != nil {
-		return "", err
-	}
-
-	importHostTmpDir := filepath.Join(s.imageTmpDir, string(s.Name()), "imports", importSourceID)
-	importContainerDir := s.containerWerfDir
-
-	importScriptHostTmpPath := filepath.Join(importHostTmpDir, "script.sh")
-	resultChecksumHostTmpPath := filepath.Join(importHostTmpDir, "checksum")
-	importScriptContainerPath := path.Join(importContainerDir, "script.sh")
-	resultChecksumContainerPath := path.Join(importContainerDir, "checksum")
-
-	command := generateChecksumCommand(importElm.Add, importElm.IncludePaths, importElm.ExcludePaths, resultChecksumContainerPath)
-	if err := stapel.CreateScript(importScriptHostTmpPath, []string{command}); err != nil {
-		return "", fmt.Errorf("unable to create script: %s", err)
-	}
-
-	runArgs := []string{
-		"--rm",
-		"--user=0:0",
-		"--workdir=/",
-		fmt.Sprintf("--volumes-from=%s", stapelContainerName),
-		fmt.Sprintf("--volume=%s:%s", importHostTmpDir, importContainerDir),
-		fmt.Sprintf("--entrypoint=%s", stapel.BashBinPath()),
-		sourceImageDockerImageName,
-		importScriptContainerPath,
-	}
-
-	if debugImportSourceChecksum() {
-		fmt.Println(runArgs)
-	}
-
-	if output, err := docker.CliRun_RecordedOutput(ctx, runArgs...); err != nil {
-		logboek.Context(ctx).Error().LogF("%s", output)
-		return "", err
-	}
-
-	data, err := ioutil.ReadFile(resultChecksumHostTmpPath)
+	sourceImageName := getSourceImageName(importElm)
+	srv, err := c.GetImportServer(ctx, sourceImageName, importElm.Stage)
 	if err != nil {
-		return "", fmt.Errorf("unable to read file with import source checksum: %s", err)
+		return "", fmt.Errorf("unable to get import server for image %q: %s", sourceImageName, err)
 	}
 
-	checksum := strings.TrimSpace(string(data))
-	return checksum, nil
+	return srv.GetImportChecksum(ctx, importElm)
 }
 
-func generateChecksumCommand(from string, includePaths, excludePaths []string, resultChecksumPath string) string {
-	findCommandParts := append([]string{}, stapel.FindBinPath(), from, "-type", "f")
-
-	var nameIncludeArgs []string
-	for _, includePath := range includePaths {
-		nameIncludeArgs = append(nameIncludeArgs, fmt.Sprintf("-wholename \"%s\"", path.Join(from, includePath)))
-	}
-
-	if len(nameIncludeArgs) != 0 {
-		findCommandParts = append(findCommandParts, fmt.Sprintf("\\( %s \\)", strings.Join(nameIncludeArgs, " -or ")))
-	}
-
-	var nameExcludeArgs []string
-	for _, excludePath := range excludePaths {
-		nameExcludeArgs = append(nameExcludeArgs, fmt.Sprintf("! -wholename \"%s\"", path.Join(from, excludePath)))
-	}
-
-	if len(nameExcludeArgs) != 0 {
-		if len(nameIncludeArgs) != 0 {
-			findCommandParts = append(findCommandParts, fmt.Sprintf("-and"))
-		}
-
-		findCommandParts = append(findCommandParts, fmt.Sprintf("\\( %s \\)", strings.Join(nameExcludeArgs, " -and ")))
-	}
-
-	findCommand := strings.Join(findCommandParts, " ")
-
-	sortCommandParts := append([]string{}, stapel.SortBinPath(), "-n")
-	sortCommand := strings.Join(sortCommandParts, " ")
-
-	xargsCommandParts := append([]string{}, stapel.XargsBinPath(), stapel.Md5sumBinPath())
-	xargsCommand := strings.Join(xargsCommandParts, " ")
-
-	md5SumCommand := stapel.Md5sumBinPath()
-
-	cutCommandParts := append([]string{}, stapel.CutBinPath(), "-d", "' '", "-f", "1")
-	cutCommand := strings.Join(cutCommandParts, " ")
-
-	commands := append([]string{}, findCommand, sortCommand, xargsCommand, md5SumCommand, cutCommand)
-	command := fmt.Sprintf("%s > %s", strings.Join(commands, " | "), resultChecksumPath)
-
-	return command
+// generateImportCommand unpacks the import archive prepared on the host into the stage container root.
+// The archive owner and group names are resolved against the stage container users.
+func generateImportCommand(importElm *config.Import, containerArchivePath string) string {
+	return strings.Join([]string{
+		fmt.Sprintf("%s -p %s", stapel.MkdirBinPath(), path.Dir(importElm.To)),
+		fmt.Sprintf("%s -xpf %s --same-owner -C /", stapel.TarBinPath(), containerArchivePath),
+	}, " && ")
 }
 
 func getImportID(importElm *config.Import) string {
@@ -241,8 +171,13 @@ func getImportID(importElm *config.Import) string {
 	)
 }
 
+// importSourceChecksumVersion is changed with the import checksum algorithm so that the import metadata calculated by the previous werf versions is not reused.
+// Version 2 is the sha256 of the files content, symlinks and hard links read from the source image layers instead of the md5 of the files content calculated in the source image container.
+const importSourceChecksumVersion = "2"
+
 func getImportSourceID(c Conveyor, importElm *config.Import) string {
 	return util.Sha256Hash(
+		"ChecksumVersion", importSourceChecksumVersion,
 		"SourceImageContentDigest", getSourceImageContentDigest(c, importElm),
 		"Add", importElm.Add,
 		"IncludePaths", strings.Join(importElm.IncludePaths, "///"),
@@ -299,7 +234,3 @@ func getSourceImageName(importElm *config.Import) string {
 
 	return sourceImageName
 }
-
-func debugImportSourceChecksum() bool {
-	return os.Getenv("WERF_DEBUG_IMPORT_SOURCE_CHECKSUM") == "1"
-}
@@ -34,7 +34,7 @@ func (s *InstallStage) GetDependencies(ctx context.Context, c Conveyor, _, _ con
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.InstallChecksum(ctx), stageDependenciesChecksum), nil
+	return s.withStageDependenciesChecksum(ctx, Install, util.Sha256Hash(s.builder.InstallChecksum(ctx), stageDependenciesChecksum))
 }
 
 func (s *InstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -42,9 +42,17 @@ func (s *InstallStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltIm
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image); err != nil {
+		return err
+	}
+
 	if err := s.builder.Install(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
 
+	if err := s.addSecretsLeakCheck(image); err != nil {
+		return err
+	}
+
 	return nil
 }
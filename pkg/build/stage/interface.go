@@ -20,6 +20,8 @@ type Interface interface {
 	PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error
 
 	PreRunHook(context.Context, Conveyor) error
+	PostRunHook(context.Context, Conveyor) error
+	WithCacheMountsLock(ctx context.Context, f func() error) error
 
 	SetDigest(digest string)
 	GetDigest() string
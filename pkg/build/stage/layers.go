@@ -0,0 +1,66 @@
+package stage
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+)
+
+// writeLayerArchive writes the archive which is added to the stage image as is, without running any commands (see container_runtime.Container.AddLayerArchives).
+func writeLayerArchive(layerPath string, writeEntries func(tw *tar.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(layerPath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(layerPath), err)
+	}
+
+	f, err := os.Create(layerPath)
+	if err != nil {
+		return fmt.Errorf("unable to create file %s: %s", layerPath, err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	if err := writeEntries(tw); err != nil {
+		return fmt.Errorf("unable to write layer archive %s: %s", layerPath, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("unable to write layer archive %s: %s", layerPath, err)
+	}
+
+	return f.Close()
+}
+
+// writeWhiteouts writes the whiteout entries, that remove the paths from the image when the layer is applied.
+func writeWhiteouts(tw *tar.Writer, paths []string) error {
+	for _, p := range paths {
+		p = path.Clean(p)
+		whiteoutName := path.Join(path.Dir(p), ".wh."+path.Base(p))
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: whiteoutName, Mode: 0600}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setLayerEntryOwner sets the entry owner and group, names are resolved against the image /etc/passwd and /etc/group when the layer is applied.
+func setLayerEntryOwner(hdr *tar.Header, owner, group string) {
+	if owner != "" {
+		if uid, err := strconv.Atoi(owner); err == nil {
+			hdr.Uid, hdr.Uname = uid, ""
+		} else {
+			hdr.Uname = owner
+		}
+	}
+
+	if group != "" {
+		if gid, err := strconv.Atoi(group); err == nil {
+			hdr.Gid, hdr.Gname = gid, ""
+		} else {
+			hdr.Gname = group
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package stage
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("layer archives", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-stage-layers-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	DescribeTable("writeWhiteouts writes the whiteout entry next to the removed path",
+		func(paths []string, expected []string) {
+			layerPath := filepath.Join(tmpDir, "layers", "layer.tar")
+			Ω(writeLayerArchive(layerPath, func(tw *tar.Writer) error {
+				return writeWhiteouts(tw, paths)
+			})).Should(Succeed())
+
+			var names []string
+			for _, hdr := range readTestLayerHeaders(layerPath) {
+				Ω(hdr.Typeflag).Should(Equal(byte(tar.TypeReg)))
+				Ω(hdr.Size).Should(BeZero())
+				names = append(names, hdr.Name)
+			}
+
+			Ω(names).Should(Equal(expected))
+		},
+		Entry("no paths", nil, nil),
+		Entry("root path", []string{"file"}, []string{".wh.file"}),
+		Entry("nested paths", []string{"app/file", "app/dir/sub"}, []string{"app/.wh.file", "app/dir/.wh.sub"}),
+		Entry("directory", []string{"app/dir/"}, []string{"app/.wh.dir"}),
+		Entry("absolute path", []string{"/var/cache/"}, []string{"/var/.wh.cache"}),
+	)
+
+	DescribeTable("setLayerEntryOwner sets the ids or the names to resolve",
+		func(owner, group string, expected tar.Header) {
+			hdr := &tar.Header{Uid: 1, Gid: 2, Uname: "root", Gname: "root"}
+			setLayerEntryOwner(hdr, owner, group)
+			Ω(*hdr).Should(Equal(expected))
+		},
+		Entry("not set", "", "", tar.Header{Uid: 1, Gid: 2, Uname: "root", Gname: "root"}),
+		Entry("ids", "1000", "2000", tar.Header{Uid: 1000, Gid: 2000}),
+		Entry("names", "app", "www", tar.Header{Uid: 1, Gid: 2, Uname: "app", Gname: "www"}),
+	)
+})
+
+func readTestLayerHeaders(layerPath string) []*tar.Header {
+	f, err := os.Open(layerPath)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+
+	var hdrs []*tar.Header
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return hdrs
+		}
+		Ω(err).ShouldNot(HaveOccurred())
+
+		hdrs = append(hdrs, hdr)
+	}
+}
@@ -34,7 +34,7 @@ func (s *SetupStage) GetDependencies(ctx context.Context, c Conveyor, _, _ conta
 		return "", err
 	}
 
-	return util.Sha256Hash(s.builder.SetupChecksum(ctx), stageDependenciesChecksum), nil
+	return s.withStageDependenciesChecksum(ctx, Setup, util.Sha256Hash(s.builder.SetupChecksum(ctx), stageDependenciesChecksum))
 }
 
 func (s *SetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImage, image container_runtime.ImageInterface) error {
@@ -42,9 +42,17 @@ func (s *SetupStage) PrepareImage(ctx context.Context, c Conveyor, prevBuiltImag
 		return err
 	}
 
+	if err := s.addSecretsVolumes(image); err != nil {
+		return err
+	}
+
 	if err := s.builder.Setup(ctx, image.BuilderContainer()); err != nil {
 		return err
 	}
 
+	if err := s.addSecretsLeakCheck(image); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -2,18 +2,30 @@ package stage
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/build/builder"
 	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/container_runtime"
+	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/stapel"
 	"github.com/werf/werf/pkg/util"
 )
 
 func getBuilder(imageBaseConfig *config.StapelImageBase, baseStageOptions *NewBaseStageOptions) builder.Builder {
 	var b builder.Builder
-	extra := &builder.Extra{ContainerWerfPath: baseStageOptions.ContainerWerfDir, TmpPath: baseStageOptions.ImageTmpDir}
+	extra := &builder.Extra{
+		ContainerWerfPath:      baseStageOptions.ContainerWerfDir,
+		TmpPath:                baseStageOptions.ImageTmpDir,
+		DisableStapelToolchain: imageBaseConfig.DisableStapelToolchain,
+		AnsibleFiles:           baseStageOptions.AnsibleFiles,
+	}
 	if imageBaseConfig.Shell != nil {
 		b = builder.NewShellBuilder(imageBaseConfig.Shell, extra)
 	} else if imageBaseConfig.Ansible != nil {
@@ -57,6 +69,228 @@ func (s *UserStage) getStageDependenciesChecksum(ctx context.Context, c Conveyor
 	return util.Sha256Hash(args...), nil
 }
 
+// withStageDependenciesChecksum adds the checksum of the stage dependencies on environment variables and files of the custom mounts to the stage checksum.
+// The stage checksum is not changed if there are no such dependencies.
+func (s *UserStage) withStageDependenciesChecksum(ctx context.Context, name StageName, checksum string) (string, error) {
+	envAndFilesChecksum, err := s.getStageDependenciesEnvAndFilesChecksum(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	if envAndFilesChecksum == "" {
+		return checksum, nil
+	}
+
+	return util.Sha256Hash(checksum, envAndFilesChecksum), nil
+}
+
+// setRunStepsBaseChecksum passes the stage inputs that are not the part of the fine-grained steps to the steps cumulative checksum:
+// the stage dependencies on environment variables and files of the custom mounts and the extra args (e.g. git patch commits).
+func (s *UserStage) setRunStepsBaseChecksum(ctx context.Context, name StageName, image container_runtime.ImageInterface, args ...string) error {
+	envAndFilesChecksum, err := s.getStageDependenciesEnvAndFilesChecksum(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if envAndFilesChecksum == "" && len(args) == 0 {
+		return nil
+	}
+
+	image.Container().SetRunStepsBaseChecksum(util.Sha256Hash(append([]string{envAndFilesChecksum}, args...)...))
+
+	return nil
+}
+
+// getStageDependenciesEnvAndFilesChecksum returns an empty string if the stage does not depend on environment variables and files of the custom mounts.
+func (s *UserStage) getStageDependenciesEnvAndFilesChecksum(ctx context.Context, name StageName) (string, error) {
+	stageDependencies := s.configStageDependencies.Get(string(name))
+	if stageDependencies == nil {
+		return "", nil
+	}
+
+	var args []string
+	for _, envName := range stageDependencies.Env {
+		if value, ok := os.LookupEnv(envName); ok {
+			args = append(args, fmt.Sprintf("%s=%s", envName, value))
+		} else {
+			args = append(args, envName)
+		}
+	}
+
+	for _, files := range stageDependencies.Files {
+		mount := files.GetMount(s.configMounts)
+		filesChecksum, err := mountFilesChecksum(util.ExpandPath(filepath.Clean(mount.From)), files.Paths)
+		if err != nil {
+			return "", fmt.Errorf("unable to calculate checksum of files of mount %s: %s", files.Mount, err)
+		}
+
+		args = append(args, path.Clean(files.Mount), filesChecksum)
+	}
+
+	if debugUserStageChecksum() {
+		logboek.Context(ctx).Debug().LogFHighlight("DEBUG: %s stage env and mount files dependencies %v\n", name, args)
+	}
+
+	return util.Sha256Hash(args...), nil
+}
+
+// mountFilesChecksum returns the checksum of the paths and contents of the files in the directory that match the globs, all files are matched if there are no globs.
+// The directory may not exist, it is created on the first mount.
+func mountFilesChecksum(dir string, globs []string) (string, error) {
+	pathMatcher := path_matcher.NewPathMatcher(path_matcher.PathMatcherOptions{IncludeGlobs: globs})
+
+	var args []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return nil
+			}
+
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if relPath != "." && !pathMatcher.IsDirOrSubmodulePathMatched(relPath) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !pathMatcher.IsPathMatched(relPath) {
+			return nil
+		}
+
+		var content string
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			content = link
+		} else if info.Mode().IsRegular() {
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			content = string(data)
+		} else {
+			return nil
+		}
+
+		args = append(args, relPath, util.Sha256Hash(content))
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return util.Sha256Hash(args...), nil
+}
+
+// addSecretsVolumes mounts the image secrets into the builder container read-only.
+// Secret values are not taken into account in the stage digest.
+func (s *UserStage) addSecretsVolumes(image container_runtime.ImageInterface) error {
+	for _, secret := range s.configSecrets {
+		value, err := secret.GetValue()
+		if err != nil {
+			return err
+		}
+
+		hostSecretPath := s.hostSecretPath(secret)
+		if err := os.MkdirAll(filepath.Dir(hostSecretPath), 0700); err != nil {
+			return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(hostSecretPath), err)
+		}
+
+		if err := os.RemoveAll(hostSecretPath); err != nil {
+			return fmt.Errorf("unable to remove %s: %s", hostSecretPath, err)
+		}
+
+		if err := ioutil.WriteFile(hostSecretPath, value, 0444); err != nil {
+			return fmt.Errorf("unable to write secret %q: %s", secret.Id, err)
+		}
+
+		image.BuilderContainer().AddVolume(fmt.Sprintf("%s:%s:ro", hostSecretPath, containerSecretPath(secret)))
+	}
+
+	return nil
+}
+
+// addSecretsLeakCheck fails the build if a file created or changed by the user stage commands contains the secret value.
+func (s *UserStage) addSecretsLeakCheck(image container_runtime.ImageInterface) error {
+	for _, secret := range s.configSecrets {
+		stat, err := os.Stat(s.hostSecretPath(secret))
+		if err != nil {
+			return fmt.Errorf("unable to stat secret %q: %s", secret.Id, err)
+		}
+
+		if stat.Size() == 0 {
+			continue
+		}
+
+		// the secret file is written before the stage container is started, so it is the marker for the files changed by the stage
+		secretPath := containerSecretPath(secret)
+		findCommand := fmt.Sprintf(
+			"%s / -xdev \\( -path %s -o -path %s -o -path /proc -o -path /sys -o -path /dev \\) -prune -o -type f -cnewer %s -print0",
+			stapel.FindBinPath(), config.SecretsContainerDir, s.containerWerfDir, secretPath,
+		)
+
+		image.BuilderContainer().AddServiceRunCommands(fmt.Sprintf(
+			"%s | %s -c '%s' %s %s",
+			findCommand, stapel.PythonBinPath(), secretsLeakCheckScript, secretPath, secret.Id,
+		))
+	}
+
+	return nil
+}
+
+// secretsLeakCheckScript searches the secret value as a substring of the files read from stdin, grep is not a part of the stapel toolchain.
+const secretsLeakCheckScript = `import mmap, os, sys
+secret = open(sys.argv[1], "rb").read()
+for path in sys.stdin.read().split("\0"):
+    try:
+        if not path or os.path.getsize(path) < len(secret):
+            continue
+        with open(path, "rb") as f:
+            if mmap.mmap(f.fileno(), 0, access=mmap.ACCESS_READ).find(secret) == -1:
+                continue
+    except (IOError, OSError):
+        continue
+    sys.stderr.write("secret %s leaked into %s: build-time secrets must not be stored in the image\n" % (sys.argv[2], path))
+    sys.exit(1)`
+
+// PostRunHook removes the secret values written for the stage container.
+func (s *UserStage) PostRunHook(_ context.Context, _ Conveyor) error {
+	if len(s.configSecrets) == 0 {
+		return nil
+	}
+
+	if err := os.RemoveAll(s.hostSecretsDir()); err != nil {
+		return fmt.Errorf("unable to remove secrets dir %s: %s", s.hostSecretsDir(), err)
+	}
+
+	return nil
+}
+
+func (s *UserStage) hostSecretsDir() string {
+	return filepath.Join(s.imageTmpDir, "secrets", string(s.Name()))
+}
+
+func (s *UserStage) hostSecretPath(secret *config.Secret) string {
+	return filepath.Join(s.hostSecretsDir(), secret.Id)
+}
+
+func containerSecretPath(secret *config.Secret) string {
+	return path.Join(config.SecretsContainerDir, secret.Id)
+}
+
 func debugUserStageChecksum() bool {
 	return os.Getenv("WERF_DEBUG_USER_STAGE_CHECKSUM") == "1"
 }
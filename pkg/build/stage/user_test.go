@@ -0,0 +1,173 @@
+package stage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+)
+
+var _ = Describe("mountFilesChecksum", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "werf-mount-files-checksum-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		writeFiles(dir, map[string]string{
+			"config.yaml":         "a: 1",
+			"cache/a.lock":        "a",
+			"cache/nested/b.lock": "b",
+			"cache/c.txt":         "c",
+		})
+		Ω(os.Symlink("config.yaml", filepath.Join(dir, "link.yaml"))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(dir)).Should(Succeed())
+	})
+
+	DescribeTable("is changed only by changes of the matched files",
+		func(globs []string, change func(dir string), expectChanged bool) {
+			before := mustMountFilesChecksum(dir, globs)
+			change(dir)
+			after := mustMountFilesChecksum(dir, globs)
+
+			if expectChanged {
+				Ω(after).ShouldNot(Equal(before))
+			} else {
+				Ω(after).Should(Equal(before))
+			}
+		},
+		Entry("all files, content change", nil, func(dir string) {
+			writeFiles(dir, map[string]string{"cache/c.txt": "changed"})
+		}, true),
+		Entry("all files, new file", nil, func(dir string) {
+			writeFiles(dir, map[string]string{"new": ""})
+		}, true),
+		Entry("all files, rename", nil, func(dir string) {
+			Ω(os.Rename(filepath.Join(dir, "cache/c.txt"), filepath.Join(dir, "cache/d.txt"))).Should(Succeed())
+		}, true),
+		Entry("all files, empty dir", nil, func(dir string) {
+			Ω(os.Mkdir(filepath.Join(dir, "empty"), os.ModePerm)).Should(Succeed())
+		}, false),
+		Entry("glob, matched file change", []string{"**/*.lock"}, func(dir string) {
+			writeFiles(dir, map[string]string{"cache/nested/b.lock": "changed"})
+		}, true),
+		Entry("glob, not matched file change", []string{"**/*.lock"}, func(dir string) {
+			writeFiles(dir, map[string]string{"cache/c.txt": "changed", "config.yaml": "changed"})
+		}, false),
+		Entry("dir glob, file change", []string{"cache"}, func(dir string) {
+			writeFiles(dir, map[string]string{"cache/nested/b.lock": "changed"})
+		}, true),
+		Entry("symlink target change", []string{"link.yaml"}, func(dir string) {
+			Ω(os.Remove(filepath.Join(dir, "link.yaml"))).Should(Succeed())
+			Ω(os.Symlink("cache/c.txt", filepath.Join(dir, "link.yaml"))).Should(Succeed())
+		}, true),
+		Entry("symlinked file content change is not followed", []string{"link.yaml"}, func(dir string) {
+			writeFiles(dir, map[string]string{"config.yaml": "changed"})
+		}, false),
+	)
+
+	It("should not depend on the directory path", func() {
+		anotherDir, err := ioutil.TempDir("", "werf-mount-files-checksum-")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(anotherDir)
+
+		writeFiles(anotherDir, map[string]string{"config.yaml": "a: 1"})
+		Ω(mustMountFilesChecksum(dir, []string{"config.yaml"})).Should(Equal(mustMountFilesChecksum(anotherDir, []string{"config.yaml"})))
+	})
+
+	It("should return the checksum of no files for the missing directory", func() {
+		emptyDir, err := ioutil.TempDir("", "werf-mount-files-checksum-")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(emptyDir)
+
+		Ω(mustMountFilesChecksum(filepath.Join(dir, "missing"), nil)).Should(Equal(mustMountFilesChecksum(emptyDir, nil)))
+	})
+})
+
+var _ = Describe("user stage env dependencies checksum", func() {
+	const envName = "WERF_TEST_USER_STAGE_DEPENDENCY"
+
+	newStage := func() *UserStage {
+		return newUserStage(nil, Install, &NewBaseStageOptions{
+			ConfigStageDependencies: &config.ImageStageDependencies{
+				Install: &config.UserStageDependencies{Env: []string{envName}},
+			},
+		})
+	}
+
+	envChecksum := func() string {
+		checksum, err := newStage().getStageDependenciesEnvAndFilesChecksum(context.Background(), Install)
+		Ω(err).ShouldNot(HaveOccurred())
+		return checksum
+	}
+
+	AfterEach(func() {
+		Ω(os.Unsetenv(envName)).Should(Succeed())
+	})
+
+	It("should distinguish unset and empty environment variable", func() {
+		Ω(os.Unsetenv(envName)).Should(Succeed())
+		unsetChecksum := envChecksum()
+
+		Ω(os.Setenv(envName, "")).Should(Succeed())
+		emptyChecksum := envChecksum()
+
+		Ω(os.Setenv(envName, "value")).Should(Succeed())
+		valueChecksum := envChecksum()
+
+		Ω(unsetChecksum).ShouldNot(Equal(emptyChecksum))
+		Ω(emptyChecksum).ShouldNot(Equal(valueChecksum))
+		Ω(unsetChecksum).ShouldNot(Equal(valueChecksum))
+	})
+
+	It("should not change the stage checksum of the stage without dependencies", func() {
+		checksum, err := newStage().withStageDependenciesChecksum(context.Background(), Setup, "checksum")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(checksum).Should(Equal("checksum"))
+	})
+})
+
+func mustMountFilesChecksum(dir string, globs []string) string {
+	checksum, err := mountFilesChecksum(dir, globs)
+	Ω(err).ShouldNot(HaveOccurred())
+	return checksum
+}
+
+func writeFiles(dir string, files map[string]string) {
+	for relPath, content := range files {
+		path := filepath.Join(dir, relPath)
+		Ω(os.MkdirAll(filepath.Dir(path), os.ModePerm)).Should(Succeed())
+		Ω(ioutil.WriteFile(path, []byte(content), 0644)).Should(Succeed())
+	}
+}
+
+var _ = Describe("UserStage.PostRunHook", func() {
+	It("removes the secret values written for the stage container", func() {
+		tmpDir, err := ioutil.TempDir("", "werf-user-stage-secrets-")
+		Ω(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(tmpDir)
+
+		install := &UserStage{BaseStage: &BaseStage{name: Install, imageTmpDir: tmpDir, configSecrets: []*config.Secret{{Id: "npm_token"}}}}
+		setup := &UserStage{BaseStage: &BaseStage{name: Setup, imageTmpDir: tmpDir, configSecrets: []*config.Secret{{Id: "npm_token"}}}}
+
+		for _, s := range []*UserStage{install, setup} {
+			writeFiles(s.hostSecretsDir(), map[string]string{"npm_token": "secret"})
+		}
+
+		Ω(install.PostRunHook(context.Background(), nil)).Should(Succeed())
+
+		_, err = os.Stat(install.hostSecretsDir())
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+		Ω(setup.hostSecretPath(setup.configSecrets[0])).Should(BeAnExistingFile())
+	})
+})
@@ -40,13 +40,24 @@ func (s *UserWithGitPatchStage) PrepareImage(ctx context.Context, c Conveyor, pr
 		return err
 	}
 
+	// the patch is applied with the first step of the fine-grained stage, so the steps are rebuilt when the patch is changed
+	var args []string
 	if isPatchEmpty, err := s.GitPatchStage.IsEmpty(ctx, c, prevBuiltImage); err != nil {
 		return err
 	} else if !isPatchEmpty {
 		if err := s.GitPatchStage.prepareImage(ctx, c, prevBuiltImage, image); err != nil {
 			return err
 		}
+
+		for _, gitMapping := range s.gitMappings {
+			latestCommitInfo, err := gitMapping.GetLatestCommitInfo(ctx, c)
+			if err != nil {
+				return fmt.Errorf("unable to get latest commit for git mapping %s: %s", gitMapping.GitRepo().GetName(), err)
+			}
+
+			args = append(args, gitMapping.Name, latestCommitInfo.Commit)
+		}
 	}
 
-	return nil
+	return s.UserStage.setRunStepsBaseChecksum(ctx, s.Name(), image, args...)
 }
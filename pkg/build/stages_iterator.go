@@ -48,7 +48,7 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 	}
 	logboek.Context(ctx).Debug().LogF("%s stage is empty: %v\n", stg.LogDetailedName(), isEmpty)
 
-	if stg.Name() != "from" && stg.Name() != "dockerfile" {
+	if stg.Name() != "from" && !isDockerfileStage(stg) {
 		if iterator.PrevStage == nil {
 			panic(fmt.Sprintf("expected PrevStage to be set for image %q stage %s!", img.GetName(), stg.Name()))
 		}
@@ -78,3 +78,9 @@ func (iterator *StagesIterator) OnImageStage(ctx context.Context, img *Image, st
 
 	return nil
 }
+
+// isDockerfileStage reports whether the stage is built by docker from the Dockerfile rather than based on the previous stage.
+func isDockerfileStage(stg stage.Interface) bool {
+	_, ok := stg.(*stage.DockerfileStage)
+	return ok
+}
@@ -0,0 +1,107 @@
+package build
+
+import (
+	"context"
+	"strings"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/werf_lock"
+)
+
+// getWerfLock returns werf.lock of the project, the empty lock if the file does not exist.
+// Base images and remote git references pinned by the lock are used instead of the actual ones without network requests.
+func (c *Conveyor) getWerfLock(ctx context.Context) (*werf_lock.Lock, error) {
+	c.getServiceRWMutex("werfLock").Lock()
+	defer c.getServiceRWMutex("werfLock").Unlock()
+
+	if c.werfLock == nil {
+		lock, err := werf_lock.Load(ctx, c.giterminismManager)
+		if err != nil {
+			return nil, err
+		}
+
+		if lock == nil {
+			lock = werf_lock.NewLock()
+		}
+
+		c.werfLock = lock
+	}
+
+	return c.werfLock, nil
+}
+
+// getLockedBaseImageReference returns the base image reference pinned by digest in werf.lock or an empty string.
+func getLockedBaseImageReference(ctx context.Context, name string, c *Conveyor) (string, error) {
+	if name == "scratch" || strings.Contains(name, "@") {
+		return "", nil
+	}
+
+	lock, err := c.getWerfLock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	lockedImage := lock.GetImage(name)
+	if lockedImage == nil {
+		if lockedImages := lock.GetRepositoryImages(name); len(lockedImages) != 0 {
+			var lockedNames []string
+			for _, image := range lockedImages {
+				lockedNames = append(lockedNames, image.Name)
+			}
+
+			logboek.Context(ctx).Warn().LogF("WARNING: Base image %s is not locked in %s which locks %s: the actual image is used, run werf lock update to lock it\n", name, werf_lock.FileName, strings.Join(lockedNames, ", "))
+		}
+
+		return "", nil
+	}
+
+	ref, err := lockedImage.Reference()
+	if err != nil {
+		return "", err
+	}
+
+	logboek.Context(ctx).Info().LogF("Using base image %s locked in %s\n", ref, werf_lock.FileName)
+
+	return ref, nil
+}
+
+// getLockedRemoteGitRepoCommit returns the commit of the remote git repository branch or tag (HEAD if both are empty) locked in werf.lock or an empty string.
+func getLockedRemoteGitRepoCommit(ctx context.Context, url, branch, tag string, c *Conveyor) (string, error) {
+	lock, err := c.getWerfLock(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	lockedGitRepo := lock.GetGitRepo(url, branch, tag)
+	if lockedGitRepo == nil {
+		return "", nil
+	}
+
+	logboek.Context(ctx).Info().LogF("Using remote git repository %s commit %s locked in %s\n", url, lockedGitRepo.Commit, werf_lock.FileName)
+
+	return lockedGitRepo.Commit, nil
+}
+
+// warnAboutNotLockedRemoteGitRepoRef warns that the remote git repository reference is resolved by the actual state of the repository
+// though werf.lock locks other references of the repository: werf.yaml is changed after the last werf lock update.
+func warnAboutNotLockedRemoteGitRepoRef(ctx context.Context, url, ref string, c *Conveyor) error {
+	lock, err := c.getWerfLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	lockedGitRepos := lock.GetUrlGitRepos(url)
+	if len(lockedGitRepos) == 0 {
+		return nil
+	}
+
+	var lockedRefs []string
+	for _, gitRepo := range lockedGitRepos {
+		lockedRefs = append(lockedRefs, gitRepo.Ref())
+	}
+
+	logboek.Context(ctx).Warn().LogF("WARNING: Remote git repository %s %s is not locked in %s which locks %s: the actual commit is used, run werf lock update to lock it\n", url, ref, werf_lock.FileName, strings.Join(lockedRefs, ", "))
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/werf_lock"
+)
+
+var _ = Describe("werf.lock", func() {
+	var ctx context.Context
+	var logOutput *bytes.Buffer
+	var lock *werf_lock.Lock
+	var c *Conveyor
+
+	noInspectBranch := func() error { return nil }
+
+	BeforeEach(func() {
+		logOutput = &bytes.Buffer{}
+		logger := logboek.NewLogger(logOutput, logOutput)
+		logger.Streams().DisableLineWrapping()
+		logger.Streams().DisableStyle()
+		ctx = logboek.NewContext(context.Background(), logger)
+
+		lock = werf_lock.NewLock()
+		c = &Conveyor{werfLock: lock, serviceRWMutex: map[string]*sync.RWMutex{}}
+	})
+
+	Describe("getLockedBaseImageReference", func() {
+		BeforeEach(func() {
+			lock.AddImage(&werf_lock.Image{Name: "alpine:3.14", Digest: "sha256:aaa"})
+		})
+
+		It("returns the locked reference", func() {
+			Ω(getLockedBaseImageReference(ctx, "alpine:3.14", c)).Should(Equal("alpine@sha256:aaa"))
+			Ω(logOutput.String()).ShouldNot(ContainSubstring("WARNING"))
+		})
+
+		It("warns that the base image is not locked when werf.lock locks another tag of the repository", func() {
+			Ω(getLockedBaseImageReference(ctx, "alpine:3.15", c)).Should(BeEmpty())
+			Ω(logOutput.String()).Should(ContainSubstring("WARNING: Base image alpine:3.15 is not locked in werf.lock which locks alpine:3.14"))
+		})
+
+		It("does not warn about the base image of another repository", func() {
+			Ω(getLockedBaseImageReference(ctx, "ubuntu:20.04", c)).Should(BeEmpty())
+			Ω(logOutput.String()).ShouldNot(ContainSubstring("WARNING"))
+		})
+	})
+
+	Describe("resolveRemoteGitRepoCommit", func() {
+		var originDir string
+		var remoteGitRepo *git_repo.Remote
+		var commits map[string]string
+
+		git := func(args ...string) string {
+			cmd := exec.Command("git", append([]string{"-c", "user.name=werf", "-c", "user.email=werf@werf.io"}, args...)...)
+			cmd.Dir = originDir
+			output, err := cmd.CombinedOutput()
+			Ω(err).ShouldNot(HaveOccurred(), string(output))
+			return strings.TrimSpace(string(output))
+		}
+
+		BeforeEach(func() {
+			var err error
+			originDir, err = ioutil.TempDir("", "werf-build-werf-lock-origin-")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			git("init", "-q")
+			git("checkout", "-q", "-b", "main")
+			git("commit", "-q", "--allow-empty", "-m", "first")
+			commits = map[string]string{"first": git("rev-parse", "HEAD")}
+			git("branch", "feature")
+
+			lock.AddGitRepo(&werf_lock.GitRepo{Url: "file://" + originDir, Branch: "main", Commit: commits["first"]})
+
+			git("commit", "-q", "--allow-empty", "-m", "second")
+			commits["second"] = git("rev-parse", "HEAD")
+
+			remoteGitRepo, err = git_repo.OpenRemoteRepo("origin", "file://"+originDir, git_repo.RemoteOptions{})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(remoteGitRepo.CloneAndFetch(ctx)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			Ω(os.RemoveAll(originDir)).Should(Succeed())
+		})
+
+		It("silently uses the stale locked commit of the advanced branch without --strict-werf-lock", func() {
+			Ω(remoteGitRepo.LatestBranchCommit(ctx, "main")).Should(Equal(commits["second"]))
+
+			Ω(resolveRemoteGitRepoCommit(ctx, remoteGitRepo, "main", noInspectBranch, c)).Should(Equal(commits["first"]))
+			Ω(logOutput.String()).ShouldNot(ContainSubstring("WARNING"))
+		})
+
+		It("warns that the branch is not locked when werf.lock locks another branch of the repository", func() {
+			Ω(resolveRemoteGitRepoCommit(ctx, remoteGitRepo, "feature", noInspectBranch, c)).Should(Equal(commits["first"]))
+			Ω(logOutput.String()).Should(ContainSubstring("WARNING: Remote git repository file://" + originDir + " feature is not locked in werf.lock which locks branch main"))
+		})
+
+		It("warns that HEAD is not locked when werf.lock locks another branch of the repository", func() {
+			Ω(resolveRemoteGitRepoCommit(ctx, remoteGitRepo, "", noInspectBranch, c)).Should(Equal(commits["second"]))
+			Ω(logOutput.String()).Should(ContainSubstring("WARNING: Remote git repository file://" + originDir + " HEAD is not locked in werf.lock which locks branch main"))
+		})
+	})
+})
@@ -35,17 +35,21 @@ type CleanupOptions struct {
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
 	DryRun                                  bool
+
+	// DevStagesStorage stages are deleted by TTL, the cleanup is skipped if nil
+	DevStagesStorage storage.StagesStorage
 }
 
 func Cleanup(ctx context.Context, projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options CleanupOptions) error {
-	return newCleanupManager(projectName, storageManager, options).run(ctx)
+	return newCleanupManager(projectName, storageManager, storageLockManager, options).run(ctx)
 }
 
-func newCleanupManager(projectName string, storageManager *manager.StorageManager, options CleanupOptions) *cleanupManager {
+func newCleanupManager(projectName string, storageManager *manager.StorageManager, storageLockManager storage.LockManager, options CleanupOptions) *cleanupManager {
 	return &cleanupManager{
 		stageManager:                            stage_manager.NewManager(),
 		ProjectName:                             projectName,
 		StorageManager:                          storageManager,
+		StorageLockManager:                      storageLockManager,
 		ImageNameList:                           options.ImageNameList,
 		DryRun:                                  options.DryRun,
 		LocalGit:                                options.LocalGit,
@@ -54,6 +58,7 @@ func newCleanupManager(projectName string, storageManager *manager.StorageManage
 		WithoutKube:                             options.WithoutKube,
 		GitHistoryBasedCleanupOptions:           options.GitHistoryBasedCleanupOptions,
 		KeepStagesBuiltWithinLastNHours:         options.KeepStagesBuiltWithinLastNHours,
+		DevStagesStorage:                        options.DevStagesStorage,
 	}
 }
 
@@ -65,6 +70,7 @@ type cleanupManager struct {
 
 	ProjectName                             string
 	StorageManager                          manager.StorageManagerInterface
+	StorageLockManager                      storage.LockManager
 	ImageNameList                           []string
 	LocalGit                                GitRepo
 	KubernetesContextClients                []*kube.ContextClient
@@ -73,6 +79,7 @@ type cleanupManager struct {
 	GitHistoryBasedCleanupOptions           config.MetaCleanup
 	KeepStagesBuiltWithinLastNHours         uint64
 	DryRun                                  bool
+	DevStagesStorage                        storage.StagesStorage
 }
 
 type GitRepo interface {
@@ -155,6 +162,14 @@ func (m *cleanupManager) run(ctx context.Context) error {
 		}
 	}
 
+	if m.DevStagesStorage != nil {
+		if err := logboek.Context(ctx).LogProcess("Cleanup expired dev stages").DoError(func() error {
+			return m.cleanupExpiredDevStages(ctx)
+		}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,79 @@
+package cleaning
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// cleanupExpiredDevStages deletes dev stages storage stages older than TTL from the stage label (storage.DefaultDevStagesTTL for stages copied from other storages).
+func (m *cleanupManager) cleanupExpiredDevStages(ctx context.Context) error {
+	stageIDs, err := m.DevStagesStorage.GetStagesIDs(ctx, m.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	var expiredStages []*image.StageDescription
+	for _, stageID := range stageIDs {
+		stageDesc, err := m.DevStagesStorage.GetStageDescription(ctx, m.ProjectName, stageID.Digest, stageID.UniqueID)
+		if err != nil {
+			return err
+		} else if stageDesc == nil {
+			continue
+		}
+
+		if time.Since(stageDesc.Info.GetCreatedAt()) > getDevStageTTL(stageDesc) {
+			expiredStages = append(expiredStages, stageDesc)
+		}
+	}
+
+	if len(expiredStages) == 0 {
+		return nil
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Deleting expired dev stages tags (%d/%d)", len(expiredStages), len(stageIDs)).DoError(func() error {
+		for _, stageDesc := range expiredStages {
+			if !m.DryRun {
+				if err := m.deleteDevStage(ctx, stageDesc); err != nil {
+					if err := handleDeletionError(err); err != nil {
+						return err
+					}
+
+					logboek.Context(ctx).Warn().LogF("WARNING: Image %s deletion failed: %s\n", stageDesc.Info.Name, err)
+					continue
+				}
+			}
+
+			logboek.Context(ctx).Default().LogFDetails("  tag: %s\n", stageDesc.Info.Tag)
+			logboek.Context(ctx).LogOptionalLn()
+		}
+
+		return nil
+	})
+}
+
+// deleteDevStage deletes the stage under the stage lock, which is held by the build of the same digest.
+func (m *cleanupManager) deleteDevStage(ctx context.Context, stageDesc *image.StageDescription) error {
+	lock, err := m.StorageLockManager.LockStage(ctx, m.ProjectName, stageDesc.StageID.Digest)
+	if err != nil {
+		return fmt.Errorf("unable to lock project %s digest %s: %s", m.ProjectName, stageDesc.StageID.Digest, err)
+	}
+	defer m.StorageLockManager.Unlock(ctx, lock)
+
+	return m.DevStagesStorage.DeleteStage(ctx, stageDesc, storage.DeleteImageOptions{})
+}
+
+func getDevStageTTL(stageDesc *image.StageDescription) time.Duration {
+	if value, ok := stageDesc.Info.Labels[image.WerfDevStagesTTLLabel]; ok {
+		if ttl, err := time.ParseDuration(value); err == nil {
+			return ttl
+		}
+	}
+
+	return storage.DefaultDevStagesTTL
+}
@@ -0,0 +1,140 @@
+package cleaning
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage"
+)
+
+var _ = DescribeTable("getDevStageTTL returns the TTL from the stage label",
+	func(labels map[string]string, expected time.Duration) {
+		stageDesc := &image.StageDescription{Info: &image.Info{Labels: labels}}
+		Ω(getDevStageTTL(stageDesc)).Should(Equal(expected))
+	},
+	Entry("label", map[string]string{image.WerfDevStagesTTLLabel: "1h30m"}, 90*time.Minute),
+	Entry("no label", map[string]string{}, storage.DefaultDevStagesTTL),
+	Entry("no labels", nil, storage.DefaultDevStagesTTL),
+	Entry("bad label value", map[string]string{image.WerfDevStagesTTLLabel: "1d"}, storage.DefaultDevStagesTTL),
+)
+
+type devStagesStorageStub struct {
+	storage.StagesStorage
+
+	stages  map[image.StageID]*image.StageDescription
+	events  *[]string
+	deleted []image.StageID
+}
+
+func (s *devStagesStorageStub) GetStagesIDs(_ context.Context, _ string) ([]image.StageID, error) {
+	var ids []image.StageID
+	for id := range s.stages {
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (s *devStagesStorageStub) GetStageDescription(_ context.Context, _, digest string, uniqueID int64) (*image.StageDescription, error) {
+	return s.stages[image.StageID{Digest: digest, UniqueID: uniqueID}], nil
+}
+
+func (s *devStagesStorageStub) DeleteStage(_ context.Context, stageDesc *image.StageDescription, _ storage.DeleteImageOptions) error {
+	*s.events = append(*s.events, "delete "+stageDesc.StageID.Digest)
+	s.deleted = append(s.deleted, *stageDesc.StageID)
+	return nil
+}
+
+type lockManagerStub struct {
+	events *[]string
+}
+
+func (m *lockManagerStub) LockStage(_ context.Context, _, digest string) (storage.LockHandle, error) {
+	*m.events = append(*m.events, "lock "+digest)
+	return storage.LockHandle{LockgateHandle: lockgate.LockHandle{LockName: digest}}, nil
+}
+
+func (m *lockManagerStub) LockStageCache(_ context.Context, _, _ string) (storage.LockHandle, error) {
+	panic("not expected")
+}
+
+func (m *lockManagerStub) Unlock(_ context.Context, lock storage.LockHandle) error {
+	*m.events = append(*m.events, "unlock "+lock.LockgateHandle.LockName)
+	return nil
+}
+
+var _ = Describe("cleanupExpiredDevStages", func() {
+	newStageDesc := func(digest string, age time.Duration, labels map[string]string) *image.StageDescription {
+		return &image.StageDescription{
+			StageID: &image.StageID{Digest: digest, UniqueID: 1},
+			Info: &image.Info{
+				Labels:            labels,
+				CreatedAtUnixNano: time.Now().Add(-age).UnixNano(),
+			},
+		}
+	}
+
+	It("deletes only expired stages under the stage lock", func() {
+		var events []string
+		devStagesStorage := &devStagesStorageStub{events: &events, stages: map[image.StageID]*image.StageDescription{}}
+		for _, stageDesc := range []*image.StageDescription{
+			newStageDesc("expired", 25*time.Hour, nil),
+			newStageDesc("fresh", time.Hour, nil),
+			newStageDesc("expired-by-label", 2*time.Hour, map[string]string{image.WerfDevStagesTTLLabel: "1h"}),
+		} {
+			devStagesStorage.stages[*stageDesc.StageID] = stageDesc
+		}
+
+		m := &cleanupManager{
+			ProjectName:        "project",
+			StorageLockManager: &lockManagerStub{events: &events},
+			DevStagesStorage:   devStagesStorage,
+		}
+
+		Ω(m.cleanupExpiredDevStages(context.Background())).Should(Succeed())
+		Ω(devStagesStorage.deleted).Should(ConsistOf(
+			image.StageID{Digest: "expired", UniqueID: 1},
+			image.StageID{Digest: "expired-by-label", UniqueID: 1},
+		))
+
+		for _, digest := range []string{"expired", "expired-by-label"} {
+			Ω(events).Should(ContainElement("lock " + digest))
+			Ω(indexOf(events, "lock "+digest)).Should(BeNumerically("<", indexOf(events, "delete "+digest)))
+			Ω(indexOf(events, "delete "+digest)).Should(BeNumerically("<", indexOf(events, "unlock "+digest)))
+		}
+	})
+
+	It("does not lock and delete stages in dry run mode", func() {
+		var events []string
+		devStagesStorage := &devStagesStorageStub{events: &events, stages: map[image.StageID]*image.StageDescription{}}
+		stageDesc := newStageDesc("expired", 25*time.Hour, nil)
+		devStagesStorage.stages[*stageDesc.StageID] = stageDesc
+
+		m := &cleanupManager{
+			ProjectName:        "project",
+			StorageLockManager: &lockManagerStub{events: &events},
+			DevStagesStorage:   devStagesStorage,
+			DryRun:             true,
+		}
+
+		Ω(m.cleanupExpiredDevStages(context.Background())).Should(Succeed())
+		Ω(events).Should(BeEmpty())
+	})
+})
+
+func indexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"fmt"
+	"path"
+)
+
 type Ansible struct {
 	BeforeInstall             []*AnsibleTask
 	Install                   []*AnsibleTask
@@ -10,6 +15,10 @@ type Ansible struct {
 	InstallCacheVersion       string
 	BeforeSetupCacheVersion   string
 	SetupCacheVersion         string
+	Roles                     []string
+	Requirements              string
+	TaskFiles                 []string
+	FineGrained               bool
 
 	raw *rawAnsible
 }
@@ -19,5 +28,23 @@ func (c *Ansible) GetDumpConfigSection() string {
 }
 
 func (c *Ansible) validate() error {
+	if !allRelativePaths(c.Roles) {
+		return newDetailedConfigError("`roles: [PATH, ...]` each path should be relative to project directory!", nil, c.raw.rawImage.doc)
+	} else if c.Requirements != "" && !isRelativePath(c.Requirements) {
+		return newDetailedConfigError("`requirements: PATH` should be relative to project directory!", nil, c.raw.rawImage.doc)
+	}
+
+	roleNames := map[string]bool{}
+	for _, role := range c.Roles {
+		roleName := path.Base(path.Clean(role))
+		if roleName == "." || roleName == "/" {
+			return newDetailedConfigError(fmt.Sprintf("`roles: [PATH, ...]` invalid role directory %q!", role), nil, c.raw.rawImage.doc)
+		} else if roleNames[roleName] {
+			return newDetailedConfigError(fmt.Sprintf("`roles: [PATH, ...]` role %q is defined more than once, the role name is the base name of the role directory!", roleName), nil, c.raw.rawImage.doc)
+		}
+
+		roleNames[roleName] = true
+	}
+
 	return nil
 }
@@ -9,3 +9,18 @@ type AnsibleTask struct {
 func (c *AnsibleTask) GetDumpConfigSection() string {
 	return dumpConfigSection(c.raw)
 }
+
+// Module returns the task module name or block if the task is a block.
+func (c *AnsibleTask) Module() string {
+	if c.raw.blockDefined() {
+		return "block"
+	}
+
+	for _, supportedModule := range supportedModules() {
+		if c.raw.Fields[supportedModule] != nil {
+			return supportedModule
+		}
+	}
+
+	return ""
+}
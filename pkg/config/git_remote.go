@@ -1,9 +1,19 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const DefaultGitRemoteAuthUsername = "oauth2"
+
 type GitRemote struct {
 	*GitRemoteExport
-	Name string
-	Url  string
+	Name         string
+	Url          string
+	Auth         *GitRemoteAuth
+	PartialClone bool
 
 	raw *rawGit
 }
@@ -13,5 +23,34 @@ func (c *GitRemote) GetRaw() interface{} {
 }
 
 func (c *GitRemote) validate() error {
+	if c.Auth != nil && !strings.HasPrefix(c.Url, "https://") && !strings.HasPrefix(c.Url, "http://") {
+		return newDetailedConfigError("`auth` is supported only for remote git with http(s) url!", c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	return nil
+}
+
+type GitRemoteAuth struct {
+	Username string
+	TokenEnv string
+
+	raw *rawGitAuth
+}
+
+// GetToken returns the token from the environment variable specified by the tokenEnv directive.
+func (c *GitRemoteAuth) GetToken() (string, error) {
+	token := os.Getenv(c.TokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("environment variable %s with the remote git token is not set", c.TokenEnv)
+	}
+
+	return token, nil
+}
+
+func (c *GitRemoteAuth) validate() error {
+	if c.TokenEnv == "" {
+		return newDetailedConfigError("`tokenEnv: ENV_NAME` required for remote git `auth`!", c.raw, c.raw.rawGit.rawStapelImage.doc)
+	}
+
 	return nil
 }
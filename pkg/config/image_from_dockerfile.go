@@ -2,6 +2,9 @@ package config
 
 import (
 	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/urlutil"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
 )
@@ -16,11 +19,55 @@ type ImageFromDockerfile struct {
 	AddHost         []string
 	Network         string
 	SSH             string
+	Secrets         []*Secret
+	Staged          bool                     // build each Dockerfile stage as a separate werf stage
+	RemoteContext   *DockerfileRemoteContext // set if Context is the subdirectory of the remote git repository
 
 	raw             *rawImageFromDockerfile
 }
 
+// DockerfileRemoteContext is the build context from the remote git repository: `context: URL#REF:SUBDIR`.
+type DockerfileRemoteContext struct {
+	Url string
+	Ref string // tag, branch or commit, the default branch if empty
+}
+
+// parseDockerfileRemoteContext parses the context in the docker build git URL format, nil for the project directory context.
+func parseDockerfileRemoteContext(context string) (remoteContext *DockerfileRemoteContext, subDir string) {
+	if !urlutil.IsGitURL(context) {
+		return nil, context
+	}
+
+	remoteContext = &DockerfileRemoteContext{}
+	remoteContext.Url = context
+
+	if ind := strings.Index(context, "#"); ind != -1 {
+		remoteContext.Url = context[:ind]
+
+		parts := strings.SplitN(context[ind+1:], ":", 2)
+		remoteContext.Ref = parts[0]
+		if len(parts) == 2 {
+			subDir = parts[1]
+		}
+	}
+
+	// the same as docker does for the short form
+	if strings.HasPrefix(remoteContext.Url, "github.com/") {
+		remoteContext.Url = "https://" + remoteContext.Url
+	}
+
+	return remoteContext, subDir
+}
+
 func (c *ImageFromDockerfile) validate(giterminismManager giterminism_manager.Interface) error {
+	if c.RemoteContext != nil {
+		if !isRelativePath(c.Context) {
+			return newDetailedConfigError("`context: URL#REF:SUBDIR` subdirectory should be relative to the remote git repository root!", nil, c.raw.doc)
+		} else if len(c.ContextAddFiles) != 0 {
+			return newDetailedConfigError("`contextAddFiles: [PATH, ...]|PATH` cannot be used with the remote git repository context!", nil, c.raw.doc)
+		}
+	}
+
 	if !isRelativePath(c.Context) {
 		return newDetailedConfigError("`context: PATH` should be relative to project directory!", nil, c.raw.doc)
 	} else if c.Dockerfile != "" && !isRelativePath(c.Dockerfile) {
@@ -0,0 +1,48 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type dockerfileContextEntry struct {
+	context               string
+	expectedRemoteContext *DockerfileRemoteContext
+	expectedSubDir        string
+}
+
+var _ = DescribeTable("parsing Dockerfile context", func(e dockerfileContextEntry) {
+	remoteContext, subDir := parseDockerfileRemoteContext(e.context)
+	Ω(remoteContext).Should(Equal(e.expectedRemoteContext))
+	Ω(subDir).Should(Equal(e.expectedSubDir))
+},
+	Entry("project directory", dockerfileContextEntry{
+		"app",
+		nil,
+		"app",
+	}),
+	Entry("git", dockerfileContextEntry{
+		"git@github.com:company/name.git",
+		&DockerfileRemoteContext{Url: "git@github.com:company/name.git"},
+		"",
+	}),
+	Entry("git with ref", dockerfileContextEntry{
+		"git@github.com:company/name.git#v1.0.0",
+		&DockerfileRemoteContext{Url: "git@github.com:company/name.git", Ref: "v1.0.0"},
+		"",
+	}),
+	Entry("https with ref and subdirectory", dockerfileContextEntry{
+		"https://github.com/company/name.git#main:docker/app",
+		&DockerfileRemoteContext{Url: "https://github.com/company/name.git", Ref: "main"},
+		"docker/app",
+	}),
+	Entry("https with subdirectory", dockerfileContextEntry{
+		"https://github.com/company/name.git#:docker/app",
+		&DockerfileRemoteContext{Url: "https://github.com/company/name.git"},
+		"docker/app",
+	}),
+	Entry("github short form", dockerfileContextEntry{
+		"github.com/company/name#3b4c1a2",
+		&DockerfileRemoteContext{Url: "https://github.com/company/name", Ref: "3b4c1a2"},
+		"",
+	}))
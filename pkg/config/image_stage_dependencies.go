@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+// ImageStageDependencies are the dependencies of the user stages on the inputs outside of git mappings.
+type ImageStageDependencies struct {
+	BeforeInstall *UserStageDependencies
+	Install       *UserStageDependencies
+	BeforeSetup   *UserStageDependencies
+	Setup         *UserStageDependencies
+
+	raw *rawImageStageDependencies
+}
+
+// Get returns the dependencies of the user stage by the stage name (beforeInstall, install, beforeSetup or setup).
+func (c *ImageStageDependencies) Get(userStageName string) *UserStageDependencies {
+	if c == nil {
+		return nil
+	}
+
+	switch userStageName {
+	case "beforeInstall":
+		return c.BeforeInstall
+	case "install":
+		return c.Install
+	case "beforeSetup":
+		return c.BeforeSetup
+	case "setup":
+		return c.Setup
+	}
+
+	return nil
+}
+
+func (c *ImageStageDependencies) validate(mounts []*Mount) error {
+	for _, userStageDependencies := range []*UserStageDependencies{c.BeforeInstall, c.Install, c.BeforeSetup, c.Setup} {
+		if userStageDependencies == nil {
+			continue
+		}
+
+		for _, files := range userStageDependencies.Files {
+			if files.GetMount(mounts) == nil {
+				return newDetailedConfigError(fmt.Sprintf("`mount: %s` should be the `to` path of the mount with `fromPath`!", files.Mount), files.raw, c.raw.rawStapelImage.doc)
+			}
+		}
+	}
+
+	return nil
+}
+
+type UserStageDependencies struct {
+	Env   []string
+	Files []*MountFilesDependency
+
+	raw *rawUserStageDependencies
+}
+
+func (c *UserStageDependencies) validate(giterminismManager giterminism_manager.Interface) error {
+	for _, envName := range c.Env {
+		if envName == "" {
+			return newDetailedConfigError("`env: [NAME, ...]|NAME` should be non-empty environment variable names!", c.raw, c.raw.doc())
+		}
+
+		if err := giterminismManager.Inspector().InspectConfigStapelStageDependenciesEnv(envName); err != nil {
+			return newDetailedConfigError(err.Error(), c.raw, c.raw.doc())
+		}
+	}
+
+	return nil
+}
+
+// MountFilesDependency is the set of files in the directory of the custom mount (`fromPath`) that the stage depends on.
+type MountFilesDependency struct {
+	Mount string   // the mount `to` path
+	Paths []string // globs relative to the mounted directory, all files if empty
+
+	raw *rawMountFilesDependency
+}
+
+// GetMount returns the custom mount with the dependency mount path.
+func (c *MountFilesDependency) GetMount(mounts []*Mount) *Mount {
+	for _, mount := range mounts {
+		if mount.Type == "custom_dir" && path.Clean(mount.To) == path.Clean(c.Mount) {
+			return mount
+		}
+	}
+
+	return nil
+}
+
+func (c *MountFilesDependency) validate() error {
+	if c.Mount == "" || !isAbsolutePath(c.Mount) {
+		return newDetailedConfigError("`mount: PATH` absolute path required for stage dependencies files!", c.raw, c.raw.rawUserStageDependencies.doc())
+	} else if !allRelativePaths(c.Paths) {
+		return newDetailedConfigError("`paths: [GLOB, ...]|GLOB` should be relative paths!", c.raw, c.raw.rawUserStageDependencies.doc())
+	}
+
+	return nil
+}
@@ -2,15 +2,27 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/werf/werf/pkg/giterminism_manager"
 )
 
+const (
+	CacheMountSharingShared = "shared"
+	CacheMountSharingLocked = "locked"
+)
+
+var cacheMountIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
 type Mount struct {
 	To   string
 	From string
 	Type string
 
+	// Id and Sharing are used only by the cache mount type.
+	Id      string
+	Sharing string
+
 	raw *rawMount
 }
 
@@ -36,8 +48,19 @@ func (c *Mount) validate(giterminismManager giterminism_manager.Interface) error
 		if c.From == "" {
 			return newDetailedConfigError("`fromPath: PATH` absolute or relative path required for mount!", c.raw, c.raw.rawStapelImage.doc)
 		}
+	} else if c.Type == "cache" {
+		if c.Id == "" || !cacheMountIdRegexp.MatchString(c.Id) {
+			return newDetailedConfigError("`id: ID` required for cache mount: only alphanumeric characters, `_`, `.` and `-` are allowed!", c.raw, c.raw.rawStapelImage.doc)
+		} else if c.Sharing != CacheMountSharingShared && c.Sharing != CacheMountSharingLocked {
+			return newDetailedConfigError(fmt.Sprintf("invalid `sharing: %s` for cache mount: expected `%s` or `%s`!", c.Sharing, CacheMountSharingShared, CacheMountSharingLocked), c.raw, c.raw.rawStapelImage.doc)
+		}
 	} else if c.Type != "tmp_dir" && c.Type != "build_dir" {
-		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir` or `build_dir`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+		return newDetailedConfigError(fmt.Sprintf("invalid `from: %s` for mount: expected `tmp_dir`, `build_dir` or `cache`!", c.Type), c.raw, c.raw.rawStapelImage.doc)
+	}
+
+	if c.Type != "cache" && (c.Id != "" || c.Sharing != "") {
+		return newDetailedConfigError("`id` and `sharing` directives can be used only with `from: cache` mount!", c.raw, c.raw.rawStapelImage.doc)
 	}
+
 	return nil
 }
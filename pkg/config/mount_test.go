@@ -0,0 +1,64 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+)
+
+type mountTestGiterminismManager struct {
+	giterminism_manager.Interface
+}
+
+func (m mountTestGiterminismManager) Inspector() giterminism_manager.Inspector {
+	return mountTestInspector{}
+}
+
+type mountTestInspector struct {
+	giterminism_manager.Inspector
+}
+
+func (i mountTestInspector) InspectConfigStapelMountBuildDir() error {
+	return nil
+}
+
+func (i mountTestInspector) InspectConfigStapelMountFromPath(_ string) error {
+	return nil
+}
+
+func mountToDirective(raw *rawMount) (*Mount, error) {
+	raw.rawStapelImage = &rawStapelImage{doc: &doc{}}
+	return raw.toDirective(mountTestGiterminismManager{})
+}
+
+var _ = DescribeTable("rawMount.toDirective makes the cache mount with the shared sharing by default",
+	func(raw *rawMount, expected *Mount) {
+		mount, err := mountToDirective(raw)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		mount.raw = nil
+		Ω(mount).Should(Equal(expected))
+	},
+	Entry("cache", &rawMount{From: "cache", Id: "apt", To: "/var/cache/apt"}, &Mount{Type: "cache", Id: "apt", To: "/var/cache/apt", Sharing: CacheMountSharingShared}),
+	Entry("locked cache", &rawMount{From: "cache", Id: "go_build.cache-1", To: "/root/.cache", Sharing: "locked"}, &Mount{Type: "cache", Id: "go_build.cache-1", To: "/root/.cache", Sharing: CacheMountSharingLocked}),
+	Entry("tmp_dir", &rawMount{From: "tmp_dir", To: "/tmp"}, &Mount{Type: "tmp_dir", To: "/tmp"}),
+	Entry("fromPath", &rawMount{FromPath: "data", To: "/data"}, &Mount{Type: "custom_dir", From: "data", To: "/data"}),
+)
+
+var _ = DescribeTable("rawMount.toDirective validates the mount",
+	func(raw *rawMount, expectedErr string) {
+		_, err := mountToDirective(raw)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(expectedErr))
+	},
+	Entry("cache without id", &rawMount{From: "cache", To: "/var/cache/apt"}, "`id: ID` required for cache mount"),
+	Entry("cache id starting with a dot", &rawMount{From: "cache", Id: ".apt", To: "/var/cache/apt"}, "`id: ID` required for cache mount"),
+	Entry("cache id with a slash", &rawMount{From: "cache", Id: "apt/cache", To: "/var/cache/apt"}, "`id: ID` required for cache mount"),
+	Entry("invalid sharing", &rawMount{From: "cache", Id: "apt", To: "/var/cache/apt", Sharing: "private"}, "invalid `sharing: private` for cache mount"),
+	Entry("id with tmp_dir", &rawMount{From: "tmp_dir", Id: "apt", To: "/tmp"}, "`id` and `sharing` directives can be used only with `from: cache` mount"),
+	Entry("sharing with build_dir", &rawMount{From: "build_dir", Sharing: "locked", To: "/build"}, "`id` and `sharing` directives can be used only with `from: cache` mount"),
+	Entry("id with fromPath", &rawMount{FromPath: "data", Id: "apt", To: "/data"}, "`id` and `sharing` directives can be used only with `from: cache` mount"),
+	Entry("relative to", &rawMount{From: "cache", Id: "apt", To: "var/cache/apt"}, "`to: PATH` absolute path required for mount"),
+	Entry("unknown from", &rawMount{From: "volume", To: "/data"}, "invalid `from: volume` for mount"),
+)
@@ -1,5 +1,7 @@
 package config
 
+import "github.com/werf/werf/pkg/util"
+
 type rawAnsible struct {
 	BeforeInstall             []rawAnsibleTask `yaml:"beforeInstall"`
 	Install                   []rawAnsibleTask `yaml:"install"`
@@ -10,6 +12,9 @@ type rawAnsible struct {
 	InstallCacheVersion       string           `yaml:"installCacheVersion,omitempty"`
 	BeforeSetupCacheVersion   string           `yaml:"beforeSetupCacheVersion,omitempty"`
 	SetupCacheVersion         string           `yaml:"setupCacheVersion,omitempty"`
+	Roles                     []string         `yaml:"roles,omitempty"`
+	Requirements              string           `yaml:"requirements,omitempty"`
+	FineGrained               bool             `yaml:"fineGrained,omitempty"`
 
 	rawImage *rawStapelImage `yaml:"-"` // parent
 
@@ -44,6 +49,9 @@ func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
 	ansible.InstallCacheVersion = c.InstallCacheVersion
 	ansible.BeforeSetupCacheVersion = c.BeforeSetupCacheVersion
 	ansible.SetupCacheVersion = c.SetupCacheVersion
+	ansible.Roles = c.Roles
+	ansible.Requirements = c.Requirements
+	ansible.FineGrained = c.FineGrained
 
 	for ind := range c.BeforeInstall {
 		if ansibleTask, err := c.BeforeInstall[ind].toDirective(); err != nil {
@@ -77,6 +85,16 @@ func (c *rawAnsible) toDirective() (ansible *Ansible, err error) {
 		}
 	}
 
+	for _, tasks := range [][]rawAnsibleTask{c.BeforeInstall, c.Install, c.BeforeSetup, c.Setup} {
+		for ind := range tasks {
+			for _, taskFile := range tasks[ind].taskFiles() {
+				if !util.IsStringsContainValue(ansible.TaskFiles, taskFile) {
+					ansible.TaskFiles = append(ansible.TaskFiles, taskFile)
+				}
+			}
+		}
+	}
+
 	ansible.raw = c
 
 	if err := c.validateDirective(ansible); err != nil {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -49,6 +50,10 @@ func (c *rawAnsibleTask) UnmarshalYAML(unmarshal func(interface{}) error) error
 			}
 			return newConfigError(fmt.Sprintf("unsupported ansible task!\n\n%s\nSupported modules list:\n%s\n%s", dumpConfigSection(c), supportedModulesString, dumpConfigDoc(c.rawAnsible.rawImage.doc)))
 		}
+
+		if taskFile, ok := c.taskFile(); ok && (taskFile == "" || strings.Contains(taskFile, "{{") || !isRelativePath(taskFile)) {
+			return newDetailedConfigError("`import_tasks: PATH` and `include_tasks: PATH` should be a static path relative to project directory!", c, c.rawAnsible.rawImage.doc)
+		}
 	}
 
 	return nil
@@ -58,6 +63,44 @@ func (c *rawAnsibleTask) blockDefined() bool {
 	return c.Block != nil || c.Rescue != nil || c.Always != nil
 }
 
+// taskFile returns the tasks file of import_tasks or include_tasks task (`import_tasks: PATH` or `import_tasks: {file: PATH}`).
+func (c *rawAnsibleTask) taskFile() (string, bool) {
+	for _, module := range []string{"import_tasks", "include_tasks"} {
+		value, ok := c.Fields[module]
+		if !ok {
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			return v, true
+		case map[interface{}]interface{}:
+			if file, ok := v["file"].(string); ok {
+				return file, true
+			}
+		}
+
+		return "", true
+	}
+
+	return "", false
+}
+
+func (c *rawAnsibleTask) taskFiles() []string {
+	var taskFiles []string
+	if taskFile, ok := c.taskFile(); ok {
+		taskFiles = append(taskFiles, taskFile)
+	}
+
+	for _, tasks := range [][]rawAnsibleTask{c.Block, c.Rescue, c.Always} {
+		for ind := range tasks {
+			taskFiles = append(taskFiles, tasks[ind].taskFiles()...)
+		}
+	}
+
+	return taskFiles
+}
+
 func supportedModules() []string {
 	var modules []string
 	// No Cloud modules
@@ -145,6 +188,8 @@ func supportedModules() []string {
 	modules = append(modules, []string{"cron", "user", "group", "getent", "locale_gen", "timezone"}...)
 	// Utilities Modules
 	modules = append(modules, []string{"meta", "assert", "debug", "fail", "set_fact", "wait_for"}...)
+	// Roles and task files (see ansible.roles and ansible.requirements directives)
+	modules = append(modules, []string{"include_role", "import_role", "include_tasks", "import_tasks"}...)
 	// No Web Infrastructure modules
 	// No Windows modules
 
@@ -0,0 +1,35 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("rawAnsibleTask.taskFile returns the task file of import_tasks and include_tasks",
+	func(fields map[string]interface{}, expectedTaskFile string, expectedOk bool) {
+		task := &rawAnsibleTask{Fields: fields}
+		taskFile, ok := task.taskFile()
+		Ω(ok).Should(Equal(expectedOk))
+		Ω(taskFile).Should(Equal(expectedTaskFile))
+	},
+	Entry("other module", map[string]interface{}{"shell": "true"}, "", false),
+	Entry("import_tasks", map[string]interface{}{"import_tasks": "ansible/tasks/common.yml"}, "ansible/tasks/common.yml", true),
+	Entry("include_tasks with file", map[string]interface{}{"include_tasks": map[interface{}]interface{}{"file": "ansible/tasks/common.yml"}}, "ansible/tasks/common.yml", true),
+	Entry("include_tasks without file", map[string]interface{}{"include_tasks": map[interface{}]interface{}{"apply": map[interface{}]interface{}{}}}, "", true),
+	Entry("invalid value", map[string]interface{}{"import_tasks": 1}, "", true),
+)
+
+var _ = DescribeTable("rawAnsibleTask.taskFiles returns the task files of the task and the nested block tasks",
+	func(task rawAnsibleTask, expected []string) {
+		Ω(task.taskFiles()).Should(Equal(expected))
+	},
+	Entry("no task files", rawAnsibleTask{Fields: map[string]interface{}{"shell": "true"}}, nil),
+	Entry("block, rescue and always",
+		rawAnsibleTask{
+			Block:  []rawAnsibleTask{{Fields: map[string]interface{}{"import_tasks": "a.yml"}}, {Fields: map[string]interface{}{"shell": "true"}}},
+			Rescue: []rawAnsibleTask{{Block: []rawAnsibleTask{{Fields: map[string]interface{}{"include_tasks": "b.yml"}}}}},
+			Always: []rawAnsibleTask{{Fields: map[string]interface{}{"include_tasks": map[interface{}]interface{}{"file": "c.yml"}}}},
+		},
+		[]string{"a.yml", "b.yml", "c.yml"},
+	),
+)
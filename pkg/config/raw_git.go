@@ -12,6 +12,8 @@ type rawGit struct {
 	Branch               string                `yaml:"branch,omitempty"`
 	Tag                  string                `yaml:"tag,omitempty"`
 	Commit               string                `yaml:"commit,omitempty"`
+	RawAuth              *rawGitAuth           `yaml:"auth,omitempty"`
+	PartialClone         bool                  `yaml:"partialClone,omitempty"`
 	RawStageDependencies *rawStageDependencies `yaml:"stageDependencies,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
@@ -80,6 +82,10 @@ func (c *rawGit) validateGitLocalDirective(gitLocal *GitLocal) (err error) {
 		return newDetailedConfigError("specify `branch: BRANCH`, `tag: TAG` and `commit: COMMIT` only for remote git!", nil, c.rawStapelImage.doc)
 	}
 
+	if c.RawAuth != nil || c.PartialClone {
+		return newDetailedConfigError("specify `auth` and `partialClone: true` only for remote git!", nil, c.rawStapelImage.doc)
+	}
+
 	if err := gitLocal.validate(); err != nil {
 		return err
 	}
@@ -133,8 +139,17 @@ func (c *rawGit) toGitRemoteDirective(giterminismManager giterminism_manager.Int
 
 	gitRemote.Url = c.Url
 	gitRemote.Name = getRepositoryID(c.Url)
+	gitRemote.PartialClone = c.PartialClone
 	gitRemote.raw = c
 
+	if c.RawAuth != nil {
+		if auth, err := c.RawAuth.toDirective(); err != nil {
+			return nil, err
+		} else {
+			gitRemote.Auth = auth
+		}
+	}
+
 	if err := c.validateGitRemoteDirective(gitRemote); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,45 @@
+package config
+
+type rawGitAuth struct {
+	Username string `yaml:"username,omitempty"`
+	TokenEnv string `yaml:"tokenEnv,omitempty"`
+
+	rawGit *rawGit `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawGitAuth) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawGit); ok {
+		c.rawGit = parent
+	}
+
+	type plain rawGitAuth
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawGit.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawGitAuth) toDirective() (gitRemoteAuth *GitRemoteAuth, err error) {
+	gitRemoteAuth = &GitRemoteAuth{}
+
+	gitRemoteAuth.Username = c.Username
+	if gitRemoteAuth.Username == "" {
+		gitRemoteAuth.Username = DefaultGitRemoteAuthUsername
+	}
+
+	gitRemoteAuth.TokenEnv = c.TokenEnv
+	gitRemoteAuth.raw = c
+
+	if err := gitRemoteAuth.validate(); err != nil {
+		return nil, err
+	}
+
+	return gitRemoteAuth, nil
+}
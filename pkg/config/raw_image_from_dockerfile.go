@@ -17,6 +17,8 @@ type rawImageFromDockerfile struct {
 	AddHost         interface{}            `yaml:"addHost,omitempty"`
 	Network         string                 `yaml:"network,omitempty"`
 	SSH             string                 `yaml:"ssh,omitempty"`
+	RawSecrets      []*rawSecret           `yaml:"secrets,omitempty"`
+	Staged          bool                   `yaml:"staged,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -83,7 +85,7 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(giterminismManag
 	image = &ImageFromDockerfile{}
 	image.Name = imageName
 	image.Dockerfile = c.Dockerfile
-	image.Context = c.Context
+	image.RemoteContext, image.Context = parseDockerfileRemoteContext(c.Context)
 
 	contextAddFile, err := InterfaceToStringArray(c.ContextAddFile, nil, c.doc)
 	if err != nil {
@@ -113,6 +115,11 @@ func (c *rawImageFromDockerfile) toImageFromDockerfileDirective(giterminismManag
 
 	image.Network = c.Network
 	image.SSH = c.SSH
+	image.Staged = c.Staged
+
+	if image.Secrets, err = toSecretDirectives(c.RawSecrets, c.doc); err != nil {
+		return nil, err
+	}
 
 	image.raw = c
 
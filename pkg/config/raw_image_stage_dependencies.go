@@ -0,0 +1,160 @@
+package config
+
+import "github.com/werf/werf/pkg/giterminism_manager"
+
+type rawImageStageDependencies struct {
+	BeforeInstall *rawUserStageDependencies `yaml:"beforeInstall,omitempty"`
+	Install       *rawUserStageDependencies `yaml:"install,omitempty"`
+	BeforeSetup   *rawUserStageDependencies `yaml:"beforeSetup,omitempty"`
+	Setup         *rawUserStageDependencies `yaml:"setup,omitempty"`
+
+	rawStapelImage *rawStapelImage `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawUserStageDependencies struct {
+	Env   interface{}                `yaml:"env,omitempty"`
+	Files []*rawMountFilesDependency `yaml:"files,omitempty"`
+
+	rawImageStageDependencies *rawImageStageDependencies `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+type rawMountFilesDependency struct {
+	Mount string      `yaml:"mount,omitempty"`
+	Paths interface{} `yaml:"paths,omitempty"`
+
+	rawUserStageDependencies *rawUserStageDependencies `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawImageStageDependencies) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawStapelImage); ok {
+		c.rawStapelImage = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawImageStageDependencies
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawStapelImage.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawUserStageDependencies) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawImageStageDependencies); ok {
+		c.rawImageStageDependencies = parent
+	}
+
+	parentStack.Push(c)
+	type plain rawUserStageDependencies
+	err := unmarshal((*plain)(c))
+	parentStack.Pop()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawUserStageDependencies) doc() *doc {
+	return c.rawImageStageDependencies.rawStapelImage.doc
+}
+
+func (c *rawMountFilesDependency) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if parent, ok := parentStack.Peek().(*rawUserStageDependencies); ok {
+		c.rawUserStageDependencies = parent
+	}
+
+	type plain rawMountFilesDependency
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.rawUserStageDependencies.doc()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawImageStageDependencies) toDirective(giterminismManager giterminism_manager.Interface) (stageDependencies *ImageStageDependencies, err error) {
+	stageDependencies = &ImageStageDependencies{}
+
+	for _, d := range []struct {
+		raw    *rawUserStageDependencies
+		target **UserStageDependencies
+	}{
+		{c.BeforeInstall, &stageDependencies.BeforeInstall},
+		{c.Install, &stageDependencies.Install},
+		{c.BeforeSetup, &stageDependencies.BeforeSetup},
+		{c.Setup, &stageDependencies.Setup},
+	} {
+		if d.raw == nil {
+			continue
+		}
+
+		if *d.target, err = d.raw.toDirective(giterminismManager); err != nil {
+			return nil, err
+		}
+	}
+
+	stageDependencies.raw = c
+
+	return stageDependencies, nil
+}
+
+func (c *rawUserStageDependencies) toDirective(giterminismManager giterminism_manager.Interface) (userStageDependencies *UserStageDependencies, err error) {
+	userStageDependencies = &UserStageDependencies{}
+
+	if userStageDependencies.Env, err = InterfaceToStringArray(c.Env, c, c.doc()); err != nil {
+		return nil, err
+	}
+
+	for _, rawFiles := range c.Files {
+		if files, err := rawFiles.toDirective(); err != nil {
+			return nil, err
+		} else {
+			userStageDependencies.Files = append(userStageDependencies.Files, files)
+		}
+	}
+
+	userStageDependencies.raw = c
+
+	if err := userStageDependencies.validate(giterminismManager); err != nil {
+		return nil, err
+	}
+
+	return userStageDependencies, nil
+}
+
+func (c *rawMountFilesDependency) toDirective() (mountFilesDependency *MountFilesDependency, err error) {
+	mountFilesDependency = &MountFilesDependency{}
+	mountFilesDependency.Mount = c.Mount
+
+	if mountFilesDependency.Paths, err = InterfaceToStringArray(c.Paths, c, c.rawUserStageDependencies.doc()); err != nil {
+		return nil, err
+	}
+
+	mountFilesDependency.raw = c
+
+	if err := mountFilesDependency.validate(); err != nil {
+		return nil, err
+	}
+
+	return mountFilesDependency, nil
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/util"
+)
+
+type giterminismManagerStub struct {
+	giterminism_manager.Interface
+}
+
+func (m giterminismManagerStub) Inspector() giterminism_manager.Inspector {
+	return giterminismInspectorStub{}
+}
+
+type giterminismInspectorStub struct {
+	giterminism_manager.Inspector
+}
+
+func (i giterminismInspectorStub) InspectConfigStapelStageDependenciesEnv(_ string) error {
+	return nil
+}
+
+func (i giterminismInspectorStub) InspectConfigStapelMountFromPath(_ string) error {
+	return nil
+}
+
+type stageDependenciesEntry struct {
+	config                    string
+	expectedStageDependencies *ImageStageDependencies
+	expectedErrSubstring      string
+}
+
+var _ = DescribeTable("parsing image stageDependencies", func(e stageDependenciesEntry) {
+	parentStack = util.NewStack()
+
+	image := &rawStapelImage{doc: &doc{Content: []byte(e.config)}}
+	err := yaml.UnmarshalStrict([]byte(e.config), &image)
+	if err == nil {
+		var imageBase *StapelImageBase
+		if imageBase, err = image.toStapelImageBaseDirective(giterminismManagerStub{}, image.Images[0]); err == nil {
+			clearStageDependenciesRaw(imageBase.StageDependencies)
+			Ω(imageBase.StageDependencies).Should(Equal(e.expectedStageDependencies))
+		}
+	}
+
+	if e.expectedErrSubstring != "" {
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(e.expectedErrSubstring))
+	} else {
+		Ω(err).ShouldNot(HaveOccurred())
+	}
+},
+	Entry("env as string and files with globs", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+mount:
+- fromPath: ~/.cache/app
+  to: /root/.cache/app
+stageDependencies:
+  install:
+    env: APP_VERSION
+    files:
+    - mount: /root/.cache/app
+      paths: ["**/*.lock", "config.yaml"]
+`,
+		expectedStageDependencies: &ImageStageDependencies{
+			Install: &UserStageDependencies{
+				Env:   []string{"APP_VERSION"},
+				Files: []*MountFilesDependency{{Mount: "/root/.cache/app", Paths: []string{"**/*.lock", "config.yaml"}}},
+			},
+		},
+	}),
+	Entry("env as list and files without paths", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+mount:
+- fromPath: ~/.cache/app
+  to: /root/.cache/app/
+stageDependencies:
+  beforeInstall:
+    env: [A, B]
+  setup:
+    files:
+    - mount: /root/.cache/app
+`,
+		expectedStageDependencies: &ImageStageDependencies{
+			BeforeInstall: &UserStageDependencies{Env: []string{"A", "B"}, Files: nil},
+			Setup: &UserStageDependencies{
+				Env:   []string{},
+				Files: []*MountFilesDependency{{Mount: "/root/.cache/app", Paths: []string{}}},
+			},
+		},
+	}),
+	Entry("empty env name", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+stageDependencies:
+  install:
+    env: [""]
+`,
+		expectedErrSubstring: "should be non-empty environment variable names",
+	}),
+	Entry("relative mount path", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+stageDependencies:
+  install:
+    files:
+    - mount: root/.cache/app
+`,
+		expectedErrSubstring: "absolute path required",
+	}),
+	Entry("absolute glob", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+stageDependencies:
+  install:
+    files:
+    - mount: /root/.cache/app
+      paths: /etc/passwd
+`,
+		expectedErrSubstring: "should be relative paths",
+	}),
+	Entry("mount without fromPath", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+mount:
+- from: tmp_dir
+  to: /root/.cache/app
+stageDependencies:
+  install:
+    files:
+    - mount: /root/.cache/app
+`,
+		expectedErrSubstring: "should be the `to` path of the mount with `fromPath`",
+	}),
+	Entry("unknown stage", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+stageDependencies:
+  docker:
+    env: A
+`,
+		expectedErrSubstring: "docker",
+	}),
+	Entry("unknown attribute", stageDependenciesEntry{
+		config: `
+image: app
+from: alpine
+stageDependencies:
+  install:
+    files:
+    - mount: /root/.cache/app
+      glob: "*"
+`,
+		expectedErrSubstring: "glob",
+	}),
+)
+
+func clearStageDependenciesRaw(stageDependencies *ImageStageDependencies) {
+	stageDependencies.raw = nil
+	for _, userStageDependencies := range []*UserStageDependencies{stageDependencies.BeforeInstall, stageDependencies.Install, stageDependencies.BeforeSetup, stageDependencies.Setup} {
+		if userStageDependencies == nil {
+			continue
+		}
+
+		userStageDependencies.raw = nil
+		for _, files := range userStageDependencies.Files {
+			files.raw = nil
+		}
+	}
+}
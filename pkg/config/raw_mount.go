@@ -6,6 +6,8 @@ type rawMount struct {
 	To       string `yaml:"to,omitempty"`
 	From     string `yaml:"from,omitempty"`
 	FromPath string `yaml:"fromPath,omitempty"`
+	Id       string `yaml:"id,omitempty"`
+	Sharing  string `yaml:"sharing,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -33,6 +35,8 @@ func (c *rawMount) toDirective(giterminismManager giterminism_manager.Interface)
 	mount = &Mount{}
 	mount.To = c.To
 	mount.From = c.FromPath
+	mount.Id = c.Id
+	mount.Sharing = c.Sharing
 
 	if c.From == "" {
 		mount.Type = "custom_dir"
@@ -40,6 +44,10 @@ func (c *rawMount) toDirective(giterminismManager giterminism_manager.Interface)
 		mount.Type = c.From
 	}
 
+	if mount.Type == "cache" && mount.Sharing == "" {
+		mount.Sharing = CacheMountSharingShared
+	}
+
 	mount.raw = c
 
 	if err := c.validateDirective(giterminismManager, mount); err != nil {
@@ -0,0 +1,67 @@
+package config
+
+import "fmt"
+
+type rawSecret struct {
+	Id  string `yaml:"id,omitempty"`
+	Env string `yaml:"env,omitempty"`
+	Src string `yaml:"src,omitempty"`
+
+	doc *doc `yaml:"-"` // parent
+
+	UnsupportedAttributes map[string]interface{} `yaml:",inline"`
+}
+
+func (c *rawSecret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	switch parent := parentStack.Peek().(type) {
+	case *rawStapelImage:
+		c.doc = parent.doc
+	case *rawImageFromDockerfile:
+		c.doc = parent.doc
+	}
+
+	type plain rawSecret
+	if err := unmarshal((*plain)(c)); err != nil {
+		return err
+	}
+
+	if err := checkOverflow(c.UnsupportedAttributes, c, c.doc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *rawSecret) toDirective() (secret *Secret, err error) {
+	secret = &Secret{}
+	secret.Id = c.Id
+	secret.Env = c.Env
+	secret.Src = c.Src
+	secret.raw = c
+
+	if err := secret.validate(); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func toSecretDirectives(rawSecrets []*rawSecret, doc *doc) ([]*Secret, error) {
+	var secrets []*Secret
+	secretById := map[string]bool{}
+	for _, rawSecret := range rawSecrets {
+		secret, err := rawSecret.toDirective()
+		if err != nil {
+			return nil, err
+		}
+
+		if secretById[secret.Id] {
+			return nil, newDetailedConfigError(fmt.Sprintf("duplicate secret `id: %s`!", secret.Id), nil, doc)
+		}
+		secretById[secret.Id] = true
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
@@ -10,6 +10,7 @@ type rawShell struct {
 	InstallCacheVersion       string      `yaml:"installCacheVersion,omitempty"`
 	BeforeSetupCacheVersion   string      `yaml:"beforeSetupCacheVersion,omitempty"`
 	SetupCacheVersion         string      `yaml:"setupCacheVersion,omitempty"`
+	FineGrained               bool        `yaml:"fineGrained,omitempty"`
 
 	rawStapelImage *rawStapelImage `yaml:"-"` // parent
 
@@ -40,6 +41,7 @@ func (c *rawShell) toDirective() (shell *Shell, err error) {
 	shell.InstallCacheVersion = c.InstallCacheVersion
 	shell.BeforeSetupCacheVersion = c.BeforeSetupCacheVersion
 	shell.SetupCacheVersion = c.SetupCacheVersion
+	shell.FineGrained = c.FineGrained
 
 	if beforeInstall, err := InterfaceToStringArray(c.BeforeInstall, c, c.rawStapelImage.doc); err != nil {
 		return nil, err
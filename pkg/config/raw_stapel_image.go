@@ -7,19 +7,22 @@ import (
 )
 
 type rawStapelImage struct {
-	Images           []string     `yaml:"-"`
-	Artifact         string       `yaml:"artifact,omitempty"`
-	From             string       `yaml:"from,omitempty"`
-	FromLatest       bool         `yaml:"fromLatest,omitempty"`
-	FromCacheVersion string       `yaml:"fromCacheVersion,omitempty"`
-	FromImage        string       `yaml:"fromImage,omitempty"`
-	FromArtifact     string       `yaml:"fromArtifact,omitempty"`
-	RawGit           []*rawGit    `yaml:"git,omitempty"`
-	RawShell         *rawShell    `yaml:"shell,omitempty"`
-	RawAnsible       *rawAnsible  `yaml:"ansible,omitempty"`
-	RawMount         []*rawMount  `yaml:"mount,omitempty"`
-	RawDocker        *rawDocker   `yaml:"docker,omitempty"`
-	RawImport        []*rawImport `yaml:"import,omitempty"`
+	Images                 []string                   `yaml:"-"`
+	Artifact               string                     `yaml:"artifact,omitempty"`
+	From                   string                     `yaml:"from,omitempty"`
+	FromLatest             bool                       `yaml:"fromLatest,omitempty"`
+	FromCacheVersion       string                     `yaml:"fromCacheVersion,omitempty"`
+	FromImage              string                     `yaml:"fromImage,omitempty"`
+	FromArtifact           string                     `yaml:"fromArtifact,omitempty"`
+	RawGit                 []*rawGit                  `yaml:"git,omitempty"`
+	RawShell               *rawShell                  `yaml:"shell,omitempty"`
+	RawAnsible             *rawAnsible                `yaml:"ansible,omitempty"`
+	RawMount               []*rawMount                `yaml:"mount,omitempty"`
+	RawSecrets             []*rawSecret               `yaml:"secrets,omitempty"`
+	RawStageDependencies   *rawImageStageDependencies `yaml:"stageDependencies,omitempty"`
+	RawDocker              *rawDocker                 `yaml:"docker,omitempty"`
+	RawImport              []*rawImport               `yaml:"import,omitempty"`
+	DisableStapelToolchain bool                       `yaml:"disableStapelToolchain,omitempty"`
 
 	doc *doc `yaml:"-"` // parent
 
@@ -211,6 +214,7 @@ func (c *rawStapelImage) toStapelImageBaseDirective(giterminismManager gitermini
 	imageBase.FromImageName = c.FromImage
 	imageBase.FromArtifactName = c.FromArtifact
 	imageBase.FromLatest = c.FromLatest
+	imageBase.DisableStapelToolchain = c.DisableStapelToolchain
 	imageBase.FromCacheVersion = c.FromCacheVersion
 
 	for _, git := range c.RawGit {
@@ -280,6 +284,16 @@ func (c *rawStapelImage) toBaseStapelImageBaseDirective(giterminismManager giter
 		}
 	}
 
+	if imageBase.Secrets, err = toSecretDirectives(c.RawSecrets, c.doc); err != nil {
+		return nil, err
+	}
+
+	if c.RawStageDependencies != nil {
+		if imageBase.StageDependencies, err = c.RawStageDependencies.toDirective(giterminismManager); err != nil {
+			return nil, err
+		}
+	}
+
 	imageBase.Git = &GitManager{}
 
 	imageBase.raw = c
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+// SecretsContainerDir is the directory where build-time secrets are available by id.
+const SecretsContainerDir = "/run/secrets"
+
+var secretIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Secret is a build-time value which is available during the image build but is neither stored in the image nor taken into account in the stage digest.
+type Secret struct {
+	Id  string
+	Env string
+	Src string
+
+	raw *rawSecret
+}
+
+// GetValue reads the secret value from the environment variable or the file.
+func (c *Secret) GetValue() ([]byte, error) {
+	if c.Env != "" {
+		value, ok := os.LookupEnv(c.Env)
+		if !ok {
+			return nil, fmt.Errorf("secret %q: environment variable %s is not set", c.Id, c.Env)
+		}
+
+		return []byte(value), nil
+	}
+
+	data, err := ioutil.ReadFile(c.GetSrcPath())
+	if err != nil {
+		return nil, fmt.Errorf("secret %q: unable to read %s: %s", c.Id, c.Src, err)
+	}
+
+	return data, nil
+}
+
+func (c *Secret) GetSrcPath() string {
+	return util.ExpandPath(c.Src)
+}
+
+func (c *Secret) validate() error {
+	if c.Id == "" {
+		return newDetailedConfigError("`id: ID` required for secret!", c.raw, c.raw.doc)
+	} else if !secretIdRegexp.MatchString(c.Id) {
+		return newDetailedConfigError(fmt.Sprintf("invalid secret `id: %s`: only alphanumeric characters, `_`, `.` and `-` are allowed!", c.Id), c.raw, c.raw.doc)
+	}
+
+	if (c.Env == "") == (c.Src == "") {
+		return newDetailedConfigError("one and only one of `env: NAME` or `src: PATH` required for secret!", c.raw, c.raw.doc)
+	}
+
+	return nil
+}
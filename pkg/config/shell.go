@@ -10,6 +10,7 @@ type Shell struct {
 	InstallCacheVersion       string
 	BeforeSetupCacheVersion   string
 	SetupCacheVersion         string
+	FineGrained               bool
 
 	raw *rawShell
 }
@@ -20,8 +20,16 @@ type StapelImageBase struct {
 	Shell            *Shell
 	Ansible          *Ansible
 	Mount            []*Mount
+	Secrets          []*Secret
 	Import           []*Import
 
+	// StageDependencies are the dependencies of the user stages on environment variables and files of the custom mounts.
+	StageDependencies *ImageStageDependencies
+
+	// DisableStapelToolchain turns off the stapel volume in the build containers:
+	// git archives, patches and imports are applied on the host as image layers, user commands are run by the image /bin/sh.
+	DisableStapelToolchain bool
+
 	raw *rawStapelImage
 }
 
@@ -98,6 +106,12 @@ func (c *StapelImageBase) validate(giterminismManager giterminism_manager.Interf
 		mountByTo[mount.To] = true
 	}
 
+	if c.StageDependencies != nil {
+		if err := c.StageDependencies.validate(c.Mount); err != nil {
+			return err
+		}
+	}
+
 	if !oneOrNone([]bool{c.From != "", c.raw.FromImage != "", c.raw.FromArtifact != ""}) {
 		return newDetailedConfigError("conflict between `from`, `fromImage` and `fromArtifact` directives!", nil, c.raw.doc)
 	}
@@ -106,6 +120,16 @@ func (c *StapelImageBase) validate(giterminismManager giterminism_manager.Interf
 		logboek.Context(context.Background()).Warn().LogLn("WARNING: Do not use artifacts as a base for other images and artifacts. The feature is deprecated, and the directive 'fromArtifact' will be completely removed in version v1.3.\n\nCareless use of artifacts may lead to difficult to trace issues that may arise long after the configuration has been written. The artifact image is cached after the first build and ignores any changes in the project git repository unless the user has explicitly specified stage dependencies. As found, this behavior is completely unexpected for users despite the fact that it is absolutely correct in the werf logic.")
 	}
 
+	if c.DisableStapelToolchain {
+		if c.Ansible != nil {
+			return newDetailedConfigError("`ansible` cannot be used with `disableStapelToolchain: true`: the ansible builder requires the stapel toolchain!", nil, c.raw.doc)
+		}
+
+		if len(c.Secrets) != 0 {
+			return newDetailedConfigError("`secrets` cannot be used with `disableStapelToolchain: true`: the secrets leak check requires the stapel toolchain!", nil, c.raw.doc)
+		}
+	}
+
 	// TODO: валидацию формата `From`
 
 	return nil
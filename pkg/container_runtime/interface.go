@@ -47,6 +47,11 @@ type Container interface {
 
 	AddServiceRunCommands(commands ...string)
 	AddRunCommands(commands ...string)
+	AddLayerArchives(paths ...string)
+	AddRunStep(name, checksum string, commands ...string)
+	SetRunStepsBaseChecksum(checksum string)
+
+	DisableStapelToolchain()
 
 	RunOptions() ContainerOptions
 	CommitChangeOptions() ContainerOptions
@@ -56,6 +61,7 @@ type Container interface {
 type BuilderContainer interface {
 	AddServiceRunCommands(commands ...string)
 	AddRunCommands(commands ...string)
+	AddRunStep(name, checksum string, commands ...string)
 
 	AddVolume(volumes ...string)
 	AddVolumeFrom(volumesFrom ...string)
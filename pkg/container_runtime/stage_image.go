@@ -66,49 +66,33 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 			defer werf.ReleaseHostLock(lock)
 		}
 
-		if debugDockerRunCommand() {
-			runArgs, err := i.container.prepareRunArgs(ctx)
-			if err != nil {
-				return err
-			}
-
-			fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
-
-			if len(i.container.prepareAllRunCommands()) != 0 {
-				fmt.Printf("Decoded command:\n%s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-			}
+		if err := i.container.buildLayersImage(ctx); err != nil {
+			return fmt.Errorf("unable to build image with layers: %s", err)
 		}
 
-		if containerRunErr := i.container.run(ctx); containerRunErr != nil {
-			if strings.HasPrefix(containerRunErr.Error(), "container run failed") {
-				if options.IntrospectBeforeError {
-					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-
-					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.introspectBefore(ctx)
-					}); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
-				} else if options.IntrospectAfterError {
-					if err := i.Commit(ctx); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
-
-					logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
-
-					if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
-						return i.Introspect(ctx)
-					}); err != nil {
-						return fmt.Errorf("introspect error failed: %s", err)
-					}
+		if len(i.container.runSteps) != 0 {
+			// the steps result is squashed into the layer archive, so the stage container has nothing to run
+			if err := i.container.runStepsAndSquash(ctx); err != nil {
+				if rmErr := i.container.rmLayersImage(ctx); rmErr != nil {
+					logboek.Context(ctx).Warn().LogF("WARNING: unable to remove image with layers: %s\n", rmErr)
 				}
 
-				if err := i.container.rm(ctx); err != nil {
-					return fmt.Errorf("introspect error failed: %s", err)
-				}
+				return err
+			}
+
+			if err := i.container.create(ctx); err != nil {
+				return err
+			}
+		} else if i.container.stapelToolchainDisabled && len(i.container.serviceRunCommands)+len(i.container.runCommands) == 0 {
+			if err := i.container.create(ctx); err != nil {
+				return err
+			}
+		} else if err := i.run(ctx, options); err != nil {
+			if rmErr := i.container.rmLayersImage(ctx); rmErr != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: unable to remove image with layers: %s\n", rmErr)
 			}
 
-			return containerRunErr
+			return err
 		}
 
 		if err := i.Commit(ctx); err != nil {
@@ -118,6 +102,10 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 		if err := i.container.rm(ctx); err != nil {
 			return err
 		}
+
+		if err := i.container.rmLayersImage(ctx); err != nil {
+			return err
+		}
 	}
 
 	if inspect, err := i.LocalDockerServerRuntime.GetImageInspect(ctx, i.MustGetBuiltId()); err != nil {
@@ -133,6 +121,55 @@ func (i *StageImage) Build(ctx context.Context, options BuildOptions) error {
 	return nil
 }
 
+func (i *StageImage) run(ctx context.Context, options BuildOptions) error {
+	if debugDockerRunCommand() {
+		runArgs, err := i.container.prepareRunArgs(ctx)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
+
+		if len(i.container.prepareAllRunCommands()) != 0 {
+			fmt.Printf("Decoded command:\n%s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
+		}
+	}
+
+	if containerRunErr := i.container.run(ctx); containerRunErr != nil {
+		if strings.HasPrefix(containerRunErr.Error(), "container run failed") {
+			if options.IntrospectBeforeError {
+				logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
+
+				if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
+					return i.introspectBefore(ctx)
+				}); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+			} else if options.IntrospectAfterError {
+				if err := i.Commit(ctx); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+
+				logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(i.container.prepareAllRunCommands(), " && "))
+
+				if err := logboek.Context(ctx).Streams().DoErrorWithoutProxyStreamDataFormatting(func() error {
+					return i.Introspect(ctx)
+				}); err != nil {
+					return fmt.Errorf("introspect error failed: %s", err)
+				}
+			}
+
+			if err := i.container.rm(ctx); err != nil {
+				return fmt.Errorf("introspect error failed: %s", err)
+			}
+		}
+
+		return containerRunErr
+	}
+
+	return nil
+}
+
 func (i *StageImage) Commit(ctx context.Context) error {
 	builtId, err := i.container.commit(ctx)
 	if err != nil {
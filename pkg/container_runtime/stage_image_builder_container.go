@@ -10,6 +10,10 @@ func (c *StageImageBuilderContainer) AddServiceRunCommands(commands ...string) {
 	c.image.container.AddServiceRunCommands(commands...)
 }
 
+func (c *StageImageBuilderContainer) AddRunStep(name, checksum string, commands ...string) {
+	c.image.container.AddRunStep(name, checksum, commands...)
+}
+
 func (c *StageImageBuilderContainer) AddVolume(volumes ...string) {
 	c.image.container.runOptions.AddVolume(volumes...)
 }
@@ -24,6 +24,14 @@ type StageImageContainer struct {
 	runOptions                 *StageImageContainerOptions
 	commitChangeOptions        *StageImageContainerOptions
 	serviceCommitChangeOptions *StageImageContainerOptions
+	layerArchives              []string
+	layersImageName            string
+	stapelToolchainDisabled    bool
+
+	runSteps                         []*runStep
+	runStepsBaseChecksum             string
+	runStepsServiceRunCommandsOffset int
+	runStepsRunCommandsOffset        int
 }
 
 func newStageImageContainer(img *StageImage) *StageImageContainer {
@@ -56,6 +64,14 @@ func (c *StageImageContainer) AddServiceRunCommands(commands ...string) {
 	c.serviceRunCommands = append(c.serviceRunCommands, commands...)
 }
 
+func (c *StageImageContainer) AddLayerArchives(paths ...string) {
+	c.layerArchives = append(c.layerArchives, paths...)
+}
+
+func (c *StageImageContainer) DisableStapelToolchain() {
+	c.stapelToolchainDisabled = true
+}
+
 func (c *StageImageContainer) RunOptions() ContainerOptions {
 	return c.runOptions
 }
@@ -69,8 +85,12 @@ func (c *StageImageContainer) ServiceCommitChangeOptions() ContainerOptions {
 }
 
 func (c *StageImageContainer) prepareRunArgs(ctx context.Context) ([]string, error) {
+	return c.prepareRunArgsFor(ctx, c.name, c.runImageId(), c.prepareRunCommand())
+}
+
+func (c *StageImageContainer) prepareRunArgsFor(ctx context.Context, containerName, imageName, command string) ([]string, error) {
 	var args []string
-	args = append(args, fmt.Sprintf("--name=%s", c.name))
+	args = append(args, fmt.Sprintf("--name=%s", containerName))
 
 	runOptions, err := c.prepareRunOptions(ctx)
 	if err != nil {
@@ -85,24 +105,42 @@ func (c *StageImageContainer) prepareRunArgs(ctx context.Context) ([]string, err
 	setColumnsEnv := fmt.Sprintf("--env=COLUMNS=%d", logboek.Context(ctx).Streams().ContentWidth())
 	runArgs = append(runArgs, setColumnsEnv)
 
-	fromImageId := c.image.fromImage.GetID()
-
 	args = append(args, runArgs...)
-	args = append(args, fromImageId)
+	args = append(args, imageName)
 	args = append(args, "-ec")
-	args = append(args, c.prepareRunCommand())
+	args = append(args, command)
 
 	return args, nil
 }
 
+// runImageId returns the image with the layer archives if any, the from image otherwise.
+func (c *StageImageContainer) runImageId() string {
+	if c.layersImageName != "" {
+		return c.layersImageName
+	}
+
+	return c.image.fromImage.GetID()
+}
+
 func (c *StageImageContainer) prepareRunCommand() string {
-	return ShelloutPack(strings.Join(c.prepareRunCommands(), " && "))
+	return c.packRunCommand(c.prepareRunCommands())
+}
+
+func (c *StageImageContainer) packRunCommand(commands []string) string {
+	command := strings.Join(commands, " && ")
+	if c.stapelToolchainDisabled {
+		return command
+	}
+
+	return ShelloutPack(command)
 }
 
 func (c *StageImageContainer) prepareRunCommands() []string {
 	runCommands := c.prepareAllRunCommands()
 	if len(runCommands) != 0 {
 		return runCommands
+	} else if c.stapelToolchainDisabled {
+		return []string{"true"}
 	} else {
 		return []string{stapel.TrueBinPath()}
 	}
@@ -131,11 +169,9 @@ func (c *StageImageContainer) prepareIntrospectBeforeArgs(ctx context.Context) (
 		return nil, err
 	}
 
-	fromImageId := c.image.fromImage.GetID()
-
-	args = append(args, fromImageId)
+	args = append(args, c.runImageId())
 	args = append(args, "-ec")
-	args = append(args, stapel.BashBinPath())
+	args = append(args, c.shellBinPath())
 
 	return args, nil
 }
@@ -150,7 +186,7 @@ func (c *StageImageContainer) prepareIntrospectArgs(ctx context.Context) ([]stri
 
 	args = append(args, imageId)
 	args = append(args, "-ec")
-	args = append(args, stapel.BashBinPath())
+	args = append(args, c.shellBinPath())
 
 	return args, nil
 }
@@ -185,9 +221,13 @@ func (c *StageImageContainer) prepareRunOptions(ctx context.Context) (*StageImag
 func (c *StageImageContainer) prepareServiceRunOptions(ctx context.Context) (*StageImageContainerOptions, error) {
 	serviceRunOptions := newStageContainerOptions()
 	serviceRunOptions.Workdir = "/"
-	serviceRunOptions.Entrypoint = stapel.BashBinPath()
+	serviceRunOptions.Entrypoint = c.shellBinPath()
 	serviceRunOptions.User = "0:0"
 
+	if c.stapelToolchainDisabled {
+		return serviceRunOptions, nil
+	}
+
 	stapelContainerName, err := stapel.GetOrCreateContainer(ctx)
 	if err != nil {
 		return nil, err
@@ -198,6 +238,14 @@ func (c *StageImageContainer) prepareServiceRunOptions(ctx context.Context) (*St
 	return serviceRunOptions, nil
 }
 
+func (c *StageImageContainer) shellBinPath() string {
+	if c.stapelToolchainDisabled {
+		return ImageShellBinPath
+	}
+
+	return stapel.BashBinPath()
+}
+
 func (c *StageImageContainer) prepareIntrospectOptions(ctx context.Context) (*StageImageContainerOptions, error) {
 	return c.prepareRunOptions(ctx)
 }
@@ -267,6 +315,61 @@ func (c *StageImageContainer) run(ctx context.Context) error {
 	return nil
 }
 
+// buildLayersImage prepares the image with the layer archives on top of the from image to run the container from.
+func (c *StageImageContainer) buildLayersImage(ctx context.Context) error {
+	if len(c.layerArchives) == 0 {
+		return nil
+	}
+
+	layersImageName, err := buildLayersImage(ctx, c.image.fromImage.GetID(), c.layerArchives)
+	if err != nil {
+		return err
+	}
+
+	c.layersImageName = layersImageName
+
+	return nil
+}
+
+func (c *StageImageContainer) rmLayersImage(ctx context.Context) error {
+	if c.layersImageName == "" {
+		return nil
+	}
+
+	if err := docker.CliRmi(ctx, "--force", c.layersImageName); err != nil {
+		return err
+	}
+
+	c.layersImageName = ""
+
+	return nil
+}
+
+// create creates the container without running any commands, that is enough to commit the layer archives when the stapel toolchain is disabled.
+// The image may have no shell at all, the entrypoint is not started and is reset on commit.
+func (c *StageImageContainer) create(ctx context.Context) error {
+	runOptions, err := c.prepareRunOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	runArgs, err := runOptions.toRunArgs()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	args = append(args, fmt.Sprintf("--name=%s", c.name))
+	args = append(args, runArgs...)
+	args = append(args, c.runImageId())
+
+	if err := docker.CliCreate(ctx, args...); err != nil {
+		return fmt.Errorf("container create failed: %s", err)
+	}
+
+	return nil
+}
+
 func (c *StageImageContainer) introspect(ctx context.Context) error {
 	runArgs, err := c.prepareIntrospectArgs(ctx)
 	if err != nil {
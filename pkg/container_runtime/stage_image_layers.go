@@ -0,0 +1,511 @@
+package container_runtime
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// ImageShellBinPath is used instead of the stapel bash when the stapel toolchain is disabled.
+const ImageShellBinPath = "/bin/sh"
+
+type dockerSaveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// imageFilesystemInfo is the part of the image filesystem required to prepare layer archives.
+type imageFilesystemInfo struct {
+	users  map[string]int
+	groups map[string]int
+
+	// dirs caches the existence of the image directories and the directories created by the prepared layers
+	dirs map[string]bool
+	// statDir checks that the directory (or the symlink to it) exists in the image
+	statDir func(dir string) (bool, error)
+}
+
+func (fsInfo *imageFilesystemInfo) isDirExist(dir string) (bool, error) {
+	if exist, ok := fsInfo.dirs[dir]; ok {
+		return exist, nil
+	}
+
+	var exist bool
+	if fsInfo.statDir != nil {
+		var err error
+		if exist, err = fsInfo.statDir(dir); err != nil {
+			return false, fmt.Errorf("unable to check directory %s: %s", dir, err)
+		}
+	}
+
+	fsInfo.dirs[dir] = exist
+
+	return exist, nil
+}
+
+// buildLayersImage appends the layer archives to the image without running any containers and loads the result into the docker server.
+// Only the config and the new layers are loaded: the docker server does not read the from image layers which already exist in its layer store.
+// Archive entries owner and group names are resolved against /etc/passwd and /etc/group of the image like tar does unpacking as root, missing parent directories are created.
+func buildLayersImage(ctx context.Context, fromImageID string, layerArchives []string) (string, error) {
+	tmpDir, err := ioutil.TempDir(werf.GetTmpDir(), "stage-layers-")
+	if err != nil {
+		return "", fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fromConfig, err := getImageConfig(ctx, fromImageID)
+	if err != nil {
+		return "", fmt.Errorf("unable to get image %s config: %s", fromImageID, err)
+	}
+
+	// the container is only created to read the image files and is never started
+	containerID, err := docker.ContainerCreate(ctx, &containertypes.Config{Image: fromImageID, Entrypoint: []string{"true"}}, nil, fmt.Sprintf("werf-stage-layers-%s", util.GenerateConsistentRandomString(10)))
+	if err != nil {
+		return "", fmt.Errorf("unable to create container from image %s: %s", fromImageID, err)
+	}
+	defer func() {
+		if err := docker.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logboek.Context(ctx).Warn().LogF("WARNING: unable to remove container %s: %s\n", containerID, err)
+		}
+	}()
+
+	fsInfo, err := getContainerFilesystemInfo(ctx, containerID)
+	if err != nil {
+		return "", fmt.Errorf("unable to read image %s filesystem: %s", fromImageID, err)
+	}
+
+	var layerPaths []string
+	for ind, layerArchive := range layerArchives {
+		layerPath := filepath.Join(tmpDir, fmt.Sprintf("layer-%d.tar", ind))
+		if err := prepareLayerArchive(layerArchive, layerPath, fsInfo); err != nil {
+			return "", fmt.Errorf("unable to prepare layer from %s: %s", layerArchive, err)
+		}
+
+		layerPaths = append(layerPaths, layerPath)
+	}
+
+	imageName := fmt.Sprintf("werf-stage-layers-%s:latest", util.GenerateConsistentRandomString(10))
+	imageArchivePath := filepath.Join(tmpDir, "image.tar")
+	if err := writeLayersImageArchive(fromConfig, layerPaths, imageName, imageArchivePath); err != nil {
+		return "", fmt.Errorf("unable to prepare image archive: %s", err)
+	}
+
+	if err := docker.ImageLoad(ctx, imageArchivePath); err != nil {
+		return "", fmt.Errorf("unable to load image %s: %s", imageName, err)
+	}
+
+	logboek.Context(ctx).Debug().LogF("Image %s with %d layers %v is built from %s\n", imageName, len(layerArchives), layerArchives, fromImageID)
+
+	return imageName, nil
+}
+
+// imageConfig is the docker image config, the variant is not the part of v1.ConfigFile.
+type imageConfig struct {
+	v1.ConfigFile
+	Variant string `json:"variant,omitempty"`
+}
+
+// getImageConfig restores the image config from the image inspect and history.
+// The history entries without the layer size are marked as empty layers, the docker server only requires that there are not more non-empty entries than layers.
+func getImageConfig(ctx context.Context, imageID string) (*imageConfig, error) {
+	inspect, err := docker.ImageInspect(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := docker.ImageHistory(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	configData, err := json.Marshal(inspect.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &imageConfig{
+		ConfigFile: v1.ConfigFile{
+			Architecture:  inspect.Architecture,
+			Author:        inspect.Author,
+			Container:     inspect.Container,
+			DockerVersion: inspect.DockerVersion,
+			OS:            inspect.Os,
+			OSVersion:     inspect.OsVersion,
+			RootFS:        v1.RootFS{Type: "layers"},
+		},
+		Variant: inspect.Variant,
+	}
+
+	if inspect.Config != nil {
+		if err := json.Unmarshal(configData, &cfg.Config); err != nil {
+			return nil, fmt.Errorf("unable to convert image config: %s", err)
+		}
+	}
+
+	if created, err := time.Parse(time.RFC3339Nano, inspect.Created); err == nil {
+		cfg.Created = v1.Time{Time: created}
+	}
+
+	for _, layer := range inspect.RootFS.Layers {
+		diffID, err := v1.NewHash(layer)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse layer %s digest: %s", layer, err)
+		}
+
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, diffID)
+	}
+
+	// the history is returned from the last entry
+	for ind := len(history) - 1; ind >= 0; ind-- {
+		cfg.History = append(cfg.History, v1.History{
+			Created:    v1.Time{Time: time.Unix(history[ind].Created, 0).UTC()},
+			CreatedBy:  history[ind].CreatedBy,
+			Comment:    history[ind].Comment,
+			EmptyLayer: history[ind].Size == 0,
+		})
+	}
+
+	return cfg, nil
+}
+
+// getContainerFilesystemInfo reads /etc/passwd and /etc/group of the created container, directories are checked on demand.
+func getContainerFilesystemInfo(ctx context.Context, containerID string) (*imageFilesystemInfo, error) {
+	fsInfo := &imageFilesystemInfo{
+		dirs: map[string]bool{},
+		statDir: func(dir string) (bool, error) {
+			stat, err := docker.ContainerStatPath(ctx, containerID, "/"+dir)
+			if client.IsErrNotFound(err) {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			return stat.Mode.IsDir() || stat.Mode&os.ModeSymlink != 0, nil
+		},
+	}
+
+	for p, ids := range map[string]*map[string]int{"/etc/passwd": &fsInfo.users, "/etc/group": &fsInfo.groups} {
+		data, err := readContainerFile(ctx, containerID, p)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %s", p, err)
+		}
+
+		*ids = parseIDsFile(bytes.NewReader(data))
+	}
+
+	return fsInfo, nil
+}
+
+// readContainerFile returns the regular file content or nil if the file does not exist.
+func readContainerFile(ctx context.Context, containerID, p string) ([]byte, error) {
+	rc, err := docker.CopyFromContainer(ctx, containerID, p)
+	if client.IsErrNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, nil
+	}
+
+	return ioutil.ReadAll(tr)
+}
+
+// parseIDsFile reads names and ids of /etc/passwd or /etc/group (the first and the third fields).
+func parseIDsFile(r io.Reader) map[string]int {
+	ids := map[string]int{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+
+		if id, err := strconv.Atoi(fields[2]); err == nil {
+			ids[fields[0]] = id
+		}
+	}
+
+	return ids
+}
+
+func prepareLayerArchive(archivePath, layerPath string, fsInfo *imageFilesystemInfo) error {
+	src, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(layerPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	tr := tar.NewReader(src)
+	tw := tar.NewWriter(dst)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		name := normalizeLayerEntryName(hdr.Name)
+		if name == "" {
+			continue
+		}
+
+		layerHdr := *hdr
+		layerHdr.Name = name
+		layerHdr.Format = tar.FormatUnknown
+		if hdr.Typeflag == tar.TypeDir {
+			layerHdr.Name += "/"
+		}
+
+		if hdr.Typeflag == tar.TypeLink {
+			layerHdr.Linkname = normalizeLayerEntryName(hdr.Linkname)
+		}
+
+		if uid, ok := fsInfo.users[hdr.Uname]; ok && hdr.Uname != "" {
+			layerHdr.Uid = uid
+		}
+		if gid, ok := fsInfo.groups[hdr.Gname]; ok && hdr.Gname != "" {
+			layerHdr.Gid = gid
+		}
+		layerHdr.Uname, layerHdr.Gname = "", ""
+
+		// missing parent directories are owned by the entry owner as if the archive is unpacked by this user
+		for _, parentDir := range parentDirs(name) {
+			if exist, err := fsInfo.isDirExist(parentDir); err != nil {
+				return err
+			} else if exist {
+				continue
+			}
+
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     parentDir + "/",
+				Mode:     0755,
+				Uid:      layerHdr.Uid,
+				Gid:      layerHdr.Gid,
+				ModTime:  hdr.ModTime,
+			}); err != nil {
+				return err
+			}
+			fsInfo.dirs[parentDir] = true
+		}
+
+		if hdr.Typeflag == tar.TypeDir {
+			fsInfo.dirs[name] = true
+		}
+
+		if err := tw.WriteHeader(&layerHdr); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return dst.Close()
+}
+
+// writeLayersImageArchive writes the image archive in the docker save format with the from image config and the new layers on top of it.
+// The from image layers are referenced in the manifest but not written: the docker server skips reading the layers which already exist in its layer store.
+func writeLayersImageArchive(fromConfig *imageConfig, layerPaths []string, imageName, imageArchivePath string) error {
+	dst, err := os.Create(imageArchivePath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	cfg := &imageConfig{ConfigFile: *fromConfig.ConfigFile.DeepCopy(), Variant: fromConfig.Variant}
+	manifest := &dockerSaveManifest{RepoTags: []string{imageName}}
+	for _, diffID := range cfg.RootFS.DiffIDs {
+		manifest.Layers = append(manifest.Layers, path.Join(diffID.Hex, "layer.tar"))
+	}
+
+	tw := tar.NewWriter(dst)
+
+	created := time.Now().UTC()
+	for _, layerPath := range layerPaths {
+		diffID, size, err := fileSha256(layerPath)
+		if err != nil {
+			return err
+		}
+
+		layerName := path.Join(diffID, "layer.tar")
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: diffID + "/", Mode: 0755}); err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: layerName, Mode: 0644, Size: size}); err != nil {
+			return err
+		}
+		if err := copyFile(tw, layerPath); err != nil {
+			return err
+		}
+
+		manifest.Layers = append(manifest.Layers, layerName)
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, v1.Hash{Algorithm: "sha256", Hex: diffID})
+		if len(cfg.History) != 0 {
+			cfg.History = append(cfg.History, v1.History{Created: v1.Time{Time: created}, CreatedBy: "werf"})
+		}
+	}
+
+	cfg.Created = v1.Time{Time: created}
+
+	configData, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	manifest.Config = fmt.Sprintf("%x.json", sha256.Sum256(configData))
+
+	if err := writeTarFile(tw, manifest.Config, configData); err != nil {
+		return err
+	}
+
+	manifestData, err := json.Marshal([]*dockerSaveManifest{manifest})
+	if err != nil {
+		return err
+	}
+
+	if err := writeTarFile(tw, "manifest.json", manifestData); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return dst.Close()
+}
+
+func readDockerSaveManifest(imageArchivePath string) (*dockerSaveManifest, error) {
+	f, err := os.Open(imageArchivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if hdr.Name != "manifest.json" {
+			continue
+		}
+
+		var manifests []*dockerSaveManifest
+		if err := json.NewDecoder(tr).Decode(&manifests); err != nil {
+			return nil, fmt.Errorf("unable to decode manifest.json: %s", err)
+		}
+
+		if len(manifests) != 1 {
+			return nil, fmt.Errorf("expected one image in the archive, got %d", len(manifests))
+		}
+
+		return manifests[0], nil
+	}
+
+	return nil, fmt.Errorf("manifest.json not found")
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(data)
+	return err
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func fileSha256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), size, nil
+}
+
+// parentDirs returns the parent directories of the entry starting from the root one.
+func parentDirs(name string) []string {
+	var dirs []string
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	return dirs
+}
+
+func normalizeLayerEntryName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
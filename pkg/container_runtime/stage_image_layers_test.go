@@ -0,0 +1,189 @@
+package container_runtime
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("parentDirs returns the parent directories starting from the root one",
+	func(name string, expected []string) {
+		Ω(parentDirs(name)).Should(Equal(expected))
+	},
+	Entry("root entry", "app", nil),
+	Entry("nested entry", "usr/lib/app", []string{"usr", "usr/lib"}),
+	Entry("absolute name", "/usr/lib/app", []string{"/usr", "/usr/lib"}),
+)
+
+var _ = Describe("layers image", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-stage-layers-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	Describe("prepareLayerArchive", func() {
+		It("resolves owners, normalizes names and creates missing parent directories", func() {
+			archivePath := filepath.Join(tmpDir, "archive.tar")
+			writeTestArchive(archivePath, []*tar.Header{
+				{Typeflag: tar.TypeReg, Name: "/usr/lib/app/bin/main", Uname: "app", Gname: "app", Mode: 0755, Size: 4},
+				{Typeflag: tar.TypeLink, Name: "usr/lib/app/bin/link", Linkname: "/usr/lib/app/bin/main", Uname: "app", Gname: "app"},
+				{Typeflag: tar.TypeDir, Name: "./etc/", Uid: 5, Uname: "unknown", Mode: 0755},
+				{Typeflag: tar.TypeReg, Name: "etc/config", Uid: 5, Gid: 6, Mode: 0644, Size: 4},
+			})
+
+			var statDirs []string
+			fsInfo := &imageFilesystemInfo{
+				users:  map[string]int{"app": 1000},
+				groups: map[string]int{"app": 2000},
+				dirs:   map[string]bool{},
+				statDir: func(dir string) (bool, error) {
+					statDirs = append(statDirs, dir)
+					return dir == "usr" || dir == "usr/lib", nil
+				},
+			}
+
+			layerPath := filepath.Join(tmpDir, "layer.tar")
+			Ω(prepareLayerArchive(archivePath, layerPath, fsInfo)).Should(Succeed())
+
+			Ω(statDirs).Should(Equal([]string{"usr", "usr/lib", "usr/lib/app", "usr/lib/app/bin"}))
+			Ω(readTestArchiveSummary(layerPath)).Should(Equal([]string{
+				"dir usr/lib/app/ 1000:2000",
+				"dir usr/lib/app/bin/ 1000:2000",
+				"file usr/lib/app/bin/main 1000:2000 data",
+				"link usr/lib/app/bin/link 1000:2000 usr/lib/app/bin/main",
+				"dir etc/ 5:0",
+				"file etc/config 5:6 data",
+			}))
+		})
+	})
+
+	Describe("writeLayersImageArchive", func() {
+		It("writes the config and the new layers referencing the from image layers", func() {
+			baseDiffID := v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", sha256.Sum256([]byte("base")))}
+			fromConfig := &imageConfig{
+				ConfigFile: v1.ConfigFile{
+					Architecture: "arm",
+					OS:           "linux",
+					RootFS:       v1.RootFS{Type: "layers", DiffIDs: []v1.Hash{baseDiffID}},
+					Config:       v1.Config{Env: []string{"A=1"}},
+					History: []v1.History{
+						{CreatedBy: "ADD base"},
+						{CreatedBy: "ENV A=1", EmptyLayer: true},
+					},
+				},
+				Variant: "v7",
+			}
+
+			layerPath := filepath.Join(tmpDir, "layer.tar")
+			Ω(ioutil.WriteFile(layerPath, []byte("layer"), 0644)).Should(Succeed())
+			layerDiffID := fmt.Sprintf("%x", sha256.Sum256([]byte("layer")))
+
+			imageArchivePath := filepath.Join(tmpDir, "image.tar")
+			Ω(writeLayersImageArchive(fromConfig, []string{layerPath}, "image:latest", imageArchivePath)).Should(Succeed())
+
+			files := readTestArchiveFiles(imageArchivePath)
+			Ω(files).Should(HaveKey(layerDiffID + "/layer.tar"))
+			Ω(files).ShouldNot(HaveKey(baseDiffID.Hex + "/layer.tar"))
+			Ω(files[layerDiffID+"/layer.tar"]).Should(Equal("layer"))
+
+			var manifests []*dockerSaveManifest
+			Ω(json.Unmarshal([]byte(files["manifest.json"]), &manifests)).Should(Succeed())
+			Ω(manifests).Should(HaveLen(1))
+			Ω(manifests[0].RepoTags).Should(Equal([]string{"image:latest"}))
+			Ω(manifests[0].Layers).Should(Equal([]string{baseDiffID.Hex + "/layer.tar", layerDiffID + "/layer.tar"}))
+			Ω(manifests[0].Config).Should(Equal(fmt.Sprintf("%x.json", sha256.Sum256([]byte(files[manifests[0].Config])))))
+
+			var cfg imageConfig
+			Ω(json.Unmarshal([]byte(files[manifests[0].Config]), &cfg)).Should(Succeed())
+			Ω(cfg.Variant).Should(Equal("v7"))
+			Ω(cfg.Config.Env).Should(Equal([]string{"A=1"}))
+			Ω(cfg.RootFS.DiffIDs).Should(Equal([]v1.Hash{baseDiffID, {Algorithm: "sha256", Hex: layerDiffID}}))
+			Ω(cfg.History).Should(HaveLen(3))
+			Ω(cfg.History[2].CreatedBy).Should(Equal("werf"))
+			Ω(cfg.History[2].EmptyLayer).Should(BeFalse())
+
+			Ω(fromConfig.RootFS.DiffIDs).Should(HaveLen(1))
+			Ω(fromConfig.History).Should(HaveLen(2))
+		})
+	})
+})
+
+// writeTestArchive writes the entries, the regular files content is "data" truncated to the size.
+func writeTestArchive(archivePath string, hdrs []*tar.Header) {
+	f, err := os.Create(archivePath)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, hdr := range hdrs {
+		Ω(tw.WriteHeader(hdr)).Should(Succeed())
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := tw.Write([]byte("data")[:hdr.Size])
+			Ω(err).ShouldNot(HaveOccurred())
+		}
+	}
+
+	Ω(tw.Close()).Should(Succeed())
+}
+
+// readTestArchiveSummary returns the "<type> <name> <uid>:<gid> <content or linkname>" of the entries.
+func readTestArchiveSummary(archivePath string) []string {
+	var res []string
+	Ω(walkLayerArchive(archivePath, func(hdr *tar.Header, r io.Reader) error {
+		Ω(hdr.Uname).Should(BeEmpty())
+		Ω(hdr.Gname).Should(BeEmpty())
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			res = append(res, fmt.Sprintf("dir %s %d:%d", hdr.Name, hdr.Uid, hdr.Gid))
+		case tar.TypeLink:
+			res = append(res, fmt.Sprintf("link %s %d:%d %s", hdr.Name, hdr.Uid, hdr.Gid, hdr.Linkname))
+		default:
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			res = append(res, fmt.Sprintf("file %s %d:%d %s", hdr.Name, hdr.Uid, hdr.Gid, data))
+		}
+
+		return nil
+	})).Should(Succeed())
+
+	return res
+}
+
+func readTestArchiveFiles(archivePath string) map[string]string {
+	files := map[string]string{}
+	Ω(walkLayerArchive(archivePath, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		files[hdr.Name] = string(data)
+		return nil
+	})).Should(Succeed())
+
+	return files
+}
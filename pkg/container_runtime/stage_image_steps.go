@@ -0,0 +1,426 @@
+package container_runtime
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+const (
+	whiteoutPrefix       = ".wh."
+	whiteoutOpaqueMarker = ".wh..wh..opq"
+)
+
+// runStep is the instruction of the fine-grained user stage.
+// Each step is run in a separate container and committed into the intermediate image, which is cached locally by the cumulative checksum of the previous steps.
+type runStep struct {
+	name     string
+	checksum string
+	commands []string
+}
+
+func (c *StageImageContainer) AddRunStep(name, checksum string, commands ...string) {
+	if len(c.runSteps) == 0 {
+		c.runStepsServiceRunCommandsOffset = len(c.serviceRunCommands)
+		c.runStepsRunCommandsOffset = len(c.runCommands)
+	}
+
+	c.runSteps = append(c.runSteps, &runStep{name: name, checksum: checksum, commands: commands})
+}
+
+// SetRunStepsBaseChecksum sets the checksum of the stage inputs that are not the part of the steps (e.g. git patch), it is the base of the steps cumulative checksum.
+func (c *StageImageContainer) SetRunStepsBaseChecksum(checksum string) {
+	c.runStepsBaseChecksum = checksum
+}
+
+// runStepsCommands returns the commands of the step: the service and user commands added before the steps are run with the first step and the rest ones with the last step.
+func (c *StageImageContainer) runStepsCommands(ind int) []string {
+	var commands []string
+
+	if debugDockerRunCommand() {
+		commands = append(commands, "set -x")
+	}
+
+	if ind == 0 {
+		commands = append(commands, c.serviceRunCommands[:c.runStepsServiceRunCommandsOffset]...)
+		commands = append(commands, c.runCommands[:c.runStepsRunCommandsOffset]...)
+	}
+
+	commands = append(commands, c.runSteps[ind].commands...)
+
+	if ind == len(c.runSteps)-1 {
+		commands = append(commands, c.serviceRunCommands[c.runStepsServiceRunCommandsOffset:]...)
+		commands = append(commands, c.runCommands[c.runStepsRunCommandsOffset:]...)
+	}
+
+	return commands
+}
+
+// runStepsAndSquash runs the steps that are not cached yet and adds the squashed result of all steps as a layer archive.
+func (c *StageImageContainer) runStepsAndSquash(ctx context.Context) error {
+	runImageInspect, err := docker.ImageInspect(ctx, c.runImageId())
+	if err != nil {
+		return fmt.Errorf("unable to inspect image %s: %s", c.runImageId(), err)
+	}
+
+	// the layers image is rebuilt with the new creation time each time, so the steps are cached by the from image and the layer archives content
+	checksum, err := c.runStepsSeedChecksum()
+	if err != nil {
+		return err
+	}
+
+	stepImage := runImageInspect.ID
+	for ind, step := range c.runSteps {
+		checksum = util.Sha256Hash(checksum, step.checksum)
+		stepImageName := fmt.Sprintf("%s:%s", image.StageStepImageRepository, checksum)
+		stepTitle := fmt.Sprintf("Step %d/%d: %s", ind+1, len(c.runSteps), step.name)
+
+		exist, err := docker.ImageExist(ctx, stepImageName)
+		if err != nil {
+			return fmt.Errorf("unable to check existence of image %s: %s", stepImageName, err)
+		}
+
+		if exist {
+			logboek.Context(ctx).Default().LogFDetails("%s (cached)\n", stepTitle)
+		} else if err := logboek.Context(ctx).Default().LogProcess(stepTitle).DoError(func() error {
+			return c.runStep(ctx, stepImage, stepImageName, c.runStepsCommands(ind))
+		}); err != nil {
+			return err
+		}
+
+		if err := lrumeta.CommonLRUImagesCache.AccessImage(ctx, stepImageName); err != nil {
+			return fmt.Errorf("error accessing last recently used images cache for %s: %s", stepImageName, err)
+		}
+
+		stepImage = stepImageName
+	}
+
+	tmpDir, err := ioutil.TempDir(werf.GetTmpDir(), "stage-steps-")
+	if err != nil {
+		return fmt.Errorf("unable to create tmp dir: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	stepImageArchivePath := filepath.Join(tmpDir, "image.tar")
+	if err := docker.ImageSave(ctx, stepImage, stepImageArchivePath); err != nil {
+		return fmt.Errorf("unable to save image %s: %s", stepImage, err)
+	}
+
+	squashedLayerPath := filepath.Join(tmpDir, "steps.tar")
+	if err := squashImageArchiveLayers(stepImageArchivePath, len(runImageInspect.RootFS.Layers), tmpDir, squashedLayerPath); err != nil {
+		return fmt.Errorf("unable to squash steps layers: %s", err)
+	}
+
+	if err := c.rmLayersImage(ctx); err != nil {
+		return err
+	}
+
+	// the layers image is rebuilt while the squashed layer archive exists
+	c.layerArchives = append(c.layerArchives, squashedLayerPath)
+
+	return c.buildLayersImage(ctx)
+}
+
+// runStepsSeedChecksum returns the checksum of the image the first step is run from and the base checksum.
+func (c *StageImageContainer) runStepsSeedChecksum() (string, error) {
+	args := []string{c.image.fromImage.GetID()}
+	for _, layerArchive := range c.layerArchives {
+		checksum, _, err := fileSha256(layerArchive)
+		if err != nil {
+			return "", fmt.Errorf("unable to calculate checksum of layer archive %s: %s", layerArchive, err)
+		}
+
+		args = append(args, checksum)
+	}
+
+	return util.Sha256Hash(append(args, c.runStepsBaseChecksum)...), nil
+}
+
+func (c *StageImageContainer) runStep(ctx context.Context, fromImage, stepImageName string, commands []string) error {
+	containerName := fmt.Sprintf("%s%v", image.StageContainerNamePrefix, util.GenerateConsistentRandomString(10))
+
+	containerLockName := ContainerLockName(containerName)
+	if _, lock, err := werf.AcquireHostLock(ctx, containerLockName, lockgate.AcquireOptions{}); err != nil {
+		return fmt.Errorf("failed to lock %s: %s", containerLockName, err)
+	} else {
+		defer werf.ReleaseHostLock(lock)
+	}
+
+	runArgs, err := c.prepareRunArgsFor(ctx, containerName, fromImage, c.packRunCommand(commands))
+	if err != nil {
+		return err
+	}
+
+	if debugDockerRunCommand() {
+		fmt.Printf("Docker run command:\ndocker run %s\n", strings.Join(runArgs, " "))
+		fmt.Printf("Decoded command:\n%s\n", strings.Join(commands, " && "))
+	}
+
+	runErr := docker.CliRun_LiveOutput(ctx, runArgs...)
+	if runErr == nil {
+		_, runErr = docker.ContainerCommit(ctx, containerName, types.ContainerCommitOptions{
+			Reference: stepImageName,
+			Changes:   []string{fmt.Sprintf("LABEL %s=true", image.WerfStageStepLabel)},
+		})
+	} else {
+		logboek.Context(ctx).Default().LogFDetails("Launched command: %s\n", strings.Join(commands, " && "))
+		logboek.Context(ctx).Default().LogFDetails("The step is run from the image %s\n", fromImage)
+		runErr = fmt.Errorf("container run failed: %s", runErr)
+	}
+
+	if err := docker.ContainerRemove(ctx, containerName, types.ContainerRemoveOptions{}); err != nil && runErr == nil {
+		return err
+	}
+
+	return runErr
+}
+
+// squashImageArchiveLayers merges the image layers starting from the index into one layer.
+// Whiteouts of the merged layers are applied to the entries of the previous merged layers and kept only for the base layers.
+func squashImageArchiveLayers(imageArchivePath string, fromLayerInd int, tmpDir, squashedLayerPath string) error {
+	manifest, err := readDockerSaveManifest(imageArchivePath)
+	if err != nil {
+		return err
+	}
+
+	if fromLayerInd > len(manifest.Layers) {
+		return fmt.Errorf("unexpected image layers count %d, at least %d expected", len(manifest.Layers), fromLayerInd)
+	}
+
+	layerPaths, err := extractImageArchiveLayers(imageArchivePath, manifest.Layers[fromLayerInd:], tmpDir)
+	if err != nil {
+		return err
+	}
+
+	entries := newSquashedEntries()
+	for ind, layerPath := range layerPaths {
+		if err := walkLayerArchive(layerPath, func(hdr *tar.Header, _ io.Reader) error {
+			entries.add(ind, hdr)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(squashedLayerPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for ind, layerPath := range layerPaths {
+		if err := walkLayerArchive(layerPath, func(hdr *tar.Header, r io.Reader) error {
+			name := normalizeLayerEntryName(hdr.Name)
+			if !entries.isSurvived(ind, name) {
+				return nil
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			if _, err := io.Copy(tw, r); err != nil {
+				return err
+			}
+
+			if _, hasMarker := entries.entries[path.Join(name, whiteoutOpaqueMarker)]; hdr.Typeflag == tar.TypeDir && entries.opaques[name] && !hasMarker {
+				// the directory is recreated after the whiteout, its content in the base layers should be hidden
+				return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: path.Join(name, whiteoutOpaqueMarker), Mode: 0600, ModTime: hdr.ModTime})
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// squashedEntries keeps the index of the layer with the survived version of each entry.
+// Entries are also indexed by the parent directory, so the removal of the directory content only visits the directory subtree.
+type squashedEntries struct {
+	entries  map[string]int
+	opaques  map[string]bool
+	children map[string]map[string]bool
+}
+
+func newSquashedEntries() *squashedEntries {
+	return &squashedEntries{entries: map[string]int{}, opaques: map[string]bool{}, children: map[string]map[string]bool{}}
+}
+
+func (e *squashedEntries) add(layerInd int, hdr *tar.Header) {
+	name := normalizeLayerEntryName(hdr.Name)
+	if name == "" {
+		return
+	}
+
+	base := path.Base(name)
+	switch {
+	case base == whiteoutOpaqueMarker:
+		e.removeFromPreviousLayers(path.Dir(name), layerInd, false)
+		e.setEntry(name, layerInd)
+	case strings.HasPrefix(base, whiteoutPrefix):
+		e.removeFromPreviousLayers(path.Join(path.Dir(name), strings.TrimPrefix(base, whiteoutPrefix)), layerInd, true)
+		e.setEntry(name, layerInd)
+	default:
+		whiteoutName := path.Join(path.Dir(name), whiteoutPrefix+base)
+		if ind, ok := e.entries[whiteoutName]; ok && ind < layerInd {
+			delete(e.entries, whiteoutName)
+			if hdr.Typeflag == tar.TypeDir {
+				e.opaques[name] = true
+			}
+		}
+
+		// the file replaces the directory of the previous layers with its content
+		if hdr.Typeflag != tar.TypeDir && len(e.children[name]) > 0 {
+			e.removeFromPreviousLayers(name, layerInd, false)
+		}
+
+		e.setEntry(name, layerInd)
+	}
+}
+
+// setEntry sets the layer of the entry and adds the entry with its parent directories to the children index.
+func (e *squashedEntries) setEntry(name string, layerInd int) {
+	e.entries[name] = layerInd
+
+	for child := name; child != "."; child = path.Dir(child) {
+		parent := path.Dir(child)
+		if e.children[parent] == nil {
+			e.children[parent] = map[string]bool{}
+		} else if e.children[parent][child] {
+			break
+		}
+
+		e.children[parent][child] = true
+	}
+}
+
+// removeFromPreviousLayers removes the entries under the path from the previous layers, the entry itself is removed too if withPath.
+func (e *squashedEntries) removeFromPreviousLayers(p string, layerInd int, withPath bool) {
+	if withPath {
+		delete(e.opaques, p)
+		if ind, ok := e.entries[p]; ok && ind < layerInd {
+			delete(e.entries, p)
+		}
+	}
+
+	for child := range e.children[p] {
+		e.removeFromPreviousLayers(child, layerInd, true)
+	}
+
+	if _, ok := e.entries[p]; !ok && len(e.children[p]) == 0 {
+		delete(e.children, p)
+		delete(e.children[path.Dir(p)], p)
+	}
+}
+
+func (e *squashedEntries) isSurvived(layerInd int, name string) bool {
+	ind, ok := e.entries[name]
+	return ok && ind == layerInd
+}
+
+// extractImageArchiveLayers extracts the layers from the image archive in the docker save format into the tmp dir.
+func extractImageArchiveLayers(imageArchivePath string, layers []string, tmpDir string) ([]string, error) {
+	layerPaths := make([]string, len(layers))
+	layerIndexes := map[string][]int{}
+	for ind, layer := range layers {
+		layerIndexes[layer] = append(layerIndexes[layer], ind)
+	}
+
+	f, err := os.Open(imageArchivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		indexes, ok := layerIndexes[hdr.Name]
+		if !ok {
+			continue
+		}
+
+		layerPath := filepath.Join(tmpDir, fmt.Sprintf("squash-layer-%d.tar", indexes[0]))
+		if err := writeFileFromReader(layerPath, tr); err != nil {
+			return nil, err
+		}
+
+		for _, ind := range indexes {
+			layerPaths[ind] = layerPath
+		}
+	}
+
+	for ind, layerPath := range layerPaths {
+		if layerPath == "" {
+			return nil, fmt.Errorf("layer %s not found in the image archive", layers[ind])
+		}
+	}
+
+	return layerPaths, nil
+}
+
+func walkLayerArchive(layerPath string, f func(hdr *tar.Header, r io.Reader) error) error {
+	file, err := os.Open(layerPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read layer %s: %s", layerPath, err)
+		}
+
+		if err := f(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func writeFileFromReader(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
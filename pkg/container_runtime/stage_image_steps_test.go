@@ -0,0 +1,190 @@
+package container_runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// testLayerEntry is the layer archive entry, the directory if the name ends with a slash.
+type testLayerEntry struct {
+	name    string
+	content string
+}
+
+var _ = Describe("squashImageArchiveLayers", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-squash-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	DescribeTable("merges the step layers on top of the base layer",
+		func(stepLayers [][]testLayerEntry, expectedEntries []testLayerEntry) {
+			layers := append([][]testLayerEntry{{{name: "base/"}, {name: "base/file", content: "base"}}}, stepLayers...)
+			imageArchivePath := writeTestImageArchive(tmpDir, layers)
+
+			squashedLayerPath := filepath.Join(tmpDir, "squashed.tar")
+			Ω(squashImageArchiveLayers(imageArchivePath, 1, tmpDir, squashedLayerPath)).Should(Succeed())
+
+			Ω(readTestLayerArchive(squashedLayerPath)).Should(ConsistOf(expectedEntries))
+		},
+		Entry("new and changed files",
+			[][]testLayerEntry{
+				{{name: "app/"}, {name: "app/a", content: "1"}},
+				{{name: "app/"}, {name: "app/a", content: "2"}, {name: "app/b", content: "b"}},
+			},
+			[]testLayerEntry{{name: "app/"}, {name: "app/a", content: "2"}, {name: "app/b", content: "b"}},
+		),
+		Entry("delete of the base layer file keeps the whiteout",
+			[][]testLayerEntry{
+				{{name: "base/"}, {name: "base/.wh.file"}},
+			},
+			[]testLayerEntry{{name: "base/"}, {name: "base/.wh.file"}},
+		),
+		Entry("delete of the step file drops the file",
+			[][]testLayerEntry{
+				{{name: "app/"}, {name: "app/a", content: "1"}, {name: "app/b", content: "b"}},
+				{{name: "app/"}, {name: "app/.wh.a"}},
+			},
+			[]testLayerEntry{{name: "app/"}, {name: "app/b", content: "b"}, {name: "app/.wh.a"}},
+		),
+		Entry("delete of the step directory drops the directory content",
+			[][]testLayerEntry{
+				{{name: "app/"}, {name: "app/dir/"}, {name: "app/dir/a", content: "1"}},
+				{{name: "app/"}, {name: "app/.wh.dir"}},
+			},
+			[]testLayerEntry{{name: "app/"}, {name: "app/.wh.dir"}},
+		),
+		Entry("opaque directory hides the content of the previous step layers",
+			[][]testLayerEntry{
+				{{name: "base/"}, {name: "base/a", content: "a"}},
+				{{name: "base/"}, {name: "base/.wh..wh..opq"}, {name: "base/b", content: "b"}},
+			},
+			[]testLayerEntry{{name: "base/"}, {name: "base/.wh..wh..opq"}, {name: "base/b", content: "b"}},
+		),
+		Entry("re-added file drops the whiteout",
+			[][]testLayerEntry{
+				{{name: "base/"}, {name: "base/.wh.file"}},
+				{{name: "base/"}, {name: "base/file", content: "new"}},
+			},
+			[]testLayerEntry{{name: "base/"}, {name: "base/file", content: "new"}},
+		),
+		Entry("re-added directory becomes opaque",
+			[][]testLayerEntry{
+				{{name: ".wh.base"}},
+				{{name: "base/"}, {name: "base/new", content: "new"}},
+			},
+			[]testLayerEntry{{name: "base/"}, {name: "base/.wh..wh..opq"}, {name: "base/new", content: "new"}},
+		),
+		Entry("file replaces the step directory",
+			[][]testLayerEntry{
+				{{name: "app/"}, {name: "app/dir/"}, {name: "app/dir/a", content: "1"}, {name: "app/dir/nested/b", content: "2"}},
+				{{name: "app/"}, {name: "app/dir", content: "file"}},
+			},
+			[]testLayerEntry{{name: "app/"}, {name: "app/dir", content: "file"}},
+		),
+		Entry("delete of the step directory drops the content without the parent directory entries",
+			[][]testLayerEntry{
+				{{name: "app/dir/nested/a", content: "1"}, {name: "app/dir2/b", content: "2"}},
+				{{name: "app/.wh.dir"}},
+			},
+			[]testLayerEntry{{name: "app/dir2/b", content: "2"}, {name: "app/.wh.dir"}},
+		),
+	)
+})
+
+var _ = Describe("squashedEntries", func() {
+	It("drops the removed subtrees from the children index", func() {
+		entries := newSquashedEntries()
+		for _, name := range []string{"app/dir/a", "app/dir/nested/b", "app/c"} {
+			entries.add(0, &tar.Header{Typeflag: tar.TypeReg, Name: name})
+		}
+		entries.add(1, &tar.Header{Typeflag: tar.TypeReg, Name: "app/.wh.dir"})
+
+		Ω(entries.entries).Should(Equal(map[string]int{"app/c": 0, "app/.wh.dir": 1}))
+		Ω(entries.children).Should(Equal(map[string]map[string]bool{
+			".":   {"app": true},
+			"app": {"app/c": true, "app/.wh.dir": true},
+		}))
+	})
+})
+
+func writeTestImageArchive(dir string, layers [][]testLayerEntry) string {
+	imageArchivePath := filepath.Join(dir, "image.tar")
+	f, err := os.Create(imageArchivePath)
+	Ω(err).ShouldNot(HaveOccurred())
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	manifest := &dockerSaveManifest{Config: "config.json"}
+	for ind, layer := range layers {
+		layerName := fmt.Sprintf("%d/layer.tar", ind)
+		manifest.Layers = append(manifest.Layers, layerName)
+
+		var buf bytes.Buffer
+		writeTestLayerEntries(&buf, layer)
+		writeTestArchiveFile(tw, layerName, buf.Bytes())
+	}
+
+	manifestData, err := json.Marshal([]*dockerSaveManifest{manifest})
+	Ω(err).ShouldNot(HaveOccurred())
+	writeTestArchiveFile(tw, "manifest.json", manifestData)
+
+	Ω(tw.Close()).Should(Succeed())
+
+	return imageArchivePath
+}
+
+func writeTestLayerEntries(w io.Writer, entries []testLayerEntry) {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		if entry.name[len(entry.name)-1] == '/' {
+			Ω(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: entry.name, Mode: 0755})).Should(Succeed())
+		} else {
+			writeTestArchiveFile(tw, entry.name, []byte(entry.content))
+		}
+	}
+	Ω(tw.Close()).Should(Succeed())
+}
+
+func writeTestArchiveFile(tw *tar.Writer, name string, data []byte) {
+	Ω(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(data))})).Should(Succeed())
+	_, err := tw.Write(data)
+	Ω(err).ShouldNot(HaveOccurred())
+}
+
+func readTestLayerArchive(layerPath string) []testLayerEntry {
+	var entries []testLayerEntry
+	Ω(walkLayerArchive(layerPath, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Typeflag == tar.TypeDir {
+			entries = append(entries, testLayerEntry{name: normalizeLayerEntryName(hdr.Name) + "/"})
+			return nil
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, testLayerEntry{name: normalizeLayerEntryName(hdr.Name), content: string(data)})
+		return nil
+	})).Should(Succeed())
+
+	return entries
+}
@@ -141,3 +141,32 @@ func AddContextAddFilesToContextArchive(ctx context.Context, originalArchivePath
 
 	return destinationArchivePath, nil
 }
+
+func AddFileToContextArchive(ctx context.Context, originalArchivePath string, tarEntryName string, data []byte) (string, error) {
+	destinationArchivePath := GetTmpArchivePath()
+
+	if err := util.CreateArchiveBasedOnAnotherOne(ctx, originalArchivePath, destinationArchivePath, []string{tarEntryName}, func(tw *tar.Writer) error {
+		header := &tar.Header{
+			Name:     tarEntryName,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("unable to write tar header for file %s: %s", tarEntryName, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("unable to write data to tar archive for file %s: %s", tarEntryName, err)
+		}
+
+		logboek.Context(ctx).Debug().LogF("Extra file was added to the current context: %q\n", tarEntryName)
+
+		return nil
+	}); err != nil {
+		return "", err
+	}
+
+	return destinationArchivePath, nil
+}
@@ -0,0 +1,266 @@
+package bundles
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	uuid "github.com/satori/go.uuid"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// Bundle archive contains the bundle chart directory, the OCI layout with all images referenced in the bundle values
+// and the bundle metadata annotations which are set on the bundle manifest on import.
+const (
+	archiveChartDir  = "chart"
+	archiveImagesDir = "images"
+	archiveInfoFile  = "info.json"
+)
+
+// ExportArchive writes the bundle directory along with all images referenced in the werf service values of the bundle
+// and the bundle metadata into the tar.gz archive.
+func ExportArchive(ctx context.Context, bundleDir, archivePath string, info Info) error {
+	vals, err := readValues(bundleDir)
+	if err != nil {
+		return err
+	}
+
+	infoData, err := json.Marshal(info.Annotations())
+	if err != nil {
+		return fmt.Errorf("unable to marshal bundle info: %s", err)
+	}
+
+	imagesTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(imagesTmpDir)
+
+	layoutPath, err := layout.Write(imagesTmpDir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("unable to create OCI layout %q: %s", imagesTmpDir, err)
+	}
+
+	savedImages := map[string]bool{}
+	for _, img := range GetImages(vals) {
+		if savedImages[img.From] {
+			continue
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Saving image %s", img.From).DoError(func() error {
+			return docker_registry.API().WriteImageIntoOCILayout(ctx, img.From, layoutPath)
+		}); err != nil {
+			return fmt.Errorf("unable to save image %s referenced in %s: %s", img.From, img.ValuesPath, err)
+		}
+		savedImages[img.From] = true
+	}
+
+	return logboek.Context(ctx).LogProcess("Writing bundle archive %q", archivePath).DoError(func() error {
+		if err := os.MkdirAll(filepath.Dir(archivePath), os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(archivePath), err)
+		}
+
+		file, err := os.Create(archivePath)
+		if err != nil {
+			return fmt.Errorf("unable to create %q: %s", archivePath, err)
+		}
+		defer file.Close()
+
+		zw := gzip.NewWriter(file)
+		defer zw.Close()
+
+		tw := tar.NewWriter(zw)
+		defer tw.Close()
+
+		if err := copyDirIntoTar(tw, archiveChartDir, bundleDir); err != nil {
+			return err
+		}
+		if err := copyDirIntoTar(tw, archiveImagesDir, imagesTmpDir); err != nil {
+			return err
+		}
+		return writeDataIntoTar(tw, archiveInfoFile, infoData)
+	})
+}
+
+// ImportArchive pushes images from the bundle archive into the repo of bundleRef without changes (image digests stay the same),
+// rewrites images references in the bundle values and publishes the bundle as bundleRef.
+// The bundle metadata is kept, but the archive does not contain the bundle signature, so the published bundle is signed with signKey if specified.
+func ImportArchive(ctx context.Context, archivePath, bundleRef string, actionConfig *action.Configuration, signKey crypto.Signer) error {
+	archiveTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(archiveTmpDir)
+
+	repo, tag, _ := ParseBundleRef(bundleRef)
+	bundleDir, err := importArchiveImages(ctx, archivePath, archiveTmpDir, repo)
+	if err != nil {
+		return err
+	}
+
+	info, err := readArchiveInfo(archiveTmpDir, tag)
+	if err != nil {
+		return err
+	}
+
+	if err := Push(ctx, bundleDir, bundleRef, actionConfig); err != nil {
+		return err
+	}
+
+	if err := SetPublishedInfo(ctx, bundleRef, *info); err != nil {
+		return err
+	}
+
+	if signKey == nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Bundle %q is not signed, it can be signed with the --sign-key option\n", bundleRef)
+		return nil
+	}
+
+	return SignPublished(ctx, bundleRef, bundleDir, signKey)
+}
+
+// importArchiveImages extracts the bundle archive into archiveDir, pushes images into the repo and rewrites images references in the bundle values.
+// Returns the extracted bundle directory.
+func importArchiveImages(ctx context.Context, archivePath, archiveDir, repo string) (string, error) {
+	if err := logboek.Context(ctx).LogProcess("Extracting bundle archive %q", archivePath).DoError(func() error {
+		return extractArchive(archivePath, archiveDir)
+	}); err != nil {
+		return "", err
+	}
+
+	bundleDir := filepath.Join(archiveDir, archiveChartDir)
+	layoutPath := layout.Path(filepath.Join(archiveDir, archiveImagesDir))
+
+	valuesNode, err := readValuesNode(bundleDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, relocation := range RelocateImages(valuesNode, repo) {
+		if err := logboek.Context(ctx).Default().LogProcess("Pushing image %s", relocation.To).DoError(func() error {
+			return docker_registry.API().PushImageFromOCILayout(ctx, layoutPath, relocation.From, relocation.To)
+		}); err != nil {
+			return "", fmt.Errorf("unable to push image %s referenced in %s: %s", relocation.From, relocation.ValuesPath, err)
+		}
+	}
+
+	if err := writeValuesNode(bundleDir, valuesNode); err != nil {
+		return "", err
+	}
+
+	return bundleDir, nil
+}
+
+// readArchiveInfo reads the bundle metadata from the extracted archive, the chart version is used for archives without the metadata.
+func readArchiveInfo(archiveDir, tag string) (*Info, error) {
+	infoPath := filepath.Join(archiveDir, archiveInfoFile)
+
+	data, err := ioutil.ReadFile(infoPath)
+	if os.IsNotExist(err) {
+		chartfilePath := filepath.Join(archiveDir, archiveChartDir, "Chart.yaml")
+		metadata, err := chartutil.LoadChartfile(chartfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load %q: %s", chartfilePath, err)
+		}
+
+		return &Info{Tag: tag, Version: metadata.Version}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", infoPath, err)
+	}
+
+	annotations := map[string]string{}
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %s", infoPath, err)
+	}
+
+	return NewInfoFromAnnotations(tag, annotations), nil
+}
+
+func writeDataIntoTar(tw *tar.Writer, tarPath string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: tarPath, Mode: 0644, Size: int64(len(data))}); err != nil {
+		return fmt.Errorf("unable to write tar header for %q: %s", tarPath, err)
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("unable to write %q into tar: %s", tarPath, err)
+	}
+
+	return nil
+}
+
+func copyDirIntoTar(tw *tar.Writer, tarDir, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		return util.CopyFileIntoTar(tw, filepath.ToSlash(filepath.Join(tarDir, relPath)), path)
+	})
+}
+
+func extractArchive(archivePath, destDir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %s", archivePath, err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %s", archivePath, err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to read %q: %s", archivePath, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		path := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(path, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid archive entry %q", header.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return fmt.Errorf("unable to create dir %q: %s", filepath.Dir(path), err)
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return fmt.Errorf("unable to create %q: %s", path, err)
+		}
+
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("unable to write %q: %s", path, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package bundles
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var _ = Describe("bundle archive", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-bundle-archive-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home"))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	writeFile := func(path, data string) {
+		Ω(os.MkdirAll(filepath.Dir(path), os.ModePerm)).Should(Succeed())
+		Ω(ioutil.WriteFile(path, []byte(data), 0644)).Should(Succeed())
+	}
+
+	It("exports the bundle with images and imports it into another repo", func() {
+		ctx := context.Background()
+
+		server := httptest.NewServer(registry.New())
+		defer server.Close()
+		registryHost := strings.TrimPrefix(server.URL, "http://")
+
+		Ω(docker_registry.Init(ctx, true, false)).Should(Succeed())
+
+		img, err := random.Image(1024, 2)
+		Ω(err).ShouldNot(HaveOccurred())
+		imgDigest, err := img.Digest()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		srcImageRef := fmt.Sprintf("%s/project:1a2b3c", registryHost)
+		ref, err := name.ParseReference(srcImageRef)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remote.Write(ref, img)).Should(Succeed())
+
+		bundleDir := filepath.Join(tmpDir, "bundle")
+		writeFile(filepath.Join(bundleDir, "Chart.yaml"), "apiVersion: v2\nname: app\nversion: 1.0.0\n")
+		writeFile(filepath.Join(bundleDir, "templates", "deployment.yaml"), "kind: Deployment\n")
+		writeFile(filepath.Join(bundleDir, "values.yaml"), fmt.Sprintf("# images\nwerf:\n  repo: %s/project\n  image:\n    backend: %s\n", registryHost, srcImageRef))
+
+		archivePath := filepath.Join(tmpDir, "archive", "bundle.tar.gz")
+		info := Info{Version: "1.0.0", Revision: "1a2b3c4d", CreatedAt: time.Date(2021, 5, 12, 10, 30, 0, 0, time.UTC)}
+		Ω(ExportArchive(ctx, bundleDir, archivePath, info)).Should(Succeed())
+
+		importedBundleDir, err := importArchiveImages(ctx, archivePath, filepath.Join(tmpDir, "import"), fmt.Sprintf("%s/mirror", registryHost))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		info.Tag = "1.0.0"
+		Ω(readArchiveInfo(filepath.Join(tmpDir, "import"), "1.0.0")).Should(Equal(&info))
+
+		for _, p := range []string{"Chart.yaml", filepath.Join("templates", "deployment.yaml")} {
+			Ω(filepath.Join(importedBundleDir, p)).Should(BeARegularFile())
+			expected, err := ioutil.ReadFile(filepath.Join(bundleDir, p))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ioutil.ReadFile(filepath.Join(importedBundleDir, p))).Should(Equal(expected))
+		}
+
+		dstImageRef := fmt.Sprintf("%s/mirror:1a2b3c", registryHost)
+		Ω(ioutil.ReadFile(filepath.Join(importedBundleDir, "values.yaml"))).Should(Equal([]byte(fmt.Sprintf("# images\nwerf:\n  repo: %s/mirror\n  image:\n    backend: %s\n", registryHost, dstImageRef))))
+
+		ref, err = name.ParseReference(dstImageRef)
+		Ω(err).ShouldNot(HaveOccurred())
+		desc, err := remote.Get(ref)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(desc.Digest).Should(Equal(imgDigest))
+	})
+
+	It("rejects the archive which is not gzipped tar", func() {
+		archivePath := filepath.Join(tmpDir, "bundle.tar.gz")
+		writeFile(archivePath, "not an archive")
+
+		err := extractArchive(archivePath, filepath.Join(tmpDir, "import"))
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("unable to read"))
+	})
+
+	It("rejects the truncated archive", func() {
+		bundleDir := filepath.Join(tmpDir, "bundle")
+		writeFile(filepath.Join(bundleDir, "Chart.yaml"), "apiVersion: v2\nname: app\nversion: 1.0.0\n")
+		writeFile(filepath.Join(bundleDir, "values.yaml"), strings.Repeat("key: value\n", 1000))
+
+		archivePath := filepath.Join(tmpDir, "bundle.tar.gz")
+		Ω(ExportArchive(context.Background(), bundleDir, archivePath, Info{})).Should(Succeed())
+
+		data, err := ioutil.ReadFile(archivePath)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(ioutil.WriteFile(archivePath, data[:len(data)/2], 0644)).Should(Succeed())
+
+		Ω(extractArchive(archivePath, filepath.Join(tmpDir, "import"))).ShouldNot(Succeed())
+	})
+
+	It("rejects the archive entry outside of the destination directory", func() {
+		archivePath := filepath.Join(tmpDir, "bundle.tar.gz")
+
+		f, err := os.Create(archivePath)
+		Ω(err).ShouldNot(HaveOccurred())
+		zw := gzip.NewWriter(f)
+		tw := tar.NewWriter(zw)
+		Ω(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../evil", Mode: 0644, Size: 4})).Should(Succeed())
+		_, err = tw.Write([]byte("evil"))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(tw.Close()).Should(Succeed())
+		Ω(zw.Close()).Should(Succeed())
+		Ω(f.Close()).Should(Succeed())
+
+		err = extractArchive(archivePath, filepath.Join(tmpDir, "import"))
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(`invalid archive entry "../evil"`))
+		Ω(filepath.Join(tmpDir, "evil")).ShouldNot(BeAnExistingFile())
+	})
+})
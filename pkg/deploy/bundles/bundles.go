@@ -0,0 +1,144 @@
+package bundles
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml_v3 "gopkg.in/yaml.v3"
+	cmd_helm "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/action"
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/logboek"
+)
+
+// Pull pulls bundle from the container registry into the local chart helm cache and exports it into the destination directory.
+func Pull(ctx context.Context, bundleRef, destDir string, actionConfig *action.Configuration) error {
+	if err := logboek.Context(ctx).LogProcess("Pulling bundle %q", bundleRef).DoError(func() error {
+		if cmd := cmd_helm.NewChartPullCmd(actionConfig, logboek.Context(ctx).OutStream()); cmd != nil {
+			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
+				return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).LogProcess("Exporting bundle %q", bundleRef).DoError(func() error {
+		if cmd := cmd_helm.NewChartExportCmd(actionConfig, logboek.Context(ctx).OutStream(), cmd_helm.ChartExportCmdOptions{Destination: destDir}); cmd != nil {
+			if err := cmd.RunE(cmd, []string{bundleRef}); err != nil {
+				return fmt.Errorf("error exporting bundle %q: %s", bundleRef, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Push saves bundle directory into the local chart helm cache and pushes it into the container registry.
+func Push(ctx context.Context, bundleDir, bundleRef string, actionConfig *action.Configuration) error {
+	if err := logboek.Context(ctx).LogProcess("Saving bundle to the local chart helm cache").DoError(func() error {
+		helmChartSaveCmd := cmd_helm.NewChartSaveCmd(actionConfig, logboek.Context(ctx).OutStream())
+		if err := helmChartSaveCmd.RunE(helmChartSaveCmd, []string{bundleDir, bundleRef}); err != nil {
+			return fmt.Errorf("error saving bundle to the local chart helm cache: %s", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).LogProcess("Pushing bundle %q", bundleRef).DoError(func() error {
+		helmChartPushCmd := cmd_helm.NewChartPushCmd(actionConfig, logboek.Context(ctx).OutStream())
+		if err := helmChartPushCmd.RunE(helmChartPushCmd, []string{bundleRef}); err != nil {
+			return fmt.Errorf("error pushing bundle %q: %s", bundleRef, err)
+		}
+		return nil
+	})
+}
+
+// ParseBundleRef splits bundle reference REPO:TAG, REPO@DIGEST or REPO:TAG@DIGEST into repo, tag and digest,
+// latest tag is used by default when neither the tag nor the digest is specified.
+func ParseBundleRef(bundleRef string) (string, string, string) {
+	var digest string
+	if ind := strings.LastIndex(bundleRef, "@"); ind != -1 {
+		bundleRef, digest = bundleRef[:ind], bundleRef[ind+1:]
+	}
+
+	lastSlashInd := strings.LastIndex(bundleRef, "/")
+	lastColonInd := strings.LastIndex(bundleRef, ":")
+	if lastColonInd > lastSlashInd {
+		return bundleRef[:lastColonInd], bundleRef[lastColonInd+1:], digest
+	}
+
+	if digest != "" {
+		return bundleRef, "", digest
+	}
+
+	return bundleRef, "latest", ""
+}
+
+func readValues(bundleDir string) (map[string]interface{}, error) {
+	valuesFile := filepath.Join(bundleDir, "values.yaml")
+
+	data, err := ioutil.ReadFile(valuesFile)
+	if os.IsNotExist(err) {
+		return map[string]interface{}{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", valuesFile, err)
+	}
+
+	vals := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vals); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %s", valuesFile, err)
+	}
+
+	return vals, nil
+}
+
+// readValuesNode reads values.yaml of the bundle as the yaml node to keep the comments and the keys order on write.
+func readValuesNode(bundleDir string) (*yaml_v3.Node, error) {
+	valuesFile := filepath.Join(bundleDir, "values.yaml")
+
+	data, err := ioutil.ReadFile(valuesFile)
+	if os.IsNotExist(err) {
+		return &yaml_v3.Node{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", valuesFile, err)
+	}
+
+	node := &yaml_v3.Node{}
+	if err := yaml_v3.Unmarshal(data, node); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal %q: %s", valuesFile, err)
+	}
+
+	return node, nil
+}
+
+func writeValuesNode(bundleDir string, node *yaml_v3.Node) error {
+	if node.Kind == 0 {
+		return nil
+	}
+
+	valuesFile := filepath.Join(bundleDir, "values.yaml")
+
+	buf := bytes.NewBuffer(nil)
+	enc := yaml_v3.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return fmt.Errorf("unable to marshal bundle values: %s", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("unable to marshal bundle values: %s", err)
+	}
+
+	if err := ioutil.WriteFile(valuesFile, buf.Bytes(), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to write %q: %s", valuesFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package bundles
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	uuid "github.com/satori/go.uuid"
+	yaml_v3 "gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+
+	"github.com/werf/logboek"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/werf"
+)
+
+// ImageRelocation describes the image referenced in the autogenerated werf service values of the bundle.
+type ImageRelocation struct {
+	// ValuesPath is the path of the image reference in the bundle values, e.g. werf.image.backend.
+	ValuesPath string
+	From       string
+	To         string
+}
+
+// Copy copies bundle fromRef into toRef along with all images referenced in the werf service values of the bundle.
+// Images are copied into the repo of toRef without changes, so image digests stay the same, the bundle metadata is kept.
+// The signature of fromRef is not valid for toRef with the rewritten values, so toRef is signed with signKey if specified.
+func Copy(ctx context.Context, fromRef, toRef string, actionConfig *action.Configuration, signKey crypto.Signer) error {
+	bundleTmpDir := filepath.Join(werf.GetServiceDir(), "tmp", "bundles", uuid.NewV4().String())
+	defer os.RemoveAll(bundleTmpDir)
+
+	if signKey == nil {
+		isSigned, err := IsSigned(ctx, fromRef)
+		if err != nil {
+			return err
+		}
+
+		if isSigned {
+			logboek.Context(ctx).Warn().LogF("WARNING: Bundle %q signature is not copied, the copied bundle %q can be signed with the --sign-key option\n", fromRef, toRef)
+		}
+	}
+
+	info, err := GetInfo(ctx, fromRef)
+	if err != nil {
+		return err
+	} else if info == nil {
+		return fmt.Errorf("%q is not a bundle", fromRef)
+	}
+
+	if err := Pull(ctx, fromRef, bundleTmpDir, actionConfig); err != nil {
+		return err
+	}
+
+	valuesNode, err := readValuesNode(bundleTmpDir)
+	if err != nil {
+		return err
+	}
+
+	toRepo, _, _ := ParseBundleRef(toRef)
+	relocations := RelocateImages(valuesNode, toRepo)
+
+	for _, relocation := range relocations {
+		if relocation.From == relocation.To {
+			continue
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Copying image %s to %s", relocation.From, relocation.To).DoError(func() error {
+			return docker_registry.API().CopyImage(ctx, relocation.From, relocation.To)
+		}); err != nil {
+			return fmt.Errorf("unable to copy image %s referenced in %s: %s", relocation.From, relocation.ValuesPath, err)
+		}
+	}
+
+	if err := writeValuesNode(bundleTmpDir, valuesNode); err != nil {
+		return err
+	}
+
+	if err := Push(ctx, bundleTmpDir, toRef, actionConfig); err != nil {
+		return err
+	}
+
+	if err := SetPublishedInfo(ctx, toRef, *info); err != nil {
+		return err
+	}
+
+	if signKey != nil {
+		return SignPublished(ctx, toRef, bundleTmpDir, signKey)
+	}
+
+	return nil
+}
+
+// GetImages returns all images referenced in the werf service values of the bundle.
+func GetImages(vals map[string]interface{}) []*ImageRelocation {
+	werfVals, ok := vals["werf"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var images []*ImageRelocation
+	addImage := func(valuesPath string, value interface{}) {
+		if ref, ok := value.(string); ok && ref != "" {
+			images = append(images, &ImageRelocation{ValuesPath: valuesPath, From: ref, To: ref})
+		}
+	}
+
+	if imageVals, ok := werfVals["image"].(map[string]interface{}); ok {
+		var names []string
+		for name := range imageVals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			addImage(fmt.Sprintf("werf.image.%s", name), imageVals[name])
+		}
+	}
+
+	addImage("werf.nameless_image", werfVals["nameless_image"])
+
+	return images
+}
+
+// RelocateImages rewrites images references in the werf service values of the bundle to the specified repo, tags and digests are kept.
+// Only the image fields of the values yaml node are changed, so the comments and the keys order are kept.
+func RelocateImages(valuesNode *yaml_v3.Node, repo string) []*ImageRelocation {
+	werfNode := yamlMappingValue(yamlDocumentContent(valuesNode), "werf")
+
+	var relocations []*ImageRelocation
+	relocate := func(valuesPath string, node *yaml_v3.Node) {
+		if node == nil || node.Kind != yaml_v3.ScalarNode || node.Tag != "!!str" || node.Value == "" {
+			return
+		}
+
+		// the image is copied without changes, so the reference by digest stays valid
+		_, tag, digest := ParseBundleRef(node.Value)
+		newRef := repo
+		if tag != "" {
+			newRef += ":" + tag
+		}
+		if digest != "" {
+			newRef += "@" + digest
+		}
+		relocations = append(relocations, &ImageRelocation{ValuesPath: valuesPath, From: node.Value, To: newRef})
+		node.Value = newRef
+	}
+
+	if imagesNode := yamlMappingValue(werfNode, "image"); imagesNode != nil && imagesNode.Kind == yaml_v3.MappingNode {
+		nodesByName := map[string]*yaml_v3.Node{}
+		var names []string
+		for i := 0; i+1 < len(imagesNode.Content); i += 2 {
+			name := imagesNode.Content[i].Value
+			nodesByName[name] = imagesNode.Content[i+1]
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			relocate(fmt.Sprintf("werf.image.%s", name), nodesByName[name])
+		}
+	}
+
+	relocate("werf.nameless_image", yamlMappingValue(werfNode, "nameless_image"))
+
+	if repoNode := yamlMappingValue(werfNode, "repo"); repoNode != nil && repoNode.Kind == yaml_v3.ScalarNode {
+		repoNode.Value = repo
+		repoNode.Tag = "!!str"
+	}
+
+	return relocations
+}
+
+func yamlDocumentContent(node *yaml_v3.Node) *yaml_v3.Node {
+	if node != nil && node.Kind == yaml_v3.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+
+	return node
+}
+
+func yamlMappingValue(node *yaml_v3.Node, key string) *yaml_v3.Node {
+	if node == nil || node.Kind != yaml_v3.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
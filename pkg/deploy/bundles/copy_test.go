@@ -0,0 +1,144 @@
+package bundles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+var _ = DescribeTable("ParseBundleRef splits the bundle reference into the repo, the tag and the digest",
+	func(ref, expectedRepo, expectedTag, expectedDigest string) {
+		repo, tag, digest := ParseBundleRef(ref)
+		Ω(repo).Should(Equal(expectedRepo))
+		Ω(tag).Should(Equal(expectedTag))
+		Ω(digest).Should(Equal(expectedDigest))
+	},
+	Entry("repo and tag", "registry.example.com/project:v1.0.0", "registry.example.com/project", "v1.0.0", ""),
+	Entry("no tag", "registry.example.com/project", "registry.example.com/project", "latest", ""),
+	Entry("registry port without tag", "localhost:5000/project", "localhost:5000/project", "latest", ""),
+	Entry("registry port and tag", "localhost:5000/project:v1", "localhost:5000/project", "v1", ""),
+	Entry("digest", "registry.example.com/project@"+testDigest, "registry.example.com/project", "", testDigest),
+	Entry("registry port and digest", "localhost:5000/project@"+testDigest, "localhost:5000/project", "", testDigest),
+	Entry("tag and digest", "localhost:5000/project:v1@"+testDigest, "localhost:5000/project", "v1", testDigest),
+)
+
+var _ = Describe("RelocateImages", func() {
+	var bundleDir string
+
+	BeforeEach(func() {
+		var err error
+		bundleDir, err = ioutil.TempDir("", "werf-bundle-")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(bundleDir)).Should(Succeed())
+	})
+
+	relocateValues := func(values, repo string) (string, []*ImageRelocation) {
+		valuesFile := filepath.Join(bundleDir, "values.yaml")
+		Ω(ioutil.WriteFile(valuesFile, []byte(values), 0644)).Should(Succeed())
+
+		valuesNode, err := readValuesNode(bundleDir)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		relocations := RelocateImages(valuesNode, repo)
+		Ω(writeValuesNode(bundleDir, valuesNode)).Should(Succeed())
+
+		data, err := ioutil.ReadFile(valuesFile)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return string(data), relocations
+	}
+
+	It("rewrites only the image fields and keeps the comments and the keys order", func() {
+		values, relocations := relocateValues(`# application settings
+replicas: 2
+werf:
+  # images of the bundle
+  repo: registry.example.com/project
+  nameless_image: ""
+  image:
+    frontend: registry.example.com/project:2a3b4c # built by werf
+    backend: registry.example.com/project:1a2b3c
+  env: production
+`, "localhost:5000/copy")
+
+		Ω(values).Should(Equal(`# application settings
+replicas: 2
+werf:
+  # images of the bundle
+  repo: localhost:5000/copy
+  nameless_image: ""
+  image:
+    frontend: localhost:5000/copy:2a3b4c # built by werf
+    backend: localhost:5000/copy:1a2b3c
+  env: production
+`))
+
+		Ω(relocations).Should(Equal([]*ImageRelocation{
+			{ValuesPath: "werf.image.backend", From: "registry.example.com/project:1a2b3c", To: "localhost:5000/copy:1a2b3c"},
+			{ValuesPath: "werf.image.frontend", From: "registry.example.com/project:2a3b4c", To: "localhost:5000/copy:2a3b4c"},
+		}))
+	})
+
+	It("relocates the nameless image", func() {
+		values, relocations := relocateValues("werf:\n  nameless_image: registry.example.com/project:1a2b3c\n", "localhost:5000/copy")
+
+		Ω(values).Should(Equal("werf:\n  nameless_image: localhost:5000/copy:1a2b3c\n"))
+		Ω(relocations).Should(Equal([]*ImageRelocation{
+			{ValuesPath: "werf.nameless_image", From: "registry.example.com/project:1a2b3c", To: "localhost:5000/copy:1a2b3c"},
+		}))
+	})
+
+	It("keeps the digests of the images referenced by digest", func() {
+		values, relocations := relocateValues(fmt.Sprintf("werf:\n  image:\n    backend: registry.example.com/project@%s\n    frontend: registry.example.com/project:2a3b4c@%s\n", testDigest, testDigest), "localhost:5000/copy")
+
+		Ω(values).Should(Equal(fmt.Sprintf("werf:\n  image:\n    backend: localhost:5000/copy@%s\n    frontend: localhost:5000/copy:2a3b4c@%s\n", testDigest, testDigest)))
+		Ω(relocations).Should(Equal([]*ImageRelocation{
+			{ValuesPath: "werf.image.backend", From: "registry.example.com/project@" + testDigest, To: "localhost:5000/copy@" + testDigest},
+			{ValuesPath: "werf.image.frontend", From: "registry.example.com/project:2a3b4c@" + testDigest, To: "localhost:5000/copy:2a3b4c@" + testDigest},
+		}))
+	})
+
+	It("does nothing without the werf service values", func() {
+		values, relocations := relocateValues("# no images\nreplicas: 2\n", "localhost:5000/copy")
+
+		Ω(values).Should(Equal("# no images\nreplicas: 2\n"))
+		Ω(relocations).Should(BeEmpty())
+	})
+
+	It("does not create values.yaml if the bundle has no values", func() {
+		valuesNode, err := readValuesNode(bundleDir)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(RelocateImages(valuesNode, "localhost:5000/copy")).Should(BeEmpty())
+		Ω(writeValuesNode(bundleDir, valuesNode)).Should(Succeed())
+		Ω(filepath.Join(bundleDir, "values.yaml")).ShouldNot(BeAnExistingFile())
+	})
+})
+
+var _ = DescribeTable("GetImages returns the images of the werf service values",
+	func(vals map[string]interface{}, expected []*ImageRelocation) {
+		Ω(GetImages(vals)).Should(Equal(expected))
+	},
+	Entry("no werf values", map[string]interface{}{"replicas": 2}, nil),
+	Entry("images and nameless image",
+		map[string]interface{}{"werf": map[string]interface{}{
+			"image":          map[string]interface{}{"frontend": "repo:2", "backend": "repo:1", "empty": ""},
+			"nameless_image": "repo:3",
+		}},
+		[]*ImageRelocation{
+			{ValuesPath: "werf.image.backend", From: "repo:1", To: "repo:1"},
+			{ValuesPath: "werf.image.frontend", From: "repo:2", To: "repo:2"},
+			{ValuesPath: "werf.nameless_image", From: "repo:3", To: "repo:3"},
+		},
+	),
+)
@@ -0,0 +1,334 @@
+package bundles
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker_registry"
+)
+
+// Bundle signature is stored in the OCI annotations of the published bundle manifest along with the signed payload.
+// Payload pins the chart content digest and digests of all images referenced in the bundle values,
+// so neither the chart nor images can be replaced under the same tags without breaking the signature.
+const (
+	SignatureAnnoName     = "werf.io/bundle-signature"
+	SignedPayloadAnnoName = "werf.io/bundle-signed-payload"
+)
+
+const helmChartContentLayerMediaType = "application/tar+gzip"
+
+type SignedPayload struct {
+	ChartDigest string `json:"chartDigest"`
+	// Images maps image references from the bundle values to image digests.
+	Images map[string]string `json:"images"`
+}
+
+// Sign records digests of the published bundle chart and images referenced in the bundle values and signs them
+// with the private key. The returned annotations should be set on the published bundle manifest.
+func Sign(ctx context.Context, bundleRef, bundleDir string, key crypto.Signer) (map[string]string, error) {
+	manifest, err := docker_registry.API().GetImageManifest(ctx, bundleRef)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bundle %q manifest: %s", bundleRef, err)
+	}
+
+	chartDigest, err := getChartDigest(bundleRef, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	vals, err := readValues(bundleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := SignedPayload{ChartDigest: chartDigest, Images: map[string]string{}}
+	for _, img := range GetImages(vals) {
+		digest, err := docker_registry.API().GetImageDigest(ctx, img.From)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get digest of image %s referenced in %s: %s", img.From, img.ValuesPath, err)
+		}
+		payload.Images[img.From] = digest
+	}
+
+	annotations, err := signPayload(payload, key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign bundle %q: %s", bundleRef, err)
+	}
+
+	return annotations, nil
+}
+
+// SignPublished signs the bundle published from the bundle directory and saves the signature into the bundle manifest annotations.
+func SignPublished(ctx context.Context, bundleRef, bundleDir string, key crypto.Signer) error {
+	return logboek.Context(ctx).LogProcess("Signing bundle %q", bundleRef).DoError(func() error {
+		annotations, err := Sign(ctx, bundleRef, bundleDir, key)
+		if err != nil {
+			return err
+		}
+
+		if err := docker_registry.API().SetImageAnnotations(ctx, bundleRef, annotations); err != nil {
+			return fmt.Errorf("unable to set bundle %q annotations: %s", bundleRef, err)
+		}
+
+		return nil
+	})
+}
+
+// Verify checks the bundle signature with the public key and that the bundle chart has not been changed since signing.
+// The bundle chart should be pulled by PullVerified afterwards, because the tag can be moved after verification.
+func Verify(ctx context.Context, bundleRef string, key crypto.PublicKey) (*SignedPayload, error) {
+	manifest, err := docker_registry.API().GetImageManifest(ctx, bundleRef)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bundle %q manifest: %s", bundleRef, err)
+	}
+
+	payload, err := verifyAnnotations(manifest.Annotations, key)
+	if err != nil {
+		return nil, fmt.Errorf("bundle %q: %s", bundleRef, err)
+	}
+
+	chartDigest, err := getChartDigest(bundleRef, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if chartDigest != payload.ChartDigest {
+		return nil, fmt.Errorf("bundle %q chart digest %s does not match the signed digest %s", bundleRef, chartDigest, payload.ChartDigest)
+	}
+
+	return payload, nil
+}
+
+// PullVerified downloads the bundle chart by the signed digest rather than by the tag and exports it into the destination directory
+// (or into the directory named as the chart in the current working directory if the destination is empty).
+func PullVerified(ctx context.Context, bundleRef string, payload *SignedPayload, destDir string) error {
+	repo, _, _ := ParseBundleRef(bundleRef)
+	chartRef := fmt.Sprintf("%s@%s", repo, payload.ChartDigest)
+
+	return logboek.Context(ctx).LogProcess("Pulling bundle %q", chartRef).DoError(func() error {
+		data, err := docker_registry.API().GetBlob(ctx, chartRef)
+		if err != nil {
+			return fmt.Errorf("unable to pull bundle chart %q: %s", chartRef, err)
+		}
+
+		if digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data)); digest != payload.ChartDigest {
+			return fmt.Errorf("pulled bundle chart digest %s does not match the signed digest %s", digest, payload.ChartDigest)
+		}
+
+		ch, err := loader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("unable to load bundle chart %q: %s", chartRef, err)
+		}
+
+		if destDir == "" {
+			err = chartutil.SaveDir(ch, ".")
+		} else {
+			err = chartutil.SaveIntoDir(ch, destDir)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to export bundle chart %q: %s", chartRef, err)
+		}
+
+		return nil
+	})
+}
+
+// IsSigned returns true if the bundle manifest contains the signature.
+func IsSigned(ctx context.Context, bundleRef string) (bool, error) {
+	manifest, err := docker_registry.API().GetImageManifest(ctx, bundleRef)
+	if err != nil {
+		return false, fmt.Errorf("unable to get bundle %q manifest: %s", bundleRef, err)
+	}
+
+	return manifest.Annotations[SignatureAnnoName] != "", nil
+}
+
+// VerifyImages checks that digests of the signed images have not been changed since signing. Images referenced
+// in the bundle values are the same as the signed ones, because the bundle chart digest is checked by Verify.
+func VerifyImages(ctx context.Context, payload *SignedPayload) error {
+	var refs []string
+	for ref := range payload.Images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		digest, err := docker_registry.API().GetImageDigest(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("unable to get digest of image %s: %s", ref, err)
+		}
+
+		if digest != payload.Images[ref] {
+			return fmt.Errorf("image %s digest %s does not match the signed digest %s", ref, digest, payload.Images[ref])
+		}
+
+		logboek.Context(ctx).Info().LogF("Image %s digest %s verified\n", ref, digest)
+	}
+
+	return nil
+}
+
+// LoadSigningKey loads PEM encoded ed25519, ECDSA or RSA private key.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key %q: %s", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key %q type %T", path, key)
+	}
+
+	return signer, nil
+}
+
+// LoadVerificationKey loads PEM encoded ed25519, ECDSA or RSA public key.
+func LoadVerificationKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEMFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key %q: %s", path, err)
+	}
+
+	return key, nil
+}
+
+func readPEMFile(path string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %q: %s", path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode %q: PEM data expected", path)
+	}
+
+	return block, nil
+}
+
+func signPayload(payload SignedPayload, key crypto.Signer) (map[string]string, error) {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal signed payload: %s", err)
+	}
+
+	signature, err := signData(key, rawPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		SignedPayloadAnnoName: base64.StdEncoding.EncodeToString(rawPayload),
+		SignatureAnnoName:     base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+func verifyAnnotations(annotations map[string]string, key crypto.PublicKey) (*SignedPayload, error) {
+	if annotations[SignatureAnnoName] == "" || annotations[SignedPayloadAnnoName] == "" {
+		return nil, fmt.Errorf("not signed")
+	}
+
+	rawPayload, err := base64.StdEncoding.DecodeString(annotations[SignedPayloadAnnoName])
+	if err != nil {
+		return nil, fmt.Errorf("annotation %s with invalid value: %s", SignedPayloadAnnoName, err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(annotations[SignatureAnnoName])
+	if err != nil {
+		return nil, fmt.Errorf("annotation %s with invalid value: %s", SignatureAnnoName, err)
+	}
+
+	if err := verifyData(key, rawPayload, signature); err != nil {
+		return nil, fmt.Errorf("signature verification failed: %s", err)
+	}
+
+	var payload SignedPayload
+	if err := json.Unmarshal(rawPayload, &payload); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal signed payload: %s", err)
+	}
+
+	return &payload, nil
+}
+
+func getChartDigest(bundleRef string, manifest *v1.Manifest) (string, error) {
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == helmChartContentLayerMediaType {
+			return layer.Digest.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("bundle %q chart content layer not found", bundleRef)
+}
+
+func signData(key crypto.Signer, data []byte) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+
+	digest := sha256.Sum256(data)
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+func verifyData(key crypto.PublicKey, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, data, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *ecdsa.PublicKey:
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil || !ecdsa.Verify(k, digest[:], sig.R, sig.S) {
+			return fmt.Errorf("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	return nil
+}
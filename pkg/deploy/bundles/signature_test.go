@@ -0,0 +1,113 @@
+package bundles
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("signature", func() {
+	payload := SignedPayload{
+		ChartDigest: "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		Images: map[string]string{
+			"registry.example.com/project:backend": "sha256:0263829989b6fd954f72baaf2fc64bc2e2f01d692d4de72986ea808f6e99813f",
+		},
+	}
+
+	DescribeTable("Sign and Verify round trip",
+		func(generateKey func() crypto.Signer) {
+			key := generateKey()
+
+			annotations, err := signPayload(payload, key)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			verifiedPayload, err := verifyAnnotations(annotations, key.Public())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(*verifiedPayload).Should(Equal(payload))
+		},
+		Entry("ed25519", func() crypto.Signer {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			Ω(err).ShouldNot(HaveOccurred())
+			return key
+		}),
+		Entry("ECDSA", func() crypto.Signer {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Ω(err).ShouldNot(HaveOccurred())
+			return key
+		}),
+		Entry("RSA", func() crypto.Signer {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Ω(err).ShouldNot(HaveOccurred())
+			return key
+		}),
+	)
+
+	DescribeTable("Verify rejects",
+		func(modifyAnnotations func(annotations map[string]string), expectedErrSubstring string) {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			annotations, err := signPayload(payload, key)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			modifyAnnotations(annotations)
+
+			_, err = verifyAnnotations(annotations, key.Public())
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(expectedErrSubstring))
+		},
+		Entry("tampered chart digest", func(annotations map[string]string) {
+			tamperedPayload := payload
+			tamperedPayload.ChartDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+			annotations[SignedPayloadAnnoName] = encodePayload(tamperedPayload)
+		}, "signature verification failed"),
+		Entry("tampered image digest", func(annotations map[string]string) {
+			tamperedPayload := SignedPayload{ChartDigest: payload.ChartDigest, Images: map[string]string{
+				"registry.example.com/project:backend": "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			}}
+			annotations[SignedPayloadAnnoName] = encodePayload(tamperedPayload)
+		}, "signature verification failed"),
+		Entry("signature of another key", func(annotations map[string]string) {
+			_, anotherKey, err := ed25519.GenerateKey(rand.Reader)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			anotherAnnotations, err := signPayload(payload, anotherKey)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			annotations[SignatureAnnoName] = anotherAnnotations[SignatureAnnoName]
+		}, "signature verification failed"),
+		Entry("invalid signature encoding", func(annotations map[string]string) {
+			annotations[SignatureAnnoName] = "!"
+		}, "invalid value"),
+		Entry("missing signature", func(annotations map[string]string) {
+			delete(annotations, SignatureAnnoName)
+		}, "not signed"),
+	)
+
+	It("Verify rejects the key of unsupported type", func() {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		annotations, err := signPayload(payload, key)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		_, err = verifyAnnotations(annotations, "key")
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring("unsupported public key type"))
+	})
+})
+
+func encodePayload(payload SignedPayload) string {
+	rawPayload, err := json.Marshal(payload)
+	Ω(err).ShouldNot(HaveOccurred())
+	return base64.StdEncoding.EncodeToString(rawPayload)
+}
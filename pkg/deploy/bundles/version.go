@@ -0,0 +1,271 @@
+package bundles
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/storage"
+)
+
+// BundleRefAnnoName is the bundle Chart.yaml annotation which is set by the bundle apply command along with the bundle metadata annotations,
+// so the release history shows which bundle has been applied.
+const BundleRefAnnoName = "werf.io/bundle-ref"
+
+const helmChartConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+
+// The bundle metadata is stored in the OCI annotations of the published bundle manifest along with the signature,
+// so the bundles list is read from the manifests without pulling the charts.
+const (
+	VersionAnnoName   = "werf.io/bundle-version"
+	RevisionAnnoName  = "werf.io/bundle-revision"
+	CreatedAtAnnoName = "werf.io/bundle-created-at"
+)
+
+// Info contains metadata of the published bundle.
+type Info struct {
+	Tag       string
+	Version   string
+	Revision  string
+	CreatedAt time.Time
+}
+
+// Annotations returns the bundle manifest annotations with the metadata, the tag is not saved.
+func (info Info) Annotations() map[string]string {
+	annotations := map[string]string{}
+	if info.Version != "" {
+		annotations[VersionAnnoName] = info.Version
+	}
+	if info.Revision != "" {
+		annotations[RevisionAnnoName] = info.Revision
+	}
+	if !info.CreatedAt.IsZero() {
+		annotations[CreatedAtAnnoName] = info.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	return annotations
+}
+
+// NewInfoFromAnnotations returns bundle metadata saved by Info.Annotations.
+func NewInfoFromAnnotations(tag string, annotations map[string]string) *Info {
+	info := &Info{
+		Tag:      tag,
+		Version:  annotations[VersionAnnoName],
+		Revision: annotations[RevisionAnnoName],
+	}
+
+	if value := annotations[CreatedAtAnnoName]; value != "" {
+		if createdAt, err := time.Parse(time.RFC3339, value); err == nil {
+			info.CreatedAt = createdAt
+		}
+	}
+
+	return info
+}
+
+// SetPublishedInfo saves bundle metadata into the published bundle manifest annotations.
+func SetPublishedInfo(ctx context.Context, bundleRef string, info Info) error {
+	if err := docker_registry.API().SetImageAnnotations(ctx, bundleRef, info.Annotations()); err != nil {
+		return fmt.Errorf("unable to set bundle %q annotations: %s", bundleRef, err)
+	}
+
+	return nil
+}
+
+// GetInfo returns bundle metadata saved by SetPublishedInfo from the bundle manifest annotations,
+// the chart version is read from the config blob for bundles published by older werf versions.
+// Nil is returned if the reference is not a bundle (e.g. the stage image stored in the same repo).
+func GetInfo(ctx context.Context, bundleRef string) (*Info, error) {
+	manifest, err := docker_registry.API().GetImageManifest(ctx, bundleRef)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bundle %q manifest: %s", bundleRef, err)
+	}
+
+	if manifest.Config.MediaType != helmChartConfigMediaType {
+		return nil, nil
+	}
+
+	repo, tag, _ := ParseBundleRef(bundleRef)
+	info := NewInfoFromAnnotations(tag, manifest.Annotations)
+	if info.Version != "" {
+		return info, nil
+	}
+
+	configRef := fmt.Sprintf("%s@%s", repo, manifest.Config.Digest)
+	data, err := docker_registry.API().GetBlob(ctx, configRef)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bundle %q config: %s", bundleRef, err)
+	}
+
+	metadata := &chart.Metadata{}
+	if err := json.Unmarshal(data, metadata); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal bundle %q config: %s", bundleRef, err)
+	}
+	info.Version = metadata.Version
+
+	return info, nil
+}
+
+// List returns metadata of all bundles published into the repo ordered by the creation time, newest first.
+func List(ctx context.Context, repo string) ([]*Info, error) {
+	tags, err := docker_registry.API().Tags(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get tags of %q: %s", repo, err)
+	}
+
+	var infos []*Info
+	for _, tag := range tags {
+		if storage.IsRepoStageOrServiceImageTag(tag) {
+			continue
+		}
+
+		info, err := GetInfo(ctx, fmt.Sprintf("%s:%s", repo, tag))
+		if err != nil {
+			return nil, err
+		}
+
+		if info != nil {
+			infos = append(infos, info)
+		}
+	}
+
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+
+	return infos, nil
+}
+
+// ResolveTag returns the tag itself if it exists in the repo, otherwise the tag is treated as semver constraint
+// (e.g. ">=1.2.0, <2.0.0", "~1.2", "1.x") and the greatest matching semver tag is returned.
+func ResolveTag(ctx context.Context, repo, tag string) (string, error) {
+	constraint, err := semver.NewConstraint(tag)
+	if err != nil {
+		return tag, nil
+	}
+
+	tags, err := docker_registry.API().Tags(ctx, repo)
+	if err != nil {
+		return "", fmt.Errorf("unable to get tags of %q: %s", repo, err)
+	}
+
+	resolvedTag := resolveTag(tags, tag, constraint)
+	if resolvedTag == "" {
+		return "", fmt.Errorf("no bundle tags matching semver constraint %q found in %q", tag, repo)
+	}
+
+	return resolvedTag, nil
+}
+
+// resolveTag returns the tag itself if it is in the tags, otherwise the greatest semver tag matching the constraint or empty string.
+func resolveTag(tags []string, tag string, constraint *semver.Constraints) string {
+	var versions []*semver.Version
+	for _, t := range tags {
+		if t == tag {
+			return tag
+		}
+
+		if version, err := semver.NewVersion(t); err == nil && constraint.Check(version) {
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		return ""
+	}
+
+	sort.Sort(semver.Collection(versions))
+	return versions[len(versions)-1].Original()
+}
+
+// GetVersionFromGit returns the bundle version calculated from the nearest semver git tag reachable from HEAD:
+// the tag version when HEAD is tagged, otherwise the tag version with the bumped patch
+// and the prerelease <number of commits since the tag>.g<short HEAD commit>, e.g. 1.2.4-3.g1a2b3c4.
+func GetVersionFromGit(ctx context.Context, localGitRepo *git_repo.Local) (string, error) {
+	headCommit, err := localGitRepo.HeadCommit(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := localGitRepo.TagsList(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to get git tags: %s", err)
+	}
+
+	var nearestVersion *semver.Version
+	var nearestVersionCommit string
+	for _, tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue
+		}
+
+		tagCommit, err := localGitRepo.TagCommit(ctx, tag)
+		if err != nil {
+			return "", fmt.Errorf("unable to get git tag %q commit: %s", tag, err)
+		}
+
+		if tagCommit != headCommit {
+			isAncestor, err := localGitRepo.IsAncestor(ctx, tagCommit, headCommit)
+			if err != nil {
+				return "", err
+			}
+			if !isAncestor {
+				continue
+			}
+		}
+
+		if nearestVersion == nil || version.GreaterThan(nearestVersion) {
+			nearestVersion = version
+			nearestVersionCommit = tagCommit
+		}
+	}
+
+	if nearestVersion != nil && nearestVersionCommit == headCommit {
+		return nearestVersion.String(), nil
+	}
+
+	commitsNumber, err := localGitRepo.CountCommits(ctx, headCommit, nearestVersionCommit)
+	if err != nil {
+		return "", fmt.Errorf("unable to count git commits: %s", err)
+	}
+
+	baseVersion := semver.MustParse("0.0.0")
+	if nearestVersion != nil {
+		baseVersion = nearestVersion
+	}
+
+	version, err := baseVersion.IncPatch().SetPrerelease(fmt.Sprintf("%d.g%s", commitsNumber, headCommit[:7]))
+	if err != nil {
+		return "", err
+	}
+
+	return version.String(), nil
+}
+
+// UpdateChartMetadata modifies Chart.yaml of the bundle directory.
+func UpdateChartMetadata(bundleDir string, updateFunc func(metadata *chart.Metadata)) error {
+	chartfilePath := filepath.Join(bundleDir, "Chart.yaml")
+
+	metadata, err := chartutil.LoadChartfile(chartfilePath)
+	if err != nil {
+		return fmt.Errorf("unable to load %q: %s", chartfilePath, err)
+	}
+
+	updateFunc(metadata)
+
+	if err := chartutil.SaveChartfile(chartfilePath, metadata); err != nil {
+		return fmt.Errorf("unable to save %q: %s", chartfilePath, err)
+	}
+
+	return nil
+}
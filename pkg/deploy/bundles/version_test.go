@@ -0,0 +1,245 @@
+package bundles
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/git_repo"
+)
+
+var _ = DescribeTable("resolveTag returns the existing tag or the greatest semver tag matching the constraint",
+	func(tags []string, tag, expected string) {
+		constraint, err := semver.NewConstraint(tag)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resolveTag(tags, tag, constraint)).Should(Equal(expected))
+	},
+	Entry("existing tag", []string{"1.0.0", "1.1.0"}, "1.0.0", "1.0.0"),
+	Entry("range", []string{"1.0.0", "1.2.5", "1.10.0", "2.0.0"}, ">=1.2.0, <2.0.0", "1.10.0"),
+	Entry("tilde", []string{"1.2.0", "1.2.7", "1.3.0"}, "~1.2", "1.2.7"),
+	Entry("wildcard", []string{"0.9.0", "1.0.1", "1.5.0"}, "1.x", "1.5.0"),
+	Entry("original tag with the v prefix", []string{"v1.0.0", "v1.1.0"}, "^1.0", "v1.1.0"),
+	Entry("prereleases are not matched by the range", []string{"1.0.0", "1.0.1-3.gabcdef0"}, ">=1.0.0", "1.0.0"),
+	Entry("non-semver tags are skipped", []string{"latest", "main", "1.0.0"}, "1.x", "1.0.0"),
+	Entry("no matching tags", []string{"1.0.0", "latest"}, "2.x", ""),
+)
+
+var _ = Describe("bundle info", func() {
+	It("converts the info into the manifest annotations and back", func() {
+		createdAt := time.Date(2021, 5, 12, 10, 30, 0, 0, time.UTC)
+		info := Info{Tag: "latest", Version: "1.2.4-3.g1a2b3c4", Revision: "1a2b3c4d", CreatedAt: createdAt}
+
+		annotations := info.Annotations()
+		Ω(annotations).Should(Equal(map[string]string{
+			VersionAnnoName:   "1.2.4-3.g1a2b3c4",
+			RevisionAnnoName:  "1a2b3c4d",
+			CreatedAtAnnoName: "2021-05-12T10:30:00Z",
+		}))
+
+		Ω(*NewInfoFromAnnotations("latest", annotations)).Should(Equal(info))
+	})
+
+	It("does not save the empty fields", func() {
+		Ω(Info{Version: "1.0.0"}.Annotations()).Should(Equal(map[string]string{VersionAnnoName: "1.0.0"}))
+	})
+})
+
+var _ = Describe("published bundle info", func() {
+	var ctx context.Context
+	var server *httptest.Server
+	var repo string
+
+	uploadBlob := func(data []byte) string {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+
+		resp, err := http.Post(fmt.Sprintf("%s/v2/project/blobs/uploads/", server.URL), "", nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.Body.Close()).Should(Succeed())
+		Ω(resp.StatusCode).Should(Equal(http.StatusAccepted))
+
+		req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s%s?digest=%s", server.URL, resp.Header.Get("Location"), digest), bytes.NewReader(data))
+		Ω(err).ShouldNot(HaveOccurred())
+		resp, err = http.DefaultClient.Do(req)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(resp.Body.Close()).Should(Succeed())
+		Ω(resp.StatusCode).Should(Equal(http.StatusCreated))
+
+		return digest
+	}
+
+	// pushBundle pushes the manifest of the bundle chart the same way as helm does, the chart content layer is not uploaded.
+	pushBundle := func(tag, chartVersion string) string {
+		configData := []byte(fmt.Sprintf(`{"apiVersion":"v2","name":"app","version":%q}`, chartVersion))
+		manifest := fmt.Sprintf(`{"schemaVersion":2,"config":{"mediaType":%q,"digest":%q,"size":%d},"layers":[{"mediaType":%q,"digest":"sha256:%x","size":1}]}`,
+			helmChartConfigMediaType, uploadBlob(configData), len(configData), helmChartContentLayerMediaType, sha256.Sum256([]byte(tag)))
+
+		digest, size, err := v1.SHA256(strings.NewReader(manifest))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		bundleRef := fmt.Sprintf("%s:%s", repo, tag)
+		ref, err := name.ParseReference(bundleRef)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remote.Put(ref, &remote.Descriptor{
+			Descriptor: v1.Descriptor{MediaType: types.OCIManifestSchema1, Size: size, Digest: digest},
+			Manifest:   []byte(manifest),
+		})).Should(Succeed())
+
+		return bundleRef
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		server = httptest.NewServer(registry.New())
+		repo = strings.TrimPrefix(server.URL, "http://") + "/project"
+
+		Ω(docker_registry.Init(ctx, true, false)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("reads the info from the bundle manifest annotations", func() {
+		bundleRef := pushBundle("1.2.4-3.g1a2b3c4", "1.2.4-3.g1a2b3c4")
+
+		createdAt := time.Date(2021, 5, 12, 10, 30, 0, 0, time.UTC)
+		Ω(SetPublishedInfo(ctx, bundleRef, Info{Version: "1.2.4-3.g1a2b3c4", Revision: "1a2b3c4d", CreatedAt: createdAt})).Should(Succeed())
+
+		info, err := GetInfo(ctx, bundleRef)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(*info).Should(Equal(Info{Tag: "1.2.4-3.g1a2b3c4", Version: "1.2.4-3.g1a2b3c4", Revision: "1a2b3c4d", CreatedAt: createdAt}))
+	})
+
+	It("returns the chart version only for the bundles published without the info", func() {
+		bundleRef := pushBundle("latest", "0.1.0")
+
+		info, err := GetInfo(ctx, bundleRef)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(*info).Should(Equal(Info{Tag: "latest", Version: "0.1.0"}))
+	})
+
+	It("lists the bundles newest first and skips the images stored in the same repo", func() {
+		for tag, createdAt := range map[string]time.Time{
+			"1.0.0": time.Date(2021, 5, 10, 0, 0, 0, 0, time.UTC),
+			"1.1.0": time.Date(2021, 5, 12, 0, 0, 0, 0, time.UTC),
+		} {
+			Ω(SetPublishedInfo(ctx, pushBundle(tag, tag), Info{Version: tag, CreatedAt: createdAt})).Should(Succeed())
+		}
+
+		img, err := random.Image(1024, 1)
+		Ω(err).ShouldNot(HaveOccurred())
+		ref, err := name.ParseReference(repo + ":app")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remote.Write(ref, img)).Should(Succeed())
+
+		infos, err := List(ctx, repo)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var tags []string
+		for _, info := range infos {
+			tags = append(tags, info.Tag)
+		}
+		Ω(tags).Should(Equal([]string{"1.1.0", "1.0.0"}))
+	})
+})
+
+var _ = Describe("GetVersionFromGit", func() {
+	var workTreeDir string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=werf", "-c", "user.email=werf@werf.io"}, args...)...)
+		cmd.Dir = workTreeDir
+		output, err := cmd.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	getVersion := func() string {
+		localGitRepo, err := git_repo.OpenLocalRepo(context.Background(), "own", workTreeDir, git_repo.OpenLocalRepoOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		version, err := GetVersionFromGit(context.Background(), localGitRepo)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return version
+	}
+
+	BeforeEach(func() {
+		var err error
+		workTreeDir, err = ioutil.TempDir("", "werf-bundle-version-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		git("init", "-q")
+		git("checkout", "-q", "-b", "main")
+		git("commit", "-q", "--allow-empty", "-m", "first")
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(workTreeDir)).Should(Succeed())
+	})
+
+	It("counts all commits if there are no semver tags", func() {
+		git("tag", "release")
+		git("commit", "-q", "--allow-empty", "-m", "second")
+
+		Ω(getVersion()).Should(Equal("0.0.1-2.g" + git("rev-parse", "--short=7", "HEAD")))
+	})
+
+	It("returns the version of the tag of the current commit", func() {
+		git("tag", "v1.2.2")
+		git("commit", "-q", "--allow-empty", "-m", "second")
+		git("tag", "-a", "v1.2.3", "-m", "v1.2.3")
+
+		Ω(getVersion()).Should(Equal("1.2.3"))
+	})
+
+	It("bumps the patch version of the nearest tag and counts the commits since the tag", func() {
+		git("tag", "v1.2.3")
+		git("commit", "-q", "--allow-empty", "-m", "second")
+		git("commit", "-q", "--allow-empty", "-m", "third")
+
+		Ω(getVersion()).Should(Equal("1.2.4-2.g" + git("rev-parse", "--short=7", "HEAD")))
+	})
+
+	It("skips the tags not reachable from the current commit", func() {
+		git("tag", "v1.0.0")
+		git("checkout", "-q", "-b", "feature")
+		git("commit", "-q", "--allow-empty", "-m", "feature")
+		git("tag", "v2.0.0")
+		git("checkout", "-q", "main")
+		git("commit", "-q", "--allow-empty", "-m", "second")
+
+		Ω(getVersion()).Should(Equal("1.0.1-1.g" + git("rev-parse", "--short=7", "HEAD")))
+	})
+
+	It("counts the merged commits since the tag", func() {
+		git("tag", "v1.0.0")
+		git("checkout", "-q", "-b", "feature")
+		git("commit", "-q", "--allow-empty", "-m", "feature")
+		git("checkout", "-q", "main")
+		git("merge", "-q", "--no-ff", "-m", "merge", "feature")
+
+		Ω(getVersion()).Should(Equal("1.0.1-2.g" + git("rev-parse", "--short=7", "HEAD")))
+	})
+})
@@ -19,4 +19,17 @@ const (
 	ShowEventsAnnoName = "werf.io/show-service-messages"
 
 	ReplicasOnCreationAnnoName = "werf.io/replicas-on-creation"
+
+	RolloutStrategyAnnoName       = "werf.io/rollout-strategy"
+	RolloutCanaryStepsAnnoName    = "werf.io/rollout-canary-steps"
+	RolloutStepPauseAnnoName      = "werf.io/rollout-step-pause"
+	RolloutMetricEndpointAnnoName = "werf.io/rollout-metric-endpoint"
+	RolloutMetricQueryAnnoName    = "werf.io/rollout-metric-query"
+	RolloutMetricMinAnnoName      = "werf.io/rollout-metric-min"
+	RolloutMetricMaxAnnoName      = "werf.io/rollout-metric-max"
+
+	RolloutPodTemplateChecksumAnnoName = "werf.io/rollout-pod-template-checksum"
+
+	WeightAnnoName                 = "werf.io/weight"
+	DeployDependencyAnnoNamePrefix = "werf.io/deploy-dependency-"
 )
@@ -0,0 +1,90 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+
+	"github.com/werf/logboek"
+)
+
+type AutoRollbackOptions struct {
+	Timeout time.Duration
+	// IsRollbackRequired decides whether the release should be rolled back after the deploy function has failed, by default the release is always rolled back
+	IsRollbackRequired func() bool
+}
+
+// RunWithAutoRollback runs deploy function and rolls the release back to the last successfully deployed revision
+// when the deploy function has failed. Rollback resources are tracked by the ResourcesWaiter.
+// The caller is responsible for holding the release lock during the whole operation.
+func RunWithAutoRollback(ctx context.Context, actionConfig *action.Configuration, releaseName string, opts AutoRollbackOptions, deployFunc func() error) error {
+	lastSuccessfulRelease, err := getLastSuccessfulRelease(actionConfig, releaseName)
+	if err != nil {
+		return err
+	}
+
+	deployErr := deployFunc()
+	if deployErr == nil {
+		return nil
+	}
+
+	if opts.IsRollbackRequired != nil && !opts.IsRollbackRequired() {
+		return deployErr
+	}
+
+	if lastSuccessfulRelease == nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: Auto rollback skipped: release %q has no successfully deployed revisions\n", releaseName)
+		return deployErr
+	}
+
+	waiter := GetResourcesWaiter(actionConfig)
+	emit := func(event StatusEvent) {
+		if waiter != nil && waiter.StatusEvents != nil {
+			_ = waiter.StatusEvents.Emit(event)
+		}
+	}
+
+	emit(StatusEvent{Type: RollbackStartedEvent, Message: fmt.Sprintf("rolling back release %q to revision %d", releaseName, lastSuccessfulRelease.Version)})
+
+	rollbackErr := logboek.Context(ctx).Default().LogProcess("Rolling back release %q to revision %d", releaseName, lastSuccessfulRelease.Version).DoError(func() error {
+		rollback := action.NewRollback(actionConfig)
+		rollback.Version = lastSuccessfulRelease.Version
+		rollback.Wait = true
+		rollback.Timeout = opts.Timeout
+
+		return rollback.Run(releaseName)
+	})
+
+	if rollbackErr != nil {
+		emit(StatusEvent{Type: RollbackFailedEvent, Message: rollbackErr.Error()})
+		return fmt.Errorf("%s\nauto rollback of release %q to revision %d failed: %s", deployErr, releaseName, lastSuccessfulRelease.Version, rollbackErr)
+	}
+
+	emit(StatusEvent{Type: RollbackFinishedEvent, Message: fmt.Sprintf("release %q has been rolled back to revision %d", releaseName, lastSuccessfulRelease.Version)})
+	return fmt.Errorf("%s\nrelease %q has been rolled back to revision %d", deployErr, releaseName, lastSuccessfulRelease.Version)
+}
+
+func getLastSuccessfulRelease(actionConfig *action.Configuration, releaseName string) (*release.Release, error) {
+	history, err := actionConfig.Releases.History(releaseName)
+	if err == driver.ErrReleaseNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get release %q history: %s", releaseName, err)
+	}
+
+	// Failed releases do not get superseded unless the next release is successful
+	successfulReleases := releaseutil.FilterFunc(func(r *release.Release) bool {
+		return r.Info.Status == release.StatusSuperseded || r.Info.Status == release.StatusDeployed
+	}).Filter(history)
+	if len(successfulReleases) == 0 {
+		return nil, nil
+	}
+
+	releaseutil.Reverse(successfulReleases, releaseutil.SortByRevision)
+	return successfulReleases[0], nil
+}
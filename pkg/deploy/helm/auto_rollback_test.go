@@ -0,0 +1,132 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+var _ = Describe("RunWithAutoRollback", func() {
+	const releaseName = "app"
+
+	var actionConfig *action.Configuration
+	var kubeClient *kubefake.FailingKubeClient
+	deployErr := errors.New("deploy failed")
+
+	BeforeEach(func() {
+		kubeClient = &kubefake.FailingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+		actionConfig = &action.Configuration{
+			Releases:   storage.Init(driver.NewMemory()),
+			KubeClient: kubeClient,
+			Log:        func(string, ...interface{}) {},
+		}
+	})
+
+	createReleases := func(statuses ...release.Status) {
+		history, _ := actionConfig.Releases.History(releaseName)
+		for i, status := range statuses {
+			version := len(history) + i + 1
+			Ω(actionConfig.Releases.Create(&release.Release{
+				Name:      releaseName,
+				Namespace: "default",
+				Version:   version,
+				Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: releaseName, Version: "1.0.0"}},
+				Info:      &release.Info{Status: status},
+				Manifest:  fmt.Sprintf("# revision %d\n", version),
+			})).Should(Succeed())
+		}
+	}
+
+	getHistory := func() []string {
+		history, err := actionConfig.Releases.History(releaseName)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		res := make([]string, len(history))
+		for _, r := range history {
+			res[r.Version-1] = fmt.Sprintf("%s %s", r.Info.Status, r.Manifest)
+		}
+		return res
+	}
+
+	runFailedDeploy := func(opts AutoRollbackOptions) error {
+		return RunWithAutoRollback(context.Background(), actionConfig, releaseName, opts, func() error {
+			createReleases(release.StatusFailed)
+			return deployErr
+		})
+	}
+
+	It("does nothing when the deploy succeeds", func() {
+		createReleases(release.StatusDeployed)
+
+		Ω(RunWithAutoRollback(context.Background(), actionConfig, releaseName, AutoRollbackOptions{}, func() error { return nil })).Should(Succeed())
+		Ω(getHistory()).Should(Equal([]string{"deployed # revision 1\n"}))
+	})
+
+	It("rolls back to the last successful revision skipping the failed and pending ones", func() {
+		createReleases(release.StatusSuperseded, release.StatusDeployed, release.StatusFailed, release.StatusPendingUpgrade)
+
+		lastSuccessfulRelease, err := getLastSuccessfulRelease(actionConfig, releaseName)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(lastSuccessfulRelease.Version).Should(Equal(2))
+
+		err = RunWithAutoRollback(context.Background(), actionConfig, releaseName, AutoRollbackOptions{}, func() error {
+			return deployErr
+		})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(Equal(fmt.Sprintf("%s\nrelease %q has been rolled back to revision 2", deployErr, releaseName)))
+
+		history := getHistory()
+		Ω(history).Should(HaveLen(5))
+		Ω(history[4]).Should(Equal("deployed # revision 2\n"))
+	})
+
+	It("rolls back to the superseded revision when the deployed one is failed", func() {
+		createReleases(release.StatusSuperseded, release.StatusFailed)
+
+		lastSuccessfulRelease, err := getLastSuccessfulRelease(actionConfig, releaseName)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(lastSuccessfulRelease.Version).Should(Equal(1))
+	})
+
+	It("returns the original error without rollback when there are no successful revisions", func() {
+		createReleases(release.StatusFailed, release.StatusPendingInstall)
+
+		Ω(runFailedDeploy(AutoRollbackOptions{})).Should(Equal(deployErr))
+		Ω(getHistory()).Should(Equal([]string{"failed # revision 1\n", "pending-install # revision 2\n", "failed # revision 3\n"}))
+	})
+
+	It("returns the original error without rollback when the release does not exist", func() {
+		Ω(RunWithAutoRollback(context.Background(), actionConfig, releaseName, AutoRollbackOptions{}, func() error { return deployErr })).Should(Equal(deployErr))
+
+		_, err := actionConfig.Releases.History(releaseName)
+		Ω(err).Should(Equal(driver.ErrReleaseNotFound))
+	})
+
+	It("returns the original error without rollback when the rollback is not required", func() {
+		createReleases(release.StatusDeployed)
+
+		Ω(runFailedDeploy(AutoRollbackOptions{IsRollbackRequired: func() bool { return false }})).Should(Equal(deployErr))
+		Ω(getHistory()).Should(Equal([]string{"deployed # revision 1\n", "failed # revision 2\n"}))
+	})
+
+	It("returns both the deploy and the rollback errors when the rollback fails", func() {
+		createReleases(release.StatusDeployed)
+		kubeClient.UpdateError = errors.New("update failed")
+
+		err := runFailedDeploy(AutoRollbackOptions{})
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(HavePrefix(deployErr.Error() + "\n"))
+		Ω(err.Error()).Should(ContainSubstring(fmt.Sprintf("auto rollback of release %q to revision 1 failed: ", releaseName)))
+		Ω(err.Error()).Should(ContainSubstring("update failed"))
+	})
+})
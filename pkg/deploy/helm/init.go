@@ -3,6 +3,7 @@ package helm
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -28,6 +29,8 @@ type InitActionConfigOptions struct {
 	HooksStatusProgressPeriod time.Duration
 	KubeConfigOptions         kube.KubeConfigOptions
 	ReleasesHistoryMax        int
+	StatusFormat              StatusFormat
+	StatusEventsOutput        io.Writer
 }
 
 func InitActionConfig(ctx context.Context, kubeInitializer KubeInitializer, namespace string, envSettings *cli.EnvSettings, registryClientHandle *helm_v3.RegistryClientHandle, actionConfig *action.Configuration, opts InitActionConfigOptions) error {
@@ -61,14 +64,36 @@ func InitActionConfig(ctx context.Context, kubeInitializer KubeInitializer, name
 
 	kubeClient := actionConfig.KubeClient.(*helm_kube.Client)
 	kubeClient.Namespace = namespace
-	kubeClient.ResourcesWaiter = NewResourcesWaiter(kubeInitializer, kubeClient, time.Now(), opts.StatusProgressPeriod, opts.HooksStatusProgressPeriod)
+	var statusEvents *StatusEventsWriter
+	if opts.StatusFormat == StatusFormatJSON {
+		out := opts.StatusEventsOutput
+		if out == nil {
+			out = os.Stdout
+		}
+		statusEvents = NewStatusEventsWriter(out)
+	}
+
+	kubeClient.ResourcesWaiter = NewResourcesWaiter(kubeInitializer, kubeClient, time.Now(), opts.StatusProgressPeriod, opts.HooksStatusProgressPeriod, statusEvents)
 	kubeClient.Extender = NewHelmKubeClientExtender()
+	actionConfig.KubeClient = NewOrderedKubeClient(ctx, kubeClient)
 
 	actionConfig.RegistryClient = registryClientHandle.RegistryClient
 
 	return nil
 }
 
+// GetResourcesWaiter returns werf resources waiter of the action config initialized by InitActionConfig
+// or nil when the action config uses fake kube client.
+func GetResourcesWaiter(actionConfig *action.Configuration) *ResourcesWaiter {
+	kubeClient := GetOrderedKubeClient(actionConfig)
+	if kubeClient == nil {
+		return nil
+	}
+
+	waiter, _ := kubeClient.ResourcesWaiter.(*ResourcesWaiter)
+	return waiter
+}
+
 // This function loads releases into the memory storage if the
 // environment variable is properly set.
 func loadReleasesInMemory(envSettings *cli.EnvSettings, actionConfig *action.Configuration) {
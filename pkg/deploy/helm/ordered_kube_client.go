@@ -0,0 +1,248 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/werf/logboek"
+)
+
+// OrderedKubeClient applies release resources by groups ordered by the werf.io/weight and werf.io/deploy-dependency-<name>
+// annotations: each group is applied and tracked by the ResourcesWaiter before the next group is applied.
+// The last group is applied without waiting, helm waits for all release resources afterwards.
+type OrderedKubeClient struct {
+	*helm_kube.Client
+
+	GroupWaitTimeout time.Duration
+
+	// ctx is used for logging and waiting for the groups, helm kube client interface methods do not accept context
+	ctx context.Context
+}
+
+func NewOrderedKubeClient(ctx context.Context, client *helm_kube.Client) *OrderedKubeClient {
+	return &OrderedKubeClient{Client: client, ctx: ctx}
+}
+
+// GetOrderedKubeClient returns werf kube client of the action config initialized by InitActionConfig
+// or nil when the action config uses fake kube client.
+func GetOrderedKubeClient(actionConfig *action.Configuration) *OrderedKubeClient {
+	kubeClient, _ := actionConfig.KubeClient.(*OrderedKubeClient)
+	return kubeClient
+}
+
+type deployGroup struct {
+	Weight    int
+	Resources helm_kube.ResourceList
+}
+
+func (c *OrderedKubeClient) Create(resources helm_kube.ResourceList) (*helm_kube.Result, error) {
+	return c.performByGroups(resources, c.Client.Create)
+}
+
+func (c *OrderedKubeClient) CreateIfNotExists(resources helm_kube.ResourceList) (*helm_kube.Result, error) {
+	return c.performByGroups(resources, c.Client.CreateIfNotExists)
+}
+
+func (c *OrderedKubeClient) Update(original, target helm_kube.ResourceList, force bool) (*helm_kube.Result, error) {
+	var appliedResources helm_kube.ResourceList
+
+	return c.performByGroups(target, func(groupResources helm_kube.ResourceList) (*helm_kube.Result, error) {
+		groupOriginal := original.Intersect(groupResources)
+		if len(appliedResources)+len(groupResources) == len(target) {
+			// Resources which are not in the target are deleted with the last group
+			groupOriginal = original.Difference(appliedResources)
+		}
+
+		res, err := c.Client.Update(groupOriginal, groupResources, force)
+		appliedResources = append(appliedResources, groupResources...)
+		return res, err
+	})
+}
+
+func (c *OrderedKubeClient) performByGroups(resources helm_kube.ResourceList, performFunc func(helm_kube.ResourceList) (*helm_kube.Result, error)) (*helm_kube.Result, error) {
+	groups, err := getDeployGroups(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(groups) < 2 {
+		return performFunc(resources)
+	}
+
+	result := &helm_kube.Result{}
+	for i, group := range groups {
+		groupDesc := fmt.Sprintf("resources group %d/%d (weight %d)", i+1, len(groups), group.Weight)
+
+		if i == len(groups)-1 {
+			logboek.Context(c.ctx).Default().LogFHighlight("Applying %s\n", groupDesc)
+
+			res, err := performFunc(group.Resources)
+			mergeResults(result, res)
+			return result, err
+		}
+
+		if err := logboek.Context(c.ctx).Default().LogProcess("Applying %s", groupDesc).DoError(func() error {
+			res, err := performFunc(group.Resources)
+			mergeResults(result, res)
+			if err != nil {
+				return err
+			}
+
+			return c.waitGroup(group.Resources)
+		}); err != nil {
+			return result, fmt.Errorf("%s failed: %s", groupDesc, err)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *OrderedKubeClient) waitGroup(resources helm_kube.ResourceList) error {
+	if c.ResourcesWaiter != nil {
+		return c.ResourcesWaiter.Wait(c.ctx, c.Namespace, resources, c.GroupWaitTimeout)
+	}
+
+	return c.Client.Wait(resources, c.GroupWaitTimeout)
+}
+
+func mergeResults(result, res *helm_kube.Result) {
+	if res == nil {
+		return
+	}
+
+	result.Created = append(result.Created, res.Created...)
+	result.Updated = append(result.Updated, res.Updated...)
+	result.Deleted = append(result.Deleted, res.Deleted...)
+}
+
+// getDeployGroups splits resources into groups by weight, resources with the same weight are split further
+// by the dependencies between them. Resources order inside each group is preserved.
+func getDeployGroups(resources helm_kube.ResourceList) ([]*deployGroup, error) {
+	weights := map[*resource.Info]int{}
+	dependencies := map[*resource.Info][]*resource.Info{}
+
+	for _, info := range resources {
+		annotations, err := metadataAccessor.Annotations(info.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		if value, hasKey := annotations[WeightAnnoName]; hasKey {
+			weight, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s annotation %s with invalid value %s: integer expected", info.ObjectName(), WeightAnnoName, value)
+			}
+			weights[info] = weight
+		}
+
+		for annoName, value := range annotations {
+			if !strings.HasPrefix(annoName, DeployDependencyAnnoNamePrefix) {
+				continue
+			}
+
+			dependency, err := findDeployDependency(resources, value)
+			if err != nil {
+				return nil, fmt.Errorf("%s annotation %s with invalid value %s: %s", info.ObjectName(), annoName, value, err)
+			}
+
+			// Dependency is not among the applied resources (e.g. hooks are applied one by one)
+			if dependency != nil {
+				dependencies[info] = append(dependencies[info], dependency)
+			}
+		}
+	}
+
+	levels := map[*resource.Info]int{}
+	inProgress := map[*resource.Info]bool{}
+
+	var getLevel func(info *resource.Info) (int, error)
+	getLevel = func(info *resource.Info) (int, error) {
+		if level, ok := levels[info]; ok {
+			return level, nil
+		}
+
+		if inProgress[info] {
+			return 0, fmt.Errorf("%s: circular deploy dependency detected", info.ObjectName())
+		}
+		inProgress[info] = true
+
+		level := 0
+		for _, dependency := range dependencies[info] {
+			switch {
+			case weights[dependency] > weights[info]:
+				return 0, fmt.Errorf("%s depends on %s which has greater weight %d", info.ObjectName(), dependency.ObjectName(), weights[dependency])
+			case weights[dependency] < weights[info]:
+				continue
+			}
+
+			dependencyLevel, err := getLevel(dependency)
+			if err != nil {
+				return 0, err
+			}
+			if dependencyLevel+1 > level {
+				level = dependencyLevel + 1
+			}
+		}
+
+		inProgress[info] = false
+		levels[info] = level
+		return level, nil
+	}
+
+	type groupKey struct{ weight, level int }
+	groupsByKey := map[groupKey]*deployGroup{}
+	var keys []groupKey
+
+	for _, info := range resources {
+		level, err := getLevel(info)
+		if err != nil {
+			return nil, err
+		}
+
+		key := groupKey{weight: weights[info], level: level}
+		group, ok := groupsByKey[key]
+		if !ok {
+			group = &deployGroup{Weight: key.weight}
+			groupsByKey[key] = group
+			keys = append(keys, key)
+		}
+		group.Resources = append(group.Resources, info)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].weight == keys[j].weight {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].weight < keys[j].weight
+	})
+
+	var groups []*deployGroup
+	for _, key := range keys {
+		groups = append(groups, groupsByKey[key])
+	}
+
+	return groups, nil
+}
+
+func findDeployDependency(resources helm_kube.ResourceList, value string) (*resource.Info, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("KIND/NAME expected")
+	}
+
+	for _, info := range resources {
+		if strings.EqualFold(info.Mapping.GroupVersionKind.Kind, parts[0]) && info.Name == parts[1] {
+			return info, nil
+		}
+	}
+
+	return nil, nil
+}
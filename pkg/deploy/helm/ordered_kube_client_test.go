@@ -0,0 +1,104 @@
+package helm
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	helm_kube "helm.sh/helm/v3/pkg/kube"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// newDeployGroupsTestResources creates the resources by "KIND/NAME[ ANNOTATION=VALUE...]" descriptions.
+func newDeployGroupsTestResources(descs ...string) helm_kube.ResourceList {
+	var resources helm_kube.ResourceList
+	for _, desc := range descs {
+		fields := strings.Fields(desc)
+		parts := strings.SplitN(fields[0], "/", 2)
+
+		obj := &unstructured.Unstructured{}
+		obj.SetKind(parts[0])
+		obj.SetName(parts[1])
+
+		annotations := map[string]string{}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			annotations[kv[0]] = kv[1]
+		}
+		obj.SetAnnotations(annotations)
+
+		resources = append(resources, &resource.Info{
+			Name:    parts[1],
+			Object:  obj,
+			Mapping: &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: parts[0]}},
+		})
+	}
+
+	return resources
+}
+
+var _ = DescribeTable("getDeployGroups splits the resources into groups by the weight and the dependencies",
+	func(descs []string, expected []string) {
+		groups, err := getDeployGroups(newDeployGroupsTestResources(descs...))
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var res []string
+		for _, group := range groups {
+			var names []string
+			for _, info := range group.Resources {
+				names = append(names, info.Name)
+			}
+			res = append(res, fmt.Sprintf("%d: %s", group.Weight, strings.Join(names, ",")))
+		}
+
+		Ω(res).Should(Equal(expected))
+	},
+	Entry("no annotations",
+		[]string{"ConfigMap/a", "Deployment/b"},
+		[]string{"0: a,b"},
+	),
+	Entry("weights",
+		[]string{"Deployment/app", "Job/migrate werf.io/weight=-10", "ConfigMap/config werf.io/weight=-10", "Service/web werf.io/weight=5"},
+		[]string{"-10: migrate,config", "0: app", "5: web"},
+	),
+	Entry("dependencies within the same weight",
+		[]string{"Deployment/app werf.io/deploy-dependency-db=statefulset/db", "StatefulSet/db werf.io/deploy-dependency-config=ConfigMap/config", "ConfigMap/config", "Service/web"},
+		[]string{"0: config,web", "0: db", "0: app"},
+	),
+	Entry("dependency with the lower weight",
+		[]string{"Deployment/app werf.io/deploy-dependency-db=StatefulSet/db", "StatefulSet/db werf.io/weight=-1"},
+		[]string{"-1: db", "0: app"},
+	),
+	Entry("dependency which is not among the resources",
+		[]string{"Deployment/app werf.io/deploy-dependency-db=StatefulSet/db"},
+		[]string{"0: app"},
+	),
+)
+
+var _ = DescribeTable("getDeployGroups returns an error for the invalid annotations and dependencies",
+	func(descs []string, expectedErr string) {
+		_, err := getDeployGroups(newDeployGroupsTestResources(descs...))
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(expectedErr))
+	},
+	Entry("invalid weight",
+		[]string{"Deployment/app werf.io/weight=high"},
+		"integer expected",
+	),
+	Entry("invalid dependency",
+		[]string{"Deployment/app werf.io/deploy-dependency-db=db"},
+		"KIND/NAME expected",
+	),
+	Entry("dependency with the greater weight",
+		[]string{"Deployment/app werf.io/deploy-dependency-db=StatefulSet/db", "StatefulSet/db werf.io/weight=1"},
+		"which has greater weight 1",
+	),
+	Entry("circular dependency",
+		[]string{"Deployment/a werf.io/deploy-dependency-b=Deployment/b", "Deployment/b werf.io/deploy-dependency-a=Deployment/a"},
+		"circular deploy dependency detected",
+	),
+)
@@ -0,0 +1,403 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+type ResourceChangeType string
+
+const (
+	ResourceCreate  ResourceChangeType = "create"
+	ResourceUpdate  ResourceChangeType = "update"
+	ResourceReplace ResourceChangeType = "replace"
+	ResourceDelete  ResourceChangeType = "delete"
+)
+
+// ResourceChange describes a change of the single release resource which will be made by the release upgrade.
+type ResourceChange struct {
+	Type      ResourceChangeType
+	Kind      string
+	Name      string
+	Namespace string
+	// Reason is set for the replace change and describes immutable fields which have been changed.
+	Reason string
+	Diff   string
+}
+
+func (change *ResourceChange) IsDestructive() bool {
+	return change.Type == ResourceReplace || change.Type == ResourceDelete
+}
+
+func (change *ResourceChange) String() string {
+	if change.Namespace != "" {
+		return fmt.Sprintf("%s/%s in namespace %s", strings.ToLower(change.Kind), change.Name, change.Namespace)
+	}
+	return fmt.Sprintf("%s/%s", strings.ToLower(change.Kind), change.Name)
+}
+
+// Changes of these fields cannot be applied by the patch, resource should be recreated.
+var immutableFieldsByKind = map[string][][]string{
+	"Deployment":            {{"spec", "selector"}},
+	"DaemonSet":             {{"spec", "selector"}},
+	"ReplicaSet":            {{"spec", "selector"}},
+	"Job":                   {{"spec", "selector"}, {"spec", "template"}},
+	"StatefulSet":           {{"spec", "selector"}, {"spec", "serviceName"}, {"spec", "podManagementPolicy"}, {"spec", "volumeClaimTemplates"}},
+	"PersistentVolumeClaim": {{"spec", "accessModes"}, {"spec", "storageClassName"}, {"spec", "volumeName"}, {"spec", "volumeMode"}, {"spec", "selector"}, {"spec", "dataSource"}},
+	"Service":               {{"spec", "clusterIP"}},
+}
+
+// GetReleaseDiff calculates changes of the release resources between live objects and the rendered manifests.
+// Expected state of each resource is calculated the same way as helm does during the upgrade: a three-way merge patch
+// from the previous release manifest, the rendered manifest and the live object is applied to the live object.
+func GetReleaseDiff(actionConfig *action.Configuration, releaseName, manifests string) ([]*ResourceChange, error) {
+	original := kube.ResourceList{}
+
+	currentRelease, err := getCurrentRelease(actionConfig, releaseName)
+	if err != nil {
+		return nil, err
+	} else if currentRelease != nil {
+		original, err = actionConfig.KubeClient.Build(bytes.NewBufferString(currentRelease.Manifest), false)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build kubernetes objects from the release %q revision %d manifest: %s", releaseName, currentRelease.Version, err)
+		}
+	}
+
+	target, err := actionConfig.KubeClient.Build(bytes.NewBufferString(skipHookManifests(manifests)), false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build kubernetes objects from the rendered manifests: %s", err)
+	}
+
+	var changes []*ResourceChange
+
+	for _, info := range target {
+		change, err := getResourceChange(info, original.Get(info))
+		if err != nil {
+			return nil, fmt.Errorf("unable to calculate diff for %s/%s: %s", strings.ToLower(info.Mapping.GroupVersionKind.Kind), info.Name, err)
+		}
+
+		if change != nil {
+			changes = append(changes, change)
+		}
+	}
+
+	for _, info := range original.Difference(target) {
+		if accessor, err := meta.Accessor(info.Object); err == nil && accessor.GetAnnotations()["helm.sh/resource-policy"] == "keep" {
+			continue
+		}
+
+		liveData, err := getLiveData(info)
+		if err != nil {
+			return nil, err
+		} else if liveData == nil {
+			continue
+		}
+
+		diff, err := getDiff(liveData, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, newResourceChange(ResourceDelete, info, diff))
+	}
+
+	return changes, nil
+}
+
+// getCurrentRelease returns the release the upgrade is calculated from: the last deployed release,
+// or the last failed or superseded release if there are no deployed ones, as helm does.
+func getCurrentRelease(actionConfig *action.Configuration, releaseName string) (*release.Release, error) {
+	lastRelease, err := actionConfig.Releases.Last(releaseName)
+	if err == driver.ErrReleaseNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get last release %q: %s", releaseName, err)
+	}
+
+	deployedRelease, err := actionConfig.Releases.Deployed(releaseName)
+	if err == nil {
+		return deployedRelease, nil
+	} else if !errors.Is(err, driver.ErrNoDeployedReleases) {
+		return nil, fmt.Errorf("unable to get deployed release %q: %s", releaseName, err)
+	}
+
+	switch lastRelease.Info.Status {
+	case release.StatusFailed, release.StatusSuperseded:
+		return lastRelease, nil
+	default:
+		return nil, nil
+	}
+}
+
+func LogReleaseDiff(ctx context.Context, changes []*ResourceChange) {
+	logboek.Context(ctx).Default().LogBlock("Release diff").Do(func() {
+		if len(changes) == 0 {
+			logboek.Context(ctx).Default().LogLn("No changes")
+			return
+		}
+
+		counters := map[ResourceChangeType]int{}
+		for _, change := range changes {
+			counters[change.Type]++
+
+			logboek.Context(ctx).Default().LogFHighlight("%s %s\n", change.Type, change)
+			if change.Reason != "" {
+				logboek.Context(ctx).Default().LogFDetails("%s\n", change.Reason)
+			}
+			logboek.Context(ctx).Default().LogF("%s\n", change.Diff)
+		}
+
+		logboek.Context(ctx).Default().LogF("Resources to create: %d, to update: %d, to replace: %d, to delete: %d\n", counters[ResourceCreate], counters[ResourceUpdate], counters[ResourceReplace], counters[ResourceDelete])
+	})
+}
+
+func getResourceChange(target, original *resource.Info) (*ResourceChange, error) {
+	targetData, err := json.Marshal(target.Object)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize target configuration: %s", err)
+	}
+
+	liveData, err := getLiveData(target)
+	if err != nil {
+		return nil, err
+	} else if liveData == nil {
+		diff, err := getDiff(nil, targetData)
+		if err != nil {
+			return nil, err
+		}
+		return newResourceChange(ResourceCreate, target, diff), nil
+	}
+
+	originalData := targetData
+	if original != nil {
+		if originalData, err = json.Marshal(original.Object); err != nil {
+			return nil, fmt.Errorf("unable to serialize original configuration: %s", err)
+		}
+	}
+
+	expectedData, err := getExpectedData(target, originalData, targetData, liveData)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := getDiff(liveData, expectedData)
+	if err != nil {
+		return nil, err
+	} else if diff == "" {
+		return nil, nil
+	}
+
+	change := newResourceChange(ResourceUpdate, target, diff)
+
+	changedFields, err := getChangedImmutableFields(change.Kind, liveData, expectedData)
+	if err != nil {
+		return nil, err
+	}
+	if len(changedFields) > 0 {
+		change.Type = ResourceReplace
+		change.Reason = fmt.Sprintf("immutable fields changed: %s", strings.Join(changedFields, ", "))
+	}
+
+	return change, nil
+}
+
+func getLiveData(info *resource.Info) ([]byte, error) {
+	obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get live object %s/%s: %s", strings.ToLower(info.Mapping.GroupVersionKind.Kind), info.Name, err)
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialize live configuration: %s", err)
+	}
+	return data, nil
+}
+
+func getExpectedData(target *resource.Info, originalData, targetData, liveData []byte) ([]byte, error) {
+	versionedObject := kube.AsVersioned(target)
+
+	_, isUnstructured := versionedObject.(runtime.Unstructured)
+	isCRD := target.Mapping.GroupVersionKind.GroupKind() == crdGroupKind
+	if isUnstructured || isCRD {
+		patch, err := jsonpatch.CreateMergePatch(originalData, targetData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create merge patch: %s", err)
+		}
+		return jsonpatch.MergePatch(liveData, patch)
+	}
+
+	patchMeta, err := strategicpatch.NewPatchMetaFromStruct(versionedObject)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create patch metadata from object: %s", err)
+	}
+
+	patch, err := strategicpatch.CreateThreeWayMergePatch(originalData, targetData, liveData, patchMeta, true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create three-way merge patch: %s", err)
+	}
+
+	return strategicpatch.StrategicMergePatch(liveData, patch, versionedObject)
+}
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+func getChangedImmutableFields(kind string, liveData, expectedData []byte) ([]string, error) {
+	live, expected := map[string]interface{}{}, map[string]interface{}{}
+	if err := json.Unmarshal(liveData, &live); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		return nil, err
+	}
+
+	var changedFields []string
+	for _, fields := range immutableFieldsByKind[kind] {
+		liveValue, _, _ := unstructured.NestedFieldNoCopy(live, fields...)
+		expectedValue, _, _ := unstructured.NestedFieldNoCopy(expected, fields...)
+		if !reflect.DeepEqual(liveValue, expectedValue) {
+			changedFields = append(changedFields, strings.Join(fields, "."))
+		}
+	}
+
+	return changedFields, nil
+}
+
+func getDiff(fromData, toData []byte) (string, error) {
+	fromYaml, err := normalizedYaml(fromData)
+	if err != nil {
+		return "", err
+	}
+
+	toYaml, err := normalizedYaml(toData)
+	if err != nil {
+		return "", err
+	}
+
+	if fromYaml == toYaml {
+		return "", nil
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYaml),
+		B:        difflib.SplitLines(toYaml),
+		FromFile: "live",
+		ToFile:   "expected",
+		Context:  3,
+	})
+}
+
+// normalizedYaml drops the fields which are maintained by the cluster and are not related to the configuration.
+func normalizedYaml(data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", err
+	}
+
+	delete(obj, "status")
+	if obj["kind"] == "Secret" {
+		maskSecretData(obj)
+	}
+	for _, field := range []string{"managedFields", "resourceVersion", "uid", "selfLink", "generation", "creationTimestamp"} {
+		unstructured.RemoveNestedField(obj, "metadata", field)
+	}
+
+	res, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(res), nil
+}
+
+// maskSecretData replaces the values of the Secret with the hashes, so the diff shows the changed keys without the secret values.
+// The data values are decoded, so the data and the stringData values with the same content have the same hash.
+func maskSecretData(obj map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for key, value := range values {
+			strValue, _ := value.(string)
+			if field == "data" {
+				if decoded, err := base64.StdEncoding.DecodeString(strValue); err == nil {
+					strValue = string(decoded)
+				}
+			}
+
+			values[key] = fmt.Sprintf("<masked, %d bytes, sha256 %s>", len(strValue), util.Sha256Hash(strValue)[:12])
+		}
+	}
+
+	// the last applied configuration contains the secret values as is
+	unstructured.RemoveNestedField(obj, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	if annotations, found, _ := unstructured.NestedMap(obj, "metadata", "annotations"); found && len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj, "metadata", "annotations")
+	}
+}
+
+func skipHookManifests(manifests string) string {
+	var keys []string
+	splitManifests := releaseutil.SplitManifests(manifests)
+	for key := range splitManifests {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var res []string
+	for _, key := range keys {
+		var head releaseutil.SimpleHead
+		if err := yaml.Unmarshal([]byte(splitManifests[key]), &head); err == nil && head.Metadata != nil {
+			if _, isHook := head.Metadata.Annotations["helm.sh/hook"]; isHook {
+				continue
+			}
+		}
+		res = append(res, splitManifests[key])
+	}
+
+	return strings.Join(res, "\n---\n")
+}
+
+func newResourceChange(changeType ResourceChangeType, info *resource.Info, diff string) *ResourceChange {
+	return &ResourceChange{
+		Type:      changeType,
+		Kind:      info.Mapping.GroupVersionKind.Kind,
+		Name:      info.Name,
+		Namespace: info.Namespace,
+		Diff:      diff,
+	}
+}
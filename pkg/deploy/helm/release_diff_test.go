@@ -0,0 +1,120 @@
+package helm
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/util"
+)
+
+var _ = DescribeTable("getChangedImmutableFields returns the changed fields which cannot be patched",
+	func(kind, liveData, expectedData string, expected []string) {
+		changedFields, err := getChangedImmutableFields(kind, []byte(liveData), []byte(expectedData))
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changedFields).Should(Equal(expected))
+	},
+	Entry("Deployment template",
+		"Deployment",
+		`{"spec":{"selector":{"app":"a"},"template":{"image":"a:1"}}}`,
+		`{"spec":{"selector":{"app":"a"},"template":{"image":"a:2"}}}`,
+		nil,
+	),
+	Entry("Deployment selector",
+		"Deployment",
+		`{"spec":{"selector":{"app":"a"}}}`,
+		`{"spec":{"selector":{"app":"b"}}}`,
+		[]string{"spec.selector"},
+	),
+	Entry("Job template",
+		"Job",
+		`{"spec":{"selector":{"controller-uid":"1"},"template":{"image":"a:1"}}}`,
+		`{"spec":{"selector":{"controller-uid":"1"},"template":{"image":"a:2"}}}`,
+		[]string{"spec.template"},
+	),
+	Entry("StatefulSet fields",
+		"StatefulSet",
+		`{"spec":{"serviceName":"a","volumeClaimTemplates":[{"name":"data"}]}}`,
+		`{"spec":{"serviceName":"b","volumeClaimTemplates":[{"name":"data"}]}}`,
+		[]string{"spec.serviceName"},
+	),
+	Entry("unknown kind",
+		"ConfigMap",
+		`{"data":{"a":"1"}}`,
+		`{"data":{"a":"2"}}`,
+		nil,
+	),
+)
+
+var _ = DescribeTable("getDiff returns the diff of the objects without the fields maintained by the cluster",
+	func(fromData, toData, expected string) {
+		var from, to []byte
+		if fromData != "" {
+			from = []byte(fromData)
+		}
+		if toData != "" {
+			to = []byte(toData)
+		}
+
+		diff, err := getDiff(from, to)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(diff).Should(Equal(expected))
+	},
+	Entry("only the cluster fields are changed",
+		`{"metadata":{"name":"a","resourceVersion":"1","uid":"1"},"data":{"a":"1"},"status":{"ready":true}}`,
+		`{"metadata":{"name":"a","resourceVersion":"2"},"data":{"a":"1"}}`,
+		"",
+	),
+	Entry("changed field",
+		`{"metadata":{"name":"a"},"data":{"a":"1"}}`,
+		`{"metadata":{"name":"a"},"data":{"a":"2"}}`,
+		"--- live\n+++ expected\n@@ -1,5 +1,5 @@\n data:\n-  a: \"1\"\n+  a: \"2\"\n metadata:\n   name: a\n \n",
+	),
+	Entry("created object",
+		"",
+		`{"data":{"a":"1"}}`,
+		"--- live\n+++ expected\n@@ -1 +1,3 @@\n+data:\n+  a: \"1\"\n \n",
+	),
+)
+
+var _ = Describe("getDiff of the Secret", func() {
+	It("shows the changed keys with the hashes instead of the values", func() {
+		diff, err := getDiff(
+			[]byte(`{"kind":"Secret","metadata":{"name":"a","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"password\":\"b2xkLXBhc3N3b3Jk\"}}"}},"data":{"password":"b2xkLXBhc3N3b3Jk","user":"YWRtaW4="}}`),
+			[]byte(`{"kind":"Secret","metadata":{"name":"a"},"data":{"password":"bmV3LXBhc3N3b3Jk"},"stringData":{"user":"admin"}}`),
+		)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(diff).Should(Equal(fmt.Sprintf(`--- live
++++ expected
+@@ -1,7 +1,8 @@
+ data:
+-  password: <masked, 12 bytes, sha256 %s>
+-  user: <masked, 5 bytes, sha256 %s>
++  password: <masked, 12 bytes, sha256 %s>
+ kind: Secret
+ metadata:
+   name: a
++stringData:
++  user: <masked, 5 bytes, sha256 %s>
+ 
+`, util.Sha256Hash("old-password")[:12], util.Sha256Hash("admin")[:12], util.Sha256Hash("new-password")[:12], util.Sha256Hash("admin")[:12])))
+		Ω(diff).ShouldNot(ContainSubstring("b2xkLXBhc3N3b3Jk"))
+	})
+})
+
+var _ = DescribeTable("skipHookManifests returns the manifests without the hooks",
+	func(manifests, expected string) {
+		Ω(skipHookManifests(manifests)).Should(Equal(expected))
+	},
+	Entry("no hooks",
+		"# Source: app/templates/a.yaml\nkind: ConfigMap\nmetadata:\n  name: a\n",
+		"# Source: app/templates/a.yaml\nkind: ConfigMap\nmetadata:\n  name: a\n",
+	),
+	Entry("hook is skipped",
+		"# Source: app/templates/a.yaml\nkind: ConfigMap\nmetadata:\n  name: a\n---\n# Source: app/templates/job.yaml\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    helm.sh/hook: pre-upgrade\n",
+		"# Source: app/templates/a.yaml\nkind: ConfigMap\nmetadata:\n  name: a\n",
+	),
+)
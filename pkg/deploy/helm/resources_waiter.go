@@ -43,16 +43,35 @@ type ResourcesWaiter struct {
 	LogsFromTime              time.Time
 	StatusProgressPeriod      time.Duration
 	HooksStatusProgressPeriod time.Duration
+
+	// StatusEvents enables structured status events output instead of human-oriented text when set
+	StatusEvents *StatusEventsWriter
 }
 
-func NewResourcesWaiter(kubeInitializer KubeInitializer, client *helm_kube.Client, logsFromTime time.Time, statusProgressPeriod, hooksStatusProgressPeriod time.Duration) *ResourcesWaiter {
+func NewResourcesWaiter(kubeInitializer KubeInitializer, client *helm_kube.Client, logsFromTime time.Time, statusProgressPeriod, hooksStatusProgressPeriod time.Duration, statusEvents *StatusEventsWriter) *ResourcesWaiter {
 	return &ResourcesWaiter{
 		KubeInitializer:           kubeInitializer,
 		Client:                    client,
 		LogsFromTime:              logsFromTime,
 		StatusProgressPeriod:      statusProgressPeriod,
 		HooksStatusProgressPeriod: hooksStatusProgressPeriod,
+		StatusEvents:              statusEvents,
+	}
+}
+
+func (waiter *ResourcesWaiter) multitrack(specs multitrack.MultitrackSpecs, statusProgressPeriod, timeout time.Duration) error {
+	opts := multitrack.MultitrackOptions{
+		StatusProgressPeriod: statusProgressPeriod,
+		Options: tracker.Options{
+			Timeout:      timeout,
+			LogsFromTime: waiter.LogsFromTime,
+		},
 	}
+
+	if waiter.StatusEvents != nil {
+		return newStatusEventsMultitracker(waiter.StatusEvents).Multitrack(kube.Client, specs, opts)
+	}
+	return multitrack.Multitrack(kube.Client, specs, opts)
 }
 
 func extractSpecReplicas(specReplicas *int32) int {
@@ -192,13 +211,7 @@ func (waiter *ResourcesWaiter) Wait(ctx context.Context, namespace string, resou
 	logboek.Context(ctx).LogOptionalLn()
 	return logboek.Context(ctx).LogProcess("Waiting for release resources to become ready").
 		DoError(func() error {
-			return multitrack.Multitrack(kube.Client, specs, multitrack.MultitrackOptions{
-				StatusProgressPeriod: waiter.StatusProgressPeriod,
-				Options: tracker.Options{
-					Timeout:      timeout,
-					LogsFromTime: waiter.LogsFromTime,
-				},
-			})
+			return waiter.multitrack(specs, waiter.StatusProgressPeriod, timeout)
 		})
 }
 
@@ -387,13 +400,7 @@ func (waiter *ResourcesWaiter) WatchUntilReady(ctx context.Context, namespace st
 
 			return logboek.Context(ctx).LogProcess("Waiting for helm hook job/%s termination", name).
 				DoError(func() error {
-					return multitrack.Multitrack(kube.Client, specs, multitrack.MultitrackOptions{
-						StatusProgressPeriod: waiter.HooksStatusProgressPeriod,
-						Options: tracker.Options{
-							Timeout:      timeout,
-							LogsFromTime: waiter.LogsFromTime,
-						},
-					})
+					return waiter.multitrack(specs, waiter.HooksStatusProgressPeriod, timeout)
 				})
 
 		default:
@@ -438,6 +445,23 @@ func (waiter *ResourcesWaiter) WaitUntilDeleted(ctx context.Context, specs []*he
 	}
 
 	return logboek.Context(ctx).Default().LogProcess("Waiting for resources elimination: %s", strings.Join(resourcesDescParts, ", ")).DoError(func() error {
-		return elimination.TrackUntilEliminated(ctx, kube.DynamicClient, eliminationSpecs, elimination.EliminationTrackerOptions{Timeout: timeout, StatusProgressPeriod: waiter.StatusProgressPeriod})
+		if waiter.StatusEvents == nil {
+			return elimination.TrackUntilEliminated(ctx, kube.DynamicClient, eliminationSpecs, elimination.EliminationTrackerOptions{Timeout: timeout, StatusProgressPeriod: waiter.StatusProgressPeriod})
+		}
+
+		for _, spec := range specs {
+			_ = waiter.StatusEvents.Emit(StatusEvent{Type: ResourceDeletingEvent, Resource: fmt.Sprintf("%s/%s", strings.ToLower(spec.GroupVersionResource.Resource), spec.ResourceName), Namespace: spec.Namespace})
+		}
+
+		if err := elimination.TrackUntilEliminated(ctx, kube.DynamicClient, eliminationSpecs, elimination.EliminationTrackerOptions{Timeout: timeout, StatusProgressPeriod: waiter.StatusProgressPeriod}); err != nil {
+			_ = waiter.StatusEvents.Emit(StatusEvent{Type: TrackingFailedEvent, Message: err.Error()})
+			return err
+		}
+
+		for _, spec := range specs {
+			_ = waiter.StatusEvents.Emit(StatusEvent{Type: ResourceDeletedEvent, Resource: fmt.Sprintf("%s/%s", strings.ToLower(spec.GroupVersionResource.Resource), spec.ResourceName), Namespace: spec.Namespace})
+		}
+
+		return nil
 	})
 }
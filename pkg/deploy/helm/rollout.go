@@ -0,0 +1,432 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/werf/kubedog/pkg/kube"
+	"github.com/werf/kubedog/pkg/trackers/rollout/multitrack"
+	"github.com/werf/logboek"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+type RolloutStrategy string
+
+const (
+	CanaryRolloutStrategy    RolloutStrategy = "canary"
+	BlueGreenRolloutStrategy RolloutStrategy = "blue-green"
+)
+
+const (
+	rolloutCopyLabelName      = "werf.io/rollout-copy"
+	defaultCanaryRolloutSteps = "10,50"
+)
+
+// RolloutSpec describes progressive delivery of the Deployment from the rendered release manifest.
+// The new pod template is verified using a temporary copy of the Deployment before the release is upgraded.
+type RolloutSpec struct {
+	Deployment          *appsv1.Deployment
+	Strategy            RolloutStrategy
+	Steps               []int
+	StepPause           time.Duration
+	MetricCheck         *RolloutMetricCheck
+	PodTemplateChecksum string
+}
+
+func (spec *RolloutSpec) CopyName() string {
+	return fmt.Sprintf("%s-werf-%s", spec.Deployment.Name, spec.Strategy)
+}
+
+func (spec *RolloutSpec) String() string {
+	return fmt.Sprintf("deploy/%s", spec.Deployment.Name)
+}
+
+// GetRolloutSpecs finds Deployments with the werf.io/rollout-strategy annotation in the rendered release manifests.
+func GetRolloutSpecs(manifests, namespace string) ([]*RolloutSpec, error) {
+	splitManifestsByKeys := releaseutil.SplitManifests(manifests)
+
+	manifestsKeys := make([]string, 0, len(splitManifestsByKeys))
+	for k := range splitManifestsByKeys {
+		manifestsKeys = append(manifestsKeys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(manifestsKeys))
+
+	var specs []*RolloutSpec
+	for _, manifestKey := range manifestsKeys {
+		var obj unstructured.Unstructured
+		if err := yaml.Unmarshal([]byte(splitManifestsByKeys[manifestKey]), &obj); err != nil {
+			return nil, fmt.Errorf("unable to decode yaml manifest: %s", err)
+		}
+
+		if obj.GetKind() != "Deployment" {
+			continue
+		}
+
+		if _, hasStrategy := obj.GetAnnotations()[RolloutStrategyAnnoName]; !hasStrategy {
+			continue
+		}
+
+		if obj.GetAPIVersion() != "apps/v1" {
+			return nil, fmt.Errorf("deploy/%s: %s annotation is supported only for apps/v1 Deployment, got %s", obj.GetName(), RolloutStrategyAnnoName, obj.GetAPIVersion())
+		}
+
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+			return nil, fmt.Errorf("unable to decode deploy/%s: %s", obj.GetName(), err)
+		}
+
+		if deployment.Namespace == "" {
+			deployment.Namespace = namespace
+		}
+
+		spec, err := newRolloutSpec(deployment)
+		if err != nil {
+			return nil, err
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs, nil
+}
+
+func newRolloutSpec(deployment *appsv1.Deployment) (*RolloutSpec, error) {
+	annotations := deployment.Annotations
+	invalidAnnoValueError := func(annoName string) error {
+		return fmt.Errorf("deploy/%s annotation %s with invalid value %q", deployment.Name, annoName, annotations[annoName])
+	}
+
+	spec := &RolloutSpec{Deployment: deployment}
+
+	switch strategy := RolloutStrategy(annotations[RolloutStrategyAnnoName]); strategy {
+	case CanaryRolloutStrategy:
+		spec.Strategy = strategy
+
+		stepsValue := defaultCanaryRolloutSteps
+		if value, hasKey := annotations[RolloutCanaryStepsAnnoName]; hasKey {
+			stepsValue = value
+		}
+
+		prevWeight := 0
+		for _, part := range strings.Split(stepsValue, ",") {
+			weight, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || weight <= prevWeight || weight > 100 {
+				return nil, fmt.Errorf("%s: ascending replicas percentages from 1 to 100 separated by comma expected", invalidAnnoValueError(RolloutCanaryStepsAnnoName))
+			}
+
+			spec.Steps = append(spec.Steps, weight)
+			prevWeight = weight
+		}
+	case BlueGreenRolloutStrategy:
+		spec.Strategy = strategy
+		spec.Steps = []int{100}
+	default:
+		return nil, fmt.Errorf("%s: choose one of %v", invalidAnnoValueError(RolloutStrategyAnnoName), []RolloutStrategy{CanaryRolloutStrategy, BlueGreenRolloutStrategy})
+	}
+
+	if value, hasKey := annotations[RolloutStepPauseAnnoName]; hasKey {
+		pause, err := time.ParseDuration(value)
+		if err != nil || pause < 0 {
+			return nil, fmt.Errorf("%s: positive duration expected", invalidAnnoValueError(RolloutStepPauseAnnoName))
+		}
+
+		spec.StepPause = pause
+	}
+
+	if query, hasKey := annotations[RolloutMetricQueryAnnoName]; hasKey {
+		metricCheck := &RolloutMetricCheck{
+			Endpoint: annotations[RolloutMetricEndpointAnnoName],
+			Query:    query,
+		}
+
+		if metricCheck.Endpoint == "" {
+			return nil, fmt.Errorf("deploy/%s annotation %s is required when %s is set", deployment.Name, RolloutMetricEndpointAnnoName, RolloutMetricQueryAnnoName)
+		}
+
+		for annoName, dest := range map[string]**float64{
+			RolloutMetricMinAnnoName: &metricCheck.Min,
+			RolloutMetricMaxAnnoName: &metricCheck.Max,
+		} {
+			if value, hasKey := annotations[annoName]; hasKey {
+				floatValue, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%s: number expected", invalidAnnoValueError(annoName))
+				}
+
+				*dest = &floatValue
+			}
+		}
+
+		if metricCheck.Min == nil && metricCheck.Max == nil {
+			return nil, fmt.Errorf("deploy/%s annotation %s or %s is required when %s is set", deployment.Name, RolloutMetricMinAnnoName, RolloutMetricMaxAnnoName, RolloutMetricQueryAnnoName)
+		}
+
+		spec.MetricCheck = metricCheck
+	}
+
+	templateData, err := json.Marshal(deployment.Spec.Template)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal deploy/%s pod template: %s", deployment.Name, err)
+	}
+	spec.PodTemplateChecksum = fmt.Sprintf("%x", sha256.Sum256(templateData))
+
+	return spec, nil
+}
+
+// RolloutOrchestrator verifies new pod templates of the Deployments with the rollout strategy
+// using temporary copies of these Deployments. Copies are scaled step by step and checked for readiness
+// and optional metric query. The release upgrade should be performed only when all rollouts passed,
+// otherwise the release stays on the current revision. After the upgrade the promoted Deployments are checked
+// with the metric queries once more and the release should be rolled back when the upgrade or this check failed.
+type RolloutOrchestrator struct {
+	Waiter *ResourcesWaiter
+
+	startedSpecs []*RolloutSpec
+}
+
+func NewRolloutOrchestrator(waiter *ResourcesWaiter) *RolloutOrchestrator {
+	return &RolloutOrchestrator{Waiter: waiter}
+}
+
+func (o *RolloutOrchestrator) Run(ctx context.Context, specs []*RolloutSpec, timeout time.Duration) error {
+	if len(specs) == 0 {
+		return nil
+	}
+
+	if o.Waiter.KubeInitializer != nil {
+		if err := o.Waiter.KubeInitializer.Init(ctx); err != nil {
+			return fmt.Errorf("kube initializer failed: %s", err)
+		}
+	}
+
+	for _, spec := range specs {
+		live, err := kube.Client.AppsV1().Deployments(spec.Deployment.Namespace).Get(ctx, spec.Deployment.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			logboek.Context(ctx).Default().LogFDetails("%s does not exist yet: %s rollout skipped\n", spec, spec.Strategy)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to get %s: %s", spec, err)
+		}
+
+		if live.Annotations[RolloutPodTemplateChecksumAnnoName] == spec.PodTemplateChecksum {
+			logboek.Context(ctx).Default().LogFDetails("%s pod template not changed: %s rollout skipped\n", spec, spec.Strategy)
+			continue
+		}
+
+		o.startedSpecs = append(o.startedSpecs, spec)
+
+		logboek.Context(ctx).LogOptionalLn()
+		if err := logboek.Context(ctx).LogProcess("Running %s rollout of %s", spec.Strategy, spec).DoError(func() error {
+			return o.runSteps(ctx, spec, extractSpecReplicas(live.Spec.Replicas), timeout)
+		}); err != nil {
+			o.emit(StatusEvent{Type: RolloutAbortedEvent, Resource: spec.String(), Namespace: spec.Deployment.Namespace, Message: err.Error()})
+
+			if cleanupErr := o.Complete(ctx, false); cleanupErr != nil {
+				logboek.Context(ctx).Warn().LogF("WARNING: %s\n", cleanupErr)
+			}
+
+			return fmt.Errorf("%s rollout of %s failed: %s", spec.Strategy, spec, err)
+		}
+	}
+
+	return nil
+}
+
+// HasVerifiedRollouts returns true when the new pod templates have passed the rollouts and the release upgrade is going to promote them.
+func (o *RolloutOrchestrator) HasVerifiedRollouts() bool {
+	return len(o.startedSpecs) > 0
+}
+
+// Promote checks the metric queries of the rollouts after the release upgrade has promoted new pod templates to the Deployments.
+func (o *RolloutOrchestrator) Promote(ctx context.Context) error {
+	for _, spec := range o.startedSpecs {
+		if spec.MetricCheck == nil {
+			continue
+		}
+
+		if err := logboek.Context(ctx).Default().LogProcess("Checking promoted %s", spec).DoError(func() error {
+			if spec.StepPause > 0 {
+				logboek.Context(ctx).Default().LogF("Pausing for %s\n", spec.StepPause)
+
+				select {
+				case <-time.After(spec.StepPause):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			return spec.MetricCheck.Check(ctx)
+		}); err != nil {
+			o.emit(StatusEvent{Type: RolloutAbortedEvent, Resource: spec.String(), Namespace: spec.Deployment.Namespace, Message: err.Error()})
+			return fmt.Errorf("%s rollout of %s failed after promotion: %s", spec.Strategy, spec, err)
+		}
+	}
+
+	return nil
+}
+
+// Complete removes temporary Deployments copies and, when the release upgrade succeeded,
+// remembers verified pod templates so that unchanged Deployments will not be rolled out again.
+func (o *RolloutOrchestrator) Complete(ctx context.Context, promoted bool) error {
+	var errMsgs []string
+
+	for _, spec := range o.startedSpecs {
+		if promoted {
+			patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, RolloutPodTemplateChecksumAnnoName, spec.PodTemplateChecksum))
+			if _, err := kube.Client.AppsV1().Deployments(spec.Deployment.Namespace).Patch(ctx, spec.Deployment.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+				errMsgs = append(errMsgs, fmt.Sprintf("unable to annotate %s: %s", spec, err))
+			} else {
+				o.emit(StatusEvent{Type: RolloutPromotedEvent, Resource: spec.String(), Namespace: spec.Deployment.Namespace})
+			}
+		}
+
+		if err := kube.Client.AppsV1().Deployments(spec.Deployment.Namespace).Delete(ctx, spec.CopyName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			errMsgs = append(errMsgs, fmt.Sprintf("unable to delete deploy/%s: %s", spec.CopyName(), err))
+		}
+	}
+
+	o.startedSpecs = nil
+
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("rollout completion failed:\n%s", strings.Join(errMsgs, "\n"))
+	}
+	return nil
+}
+
+func (o *RolloutOrchestrator) runSteps(ctx context.Context, spec *RolloutSpec, replicas int, timeout time.Duration) error {
+	for i, weight := range spec.Steps {
+		copyReplicas := int(math.Ceil(float64(replicas) * float64(weight) / 100))
+		if copyReplicas < 1 {
+			copyReplicas = 1
+		}
+
+		stepDesc := fmt.Sprintf("step %d/%d: %d%% (%d of %d replicas)", i+1, len(spec.Steps), weight, copyReplicas, replicas)
+
+		if err := logboek.Context(ctx).Default().LogProcess("Rollout %s", stepDesc).DoError(func() error {
+			if err := o.applyCopy(ctx, spec, int32(copyReplicas)); err != nil {
+				return err
+			}
+
+			trackSpec, err := prepareMultitrackSpec(spec.CopyName(), "deploy", spec.Deployment.Namespace, spec.Deployment.Annotations, allowedFailuresCountOptions{multiplier: copyReplicas, defaultPerReplica: 1})
+			if err != nil {
+				return err
+			}
+
+			if err := o.Waiter.multitrack(multitrack.MultitrackSpecs{Deployments: []multitrack.MultitrackSpec{*trackSpec}}, o.Waiter.StatusProgressPeriod, timeout); err != nil {
+				return err
+			}
+
+			if spec.StepPause > 0 {
+				logboek.Context(ctx).Default().LogF("Pausing for %s\n", spec.StepPause)
+
+				select {
+				case <-time.After(spec.StepPause):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if spec.MetricCheck != nil {
+				if err := spec.MetricCheck.Check(ctx); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("%s: %s", stepDesc, err)
+		}
+
+		o.emit(StatusEvent{Type: RolloutStepPassedEvent, Resource: spec.String(), Namespace: spec.Deployment.Namespace, Message: stepDesc})
+	}
+
+	return nil
+}
+
+func (o *RolloutOrchestrator) applyCopy(ctx context.Context, spec *RolloutSpec, replicas int32) error {
+	copyDeployment := newRolloutCopyDeployment(spec, replicas)
+	deployments := kube.Client.AppsV1().Deployments(spec.Deployment.Namespace)
+
+	existing, err := deployments.Get(ctx, copyDeployment.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := deployments.Create(ctx, copyDeployment, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create deploy/%s: %s", copyDeployment.Name, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get deploy/%s: %s", copyDeployment.Name, err)
+	}
+
+	// selector is immutable
+	copyDeployment.Spec.Selector = existing.Spec.Selector
+	copyDeployment.ResourceVersion = existing.ResourceVersion
+
+	if _, err := deployments.Update(ctx, copyDeployment, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update deploy/%s: %s", copyDeployment.Name, err)
+	}
+
+	return nil
+}
+
+func newRolloutCopyDeployment(spec *RolloutSpec, replicas int32) *appsv1.Deployment {
+	copyLabels := map[string]string{rolloutCopyLabelName: string(spec.Strategy)}
+
+	deploymentSpec := spec.Deployment.Spec.DeepCopy()
+	deploymentSpec.Replicas = &replicas
+
+	selector := deploymentSpec.Selector
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+	selector.MatchLabels = mergeLabels(selector.MatchLabels, copyLabels)
+	deploymentSpec.Selector = selector
+	deploymentSpec.Template.Labels = mergeLabels(deploymentSpec.Template.Labels, copyLabels)
+
+	annotations := map[string]string{}
+	for k, v := range spec.Deployment.Annotations {
+		if !strings.HasPrefix(k, "werf.io/rollout-") {
+			annotations[k] = v
+		}
+	}
+
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        spec.CopyName(),
+			Namespace:   spec.Deployment.Namespace,
+			Labels:      mergeLabels(spec.Deployment.Labels, copyLabels),
+			Annotations: annotations,
+		},
+		Spec: *deploymentSpec,
+	}
+}
+
+func mergeLabels(labels ...map[string]string) map[string]string {
+	res := map[string]string{}
+	for _, l := range labels {
+		for k, v := range l {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func (o *RolloutOrchestrator) emit(event StatusEvent) {
+	if o.Waiter.StatusEvents != nil {
+		_ = o.Waiter.StatusEvents.Emit(event)
+	}
+}
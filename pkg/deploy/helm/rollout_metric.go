@@ -0,0 +1,122 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RolloutMetricCheck is an instant query to the Prometheus-compatible HTTP API which result should fit into the [Min, Max] range.
+type RolloutMetricCheck struct {
+	Endpoint string
+	Query    string
+	Min      *float64
+	Max      *float64
+}
+
+type prometheusQueryResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+func (check *RolloutMetricCheck) Check(ctx context.Context) error {
+	value, err := check.queryValue(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to evaluate metric query %q: %s", check.Query, err)
+	}
+
+	if check.Min != nil && value < *check.Min {
+		return fmt.Errorf("metric query %q value %v is less than allowed minimum %v", check.Query, value, *check.Min)
+	}
+
+	if check.Max != nil && value > *check.Max {
+		return fmt.Errorf("metric query %q value %v is greater than allowed maximum %v", check.Query, value, *check.Max)
+	}
+
+	return nil
+}
+
+func (check *RolloutMetricCheck) queryValue(ctx context.Context) (float64, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/query?%s", strings.TrimSuffix(check.Endpoint, "/"), url.Values{"query": []string{check.Query}}.Encode())
+
+	reqCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, queryURL, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read response body: %s", err)
+	}
+
+	var queryResponse prometheusQueryResponse
+	if err := json.Unmarshal(body, &queryResponse); err != nil {
+		return 0, fmt.Errorf("unable to parse response (status code %d): %s", resp.StatusCode, err)
+	}
+
+	if queryResponse.Status != "success" {
+		return 0, fmt.Errorf("query failed: %s: %s", queryResponse.ErrorType, queryResponse.Error)
+	}
+
+	return parsePrometheusQueryResult(queryResponse.Data.ResultType, queryResponse.Data.Result)
+}
+
+func parsePrometheusQueryResult(resultType string, result json.RawMessage) (float64, error) {
+	var sample []interface{}
+
+	switch resultType {
+	case "scalar":
+		if err := json.Unmarshal(result, &sample); err != nil {
+			return 0, fmt.Errorf("unable to parse scalar result: %s", err)
+		}
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(result, &vector); err != nil {
+			return 0, fmt.Errorf("unable to parse vector result: %s", err)
+		}
+
+		switch len(vector) {
+		case 0:
+			return 0, fmt.Errorf("empty result")
+		case 1:
+			sample = vector[0].Value
+		default:
+			return 0, fmt.Errorf("single sample expected, got %d samples", len(vector))
+		}
+	default:
+		return 0, fmt.Errorf("unsupported result type %q: scalar or vector expected", resultType)
+	}
+
+	if len(sample) != 2 {
+		return 0, fmt.Errorf("unexpected sample format %v", sample)
+	}
+
+	valueString, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", sample[1])
+	}
+
+	return strconv.ParseFloat(valueString, 64)
+}
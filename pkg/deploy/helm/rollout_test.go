@@ -0,0 +1,156 @@
+package helm
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newRolloutTestDeployment(annotations map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "ns", Annotations: annotations},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "backend", Image: "backend:v1"}}},
+			},
+		},
+	}
+}
+
+func float64P(v float64) *float64 {
+	return &v
+}
+
+var _ = Describe("newRolloutSpec", func() {
+	DescribeTable("should parse rollout annotations",
+		func(annotations map[string]string, expectedSpec RolloutSpec) {
+			spec, err := newRolloutSpec(newRolloutTestDeployment(annotations))
+			Expect(err).To(Succeed())
+
+			Expect(spec.Strategy).To(Equal(expectedSpec.Strategy))
+			Expect(spec.Steps).To(Equal(expectedSpec.Steps))
+			Expect(spec.StepPause).To(Equal(expectedSpec.StepPause))
+			Expect(spec.MetricCheck).To(Equal(expectedSpec.MetricCheck))
+			Expect(spec.PodTemplateChecksum).To(HaveLen(64))
+		},
+		Entry("canary with default steps",
+			map[string]string{RolloutStrategyAnnoName: "canary"},
+			RolloutSpec{Strategy: CanaryRolloutStrategy, Steps: []int{10, 50}},
+		),
+		Entry("canary with custom steps and pause",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutCanaryStepsAnnoName: "5, 25,100", RolloutStepPauseAnnoName: "1m30s"},
+			RolloutSpec{Strategy: CanaryRolloutStrategy, Steps: []int{5, 25, 100}, StepPause: 90 * time.Second},
+		),
+		Entry("blue-green",
+			map[string]string{RolloutStrategyAnnoName: "blue-green"},
+			RolloutSpec{Strategy: BlueGreenRolloutStrategy, Steps: []int{100}},
+		),
+		Entry("metric check with the range",
+			map[string]string{
+				RolloutStrategyAnnoName:       "blue-green",
+				RolloutMetricEndpointAnnoName: "http://prometheus:9090",
+				RolloutMetricQueryAnnoName:    "sum(up)",
+				RolloutMetricMinAnnoName:      "1",
+				RolloutMetricMaxAnnoName:      "2.5",
+			},
+			RolloutSpec{Strategy: BlueGreenRolloutStrategy, Steps: []int{100}, MetricCheck: &RolloutMetricCheck{Endpoint: "http://prometheus:9090", Query: "sum(up)", Min: float64P(1), Max: float64P(2.5)}},
+		),
+		Entry("metric check with the maximum only",
+			map[string]string{
+				RolloutStrategyAnnoName:       "canary",
+				RolloutMetricEndpointAnnoName: "http://prometheus:9090",
+				RolloutMetricQueryAnnoName:    "sum(errors)",
+				RolloutMetricMaxAnnoName:      "0",
+			},
+			RolloutSpec{Strategy: CanaryRolloutStrategy, Steps: []int{10, 50}, MetricCheck: &RolloutMetricCheck{Endpoint: "http://prometheus:9090", Query: "sum(errors)", Max: float64P(0)}},
+		),
+	)
+
+	DescribeTable("should fail on invalid annotations",
+		func(annotations map[string]string, expectedErr string) {
+			_, err := newRolloutSpec(newRolloutTestDeployment(annotations))
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("unknown strategy",
+			map[string]string{RolloutStrategyAnnoName: "rolling"},
+			`deploy/backend annotation werf.io/rollout-strategy with invalid value "rolling": choose one of [canary blue-green]`,
+		),
+		Entry("not ascending steps",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutCanaryStepsAnnoName: "50,10"},
+			`deploy/backend annotation werf.io/rollout-canary-steps with invalid value "50,10": ascending replicas percentages from 1 to 100 separated by comma expected`,
+		),
+		Entry("step over 100 percents",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutCanaryStepsAnnoName: "50,150"},
+			`deploy/backend annotation werf.io/rollout-canary-steps with invalid value "50,150": ascending replicas percentages from 1 to 100 separated by comma expected`,
+		),
+		Entry("zero step",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutCanaryStepsAnnoName: "0,50"},
+			`deploy/backend annotation werf.io/rollout-canary-steps with invalid value "0,50": ascending replicas percentages from 1 to 100 separated by comma expected`,
+		),
+		Entry("invalid pause",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutStepPauseAnnoName: "-1s"},
+			`deploy/backend annotation werf.io/rollout-step-pause with invalid value "-1s": positive duration expected`,
+		),
+		Entry("metric query without endpoint",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutMetricQueryAnnoName: "sum(up)", RolloutMetricMinAnnoName: "1"},
+			"deploy/backend annotation werf.io/rollout-metric-endpoint is required when werf.io/rollout-metric-query is set",
+		),
+		Entry("metric query without range",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutMetricQueryAnnoName: "sum(up)", RolloutMetricEndpointAnnoName: "http://prometheus:9090"},
+			"deploy/backend annotation werf.io/rollout-metric-min or werf.io/rollout-metric-max is required when werf.io/rollout-metric-query is set",
+		),
+		Entry("invalid metric range value",
+			map[string]string{RolloutStrategyAnnoName: "canary", RolloutMetricQueryAnnoName: "sum(up)", RolloutMetricEndpointAnnoName: "http://prometheus:9090", RolloutMetricMaxAnnoName: "many"},
+			`deploy/backend annotation werf.io/rollout-metric-max with invalid value "many": number expected`,
+		),
+	)
+
+	It("should change the pod template checksum only with the pod template", func() {
+		annotations := map[string]string{RolloutStrategyAnnoName: "canary"}
+
+		deployment := newRolloutTestDeployment(annotations)
+		spec, err := newRolloutSpec(deployment)
+		Expect(err).To(Succeed())
+
+		deployment.Spec.Replicas = new(int32)
+		sameTemplateSpec, err := newRolloutSpec(deployment)
+		Expect(err).To(Succeed())
+		Expect(sameTemplateSpec.PodTemplateChecksum).To(Equal(spec.PodTemplateChecksum))
+
+		deployment.Spec.Template.Spec.Containers[0].Image = "backend:v2"
+		newTemplateSpec, err := newRolloutSpec(deployment)
+		Expect(err).To(Succeed())
+		Expect(newTemplateSpec.PodTemplateChecksum).NotTo(Equal(spec.PodTemplateChecksum))
+	})
+})
+
+var _ = Describe("parsePrometheusQueryResult", func() {
+	DescribeTable("should parse the single value",
+		func(resultType, result string, expectedValue float64) {
+			value, err := parsePrometheusQueryResult(resultType, []byte(result))
+			Expect(err).To(Succeed())
+			Expect(value).To(Equal(expectedValue))
+		},
+		Entry("scalar", "scalar", `[1631270000.123, "0.25"]`, 0.25),
+		Entry("single sample vector", "vector", `[{"metric": {"app": "backend"}, "value": [1631270000.123, "42"]}]`, 42.0),
+	)
+
+	DescribeTable("should fail on unexpected result",
+		func(resultType, result, expectedErr string) {
+			_, err := parsePrometheusQueryResult(resultType, []byte(result))
+			Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+		},
+		Entry("empty vector", "vector", `[]`, "empty result"),
+		Entry("multiple samples vector", "vector", `[{"value": [1, "1"]}, {"value": [1, "2"]}]`, "single sample expected, got 2 samples"),
+		Entry("matrix", "matrix", `[]`, `unsupported result type "matrix": scalar or vector expected`),
+		Entry("bad sample format", "scalar", `[1]`, "unexpected sample format"),
+		Entry("not string sample value", "scalar", `[1, 2]`, "unexpected sample value 2"),
+		Entry("not number sample value", "scalar", `[1, "NaN?"]`, "invalid syntax"),
+		Entry("malformed result", "scalar", `{}`, "unable to parse scalar result"),
+	)
+})
@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type StatusFormat string
+
+const (
+	StatusFormatText StatusFormat = "text"
+	StatusFormatJSON StatusFormat = "json"
+)
+
+type StatusEventType string
+
+const (
+	TrackingStartedEvent  StatusEventType = "trackingStarted"
+	TrackingFinishedEvent StatusEventType = "trackingFinished"
+	TrackingFailedEvent   StatusEventType = "trackingFailed"
+
+	ResourceAddedEvent           StatusEventType = "resourceAdded"
+	ResourceReadyEvent           StatusEventType = "resourceReady"
+	ResourceFailedEvent          StatusEventType = "resourceFailed"
+	ResourceDeletingEvent        StatusEventType = "resourceDeleting"
+	ResourceDeletedEvent         StatusEventType = "resourceDeleted"
+	ResourceKubeEvent            StatusEventType = "kubeEvent"
+	ResourceLogLineEvent         StatusEventType = "logLine"
+	ResourcePodAddedEvent        StatusEventType = "podAdded"
+	FailModeDecisionEvent        StatusEventType = "failModeDecision"
+	ResourceTrackingStoppedEvent StatusEventType = "resourceTrackingStopped"
+
+	RolloutStepPassedEvent StatusEventType = "rolloutStepPassed"
+	RolloutAbortedEvent    StatusEventType = "rolloutAborted"
+	RolloutPromotedEvent   StatusEventType = "rolloutPromoted"
+
+	RollbackStartedEvent  StatusEventType = "rollbackStarted"
+	RollbackFinishedEvent StatusEventType = "rollbackFinished"
+	RollbackFailedEvent   StatusEventType = "rollbackFailed"
+)
+
+type FailModeDecision string
+
+const (
+	ContinueTrackingDecision       FailModeDecision = "continueTracking"
+	HopeUntilEndOfDeployDecision   FailModeDecision = "hopeUntilEndOfDeployProcess"
+	FailWholeDeployProcessDecision FailModeDecision = "failWholeDeployProcess"
+	IgnoreFailureDecision          FailModeDecision = "ignoreFailure"
+)
+
+type StatusEvent struct {
+	Time      time.Time       `json:"time"`
+	Type      StatusEventType `json:"type"`
+	Resource  string          `json:"resource,omitempty"`
+	Namespace string          `json:"namespace,omitempty"`
+	Pod       string          `json:"pod,omitempty"`
+	Container string          `json:"container,omitempty"`
+	Message   string          `json:"message,omitempty"`
+
+	FailMode             string           `json:"failMode,omitempty"`
+	Decision             FailModeDecision `json:"decision,omitempty"`
+	FailuresCount        int              `json:"failuresCount,omitempty"`
+	AllowedFailuresCount int              `json:"allowedFailuresCount,omitempty"`
+}
+
+// StatusEventsWriter writes each status event as a single JSON line, so the output can be consumed as a stream.
+type StatusEventsWriter struct {
+	mux sync.Mutex
+	out io.Writer
+}
+
+func NewStatusEventsWriter(out io.Writer) *StatusEventsWriter {
+	return &StatusEventsWriter{out: out}
+}
+
+func (w *StatusEventsWriter) Emit(event StatusEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to marshal status event: %s", err)
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("unable to write status event: %s", err)
+	}
+
+	return nil
+}
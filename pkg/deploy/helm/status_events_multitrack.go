@@ -0,0 +1,558 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/werf/kubedog/pkg/tracker"
+	"github.com/werf/kubedog/pkg/tracker/canary"
+	"github.com/werf/kubedog/pkg/tracker/controller"
+	"github.com/werf/kubedog/pkg/tracker/daemonset"
+	"github.com/werf/kubedog/pkg/tracker/deployment"
+	"github.com/werf/kubedog/pkg/tracker/job"
+	"github.com/werf/kubedog/pkg/tracker/pod"
+	"github.com/werf/kubedog/pkg/tracker/replicaset"
+	"github.com/werf/kubedog/pkg/tracker/statefulset"
+	"github.com/werf/kubedog/pkg/trackers/rollout/multitrack"
+	"k8s.io/client-go/kubernetes"
+)
+
+type trackedResourceStatus string
+
+const (
+	trackedResourceActive            trackedResourceStatus = "active"
+	trackedResourceSucceeded         trackedResourceStatus = "succeeded"
+	trackedResourceFailed            trackedResourceStatus = "failed"
+	trackedResourceHoping            trackedResourceStatus = "hoping"
+	trackedResourceActiveAfterHoping trackedResourceStatus = "activeAfterHoping"
+)
+
+type trackedResource struct {
+	Kind          string
+	Spec          multitrack.MultitrackSpec
+	Status        trackedResourceStatus
+	FailuresCount int
+	FailedReason  string
+	IsDone        bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (res *trackedResource) Name() string {
+	return fmt.Sprintf("%s/%s", res.Kind, res.Spec.ResourceName)
+}
+
+// statusEventsMultitracker tracks resources following the same rules as kubedog multitrack
+// (fail modes, allowed failures count, track termination modes, logs filtering),
+// but reports the progress as a stream of status events instead of human-oriented text.
+type statusEventsMultitracker struct {
+	events *StatusEventsWriter
+
+	mux           sync.Mutex
+	resources     []*trackedResource
+	isTerminating bool
+}
+
+func newStatusEventsMultitracker(events *StatusEventsWriter) *statusEventsMultitracker {
+	return &statusEventsMultitracker{events: events}
+}
+
+func (mt *statusEventsMultitracker) Multitrack(kubeClient kubernetes.Interface, specs multitrack.MultitrackSpecs, opts multitrack.MultitrackOptions) error {
+	parentContext := opts.ParentContext
+	if parentContext == nil {
+		parentContext = context.Background()
+	}
+
+	for _, desc := range []struct {
+		kind  string
+		specs []multitrack.MultitrackSpec
+	}{
+		{"deploy", specs.Deployments},
+		{"sts", specs.StatefulSets},
+		{"ds", specs.DaemonSets},
+		{"job", specs.Jobs},
+		{"canary", specs.Canaries},
+	} {
+		for _, spec := range desc.specs {
+			mt.addResource(parentContext, desc.kind, spec)
+		}
+	}
+
+	if len(mt.resources) == 0 {
+		return nil
+	}
+	defer mt.cancelAll()
+
+	mt.emit(StatusEvent{Type: TrackingStartedEvent})
+
+	errorChan := make(chan error, len(mt.resources))
+	doneChan := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, res := range mt.resources {
+		wg.Add(1)
+
+		go func(res *trackedResource) {
+			defer wg.Done()
+
+			err := mt.trackResource(kubeClient, res, opts)
+			mt.resourceTrackingDone(res, err, errorChan)
+		}(res)
+	}
+
+	mt.mux.Lock()
+	mt.applyTrackTerminationMode()
+	mt.mux.Unlock()
+
+	go func() {
+		wg.Wait()
+		close(doneChan)
+	}()
+
+	var err error
+	select {
+	case err = <-errorChan:
+	case <-doneChan:
+		select {
+		case err = <-errorChan:
+		default:
+			mt.mux.Lock()
+			err = mt.formatFailedResourcesError()
+			mt.mux.Unlock()
+		}
+	}
+
+	if err != nil {
+		mt.emit(StatusEvent{Type: TrackingFailedEvent, Message: err.Error()})
+		return err
+	}
+
+	mt.emit(StatusEvent{Type: TrackingFinishedEvent})
+	return nil
+}
+
+func (mt *statusEventsMultitracker) addResource(parentContext context.Context, kind string, spec multitrack.MultitrackSpec) {
+	setDefaultMultitrackSpecValues(&spec)
+	if kind == "canary" {
+		*spec.AllowFailuresCount = 0
+	}
+
+	ctx, cancel := context.WithCancel(parentContext)
+	mt.resources = append(mt.resources, &trackedResource{
+		Kind:   kind,
+		Spec:   spec,
+		Status: trackedResourceActive,
+		ctx:    ctx,
+		cancel: cancel,
+	})
+}
+
+func setDefaultMultitrackSpecValues(spec *multitrack.MultitrackSpec) {
+	if spec.TrackTerminationMode == "" {
+		spec.TrackTerminationMode = multitrack.WaitUntilResourceReady
+	}
+
+	if spec.FailMode == "" {
+		spec.FailMode = multitrack.FailWholeDeployProcessImmediately
+	}
+
+	allowFailuresCount := new(int)
+	if spec.AllowFailuresCount == nil {
+		*allowFailuresCount = 1
+	} else {
+		*allowFailuresCount = *spec.AllowFailuresCount
+	}
+	spec.AllowFailuresCount = allowFailuresCount
+}
+
+func (mt *statusEventsMultitracker) trackResource(kubeClient kubernetes.Interface, res *trackedResource, opts multitrack.MultitrackOptions) error {
+	trackerOpts := tracker.Options{
+		ParentContext:                            res.ctx,
+		Timeout:                                  opts.Timeout,
+		LogsFromTime:                             opts.LogsFromTime,
+		IgnoreReadinessProbeFailsByContainerName: res.Spec.IgnoreReadinessProbeFailsByContainerName,
+	}
+
+	switch res.Kind {
+	case "deploy":
+		feed := deployment.NewFeed()
+		mt.setupControllerFeed(res, feed, true)
+		return feed.Track(res.Spec.ResourceName, res.Spec.Namespace, kubeClient, trackerOpts)
+	case "sts":
+		feed := statefulset.NewFeed()
+		mt.setupControllerFeed(res, feed, false)
+		return feed.Track(res.Spec.ResourceName, res.Spec.Namespace, kubeClient, trackerOpts)
+	case "ds":
+		feed := daemonset.NewFeed()
+		mt.setupControllerFeed(res, feed, false)
+		return feed.Track(res.Spec.ResourceName, res.Spec.Namespace, kubeClient, trackerOpts)
+	case "job":
+		feed := job.NewFeed()
+		mt.setupJobFeed(res, feed)
+		return feed.Track(res.Spec.ResourceName, res.Spec.Namespace, kubeClient, trackerOpts)
+	case "canary":
+		feed := canary.NewFeed()
+		mt.setupCanaryFeed(res, feed)
+		return feed.Track(res.Spec.ResourceName, res.Spec.Namespace, kubeClient, trackerOpts)
+	default:
+		panic(fmt.Sprintf("unexpected resource kind %q", res.Kind))
+	}
+}
+
+func (mt *statusEventsMultitracker) setupControllerFeed(res *trackedResource, feed controller.ControllerFeed, newReplicaSetOnly bool) {
+	feed.OnAdded(func(isReady bool) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceAddedEvent})
+		if isReady {
+			return mt.handleResourceReady(res)
+		}
+		return nil
+	})
+	feed.OnReady(func() error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceReady(res)
+	})
+	feed.OnFailed(func(reason string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceFailure(res, StatusEvent{Message: reason})
+	})
+	feed.OnEventMsg(func(msg string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceKubeEvent, Message: msg})
+		return nil
+	})
+	feed.OnAddedReplicaSet(func(replicaset.ReplicaSet) error {
+		return nil
+	})
+	feed.OnAddedPod(func(rsPod replicaset.ReplicaSetPod) error {
+		if newReplicaSetOnly && !rsPod.ReplicaSet.IsNew {
+			return nil
+		}
+
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourcePodAddedEvent, Pod: rsPod.Name})
+		return nil
+	})
+	feed.OnPodError(func(podError replicaset.ReplicaSetPodError) error {
+		if newReplicaSetOnly && !podError.ReplicaSet.IsNew {
+			return nil
+		}
+
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceFailure(res, StatusEvent{Pod: podError.PodName, Container: podError.ContainerName, Message: podError.Message})
+	})
+	feed.OnPodLogChunk(func(chunk *replicaset.ReplicaSetPodLogChunk) error {
+		if newReplicaSetOnly && !chunk.ReplicaSet.IsNew {
+			return nil
+		}
+
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.handleLogChunk(res, chunk.PodName, chunk.ContainerLogChunk)
+		return nil
+	})
+}
+
+func (mt *statusEventsMultitracker) setupJobFeed(res *trackedResource, feed job.Feed) {
+	feed.OnAdded(func() error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceAddedEvent})
+		return nil
+	})
+	feed.OnSucceeded(func() error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceReady(res)
+	})
+	feed.OnFailed(func(reason string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceFailure(res, StatusEvent{Message: reason})
+	})
+	feed.OnEventMsg(func(msg string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceKubeEvent, Message: msg})
+		return nil
+	})
+	feed.OnAddedPod(func(podName string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourcePodAddedEvent, Pod: podName})
+		return nil
+	})
+	feed.OnPodError(func(podError pod.PodError) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceFailure(res, StatusEvent{Pod: podError.PodName, Container: podError.ContainerName, Message: podError.Message})
+	})
+	feed.OnPodLogChunk(func(chunk *pod.PodLogChunk) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.handleLogChunk(res, chunk.PodName, chunk.ContainerLogChunk)
+		return nil
+	})
+}
+
+func (mt *statusEventsMultitracker) setupCanaryFeed(res *trackedResource, feed canary.Feed) {
+	feed.OnAdded(func() error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceAddedEvent})
+		return nil
+	})
+	feed.OnSucceeded(func() error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceReady(res)
+	})
+	feed.OnFailed(func(reason string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		return mt.handleResourceFailure(res, StatusEvent{Message: reason})
+	})
+	feed.OnEventMsg(func(msg string) error {
+		mt.mux.Lock()
+		defer mt.mux.Unlock()
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceKubeEvent, Message: msg})
+		return nil
+	})
+}
+
+func (mt *statusEventsMultitracker) handleResourceReady(res *trackedResource) error {
+	res.Status = trackedResourceSucceeded
+	mt.emitResourceEvent(res, StatusEvent{Type: ResourceReadyEvent})
+	return tracker.StopTrack
+}
+
+func (mt *statusEventsMultitracker) handleResourceFailure(res *trackedResource, failureEvent StatusEvent) error {
+	reason := failureEvent.Message
+	if failureEvent.Pod != "" {
+		reason = fmt.Sprintf("po/%s container/%s: %s", failureEvent.Pod, failureEvent.Container, failureEvent.Message)
+	}
+
+	failureEvent.Type = ResourceFailedEvent
+	mt.emitResourceEvent(res, failureEvent)
+
+	emitDecision := func(decision FailModeDecision, message string) {
+		mt.emitResourceEvent(res, StatusEvent{
+			Type:                 FailModeDecisionEvent,
+			Message:              message,
+			FailMode:             string(res.Spec.FailMode),
+			Decision:             decision,
+			FailuresCount:        res.FailuresCount,
+			AllowedFailuresCount: *res.Spec.AllowFailuresCount,
+		})
+	}
+
+	failResource := func() error {
+		res.Status = trackedResourceFailed
+		res.FailedReason = reason
+		emitDecision(FailWholeDeployProcessDecision, reason)
+		return multitrack.ErrFailWholeDeployProcessImmediately
+	}
+
+	switch res.Spec.FailMode {
+	case multitrack.FailWholeDeployProcessImmediately:
+		res.FailuresCount++
+
+		if strings.Contains(reason, "ErrImageNeverPull") {
+			return failResource()
+		}
+
+		if res.FailuresCount <= *res.Spec.AllowFailuresCount {
+			emitDecision(ContinueTrackingDecision, reason)
+			return nil
+		}
+
+		return failResource()
+
+	case multitrack.HopeUntilEndOfDeployProcess:
+		if res.Status == trackedResourceActive {
+			res.Status = trackedResourceHoping
+		}
+
+		if res.Status == trackedResourceHoping {
+			if activeResourcesNames := mt.getActiveResourcesNames(); len(activeResourcesNames) > 0 {
+				emitDecision(HopeUntilEndOfDeployDecision, fmt.Sprintf("waiting until following resources are ready before counting errors: %s", strings.Join(activeResourcesNames, ", ")))
+				return nil
+			}
+
+			res.Status = trackedResourceActiveAfterHoping
+		}
+
+		res.FailuresCount++
+
+		if res.FailuresCount <= *res.Spec.AllowFailuresCount {
+			emitDecision(ContinueTrackingDecision, reason)
+			return nil
+		}
+
+		return failResource()
+
+	case multitrack.IgnoreAndContinueDeployProcess:
+		res.FailuresCount++
+		emitDecision(IgnoreFailureDecision, reason)
+		return nil
+
+	default:
+		panic(fmt.Sprintf("bad fail mode %#v for resource %s", res.Spec.FailMode, res.Name()))
+	}
+}
+
+func (mt *statusEventsMultitracker) handleLogChunk(res *trackedResource, podName string, chunk *pod.ContainerLogChunk) {
+	if res.Spec.SkipLogs {
+		return
+	}
+
+	for _, containerName := range res.Spec.SkipLogsForContainers {
+		if containerName == chunk.ContainerName {
+			return
+		}
+	}
+
+	if len(res.Spec.ShowLogsOnlyForContainers) > 0 {
+		var showLogs bool
+		for _, containerName := range res.Spec.ShowLogsOnlyForContainers {
+			if containerName == chunk.ContainerName {
+				showLogs = true
+				break
+			}
+		}
+
+		if !showLogs {
+			return
+		}
+	}
+
+	var logRegexp *regexp.Regexp
+	if res.Spec.LogRegexByContainerName[chunk.ContainerName] != nil {
+		logRegexp = res.Spec.LogRegexByContainerName[chunk.ContainerName]
+	} else if res.Spec.LogRegex != nil {
+		logRegexp = res.Spec.LogRegex
+	}
+
+	for _, logLine := range chunk.LogLines {
+		if logRegexp != nil && logRegexp.FindString(logLine.Message) == "" {
+			continue
+		}
+
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceLogLineEvent, Pod: podName, Container: chunk.ContainerName, Message: logLine.Message})
+	}
+}
+
+func (mt *statusEventsMultitracker) resourceTrackingDone(res *trackedResource, err error, errorChan chan error) {
+	mt.mux.Lock()
+	defer mt.mux.Unlock()
+
+	res.IsDone = true
+
+	if err == multitrack.ErrFailWholeDeployProcessImmediately {
+		errorChan <- mt.formatFailedResourcesError()
+		return
+	} else if err != nil {
+		errorChan <- fmt.Errorf("%s track failed: %s", res.Name(), err)
+		return
+	}
+
+	if res.Status != trackedResourceSucceeded && res.Status != trackedResourceFailed {
+		mt.emitResourceEvent(res, StatusEvent{Type: ResourceTrackingStoppedEvent, Message: fmt.Sprintf("track termination mode %s", res.Spec.TrackTerminationMode)})
+	}
+
+	mt.applyTrackTerminationMode()
+}
+
+// applyTrackTerminationMode stops tracking of the rest resources when there are no resources
+// left with the WaitUntilResourceReady track termination mode.
+func (mt *statusEventsMultitracker) applyTrackTerminationMode() {
+	if mt.isTerminating {
+		return
+	}
+
+	for _, res := range mt.resources {
+		if !res.IsDone && res.Spec.TrackTerminationMode == multitrack.WaitUntilResourceReady {
+			return
+		}
+	}
+
+	mt.isTerminating = true
+
+	for _, res := range mt.resources {
+		if !res.IsDone {
+			res.cancel()
+		}
+	}
+}
+
+func (mt *statusEventsMultitracker) getActiveResourcesNames() []string {
+	var names []string
+	for _, res := range mt.resources {
+		if !res.IsDone && res.Status == trackedResourceActive {
+			names = append(names, res.Name())
+		}
+	}
+	return names
+}
+
+func (mt *statusEventsMultitracker) formatFailedResourcesError() error {
+	var msgParts []string
+	for _, res := range mt.resources {
+		if res.Status == trackedResourceFailed {
+			msgParts = append(msgParts, fmt.Sprintf("%s failed: %s", res.Name(), res.FailedReason))
+		}
+	}
+
+	if len(msgParts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(msgParts, "\n"))
+}
+
+func (mt *statusEventsMultitracker) cancelAll() {
+	mt.mux.Lock()
+	defer mt.mux.Unlock()
+
+	for _, res := range mt.resources {
+		res.cancel()
+	}
+}
+
+func (mt *statusEventsMultitracker) emitResourceEvent(res *trackedResource, event StatusEvent) {
+	event.Resource = res.Name()
+	event.Namespace = res.Spec.Namespace
+	mt.emit(event)
+}
+
+func (mt *statusEventsMultitracker) emit(event StatusEvent) {
+	// status events stream is best-effort and should not break resources tracking
+	_ = mt.events.Emit(event)
+}
@@ -0,0 +1,254 @@
+package helm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/kubedog/pkg/display"
+	"github.com/werf/kubedog/pkg/tracker"
+	"github.com/werf/kubedog/pkg/tracker/job"
+	"github.com/werf/kubedog/pkg/tracker/pod"
+	"github.com/werf/kubedog/pkg/trackers/rollout/multitrack"
+)
+
+// jobFeedStub records callbacks set up by the multitracker, so the test can call them as the kubedog job tracker does.
+type jobFeedStub struct {
+	job.Feed
+
+	onAdded       func() error
+	onSucceeded   func() error
+	onFailed      func(reason string) error
+	onEventMsg    func(msg string) error
+	onAddedPod    func(podName string) error
+	onPodLogChunk func(*pod.PodLogChunk) error
+	onPodError    func(pod.PodError) error
+}
+
+func (f *jobFeedStub) OnAdded(fn func() error)                       { f.onAdded = fn }
+func (f *jobFeedStub) OnSucceeded(fn func() error)                   { f.onSucceeded = fn }
+func (f *jobFeedStub) OnFailed(fn func(reason string) error)         { f.onFailed = fn }
+func (f *jobFeedStub) OnEventMsg(fn func(msg string) error)          { f.onEventMsg = fn }
+func (f *jobFeedStub) OnAddedPod(fn func(podName string) error)      { f.onAddedPod = fn }
+func (f *jobFeedStub) OnPodLogChunk(fn func(*pod.PodLogChunk) error) { f.onPodLogChunk = fn }
+func (f *jobFeedStub) OnPodError(fn func(pod.PodError) error)        { f.onPodError = fn }
+
+type statusEventsTestEnv struct {
+	out *bytes.Buffer
+	mt  *statusEventsMultitracker
+}
+
+func newStatusEventsTestEnv() *statusEventsTestEnv {
+	out := bytes.NewBuffer(nil)
+	return &statusEventsTestEnv{out: out, mt: newStatusEventsMultitracker(NewStatusEventsWriter(out))}
+}
+
+func (env *statusEventsTestEnv) addJob(spec multitrack.MultitrackSpec) (*trackedResource, *jobFeedStub) {
+	env.mt.addResource(context.Background(), "job", spec)
+	res := env.mt.resources[len(env.mt.resources)-1]
+
+	feed := &jobFeedStub{}
+	env.mt.setupJobFeed(res, feed)
+
+	return res, feed
+}
+
+// events returns emitted events without time and resets the output.
+func (env *statusEventsTestEnv) events() []StatusEvent {
+	var events []StatusEvent
+
+	scanner := bufio.NewScanner(env.out)
+	for scanner.Scan() {
+		var event StatusEvent
+		Expect(json.Unmarshal(scanner.Bytes(), &event)).To(Succeed())
+		Expect(event.Time.IsZero()).To(BeFalse())
+
+		event.Time = time.Time{}
+		events = append(events, event)
+	}
+	Expect(scanner.Err()).To(Succeed())
+
+	env.out.Reset()
+
+	return events
+}
+
+func intP(v int) *int {
+	return &v
+}
+
+func newPodError(podName, containerName, message string) pod.PodError {
+	return pod.PodError{PodName: podName, ContainerError: pod.ContainerError{ContainerName: containerName, Message: message}}
+}
+
+func newPodLogChunk(podName, containerName string, messages ...string) *pod.PodLogChunk {
+	chunk := &pod.PodLogChunk{PodName: podName, ContainerLogChunk: &pod.ContainerLogChunk{ContainerName: containerName}}
+	for _, msg := range messages {
+		chunk.LogLines = append(chunk.LogLines, display.LogLine{Message: msg})
+	}
+	return chunk
+}
+
+var _ = Describe("statusEventsMultitracker", func() {
+	var env *statusEventsTestEnv
+
+	BeforeEach(func() {
+		env = newStatusEventsTestEnv()
+	})
+
+	It("should map job feed callbacks to resource events", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", Namespace: "ns"})
+
+		Expect(feed.onAdded()).To(Succeed())
+		Expect(feed.onAddedPod("migrate-abc")).To(Succeed())
+		Expect(feed.onEventMsg("Created pod: migrate-abc")).To(Succeed())
+		Expect(feed.onSucceeded()).To(Equal(tracker.StopTrack))
+
+		Expect(res.Status).To(Equal(trackedResourceSucceeded))
+		Expect(env.events()).To(Equal([]StatusEvent{
+			{Type: ResourceAddedEvent, Resource: "job/migrate", Namespace: "ns"},
+			{Type: ResourcePodAddedEvent, Resource: "job/migrate", Namespace: "ns", Pod: "migrate-abc"},
+			{Type: ResourceKubeEvent, Resource: "job/migrate", Namespace: "ns", Message: "Created pod: migrate-abc"},
+			{Type: ResourceReadyEvent, Resource: "job/migrate", Namespace: "ns"},
+		}))
+	})
+
+	It("should continue tracking until allowed failures count is exceeded", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", Namespace: "ns"})
+
+		Expect(feed.onPodError(newPodError("migrate-abc", "main", "CrashLoopBackOff"))).To(Succeed())
+		Expect(env.events()).To(Equal([]StatusEvent{
+			{Type: ResourceFailedEvent, Resource: "job/migrate", Namespace: "ns", Pod: "migrate-abc", Container: "main", Message: "CrashLoopBackOff"},
+			{
+				Type: FailModeDecisionEvent, Resource: "job/migrate", Namespace: "ns",
+				Message:  "po/migrate-abc container/main: CrashLoopBackOff",
+				FailMode: string(multitrack.FailWholeDeployProcessImmediately), Decision: ContinueTrackingDecision,
+				FailuresCount: 1, AllowedFailuresCount: 1,
+			},
+		}))
+
+		Expect(feed.onFailed("BackoffLimitExceeded")).To(Equal(multitrack.ErrFailWholeDeployProcessImmediately))
+		Expect(env.events()).To(Equal([]StatusEvent{
+			{Type: ResourceFailedEvent, Resource: "job/migrate", Namespace: "ns", Message: "BackoffLimitExceeded"},
+			{
+				Type: FailModeDecisionEvent, Resource: "job/migrate", Namespace: "ns",
+				Message:  "BackoffLimitExceeded",
+				FailMode: string(multitrack.FailWholeDeployProcessImmediately), Decision: FailWholeDeployProcessDecision,
+				FailuresCount: 2, AllowedFailuresCount: 1,
+			},
+		}))
+
+		Expect(res.Status).To(Equal(trackedResourceFailed))
+		Expect(env.mt.formatFailedResourcesError()).To(MatchError("job/migrate failed: BackoffLimitExceeded"))
+	})
+
+	It("should fail immediately on ErrImageNeverPull regardless of allowed failures count", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", AllowFailuresCount: intP(5)})
+
+		Expect(feed.onPodError(newPodError("migrate-abc", "main", "ErrImageNeverPull"))).To(Equal(multitrack.ErrFailWholeDeployProcessImmediately))
+		Expect(res.Status).To(Equal(trackedResourceFailed))
+
+		events := env.events()
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].Decision).To(Equal(FailWholeDeployProcessDecision))
+	})
+
+	It("should report ignored failures without failing the resource", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", FailMode: multitrack.IgnoreAndContinueDeployProcess})
+
+		for i := 0; i < 3; i++ {
+			Expect(feed.onFailed("BackoffLimitExceeded")).To(Succeed())
+		}
+
+		Expect(res.Status).To(Equal(trackedResourceActive))
+
+		events := env.events()
+		Expect(events).To(HaveLen(6))
+		Expect(events[5].Decision).To(Equal(IgnoreFailureDecision))
+		Expect(events[5].FailuresCount).To(Equal(3))
+	})
+
+	It("should hope until other resources are ready and count failures afterwards", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", FailMode: multitrack.HopeUntilEndOfDeployProcess, AllowFailuresCount: intP(0)})
+		_, otherFeed := env.addJob(multitrack.MultitrackSpec{ResourceName: "other"})
+
+		Expect(feed.onFailed("BackoffLimitExceeded")).To(Succeed())
+		Expect(res.Status).To(Equal(trackedResourceHoping))
+		Expect(res.FailuresCount).To(Equal(0))
+
+		events := env.events()
+		Expect(events).To(HaveLen(2))
+		Expect(events[1].Decision).To(Equal(HopeUntilEndOfDeployDecision))
+		Expect(events[1].Message).To(Equal("waiting until following resources are ready before counting errors: job/other"))
+
+		Expect(otherFeed.onSucceeded()).To(Equal(tracker.StopTrack))
+		env.mt.resources[1].IsDone = true
+		env.events()
+
+		Expect(feed.onFailed("BackoffLimitExceeded")).To(Equal(multitrack.ErrFailWholeDeployProcessImmediately))
+		Expect(res.Status).To(Equal(trackedResourceFailed))
+		Expect(res.FailuresCount).To(Equal(1))
+	})
+
+	It("should filter log lines by containers and regexps", func() {
+		_, feed := env.addJob(multitrack.MultitrackSpec{
+			ResourceName:              "migrate",
+			ShowLogsOnlyForContainers: []string{"main", "sidecar"},
+			SkipLogsForContainers:     []string{"sidecar"},
+			LogRegexByContainerName:   map[string]*regexp.Regexp{"main": regexp.MustCompile("^migrated")},
+		})
+
+		Expect(feed.onPodLogChunk(newPodLogChunk("migrate-abc", "main", "starting", "migrated 1", "migrated 2"))).To(Succeed())
+		Expect(feed.onPodLogChunk(newPodLogChunk("migrate-abc", "sidecar", "migrated 3"))).To(Succeed())
+		Expect(feed.onPodLogChunk(newPodLogChunk("migrate-abc", "init", "migrated 4"))).To(Succeed())
+
+		Expect(env.events()).To(Equal([]StatusEvent{
+			{Type: ResourceLogLineEvent, Resource: "job/migrate", Pod: "migrate-abc", Container: "main", Message: "migrated 1"},
+			{Type: ResourceLogLineEvent, Resource: "job/migrate", Pod: "migrate-abc", Container: "main", Message: "migrated 2"},
+		}))
+	})
+
+	It("should not emit log lines when logs are skipped", func() {
+		_, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", SkipLogs: true})
+
+		Expect(feed.onPodLogChunk(newPodLogChunk("migrate-abc", "main", "migrated"))).To(Succeed())
+		Expect(env.events()).To(BeEmpty())
+	})
+
+	It("should stop non-blocking resources tracking when blocking resources are done", func() {
+		blocking, _ := env.addJob(multitrack.MultitrackSpec{ResourceName: "blocking"})
+		nonBlocking, _ := env.addJob(multitrack.MultitrackSpec{ResourceName: "non-blocking", TrackTerminationMode: multitrack.NonBlocking})
+
+		env.mt.applyTrackTerminationMode()
+		Expect(nonBlocking.ctx.Err()).To(BeNil())
+
+		blocking.Status = trackedResourceSucceeded
+		errorChan := make(chan error, 2)
+		env.mt.resourceTrackingDone(blocking, nil, errorChan)
+		Expect(nonBlocking.ctx.Err()).To(Equal(context.Canceled))
+
+		env.mt.resourceTrackingDone(nonBlocking, nil, errorChan)
+		Expect(errorChan).To(BeEmpty())
+		Expect(env.events()).To(Equal([]StatusEvent{
+			{Type: ResourceTrackingStoppedEvent, Resource: "job/non-blocking", Message: "track termination mode NonBlocking"},
+		}))
+	})
+
+	It("should report tracking errors and failed resources through the error channel", func() {
+		res, feed := env.addJob(multitrack.MultitrackSpec{ResourceName: "migrate", AllowFailuresCount: intP(0)})
+		errorChan := make(chan error, 1)
+
+		Expect(feed.onFailed("BackoffLimitExceeded")).To(Equal(multitrack.ErrFailWholeDeployProcessImmediately))
+		env.mt.resourceTrackingDone(res, multitrack.ErrFailWholeDeployProcessImmediately, errorChan)
+		Expect(<-errorChan).To(MatchError("job/migrate failed: BackoffLimitExceeded"))
+
+		env.mt.resourceTrackingDone(res, context.DeadlineExceeded, errorChan)
+		Expect(<-errorChan).To(MatchError("job/migrate track failed: context deadline exceeded"))
+	})
+})
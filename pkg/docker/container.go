@@ -1,9 +1,12 @@
 package docker
 
 import (
+	"io"
+
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/command/container"
 	"github.com/docker/docker/api/types"
+	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"golang.org/x/net/context"
 )
@@ -39,6 +42,25 @@ func ContainerCommit(ctx context.Context, ref string, commitOptions types.Contai
 	return response.ID, nil
 }
 
+func ContainerCreate(ctx context.Context, config *containertypes.Config, hostConfig *containertypes.HostConfig, containerName string) (string, error) {
+	response, err := apiCli(ctx).ContainerCreate(ctx, config, hostConfig, nil, nil, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	return response.ID, nil
+}
+
+func ContainerStatPath(ctx context.Context, ref, path string) (types.ContainerPathStat, error) {
+	return apiCli(ctx).ContainerStatPath(ctx, ref, path)
+}
+
+// CopyFromContainer returns the tar archive with the file or directory of the container filesystem.
+func CopyFromContainer(ctx context.Context, ref, path string) (io.ReadCloser, error) {
+	rc, _, err := apiCli(ctx).CopyFromContainer(ctx, ref, path)
+	return rc, err
+}
+
 func ContainerRemove(ctx context.Context, ref string, options types.ContainerRemoveOptions) error {
 	return apiCli(ctx).ContainerRemove(ctx, ref, options)
 }
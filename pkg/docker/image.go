@@ -3,6 +3,7 @@ package docker
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"strings"
@@ -12,7 +13,9 @@ import (
 	"github.com/docker/cli/cli/command/image"
 	"github.com/docker/cli/cli/streams"
 	"github.com/docker/docker/api/types"
+	imagetypes "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"golang.org/x/net/context"
 
 	"github.com/werf/logboek"
@@ -65,6 +68,53 @@ func ImageInspect(ctx context.Context, ref string) (*types.ImageInspect, error)
 	return &inspect, nil
 }
 
+func ImageHistory(ctx context.Context, ref string) ([]imagetypes.HistoryResponseItem, error) {
+	return apiCli(ctx).ImageHistory(ctx, ref)
+}
+
+// ImageSaveReader returns the image stream in the docker save format.
+func ImageSaveReader(ctx context.Context, ref string) (io.ReadCloser, error) {
+	return apiCli(ctx).ImageSave(ctx, []string{ref})
+}
+
+// ImageSave writes the image in the docker save format into the file.
+func ImageSave(ctx context.Context, ref, path string) error {
+	rc, err := ImageSaveReader(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// ImageLoad loads the images from the file in the docker save format.
+func ImageLoad(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	resp, err := apiCli(ctx).ImageLoad(ctx, f, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return jsonmessage.DisplayJSONMessagesStream(resp.Body, ioutil.Discard, 0, false, nil)
+}
+
 func doCliPull(c command.Cli, args ...string) error {
 	return prepareCliCmd(image.NewPullCommand(c), args...).Execute()
 }
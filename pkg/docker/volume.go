@@ -1,9 +1,25 @@
 package docker
 
 import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	volumetypes "github.com/docker/docker/api/types/volume"
 	"golang.org/x/net/context"
 )
 
+func VolumeCreate(ctx context.Context, options volumetypes.VolumeCreateBody) (types.Volume, error) {
+	return apiCli(ctx).VolumeCreate(ctx, options)
+}
+
+func VolumeList(ctx context.Context, filterSet filters.Args) ([]*types.Volume, error) {
+	resp, err := apiCli(ctx).VolumeList(ctx, filterSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Volumes, nil
+}
+
 func VolumeRm(ctx context.Context, volumeName string, force bool) error {
 	return apiCli(ctx).VolumeRemove(ctx, volumeName, force)
 }
@@ -1,9 +1,12 @@
 package docker_registry
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
@@ -14,8 +17,10 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 	"github.com/google/go-containerregistry/pkg/v1/mutate"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/werf/logboek"
 
@@ -140,6 +145,16 @@ func (api *api) GetRepoImage(_ context.Context, reference string) (*image.Info,
 	return repoImage, nil
 }
 
+// GetRepoImageLayers returns the image layers from the base one, the layers blobs are fetched on reading.
+func (api *api) GetRepoImageLayers(_ context.Context, reference string) ([]v1.Layer, error) {
+	img, _, err := api.image(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return img.Layers()
+}
+
 func (api *api) list(reference string, extraListOptions ...remote.Option) ([]string, error) {
 	repo, err := name.NewRepository(reference, api.newRepositoryOptions()...)
 	if err != nil {
@@ -357,3 +372,269 @@ func (api *api) ParseReferenceParts(reference string) (referenceParts, error) {
 
 	return referenceParts, nil
 }
+
+// CopyImage copies image or image index manifest with all referenced blobs as is, so the destination has the same digest.
+func (api *api) CopyImage(_ context.Context, sourceReference, destinationReference string) error {
+	srcRef, err := name.ParseReference(sourceReference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", sourceReference, err)
+	}
+
+	dstRef, err := name.ParseReference(destinationReference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", destinationReference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	desc, err := remote.Get(srcRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return fmt.Errorf("reading image %q: %v", srcRef, err)
+	}
+
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return fmt.Errorf("reading image index %q: %v", srcRef, err)
+		}
+
+		if err := remote.WriteIndex(dstRef, index, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+			return fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+		}
+	} else {
+		img, err := desc.Image()
+		if err != nil {
+			return fmt.Errorf("reading image %q: %v", srcRef, err)
+		}
+
+		if err := remote.Write(dstRef, img, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+			return fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+		}
+	}
+
+	dstDesc, err := remote.Head(dstRef, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return fmt.Errorf("reading image %q: %v", dstRef, err)
+	}
+
+	if dstDesc.Digest != desc.Digest {
+		return fmt.Errorf("digest of the copied image %s %s does not match the source image digest %s", dstRef, dstDesc.Digest, desc.Digest)
+	}
+
+	return nil
+}
+
+// WriteImageIntoOCILayout appends image or image index into the OCI layout as is, the reference is saved
+// into the org.opencontainers.image.ref.name annotation of the layout index.
+func (api *api) WriteImageIntoOCILayout(_ context.Context, reference string, layoutPath layout.Path) error {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return fmt.Errorf("reading image %q: %v", ref, err)
+	}
+
+	annotations := layout.WithAnnotations(map[string]string{imagespec.AnnotationRefName: reference})
+
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return fmt.Errorf("reading image index %q: %v", ref, err)
+		}
+		return layoutPath.AppendIndex(index, annotations)
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return fmt.Errorf("reading image %q: %v", ref, err)
+	}
+	return layoutPath.AppendImage(img, annotations)
+}
+
+// PushImageFromOCILayout pushes image or image index saved by WriteImageIntoOCILayout with the specified layout reference
+// into the destination reference without changes, so the pushed image has the same digest.
+func (api *api) PushImageFromOCILayout(_ context.Context, layoutPath layout.Path, layoutReference, destinationReference string) error {
+	dstRef, err := name.ParseReference(destinationReference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", destinationReference, err)
+	}
+
+	layoutIndex, err := layoutPath.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("reading OCI layout %q: %v", layoutPath, err)
+	}
+
+	indexManifest, err := layoutIndex.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("reading OCI layout %q index: %v", layoutPath, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	for _, desc := range indexManifest.Manifests {
+		if desc.Annotations[imagespec.AnnotationRefName] != layoutReference {
+			continue
+		}
+
+		if desc.MediaType.IsIndex() {
+			index, err := layoutIndex.ImageIndex(desc.Digest)
+			if err != nil {
+				return fmt.Errorf("reading image index %q from OCI layout: %v", layoutReference, err)
+			}
+
+			if err := remote.WriteIndex(dstRef, index, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+				return fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+			}
+			return nil
+		}
+
+		img, err := layoutIndex.Image(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("reading image %q from OCI layout: %v", layoutReference, err)
+		}
+
+		if err := remote.Write(dstRef, img, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+			return fmt.Errorf("write to the remote %s have failed: %s", dstRef.String(), err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("image %q not found in OCI layout %q", layoutReference, layoutPath)
+}
+
+// GetImageDigest returns digest of the image or image index manifest without downloading the manifest.
+func (api *api) GetImageDigest(_ context.Context, reference string) (string, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return "", fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	desc, err := remote.Head(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return "", fmt.Errorf("reading image %q: %v", ref, err)
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// GetImageManifest returns the image manifest, only annotations are set for the image index.
+func (api *api) GetImageManifest(_ context.Context, reference string) (*v1.Manifest, error) {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("reading image %q: %v", ref, err)
+	}
+
+	manifest, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse image %q manifest: %s", ref, err)
+	}
+
+	return manifest, nil
+}
+
+// GetBlob returns content of the blob by the digest reference REPO@DIGEST, the content is verified against the digest.
+func (api *api) GetBlob(_ context.Context, reference string) ([]byte, error) {
+	ref, err := name.NewDigest(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	layer, err := remote.Layer(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %q: %v", ref, err)
+	}
+
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %q: %v", ref, err)
+	}
+	defer rc.Close()
+
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %q: %v", ref, err)
+	}
+
+	return data, nil
+}
+
+// SetImageAnnotations merges annotations into the image or image index manifest and pushes the manifest back
+// by the same reference. Referenced blobs are not changed, but the manifest digest is.
+func (api *api) SetImageAnnotations(_ context.Context, reference string, annotations map[string]string) error {
+	ref, err := name.ParseReference(reference, api.parseReferenceOptions()...)
+	if err != nil {
+		return fmt.Errorf("parsing reference %q: %v", reference, err)
+	}
+
+	oldDefaultTransport := http.DefaultTransport
+	http.DefaultTransport = api.getHttpTransport()
+	defer func() { http.DefaultTransport = oldDefaultTransport }()
+
+	desc, err := remote.Get(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return fmt.Errorf("reading image %q: %v", ref, err)
+	}
+
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+		return fmt.Errorf("unable to unmarshal image %q manifest: %s", ref, err)
+	}
+
+	newAnnotations := map[string]interface{}{}
+	if oldAnnotations, ok := manifest["annotations"].(map[string]interface{}); ok {
+		newAnnotations = oldAnnotations
+	}
+	for k, v := range annotations {
+		newAnnotations[k] = v
+	}
+	manifest["annotations"] = newAnnotations
+
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("unable to marshal image %q manifest: %s", ref, err)
+	}
+
+	digest, size, err := v1.SHA256(bytes.NewReader(rawManifest))
+	if err != nil {
+		return fmt.Errorf("unable to calculate image %q manifest digest: %s", ref, err)
+	}
+
+	newDesc := &remote.Descriptor{
+		Descriptor: v1.Descriptor{MediaType: desc.MediaType, Size: size, Digest: digest},
+		Manifest:   rawManifest,
+	}
+
+	if err := remote.Put(ref, newDesc, remote.WithAuthFromKeychain(authn.DefaultKeychain)); err != nil {
+		return fmt.Errorf("write to the remote %s have failed: %s", ref.String(), err)
+	}
+
+	return nil
+}
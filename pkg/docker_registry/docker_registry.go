@@ -19,6 +19,7 @@ type DockerRegistry interface {
 	Tags(ctx context.Context, reference string) ([]string, error)
 	GetRepoImage(ctx context.Context, reference string) (*image.Info, error)
 	TryGetRepoImage(ctx context.Context, reference string) (*image.Info, error)
+	GetRepoImageLayers(ctx context.Context, reference string) ([]v1.Layer, error)
 	IsRepoImageExists(ctx context.Context, reference string) (bool, error)
 	DeleteRepoImage(ctx context.Context, repoImage *image.Info) error
 	PushImage(ctx context.Context, reference string, opts *PushImageOptions) error
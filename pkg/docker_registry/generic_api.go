@@ -7,6 +7,7 @@ import (
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
 
 	"github.com/werf/werf/pkg/docker"
 	"github.com/werf/werf/pkg/image"
@@ -40,6 +41,38 @@ func (api *genericApi) MutateAndPushImage(ctx context.Context, sourceReference,
 	return api.commonApi.MutateAndPushImage(ctx, sourceReference, destinationReference, mutateConfigFunc)
 }
 
+func (api *genericApi) CopyImage(ctx context.Context, sourceReference, destinationReference string) error {
+	return api.commonApi.CopyImage(ctx, sourceReference, destinationReference)
+}
+
+func (api *genericApi) WriteImageIntoOCILayout(ctx context.Context, reference string, layoutPath layout.Path) error {
+	return api.commonApi.WriteImageIntoOCILayout(ctx, reference, layoutPath)
+}
+
+func (api *genericApi) PushImageFromOCILayout(ctx context.Context, layoutPath layout.Path, layoutReference, destinationReference string) error {
+	return api.commonApi.PushImageFromOCILayout(ctx, layoutPath, layoutReference, destinationReference)
+}
+
+func (api *genericApi) Tags(ctx context.Context, reference string) ([]string, error) {
+	return api.commonApi.Tags(ctx, reference)
+}
+
+func (api *genericApi) GetImageDigest(ctx context.Context, reference string) (string, error) {
+	return api.commonApi.GetImageDigest(ctx, reference)
+}
+
+func (api *genericApi) GetImageManifest(ctx context.Context, reference string) (*v1.Manifest, error) {
+	return api.commonApi.GetImageManifest(ctx, reference)
+}
+
+func (api *genericApi) GetBlob(ctx context.Context, reference string) ([]byte, error) {
+	return api.commonApi.GetBlob(ctx, reference)
+}
+
+func (api *genericApi) SetImageAnnotations(ctx context.Context, reference string, annotations map[string]string) error {
+	return api.commonApi.SetImageAnnotations(ctx, reference, annotations)
+}
+
 func (api *genericApi) GetRepoImageConfigFile(ctx context.Context, reference string) (*v1.ConfigFile, error) {
 	mirrorReferenceList, err := api.mirrorReferenceList(reference)
 	if err != nil {
@@ -79,6 +79,25 @@ func (repo *Base) TagCommit(ctx context.Context, branch string) (string, error)
 	panic("not implemented")
 }
 
+// getTagCommit returns the commit of the lightweight or annotated tag.
+func getTagCommit(rawRepo *git.Repository, tag string) (string, error) {
+	ref, err := rawRepo.Tag(tag)
+	if err != nil {
+		return "", err
+	}
+
+	obj, err := rawRepo.TagObject(ref.Hash())
+	switch err {
+	case nil:
+		// Tag object present
+		return obj.Target.String(), nil
+	case plumbing.ErrObjectNotFound:
+		return ref.Hash().String(), nil
+	default:
+		return "", err
+	}
+}
+
 func (repo *Base) remoteOriginUrl(repoPath string) (string, error) {
 	repository, err := git.PlainOpenWithOptions(repoPath, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
 	if err != nil {
@@ -243,8 +262,14 @@ func (repo *Base) createPatch(ctx context.Context, repoPath, gitDir, repoID, wor
 }
 
 func HasSubmodulesInCommit(commit *object.Commit) (bool, error) {
-	_, err := commit.File(".gitmodules")
-	if err == object.ErrFileNotFound {
+	// the tree entry is checked without reading the blob which may be missing in the partial clone
+	tree, err := commit.Tree()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tree.FindEntry(".gitmodules")
+	if err == object.ErrEntryNotFound {
 		return false, nil
 	}
 	if err != nil {
@@ -253,7 +278,7 @@ func HasSubmodulesInCommit(commit *object.Commit) (bool, error) {
 	return true, nil
 }
 
-func (repo *Base) createDetachedMergeCommit(ctx context.Context, gitDir, path, workTreeCacheDir string, fromCommit, toCommit string) (string, error) {
+func (repo *Base) createDetachedMergeCommit(ctx context.Context, gitDir, path, workTreeCacheDir string, fromCommit, toCommit string, sparseCheckoutPatterns []string) (string, error) {
 	if lock, err := CommonGitDataManager.LockGC(ctx, true); err != nil {
 		return "", err
 	} else {
@@ -277,7 +302,7 @@ func (repo *Base) createDetachedMergeCommit(ctx context.Context, gitDir, path, w
 		return "", err
 	}
 
-	return true_git.CreateDetachedMergeCommit(ctx, gitDir, workTreeCacheDir, fromCommit, toCommit, true_git.CreateDetachedMergeCommitOptions{HasSubmodules: hasSubmodules})
+	return true_git.CreateDetachedMergeCommit(ctx, gitDir, workTreeCacheDir, fromCommit, toCommit, true_git.CreateDetachedMergeCommitOptions{HasSubmodules: hasSubmodules, SparseCheckoutPatterns: sparseCheckoutPatterns})
 }
 
 func (repo *Base) getMergeCommitParents(gitDir, commit string) ([]string, error) {
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/types"
@@ -190,7 +191,7 @@ func (repo *Local) IsShallowClone() (bool, error) {
 }
 
 func (repo *Local) CreateDetachedMergeCommit(ctx context.Context, fromCommit, toCommit string) (string, error) {
-	return repo.createDetachedMergeCommit(ctx, repo.GitDir, repo.WorkTreeDir, repo.getRepoWorkTreeCacheDir(repo.getRepoID()), fromCommit, toCommit)
+	return repo.createDetachedMergeCommit(ctx, repo.GitDir, repo.WorkTreeDir, repo.getRepoWorkTreeCacheDir(repo.getRepoID()), fromCommit, toCommit, nil)
 }
 
 func (repo *Local) GetMergeCommitParents(_ context.Context, commit string) ([]string, error) {
@@ -221,6 +222,11 @@ func (repo *Local) IsAncestor(_ context.Context, ancestorCommit, descendantCommi
 	return true_git.IsAncestor(ancestorCommit, descendantCommit, repo.GitDir)
 }
 
+// CountCommits returns the number of commits reachable from the commit but not from the excluded commit (all reachable commits if the excluded commit is empty).
+func (repo *Local) CountCommits(_ context.Context, commit, excludedCommit string) (int, error) {
+	return true_git.CountCommits(commit, excludedCommit, repo.GitDir)
+}
+
 func (repo *Local) RemoteOriginUrl(_ context.Context) (string, error) {
 	return repo.remoteOriginUrl(repo.WorkTreeDir)
 }
@@ -250,6 +256,20 @@ func (repo *Local) IsCommitExists(ctx context.Context, commit string) (bool, err
 	return repo.isCommitExists(ctx, repo.WorkTreeDir, repo.GitDir, commit)
 }
 
+func (repo *Local) TagCommit(_ context.Context, tag string) (string, error) {
+	rawRepo, err := git.PlainOpenWithOptions(repo.WorkTreeDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return "", fmt.Errorf("cannot open repo %q: %s", repo.WorkTreeDir, err)
+	}
+
+	commit, err := getTagCommit(rawRepo, tag)
+	if err != nil {
+		return "", fmt.Errorf("bad tag %q of repo %s: %s", tag, repo.String(), err)
+	}
+
+	return commit, nil
+}
+
 func (repo *Local) TagsList(_ context.Context) ([]string, error) {
 	return repo.tagsList(repo.WorkTreeDir)
 }
@@ -485,7 +505,7 @@ func (repo *Local) initRepoHandleBackedByWorkTree(ctx context.Context, commit st
 				return err
 			}
 
-			repoHandle, err = repo_handle.NewHandle(repositoryWithPreparedWorktree)
+			repoHandle, err = repo_handle.NewHandleWithMissingBlobReader(repositoryWithPreparedWorktree, repo.readMissingBlob)
 			return err
 		}); err != nil {
 			return nil, err
@@ -493,10 +513,15 @@ func (repo *Local) initRepoHandleBackedByWorkTree(ctx context.Context, commit st
 
 		return repoHandle, nil
 	} else {
-		return repo_handle.NewHandle(repository)
+		return repo_handle.NewHandleWithMissingBlobReader(repository, repo.readMissingBlob)
 	}
 }
 
+// readMissingBlob reads the blob with git, which fetches the missing blob if the repository is a partial clone.
+func (repo *Local) readMissingBlob(hash plumbing.Hash) ([]byte, error) {
+	return true_git.ReadBlob(repo.GitDir, hash.String())
+}
+
 func debugGiterminismManager() bool {
 	return os.Getenv("WERF_DEBUG_GITERMINISM_MANAGER") == "1"
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"time"
 
 	"gopkg.in/ini.v1"
@@ -13,6 +14,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
 
 	"github.com/werf/lockgate"
 	"github.com/werf/logboek"
@@ -24,18 +26,42 @@ import (
 	"github.com/werf/werf/pkg/werf"
 )
 
+const partialCloneFilter = "blob:none"
+
 type Remote struct {
 	*Base
 	Url      string
 	IsDryRun bool
+	RemoteOptions
 
 	Endpoint *transport.Endpoint
 }
 
-func OpenRemoteRepo(name, url string) (*Remote, error) {
-	repo := &Remote{Url: url}
+type RemoteOptions struct {
+	// Username and Token are used for the basic authorization over http(s), ssh-agent or the system git configuration is used if the token is empty.
+	Username string
+	Token    string
+
+	// PartialClone enables the clone without file contents, contents are fetched on demand only for the files from SparseCheckoutPatterns.
+	PartialClone           bool
+	SparseCheckoutPatterns []string
+}
+
+func OpenRemoteRepo(name, url string, opts RemoteOptions) (*Remote, error) {
+	repo := &Remote{Url: url, RemoteOptions: opts}
 	repo.Base = NewBase(name, repo.initRepoHandleBackedByWorkTree)
-	return repo, repo.ValidateEndpoint()
+
+	if err := repo.ValidateEndpoint(); err != nil {
+		return repo, err
+	}
+
+	if repo.Token != "" {
+		if err := true_git.SetHTTPBasicAuth(repo.Url, repo.Username, repo.Token); err != nil {
+			return repo, err
+		}
+	}
+
+	return repo, nil
 }
 
 func (repo *Remote) ValidateEndpoint() error {
@@ -48,7 +74,7 @@ func (repo *Remote) ValidateEndpoint() error {
 }
 
 func (repo *Remote) CreateDetachedMergeCommit(ctx context.Context, fromCommit, toCommit string) (string, error) {
-	return repo.createDetachedMergeCommit(ctx, repo.GetClonePath(), repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), fromCommit, toCommit)
+	return repo.createDetachedMergeCommit(ctx, repo.GetClonePath(), repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), fromCommit, toCommit, repo.SparseCheckoutPatterns)
 }
 
 func (repo *Remote) GetMergeCommitParents(_ context.Context, commit string) ([]string, error) {
@@ -167,12 +193,24 @@ func (repo *Remote) Clone(ctx context.Context) (bool, error) {
 		// Ensure cleanup on failure
 		defer os.RemoveAll(tmpPath)
 
-		_, err = git.PlainClone(tmpPath, true, &git.CloneOptions{
-			URL:               repo.Url,
-			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-		})
-		if err != nil {
-			return err
+		if repo.PartialClone {
+			if err := true_git.Clone(ctx, repo.Url, tmpPath, true_git.CloneOptions{Bare: true, Filter: partialCloneFilter}); err != nil {
+				return fmt.Errorf("unable to clone %s: %s", repo.Url, err)
+			}
+
+			// the bare clone does not create remote-tracking branches which are used to get the latest branch commit
+			if err := repo.fetchPartialClone(ctx, tmpPath); err != nil {
+				return err
+			}
+		} else {
+			_, err = git.PlainClone(tmpPath, true, &git.CloneOptions{
+				URL:               repo.Url,
+				Auth:              repo.getAuth(),
+				RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			})
+			if err != nil {
+				return err
+			}
 		}
 
 		if err := repo.updateLastAccessAt(ctx, tmpPath); err != nil {
@@ -218,7 +256,11 @@ func (repo *Remote) Fetch(ctx context.Context) error {
 
 		logboek.Context(ctx).Default().LogFDetails("Fetch remote %s of %s\n", remoteName, repo.Url)
 
-		err = rawRepo.Fetch(&git.FetchOptions{RemoteName: remoteName, Force: true, Tags: git.AllTags})
+		if repo.PartialClone {
+			return repo.fetchPartialClone(ctx, repo.GetClonePath())
+		}
+
+		err = rawRepo.Fetch(&git.FetchOptions{RemoteName: remoteName, Force: true, Tags: git.AllTags, Auth: repo.getAuth()})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			return fmt.Errorf("cannot fetch remote %q of repo %q: %s", remoteName, repo.String(), err)
 		}
@@ -227,6 +269,26 @@ func (repo *Remote) Fetch(ctx context.Context) error {
 	})
 }
 
+func (repo *Remote) fetchPartialClone(ctx context.Context, repoPath string) error {
+	if err := true_git.Fetch(ctx, repoPath, true_git.FetchOptions{
+		TagsOnly: true,
+		Force:    true,
+		RefSpecs: map[string]string{"origin": "+refs/heads/*:refs/remotes/origin/*"},
+	}); err != nil {
+		return fmt.Errorf("cannot fetch remote origin of repo %q: %s", repo.String(), err)
+	}
+
+	return nil
+}
+
+func (repo *Remote) getAuth() transport.AuthMethod {
+	if repo.Token == "" {
+		return nil
+	}
+
+	return &http.BasicAuth{Username: repo.Username, Password: repo.Token}
+}
+
 func (repo *Remote) HeadCommit(_ context.Context) (string, error) {
 	return getHeadCommit(repo.GetClonePath())
 }
@@ -283,34 +345,23 @@ func (repo *Remote) TagCommit(ctx context.Context, tag string) (string, error) {
 		return "", fmt.Errorf("cannot open repo: %s", err)
 	}
 
-	ref, err := rawRepo.Tag(tag)
+	res, err := getTagCommit(rawRepo, tag)
 	if err != nil {
 		return "", fmt.Errorf("bad tag %q of repo %s: %s", tag, repo.String(), err)
 	}
 
-	var res string
-
-	obj, err := rawRepo.TagObject(ref.Hash())
-	switch err {
-	case nil:
-		// Tag object present
-		res = obj.Target.String()
-	case plumbing.ErrObjectNotFound:
-		res = ref.Hash().String()
-	default:
-		return "", fmt.Errorf("bad tag %q of repo %s: %s", tag, repo.String(), err)
-	}
-
 	logboek.Context(ctx).Info().LogF("Using commit %q of repo %q tag %q\n", res, repo.String(), tag)
 
 	return res, nil
 }
 
 func (repo *Remote) GetOrCreatePatch(ctx context.Context, opts PatchOptions) (Patch, error) {
+	opts.SparseCheckoutPatterns = repo.SparseCheckoutPatterns
 	return repo.getOrCreatePatch(ctx, repo.GetClonePath(), repo.GetClonePath(), repo.getRepoID(), repo.getWorkTreeCacheDir(repo.getRepoID()), opts)
 }
 
 func (repo *Remote) GetOrCreateArchive(ctx context.Context, opts ArchiveOptions) (Archive, error) {
+	opts.SparseCheckoutPatterns = repo.SparseCheckoutPatterns
 	return repo.getOrCreateArchive(ctx, repo.GetClonePath(), repo.GetClonePath(), repo.getRepoID(), repo.getWorkTreeCacheDir(repo.getRepoID()), opts)
 }
 
@@ -323,15 +374,71 @@ func (repo *Remote) GetOrCreateChecksum(ctx context.Context, opts ChecksumOption
 	return
 }
 
+// ReferenceType is the type of the reference resolved by Remote.ResolveReference.
+type ReferenceType string
+
+const (
+	TagReference    ReferenceType = "tag"
+	BranchReference ReferenceType = "branch"
+	CommitReference ReferenceType = "commit"
+)
+
+var commitReferenceRegexp = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// ResolveReference resolves the tag, the branch or the full or abbreviated commit hash (in this order) to the commit.
+func (repo *Remote) ResolveReference(ctx context.Context, ref string) (string, ReferenceType, error) {
+	tags, err := repo.TagsList(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get tags of repo %s: %s", repo.String(), err)
+	}
+
+	if util.IsStringsContainValue(tags, ref) {
+		commit, err := repo.TagCommit(ctx, ref)
+		return commit, TagReference, err
+	}
+
+	branches, err := repo.RemoteBranchesList(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get branches of repo %s: %s", repo.String(), err)
+	}
+
+	if util.IsStringsContainValue(branches, ref) {
+		commit, err := repo.LatestBranchCommit(ctx, ref)
+		return commit, BranchReference, err
+	}
+
+	if commitReferenceRegexp.MatchString(ref) {
+		commit, err := true_git.ResolveCommit(ref, repo.GetClonePath())
+		if err != nil {
+			return "", "", err
+		}
+
+		if commit != "" {
+			return commit, CommitReference, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("reference %q is not found in repo %s: expected tag, branch or commit", ref, repo.String())
+}
+
 func (repo *Remote) IsCommitExists(ctx context.Context, commit string) (bool, error) {
 	return repo.isCommitExists(ctx, repo.GetClonePath(), repo.GetClonePath(), commit)
 }
 
 func (repo *Remote) getRepoID() string {
+	if repo.PartialClone {
+		return util.Sha256Hash(repo.getFilesystemRelativePathByEndpoint(), partialCloneFilter)
+	}
+
 	return util.Sha256Hash(repo.getFilesystemRelativePathByEndpoint())
 }
 
 func (repo *Remote) getWorkTreeCacheDir(repoID string) string {
+	// work trees with different sparse checkout patterns cannot be reused
+	if len(repo.SparseCheckoutPatterns) != 0 {
+		return filepath.Join(GetWorkTreeCacheDir(), "remote", util.Sha256Hash(append([]string{repoID}, repo.SparseCheckoutPatterns...)...))
+	}
+
 	return filepath.Join(GetWorkTreeCacheDir(), "remote", repoID)
 }
 
@@ -376,13 +483,13 @@ func (repo *Remote) initRepoHandleBackedByWorkTree(ctx context.Context, commit s
 	}
 
 	var repoHandle repo_handle.Handle
-	if err := true_git.WithWorkTree(ctx, repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), commit, true_git.WithWorkTreeOptions{HasSubmodules: hasSubmodules}, func(preparedWorkTreeDir string) error {
+	if err := true_git.WithWorkTree(ctx, repo.GetClonePath(), repo.getWorkTreeCacheDir(repo.getRepoID()), commit, true_git.WithWorkTreeOptions{HasSubmodules: hasSubmodules, SparseCheckoutPatterns: repo.SparseCheckoutPatterns}, func(preparedWorkTreeDir string) error {
 		repositoryWithPreparedWorktree, err := true_git.GitOpenWithCustomWorktreeDir(repo.GetClonePath(), preparedWorkTreeDir)
 		if err != nil {
 			return err
 		}
 
-		repoHandle, err = repo_handle.NewHandle(repositoryWithPreparedWorktree)
+		repoHandle, err = repo_handle.NewHandleWithMissingBlobReader(repositoryWithPreparedWorktree, repo.readMissingBlob)
 		return err
 	}); err != nil {
 		return nil, err
@@ -390,3 +497,8 @@ func (repo *Remote) initRepoHandleBackedByWorkTree(ctx context.Context, commit s
 
 	return repoHandle, nil
 }
+
+// readMissingBlob reads the blob with git, which fetches the blob of the partial clone from the origin on demand.
+func (repo *Remote) readMissingBlob(hash plumbing.Hash) ([]byte, error) {
+	return true_git.ReadBlob(repo.GetClonePath(), hash.String())
+}
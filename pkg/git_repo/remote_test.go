@@ -0,0 +1,133 @@
+package git_repo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Remote.ResolveReference", func() {
+	var originDir string
+	var remoteRepo *Remote
+	var commits map[string]string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=werf", "-c", "user.email=werf@werf.io"}, args...)...)
+		cmd.Dir = originDir
+		output, err := cmd.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	BeforeEach(func() {
+		var err error
+		originDir, err = ioutil.TempDir("", "werf-remote-origin-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		git("init", "-q")
+		git("checkout", "-q", "-b", "main")
+		git("commit", "-q", "--allow-empty", "-m", "first")
+		commits = map[string]string{"first": git("rev-parse", "HEAD")}
+		git("tag", "-a", "v1.0.0", "-m", "v1.0.0")
+		git("commit", "-q", "--allow-empty", "-m", "second")
+		commits["second"] = git("rev-parse", "HEAD")
+		git("branch", "feature")
+
+		remoteRepo, err = OpenRemoteRepo("origin", "file://"+originDir, RemoteOptions{})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remoteRepo.CloneAndFetch(context.Background())).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(originDir)).Should(Succeed())
+	})
+
+	DescribeTable("resolves tags, branches and commits",
+		func(ref func() string, expectedCommit string, expectedType ReferenceType) {
+			commit, refType, err := remoteRepo.ResolveReference(context.Background(), ref())
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(commit).Should(Equal(commits[expectedCommit]))
+			Ω(refType).Should(Equal(expectedType))
+		},
+		Entry("annotated tag", func() string { return "v1.0.0" }, "first", TagReference),
+		Entry("branch", func() string { return "feature" }, "second", BranchReference),
+		Entry("full commit", func() string { return commits["first"] }, "first", CommitReference),
+		Entry("abbreviated commit", func() string { return commits["first"][:7] }, "first", CommitReference),
+	)
+
+	DescribeTable("fails on unknown references",
+		func(ref string) {
+			_, _, err := remoteRepo.ResolveReference(context.Background(), ref)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("is not found"))
+		},
+		Entry("unknown branch", "unknown"),
+		Entry("unknown abbreviated commit", "0000000"),
+	)
+})
+
+var _ = Describe("Remote partial clone", func() {
+	const lfsOid = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	const lfsPointer = "version https://git-lfs.github.com/spec/v1\noid sha256:" + lfsOid + "\nsize 12345\n"
+
+	var originDir string
+	var remoteRepo *Remote
+	var commit string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=werf", "-c", "user.email=werf@werf.io"}, args...)...)
+		cmd.Dir = originDir
+		output, err := cmd.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	BeforeEach(func() {
+		var err error
+		originDir, err = ioutil.TempDir("", "werf-remote-origin-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		git("init", "-q")
+		git("config", "uploadpack.allowFilter", "true")
+		Ω(os.MkdirAll(filepath.Join(originDir, "assets"), 0o755)).Should(Succeed())
+		Ω(os.MkdirAll(filepath.Join(originDir, "src"), 0o755)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(originDir, "assets", ".gitattributes"), []byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"), 0o644)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(originDir, "assets", "file.bin"), []byte(lfsPointer), 0o644)).Should(Succeed())
+		Ω(ioutil.WriteFile(filepath.Join(originDir, "src", "main.go"), []byte("package main\n"), 0o644)).Should(Succeed())
+		git("add", "-A")
+		git("commit", "-q", "-m", "initial")
+		commit = git("rev-parse", "HEAD")
+
+		remoteRepo, err = OpenRemoteRepo("origin", "file://"+originDir, RemoteOptions{PartialClone: true, SparseCheckoutPatterns: []string{"/src"}})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remoteRepo.CloneAndFetch(context.Background())).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(originDir)).Should(Succeed())
+	})
+
+	It("fetches the git lfs pointer and .gitattributes blobs which are not checked out", func() {
+		rawRepo, err := gogit.PlainOpen(remoteRepo.GetClonePath())
+		Ω(err).ShouldNot(HaveOccurred())
+		_, err = rawRepo.BlobObject(plumbing.NewHash(git("rev-parse", "HEAD:assets/file.bin")))
+		Ω(err).Should(Equal(plumbing.ErrObjectNotFound))
+
+		entry, err := remoteRepo.GetCommitTreeEntry(context.Background(), commit, "assets/file.bin")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(entry.LfsOid).Should(Equal(lfsOid))
+
+		content, err := remoteRepo.ReadCommitTreeEntryContent(context.Background(), commit, "assets/file.bin")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(content)).Should(Equal(lfsPointer))
+	})
+})
@@ -5,10 +5,12 @@ import (
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
 )
 
 type handle struct {
 	repository          *git.Repository
+	readMissingBlob     func(hash plumbing.Hash) ([]byte, error)
 	submoduleHandleList []SubmoduleHandle
 }
 
@@ -22,6 +24,14 @@ func (h *handle) Repository() Repository {
 	return h.repository
 }
 
+func (h *handle) ReadMissingBlob(hash plumbing.Hash) ([]byte, error) {
+	if h.readMissingBlob == nil {
+		return nil, fmt.Errorf("blob %s is not available: fetch the missing objects of the partial clone", hash)
+	}
+
+	return h.readMissingBlob(hash)
+}
+
 func (h *handle) Submodule(submodulePath string) (SubmoduleHandle, error) {
 	for _, s := range h.submoduleHandleList {
 		if s.Config().Path == submodulePath {
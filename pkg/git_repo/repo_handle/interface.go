@@ -14,6 +14,8 @@ import (
 // and then working exclusively with git objects.
 type Handle interface {
 	Repository() Repository
+	// ReadMissingBlob reads the blob which is not available in the repository storage (e.g. the blob of the partial clone).
+	ReadMissingBlob(hash plumbing.Hash) ([]byte, error)
 	Submodule(submodulePath string) (SubmoduleHandle, error)
 	Submodules() []SubmoduleHandle
 }
@@ -30,7 +32,14 @@ type Repository interface {
 }
 
 func NewHandle(repository *git.Repository) (Handle, error) {
+	return NewHandleWithMissingBlobReader(repository, nil)
+}
+
+// NewHandleWithMissingBlobReader creates the handle which reads the blobs missing in the repository storage with readMissingBlob,
+// e.g. with git that fetches the blobs of the partial clone on demand.
+func NewHandleWithMissingBlobReader(repository *git.Repository, readMissingBlob func(hash plumbing.Hash) ([]byte, error)) (Handle, error) {
 	h := newHandle(repository)
+	h.readMissingBlob = readMissingBlob
 
 	submoduleHandleList, err := getSubmoduleHandleList(repository)
 	if err != nil {
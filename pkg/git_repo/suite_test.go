@@ -0,0 +1,47 @@
+package git_repo
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/lockgate"
+
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Git Repo Suite")
+}
+
+var werfHomeDir string
+
+var _ = BeforeSuite(func() {
+	var err error
+	werfHomeDir, err = ioutil.TempDir("", "werf-git-repo-test-")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	Ω(werf.Init(werfHomeDir, werfHomeDir)).Should(Succeed())
+	Ω(true_git.Init(true_git.Options{})).Should(Succeed())
+	Ω(Init(gitDataManagerStub{})).Should(Succeed())
+})
+
+var _ = AfterSuite(func() {
+	Ω(os.RemoveAll(werfHomeDir)).Should(Succeed())
+})
+
+// gitDataManagerStub provides the GC lock only, archives and patches are not cached.
+type gitDataManagerStub struct {
+	GitDataManager
+}
+
+func (gitDataManagerStub) LockGC(ctx context.Context, shared bool) (lockgate.LockHandle, error) {
+	_, lock, err := werf.AcquireHostLock(ctx, "git_data_manager", lockgate.AcquireOptions{Shared: shared})
+	return lock, err
+}
@@ -79,10 +79,22 @@ func (c Config) IsConfigStapelMountFromPathAccepted(fromPath string) bool {
 	return c.Config.Stapel.Mount.IsFromPathAccepted(fromPath)
 }
 
+func (c Config) IsConfigStapelStageDependenciesEnvNameAccepted(envName string) (bool, error) {
+	return c.Config.Stapel.StageDependencies.IsEnvNameAccepted(envName)
+}
+
+func (c Config) UncommittedConfigStapelAnsibleFilePathMatcher() path_matcher.PathMatcher {
+	return c.Config.Stapel.Ansible.UncommittedFilePathMatcher()
+}
+
 func (c Config) IsConfigDockerfileContextAddFileAccepted(relPath string) bool {
 	return c.Config.Dockerfile.IsContextAddFileAccepted(relPath)
 }
 
+func (c Config) IsConfigDockerfileRemoteContextBranchAccepted() bool {
+	return c.Config.Dockerfile.AllowRemoteContextBranch
+}
+
 func (c Config) IsUncommittedDockerfileAccepted(relPath string) bool {
 	return c.Config.Dockerfile.IsUncommittedAccepted(relPath)
 }
@@ -95,6 +107,18 @@ func (c Config) UncommittedHelmFilePathMatcher() path_matcher.PathMatcher {
 	return c.Helm.UncommittedHelmFilePathMatcher()
 }
 
+func (c Config) IsHelmSetFlagsAccepted() bool {
+	return c.Helm.AllowSetFlags
+}
+
+func (c Config) IsHelmValuesFileAccepted(relPath string) bool {
+	return c.Helm.IsValuesFileAccepted(relPath)
+}
+
+func (c Config) IsHelmEnvNameAccepted(envName string) (bool, error) {
+	return c.Helm.IsEnvNameAccepted(envName)
+}
+
 type config struct {
 	AllowUncommitted          bool                `json:"allowUncommitted"`
 	AllowUncommittedTemplates []string            `json:"allowUncommittedTemplates"`
@@ -113,7 +137,15 @@ type goTemplateRendering struct {
 }
 
 func (r goTemplateRendering) IsEnvNameAccepted(name string) (bool, error) {
-	for _, pattern := range r.AllowEnvVariables {
+	return isEnvNameMatched(r.AllowEnvVariables, name)
+}
+
+func (r goTemplateRendering) UncommittedFilePathMatcher() path_matcher.PathMatcher {
+	return pathMatcher(r.AllowUncommittedFiles)
+}
+
+func isEnvNameMatched(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
 		match, err := func() (bool, error) {
 			if strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
 				expr := fmt.Sprintf("^%s$", pattern[1:len(pattern)-1])
@@ -140,14 +172,12 @@ func (r goTemplateRendering) IsEnvNameAccepted(name string) (bool, error) {
 	return false, nil
 }
 
-func (r goTemplateRendering) UncommittedFilePathMatcher() path_matcher.PathMatcher {
-	return pathMatcher(r.AllowUncommittedFiles)
-}
-
 type stapel struct {
-	AllowFromLatest bool  `json:"allowFromLatest"`
-	Git             git   `json:"git"`
-	Mount           mount `json:"mount"`
+	AllowFromLatest   bool              `json:"allowFromLatest"`
+	Git               git               `json:"git"`
+	Mount             mount             `json:"mount"`
+	Ansible           ansible           `json:"ansible"`
+	StageDependencies stageDependencies `json:"stageDependencies"`
 }
 
 type git struct {
@@ -163,10 +193,27 @@ func (m mount) IsFromPathAccepted(path string) bool {
 	return isPathMatched(m.AllowFromPaths, path)
 }
 
+type ansible struct {
+	AllowUncommittedFiles []string `json:"allowUncommittedFiles"`
+}
+
+func (a ansible) UncommittedFilePathMatcher() path_matcher.PathMatcher {
+	return pathMatcher(a.AllowUncommittedFiles)
+}
+
+type stageDependencies struct {
+	AllowEnvVariables []string `json:"allowEnvVariables"`
+}
+
+func (s stageDependencies) IsEnvNameAccepted(name string) (bool, error) {
+	return isEnvNameMatched(s.AllowEnvVariables, name)
+}
+
 type dockerfile struct {
 	AllowUncommitted                  []string `json:"allowUncommitted"`
 	AllowUncommittedDockerignoreFiles []string `json:"allowUncommittedDockerignoreFiles"`
 	AllowContextAddFiles              []string `json:"allowContextAddFiles"`
+	AllowRemoteContextBranch          bool     `json:"allowRemoteContextBranch"`
 }
 
 func (d dockerfile) IsContextAddFileAccepted(path string) bool {
@@ -183,12 +230,23 @@ func (d dockerfile) IsUncommittedDockerignoreAccepted(path string) bool {
 
 type helm struct {
 	AllowUncommittedFiles []string `json:"allowUncommittedFiles"`
+	AllowSetFlags         bool     `json:"allowSetFlags"`
+	AllowValuesFiles      []string `json:"allowValuesFiles"`
+	AllowEnvVariables     []string `json:"allowEnvVariables"`
 }
 
 func (h helm) UncommittedHelmFilePathMatcher() path_matcher.PathMatcher {
 	return pathMatcher(h.AllowUncommittedFiles)
 }
 
+func (h helm) IsValuesFileAccepted(path string) bool {
+	return isPathMatched(h.AllowValuesFiles, path)
+}
+
+func (h helm) IsEnvNameAccepted(name string) (bool, error) {
+	return isEnvNameMatched(h.AllowEnvVariables, name)
+}
+
 func isPathMatched(patterns []string, p string) bool {
 	return pathMatcher(patterns).IsPathMatched(p)
 }
@@ -68,6 +68,10 @@ definitions:
         $ref: '#/definitions/ConfigStapelGit'
       mount:
         $ref: '#/definitions/ConfigStapelMount'
+      ansible:
+        $ref: '#/definitions/ConfigStapelAnsible'
+      stageDependencies:
+        $ref: '#/definitions/ConfigStapelStageDependencies'
   ConfigStapelGit:
     type: object
     additionalProperties: {}
@@ -84,6 +88,22 @@ definitions:
         type: array
         items:
           type: string
+  ConfigStapelAnsible:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowUncommittedFiles:
+        type: array
+        items:
+          type: string
+  ConfigStapelStageDependencies:
+    type: object
+    additionalProperties: {}
+    properties:
+      allowEnvVariables:
+        type: array
+        items:
+          type: string
   ConfigDockerfile:
     type: object
     additionalProperties: {}
@@ -100,6 +120,8 @@ definitions:
         type: array
         items:
           type: string
+      allowRemoteContextBranch:
+        type: boolean
   Helm:
     type: object
     additionalProperties: {}
@@ -108,6 +130,16 @@ definitions:
         type: array
         items:
           type: string
+      allowSetFlags:
+        type: boolean
+      allowValuesFiles:
+        type: array
+        items:
+          type: string
+      allowEnvVariables:
+        type: array
+        items:
+          type: string
 `
 )
 
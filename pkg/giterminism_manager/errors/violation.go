@@ -0,0 +1,49 @@
+package errors
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Violation is the giterminism restriction violation found in the collecting mode (werf giterminism check).
+type Violation struct {
+	Message string
+	// Snippet is the werf-giterminism.yaml part that allows the violation, empty if the violation cannot be allowed by the config.
+	Snippet string
+}
+
+type Violations struct {
+	list []Violation
+}
+
+func (v *Violations) Add(message, snippet string) {
+	for _, violation := range v.list {
+		if violation.Message == message {
+			return
+		}
+	}
+
+	v.list = append(v.list, Violation{Message: message, Snippet: snippet})
+}
+
+func (v *Violations) List() []Violation {
+	return v.list
+}
+
+// NewConfigSnippet returns werf-giterminism.yaml part with the value set by the dot-separated key path (e.g. "config.stapel.allowFromLatest").
+func NewConfigSnippet(keyPath string, value interface{}) string {
+	keys := strings.Split(keyPath, ".")
+
+	var data interface{} = value
+	for ind := len(keys) - 1; ind >= 0; ind-- {
+		data = yaml.MapSlice{{Key: keys[ind], Value: data}}
+	}
+
+	out, err := yaml.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+
+	return string(out)
+}
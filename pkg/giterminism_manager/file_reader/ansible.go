@@ -0,0 +1,85 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+)
+
+func (r FileReader) ReadAnsibleFile(ctx context.Context, relPath string) (data []byte, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadAnsibleFile %q", relPath).
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			data, err = r.readAnsibleFile(ctx, relPath)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("dataLength: %d\nerr: %q\n", len(data), err)
+			}
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ansible file %q: %s", filepath.ToSlash(relPath), err)
+	}
+
+	return data, nil
+}
+
+func (r FileReader) readAnsibleFile(ctx context.Context, relPath string) ([]byte, error) {
+	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigStapelAnsibleFilePathMatcher().IsPathMatched, allowUncommittedFilesSnippetFunc("config.stapel.ansible.allowUncommittedFiles"))
+}
+
+// ReadAnsibleDirFiles reads all files of the directory (e.g. an ansible role), the result keys are slash-separated paths relative to the directory.
+func (r FileReader) ReadAnsibleDirFiles(ctx context.Context, relDirPath string) (files map[string][]byte, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadAnsibleDirFiles %q", relDirPath).
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			files, err = r.readAnsibleDirFiles(ctx, relDirPath)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("files: %d\nerr: %q\n", len(files), err)
+			}
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read ansible files in directory %q: %s", filepath.ToSlash(relDirPath), err)
+	}
+
+	return files, nil
+}
+
+func (r FileReader) readAnsibleDirFiles(ctx context.Context, relDirPath string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	if err := r.WalkConfigurationFilesWithGlob(
+		ctx,
+		relDirPath,
+		"**/*",
+		r.giterminismConfig.UncommittedConfigStapelAnsibleFilePathMatcher(),
+		allowUncommittedFilesSnippetFunc("config.stapel.ansible.allowUncommittedFiles"),
+		func(relativeToDirNotResolvedPath string, data []byte, err error) error {
+			if err != nil {
+				return err
+			}
+
+			files[filepath.ToSlash(relativeToDirNotResolvedPath)] = data
+
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/werf/logboek"
 	"github.com/werf/logboek/pkg/types"
+
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
 )
 
 var DefaultWerfConfigNames = []string{"werf.yaml", "werf.yml"}
@@ -71,6 +73,8 @@ func (r FileReader) readConfig(ctx context.Context, customRelPath string) (strin
 	for _, configPath := range configRelPathList {
 		data, err := r.ReadAndCheckConfigurationFile(ctx, configPath, func(_ string) bool {
 			return r.giterminismConfig.IsUncommittedConfigAccepted()
+		}, func(_ string) string {
+			return errors.NewConfigSnippet("config.allowUncommitted", true)
 		})
 		if err != nil {
 			switch err.(type) {
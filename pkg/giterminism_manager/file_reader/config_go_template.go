@@ -14,6 +14,7 @@ func (r FileReader) ConfigGoTemplateFilesGlob(ctx context.Context, glob string)
 		"",
 		glob,
 		r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher(),
+		allowUncommittedFilesSnippetFunc("config.goTemplateRendering.allowUncommittedFiles"),
 		func(relativeToDirNotResolvedPath string, data []byte, err error) error {
 			if err != nil {
 				return err
@@ -31,7 +32,7 @@ func (r FileReader) ConfigGoTemplateFilesGlob(ctx context.Context, glob string)
 }
 
 func (r FileReader) ConfigGoTemplateFilesGet(ctx context.Context, relPath string) ([]byte, error) {
-	data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher().IsPathMatched)
+	data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedConfigGoTemplateRenderingFilePathMatcher().IsPathMatched, allowUncommittedFilesSnippetFunc("config.goTemplateRendering.allowUncommittedFiles"))
 	if err != nil {
 		return nil, fmt.Errorf("{{ .Files.Get %q }}: %s", relPath, err)
 	}
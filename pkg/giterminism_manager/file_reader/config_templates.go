@@ -45,6 +45,7 @@ func (r FileReader) readConfigTemplateFiles(ctx context.Context, customDirRelPat
 		templatesDirRelPath,
 		"**/*.tmpl",
 		r.giterminismConfig.UncommittedConfigTemplateFilePathMatcher(),
+		allowUncommittedFilesSnippetFunc("config.allowUncommittedTemplates"),
 		func(relativeToDirNotResolvedPath string, data []byte, err error) error {
 			return tmplFunc(filepath.ToSlash(relativeToDirNotResolvedPath), data, err)
 		},
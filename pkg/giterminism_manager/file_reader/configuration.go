@@ -13,7 +13,7 @@ import (
 
 // WalkConfigurationFilesWithGlob reads the configuration files taking into account the giterminism config.
 // The result paths are relative to the passed directory, the method does reverse resolving for symlinks.
-func (r FileReader) WalkConfigurationFilesWithGlob(ctx context.Context, dir, glob string, acceptedFilePathMatcher path_matcher.PathMatcher, handleFileFunc func(relativeToDirNotResolvedPath string, data []byte, err error) error) (err error) {
+func (r FileReader) WalkConfigurationFilesWithGlob(ctx context.Context, dir, glob string, acceptedFilePathMatcher path_matcher.PathMatcher, allowSnippetFunc func(relPath string) string, handleFileFunc func(relativeToDirNotResolvedPath string, data []byte, err error) error) (err error) {
 	logboek.Context(ctx).Debug().
 		LogBlock("WalkConfigurationFilesWithGlob %q %q", dir, glob).
 		Options(func(options types.LogBlockOptionsInterface) {
@@ -22,7 +22,7 @@ func (r FileReader) WalkConfigurationFilesWithGlob(ctx context.Context, dir, glo
 			}
 		}).
 		Do(func() {
-			err = r.walkConfigurationFilesWithGlob(ctx, dir, glob, acceptedFilePathMatcher, handleFileFunc)
+			err = r.walkConfigurationFilesWithGlob(ctx, dir, glob, acceptedFilePathMatcher, allowSnippetFunc, handleFileFunc)
 
 			if debug() {
 				logboek.Context(ctx).Debug().LogF("err: %q\n", err)
@@ -32,7 +32,7 @@ func (r FileReader) WalkConfigurationFilesWithGlob(ctx context.Context, dir, glo
 	return
 }
 
-func (r FileReader) walkConfigurationFilesWithGlob(ctx context.Context, dir, glob string, acceptedFilePathMatcher path_matcher.PathMatcher, handleFileFunc func(relativeToDirNotResolvedPath string, data []byte, err error) error) (err error) {
+func (r FileReader) walkConfigurationFilesWithGlob(ctx context.Context, dir, glob string, acceptedFilePathMatcher path_matcher.PathMatcher, allowSnippetFunc func(relPath string) string, handleFileFunc func(relativeToDirNotResolvedPath string, data []byte, err error) error) (err error) {
 	relToDirFilePathListFromFS, err := r.ListFilesWithGlob(ctx, dir, glob, r.SkipFileFunc(acceptedFilePathMatcher))
 	if err != nil {
 		return err
@@ -41,7 +41,7 @@ func (r FileReader) walkConfigurationFilesWithGlob(ctx context.Context, dir, glo
 	if r.sharedOptions.LooseGiterminism() {
 		for _, relToDirPath := range relToDirFilePathListFromFS {
 			relPath := filepath.Join(dir, relToDirPath)
-			data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, acceptedFilePathMatcher.IsPathMatched)
+			data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, acceptedFilePathMatcher.IsPathMatched, allowSnippetFunc)
 			if err := handleFileFunc(relToDirPath, data, err); err != nil {
 				return err
 			}
@@ -61,7 +61,7 @@ func (r FileReader) walkConfigurationFilesWithGlob(ctx context.Context, dir, glo
 	var relPathListWithUntrackedFiles []string
 	for _, relToDirPath := range relToDirPathList {
 		relPath := filepath.Join(dir, relToDirPath)
-		data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, acceptedFilePathMatcher.IsPathMatched)
+		data, err := r.ReadAndCheckConfigurationFile(ctx, relPath, acceptedFilePathMatcher.IsPathMatched, allowSnippetFunc)
 		err = handleFileFunc(relToDirPath, data, err)
 		if err != nil {
 			switch err.(type) {
@@ -89,7 +89,8 @@ func (r FileReader) walkConfigurationFilesWithGlob(ctx context.Context, dir, glo
 }
 
 // ReadAndCheckConfigurationFile does CheckConfigurationFileExistenceAndAcceptance and ReadConfigurationFile.
-func (r FileReader) ReadAndCheckConfigurationFile(ctx context.Context, relPath string, isFileAcceptedCheckFunc func(relPath string) bool) (data []byte, err error) {
+// In the violations collecting mode the uncommitted file is reported with the snippet built by allowSnippetFunc (nil if the file cannot be allowed) and read from the project directory.
+func (r FileReader) ReadAndCheckConfigurationFile(ctx context.Context, relPath string, isFileAcceptedCheckFunc func(relPath string) bool, allowSnippetFunc func(relPath string) string) (data []byte, err error) {
 	logboek.Context(ctx).Debug().
 		LogBlock("ReadAndCheckConfigurationFile %q", relPath).
 		Options(func(options types.LogBlockOptionsInterface) {
@@ -98,7 +99,7 @@ func (r FileReader) ReadAndCheckConfigurationFile(ctx context.Context, relPath s
 			}
 		}).
 		Do(func() {
-			data, err = r.readAndCheckConfigurationFile(ctx, relPath, isFileAcceptedCheckFunc)
+			data, err = r.readAndCheckConfigurationFile(ctx, relPath, isFileAcceptedCheckFunc, allowSnippetFunc)
 
 			if debug() {
 				logboek.Context(ctx).Debug().LogF("dataLength: %v\nerr: %q\n", len(data), err)
@@ -108,8 +109,12 @@ func (r FileReader) ReadAndCheckConfigurationFile(ctx context.Context, relPath s
 	return
 }
 
-func (r FileReader) readAndCheckConfigurationFile(ctx context.Context, relPath string, isFileAcceptedCheckFunc func(relPath string) bool) ([]byte, error) {
+func (r FileReader) readAndCheckConfigurationFile(ctx context.Context, relPath string, isFileAcceptedCheckFunc func(relPath string) bool, allowSnippetFunc func(relPath string) string) ([]byte, error) {
 	if err := r.CheckConfigurationFileExistenceAndAcceptance(ctx, relPath, isFileAcceptedCheckFunc); err != nil {
+		if r.reportFileViolation(err, relPath, allowSnippetFunc) {
+			return r.readFileOrCommitFile(ctx, relPath)
+		}
+
 		return nil, err
 	}
 
@@ -52,7 +52,7 @@ func (r FileReader) ReadDockerfile(ctx context.Context, relPath string) (data []
 }
 
 func (r FileReader) readDockerfile(ctx context.Context, relPath string) ([]byte, error) {
-	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.IsUncommittedDockerfileAccepted)
+	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.IsUncommittedDockerfileAccepted, allowUncommittedFilesSnippetFunc("config.dockerfile.allowUncommitted"))
 }
 
 func (r FileReader) ReadDockerignore(ctx context.Context, relPath string) (data []byte, err error) {
@@ -79,5 +79,5 @@ func (r FileReader) ReadDockerignore(ctx context.Context, relPath string) (data
 }
 
 func (r FileReader) readDockerignore(ctx context.Context, relPath string) ([]byte, error) {
-	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.IsUncommittedDockerignoreAccepted)
+	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.IsUncommittedDockerignoreAccepted, allowUncommittedFilesSnippetFunc("config.dockerfile.allowUncommittedDockerignoreFiles"))
 }
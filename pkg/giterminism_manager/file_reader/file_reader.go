@@ -24,6 +24,7 @@ type giterminismConfig interface {
 	IsUncommittedConfigAccepted() bool
 	UncommittedConfigTemplateFilePathMatcher() path_matcher.PathMatcher
 	UncommittedConfigGoTemplateRenderingFilePathMatcher() path_matcher.PathMatcher
+	UncommittedConfigStapelAnsibleFilePathMatcher() path_matcher.PathMatcher
 	IsUncommittedDockerfileAccepted(relPath string) bool
 	IsUncommittedDockerignoreAccepted(relPath string) bool
 	UncommittedHelmFilePathMatcher() path_matcher.PathMatcher
@@ -36,6 +37,7 @@ type sharedOptions interface {
 	HeadCommit() string
 	LooseGiterminism() bool
 	Dev() bool
+	ReportViolation(message, snippet string) bool
 }
 
 func debug() bool {
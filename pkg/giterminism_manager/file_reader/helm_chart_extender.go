@@ -45,7 +45,7 @@ func (r FileReader) ReadChartFile(ctx context.Context, path string) ([]byte, err
 }
 
 func (r FileReader) readChartFile(ctx context.Context, relPath string) ([]byte, error) {
-	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedHelmFilePathMatcher().IsPathMatched)
+	return r.ReadAndCheckConfigurationFile(ctx, relPath, r.giterminismConfig.UncommittedHelmFilePathMatcher().IsPathMatched, allowUncommittedFilesSnippetFunc("helm.allowUncommittedFiles"))
 }
 
 func (r FileReader) LoadChartDir(ctx context.Context, chartDir string) ([]*chart.ChartExtenderBufferedFile, error) {
@@ -68,6 +68,7 @@ func (r FileReader) loadChartDir(ctx context.Context, relDir string) ([]*chart.C
 		relDir,
 		"**/*",
 		r.giterminismConfig.UncommittedHelmFilePathMatcher(),
+		allowUncommittedFilesSnippetFunc("helm.allowUncommittedFiles"),
 		func(relativeToDirNotResolvedPath string, data []byte, err error) error {
 			if err != nil {
 				return err
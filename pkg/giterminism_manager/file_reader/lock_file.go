@@ -0,0 +1,59 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/logboek"
+	"github.com/werf/logboek/pkg/types"
+)
+
+const LockFileName = "werf.lock"
+
+func (r FileReader) IsLockFileExistAnywhere(ctx context.Context) (exist bool, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("IsLockFileExistAnywhere").
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			exist, err = r.IsConfigurationFileExistAnywhere(ctx, LockFileName)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("exist: %v\nerr: %q\n", exist, err)
+			}
+		})
+
+	return
+}
+
+func (r FileReader) ReadLockFile(ctx context.Context) (data []byte, err error) {
+	logboek.Context(ctx).Debug().
+		LogBlock("ReadLockFile").
+		Options(func(options types.LogBlockOptionsInterface) {
+			if !debug() {
+				options.Mute()
+			}
+		}).
+		Do(func() {
+			data, err = r.readLockFile(ctx)
+
+			if debug() {
+				logboek.Context(ctx).Debug().LogF("dataLength: %v\nerr: %q\n", len(data), err)
+			}
+		})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read werf lock file: %s", err)
+	}
+
+	return
+}
+
+func (r FileReader) readLockFile(ctx context.Context) ([]byte, error) {
+	return r.ReadAndCheckConfigurationFile(ctx, LockFileName, func(relPath string) bool {
+		return false
+	}, nil)
+}
@@ -0,0 +1,50 @@
+package file_reader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
+)
+
+// allowUncommittedFilesSnippetFunc returns the function building werf-giterminism.yaml snippet that adds the file to the list option.
+func allowUncommittedFilesSnippetFunc(keyPath string) func(relPath string) string {
+	return func(relPath string) string {
+		return errors.NewConfigSnippet(keyPath, []string{filepath.ToSlash(relPath)})
+	}
+}
+
+// reportFileViolation returns true if the error is about the uncommitted or untracked file and the violation has been saved in the violations collecting mode.
+func (r FileReader) reportFileViolation(err error, relPath string, allowSnippetFunc func(relPath string) string) bool {
+	var msg string
+	switch err.(type) {
+	case UntrackedFilesError:
+		msg = fmt.Sprintf("the untracked file %q %s", filepath.ToSlash(relPath), r.uncommittedUntrackedExpectedAction())
+	case UncommittedFilesError:
+		msg = fmt.Sprintf("the file %q %s", filepath.ToSlash(relPath), r.uncommittedUntrackedExpectedAction())
+	default:
+		return false
+	}
+
+	var snippet string
+	if allowSnippetFunc != nil {
+		snippet = allowSnippetFunc(relPath)
+	}
+
+	return r.sharedOptions.ReportViolation(msg, snippet)
+}
+
+// readFileOrCommitFile reads the file from the project directory or from the commit if the file has been deleted locally.
+func (r FileReader) readFileOrCommitFile(ctx context.Context, relPath string) ([]byte, error) {
+	exist, err := r.IsRegularFileExist(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if exist {
+		return r.ReadFile(ctx, relPath)
+	}
+
+	return r.ReadCommitFile(ctx, relPath)
+}
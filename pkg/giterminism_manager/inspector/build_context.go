@@ -2,6 +2,8 @@ package inspector
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
 
 	"github.com/werf/werf/pkg/path_matcher"
 )
@@ -11,5 +13,22 @@ func (i Inspector) InspectBuildContextFiles(ctx context.Context, matcher path_ma
 		return nil
 	}
 
-	return i.fileReader.ValidateStatusResult(ctx, matcher)
+	err := i.fileReader.ValidateStatusResult(ctx, matcher)
+	if err == nil {
+		return nil
+	}
+
+	// Each changed file of the build context is reported separately in the violations collecting mode.
+	pathList, listErr := i.fileReader.StatusPathList(ctx, matcher)
+	if listErr != nil || len(pathList) == 0 {
+		return err
+	}
+
+	for _, relPath := range pathList {
+		if !i.sharedOptions.ReportViolation(fmt.Sprintf("the build context file %q must be committed", filepath.ToSlash(relPath)), "") {
+			return err
+		}
+	}
+
+	return nil
 }
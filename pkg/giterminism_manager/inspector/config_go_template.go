@@ -3,6 +3,8 @@ package inspector
 import (
 	"context"
 	"fmt"
+
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
 )
 
 func (i Inspector) InspectConfigGoTemplateRenderingEnv(ctx context.Context, envName string) error {
@@ -16,7 +18,7 @@ func (i Inspector) InspectConfigGoTemplateRenderingEnv(ctx context.Context, envN
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(fmt.Sprintf(`env name %q not allowed by giterminism
+	return i.reportViolation(NewExternalDependencyFoundError, fmt.Sprintf(`env name %q not allowed by giterminism
 
-The use of the function env complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the value of the environment variable affects the final digest of built images.`, envName))
+The use of the function env complicates the sharing and reproducibility of the configuration in CI jobs and among developers, because the value of the environment variable affects the final digest of built images.`, envName), errors.NewConfigSnippet("config.goTemplateRendering.allowEnvVariables", []string{envName}))
 }
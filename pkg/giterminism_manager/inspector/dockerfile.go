@@ -3,6 +3,8 @@ package inspector
 import (
 	"fmt"
 	"path/filepath"
+
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
 )
 
 func (i Inspector) InspectConfigDockerfileContextAddFile(relPath string) error {
@@ -14,7 +16,31 @@ func (i Inspector) InspectConfigDockerfileContextAddFile(relPath string) error {
 		return nil
 	}
 
-	return NewExternalDependencyFoundError(fmt.Sprintf(`contextAddFile %q not allowed by giterminism
+	return i.reportViolation(NewExternalDependencyFoundError, fmt.Sprintf(`contextAddFile %q not allowed by giterminism
+
+The use of the directive contextAddFiles complicates the sharing and reproducibility of the configuration in CI jobs and among developers because the file data affects the final digest of built images and must be identical at all steps of the pipeline and during local development.`, filepath.ToSlash(relPath)), errors.NewConfigSnippet("config.dockerfile.allowContextAddFiles", []string{filepath.ToSlash(relPath)}))
+}
+
+func (i Inspector) InspectConfigDockerfileRemoteContextBranch() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigDockerfileRemoteContextBranchAccepted() {
+		return nil
+	}
+
+	return i.reportViolation(NewExternalDependencyFoundError, `remote git context branch not allowed by giterminism
+
+The build context from a remote git repository branch (the default branch if the reference is not specified) may break the previous builds' reproducibility. The new commit in the branch changes the build context and the stage digests, thus all previously built images become unusable.
+
+As an alternative, we recommend specifying an unchangeable reference, tag, or commit in the context (URL#REF:SUBDIR) to guarantee the application's controllable and predictable life cycle.`, errors.NewConfigSnippet("config.dockerfile.allowRemoteContextBranch", true))
+}
+
+func (i Inspector) InspectConfigDockerfileAddGitBranch(url string) error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsConfigDockerfileRemoteContextBranchAccepted() {
+		return nil
+	}
+
+	return i.reportViolation(NewExternalDependencyFoundError, fmt.Sprintf(`Dockerfile ADD of the remote git repository %s branch not allowed by giterminism
+
+The ADD instruction source from a remote git repository branch (the default branch if the reference is not specified) may break the previous builds' reproducibility. The new commit in the branch changes the stage digests, thus all previously built images become unusable.
 
-The use of the directive contextAddFiles complicates the sharing and reproducibility of the configuration in CI jobs and among developers because the file data affects the final digest of built images and must be identical at all steps of the pipeline and during local development.`, filepath.ToSlash(relPath)))
+As an alternative, we recommend specifying an unchangeable reference, tag, or commit in the source (URL#REF) to guarantee the application's controllable and predictable life cycle.`, url), errors.NewConfigSnippet("config.dockerfile.allowRemoteContextBranch", true))
 }
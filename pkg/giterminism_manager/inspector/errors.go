@@ -9,3 +9,7 @@ import (
 func NewExternalDependencyFoundError(msg string) error {
 	return errors.NewError(fmt.Sprintf("the configuration with potential external dependency found in the werf config: %s", msg))
 }
+
+func NewDeployExternalDependencyFoundError(msg string) error {
+	return errors.NewError(fmt.Sprintf("the deploy configuration with potential external dependency found: %s", msg))
+}
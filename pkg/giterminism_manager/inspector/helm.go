@@ -0,0 +1,44 @@
+package inspector
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
+)
+
+func (i Inspector) InspectHelmSetFlags() error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsHelmSetFlagsAccepted() {
+		return nil
+	}
+
+	return i.reportViolation(NewDeployExternalDependencyFoundError, `--set, --set-string and --set-file options not allowed by giterminism
+
+Values passed with the command line options are not stored in the project git repository, so the release cannot be reproduced from the commit. As an alternative, we recommend keeping values in the committed values files of the chart and selecting them with the werf environment.`, errors.NewConfigSnippet("helm.allowSetFlags", true))
+}
+
+func (i Inspector) InspectHelmValuesFile(relPath string) error {
+	if i.sharedOptions.LooseGiterminism() || i.giterminismConfig.IsHelmValuesFileAccepted(relPath) {
+		return nil
+	}
+
+	return i.reportViolation(NewDeployExternalDependencyFoundError, fmt.Sprintf(`values file %q not allowed by giterminism
+
+The set of values files passed with the --values and --secret-values options is not stored in the project git repository, so the release cannot be reproduced from the commit without knowing the exact command line.`, filepath.ToSlash(relPath)), errors.NewConfigSnippet("helm.allowValuesFiles", []string{filepath.ToSlash(relPath)}))
+}
+
+func (i Inspector) InspectHelmEnv(envName string) error {
+	if i.sharedOptions.LooseGiterminism() {
+		return nil
+	}
+
+	if isAccepted, err := i.giterminismConfig.IsHelmEnvNameAccepted(envName); err != nil {
+		return err
+	} else if isAccepted {
+		return nil
+	}
+
+	return i.reportViolation(NewDeployExternalDependencyFoundError, fmt.Sprintf(`env name %q not allowed by giterminism
+
+Values passed with the environment variables are not stored in the project git repository, so the release cannot be reproduced from the commit.`, envName), errors.NewConfigSnippet("helm.allowEnvVariables", []string{envName}))
+}
@@ -2,6 +2,7 @@ package inspector
 
 import (
 	"context"
+	"strings"
 
 	"github.com/werf/werf/pkg/git_repo"
 	"github.com/werf/werf/pkg/path_matcher"
@@ -24,11 +25,17 @@ type giterminismConfig interface {
 	IsConfigStapelGitBranchAccepted() bool
 	IsConfigStapelMountBuildDirAccepted() bool
 	IsConfigStapelMountFromPathAccepted(fromPath string) bool
+	IsConfigStapelStageDependenciesEnvNameAccepted(envName string) (bool, error)
 	IsConfigDockerfileContextAddFileAccepted(relPath string) bool
+	IsConfigDockerfileRemoteContextBranchAccepted() bool
+	IsHelmSetFlagsAccepted() bool
+	IsHelmValuesFileAccepted(relPath string) bool
+	IsHelmEnvNameAccepted(envName string) (bool, error)
 }
 
 type fileReader interface {
 	ValidateStatusResult(ctx context.Context, pathMatcher path_matcher.PathMatcher) error
+	StatusPathList(ctx context.Context, pathMatcher path_matcher.PathMatcher) ([]string, error)
 }
 
 type sharedOptions interface {
@@ -37,4 +44,15 @@ type sharedOptions interface {
 	HeadCommit() string
 	LooseGiterminism() bool
 	Dev() bool
+	ReportViolation(message, snippet string) bool
+}
+
+// reportViolation returns the error built from the message or, in the violations collecting mode,
+// saves the first line of the message along with the werf-giterminism.yaml snippet that allows the violation.
+func (i Inspector) reportViolation(newErrorFunc func(msg string) error, msg, snippet string) error {
+	if i.sharedOptions.ReportViolation(strings.SplitN(msg, "\n", 2)[0], snippet) {
+		return nil
+	}
+
+	return newErrorFunc(msg)
 }
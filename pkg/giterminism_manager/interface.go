@@ -7,6 +7,7 @@ import (
 	"helm.sh/helm/v3/pkg/cli"
 
 	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager/errors"
 	"github.com/werf/werf/pkg/path_matcher"
 )
 
@@ -20,6 +21,7 @@ type Interface interface {
 	RelativeToGitProjectDir() string
 	LooseGiterminism() bool
 	Dev() bool
+	Violations() []errors.Violation
 }
 
 type FileReader interface {
@@ -28,9 +30,13 @@ type FileReader interface {
 	ReadConfigTemplateFiles(ctx context.Context, customRelDirPath string, tmplFunc func(templatePathInsideDir string, data []byte, err error) error) error
 	ConfigGoTemplateFilesGet(ctx context.Context, relPath string) ([]byte, error)
 	ConfigGoTemplateFilesGlob(ctx context.Context, pattern string) (map[string]interface{}, error)
+	ReadAnsibleFile(ctx context.Context, relPath string) ([]byte, error)
+	ReadAnsibleDirFiles(ctx context.Context, relDirPath string) (map[string][]byte, error)
 	ReadDockerfile(ctx context.Context, relPath string) ([]byte, error)
 	IsDockerignoreExistAnywhere(ctx context.Context, relPath string) (bool, error)
 	ReadDockerignore(ctx context.Context, relPath string) ([]byte, error)
+	IsLockFileExistAnywhere(ctx context.Context) (bool, error)
+	ReadLockFile(ctx context.Context) ([]byte, error)
 
 	HelmChartExtender
 }
@@ -47,6 +53,12 @@ type Inspector interface {
 	InspectConfigStapelGitBranch() error
 	InspectConfigStapelMountBuildDir() error
 	InspectConfigStapelMountFromPath(fromPath string) error
+	InspectConfigStapelStageDependenciesEnv(envName string) error
 	InspectConfigDockerfileContextAddFile(relPath string) error
+	InspectConfigDockerfileRemoteContextBranch() error
+	InspectConfigDockerfileAddGitBranch(url string) error
 	InspectBuildContextFiles(ctx context.Context, matcher path_matcher.PathMatcher) error
+	InspectHelmSetFlags() error
+	InspectHelmValuesFile(relPath string) error
+	InspectHelmEnv(envName string) error
 }
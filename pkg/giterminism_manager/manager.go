@@ -16,6 +16,9 @@ import (
 type NewManagerOptions struct {
 	LooseGiterminism bool
 	Dev              bool
+
+	// CollectViolations makes the manager collect all giterminism violations instead of failing on the first one.
+	CollectViolations bool
 }
 
 func NewManager(ctx context.Context, projectDir string, localGitRepo *git_repo.Local, headCommit string, options NewManagerOptions) (Interface, error) {
@@ -27,6 +30,10 @@ func NewManager(ctx context.Context, projectDir string, localGitRepo *git_repo.L
 		dev:              options.Dev,
 	}
 
+	if options.CollectViolations {
+		sharedOptions.violations = &errors.Violations{}
+	}
+
 	if options.LooseGiterminism {
 		err := errors.NewError(`DEPRECATION WARNING: The --loose-giterminism option (and WERF_LOOSE_GITERMINISM env variable) is forbidden and will be removed in v1.2!
 Please use werf-giterminism.yaml config instead to loosen giterminism restrictions if needed.`)
@@ -70,12 +77,21 @@ func (m Manager) Inspector() Inspector {
 	return m.inspector
 }
 
+func (m Manager) Violations() []errors.Violation {
+	if m.violations == nil {
+		return nil
+	}
+
+	return m.violations.List()
+}
+
 type sharedOptions struct {
 	projectDir       string
 	headCommit       string
 	localGitRepo     *git_repo.Local
 	looseGiterminism bool
 	dev              bool
+	violations       *errors.Violations
 }
 
 func (s *sharedOptions) ProjectDir() string {
@@ -101,3 +117,13 @@ func (s *sharedOptions) LooseGiterminism() bool {
 func (s *sharedOptions) Dev() bool {
 	return s.dev
 }
+
+// ReportViolation saves the violation and returns true if the violations collecting mode is enabled.
+func (s *sharedOptions) ReportViolation(message, snippet string) bool {
+	if s.violations == nil {
+		return false
+	}
+
+	s.violations.Add(message, snippet)
+	return true
+}
@@ -0,0 +1,215 @@
+package host_cleaning
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/dustin/go-humanize"
+
+	"github.com/werf/lockgate"
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/stapel"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/volumeutils"
+	"github.com/werf/werf/pkg/werf"
+)
+
+type CacheMountDesc struct {
+	Id         string
+	VolumeName string
+	Size       int64
+	LastUsedAt time.Time
+}
+
+type CacheMountsLruSort []*CacheMountDesc
+
+func (a CacheMountsLruSort) Len() int { return len(a) }
+func (a CacheMountsLruSort) Less(i, j int) bool {
+	return a[i].LastUsedAt.Before(a[j].LastUsedAt)
+}
+func (a CacheMountsLruSort) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+
+// GetCacheMountsDescs returns cache mount volumes sorted from the least recently used.
+func GetCacheMountsDescs(ctx context.Context) ([]*CacheMountDesc, error) {
+	volumes, err := getCacheMountVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+
+	sizeByName := map[string]int64{}
+	if diskUsage, err := docker.DiskUsage(ctx); err != nil {
+		return nil, fmt.Errorf("unable to get docker disk usage: %s", err)
+	} else {
+		for _, volume := range diskUsage.Volumes {
+			if volume.UsageData != nil {
+				sizeByName[volume.Name] = volume.UsageData.Size
+			}
+		}
+	}
+
+	return newLruSortedCacheMountsDescs(ctx, volumes, sizeByName, lrumeta.CommonLRUCacheMountsCache)
+}
+
+// newLruSortedCacheMountsDescs returns descs of the volumes sorted from the least recently used.
+// The creation time is used for the volumes which have not been accessed yet.
+func newLruSortedCacheMountsDescs(ctx context.Context, volumes []*types.Volume, sizeByName map[string]int64, lruCache *lrumeta.LRUCacheMountsCache) ([]*CacheMountDesc, error) {
+	var descs []*CacheMountDesc
+	for _, volume := range volumes {
+		lastUsedAt, err := lruCache.GetCacheMountLastAccessTime(ctx, volume.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error accessing last recently used cache mounts cache: %s", err)
+		}
+
+		desc := newCacheMountDesc(volume)
+		desc.Size = sizeByName[volume.Name]
+		if !lastUsedAt.IsZero() {
+			desc.LastUsedAt = lastUsedAt
+		}
+		descs = append(descs, desc)
+	}
+
+	sort.Sort(CacheMountsLruSort(descs))
+
+	return descs, nil
+}
+
+// RunGCForCacheMounts removes the least recently used cache mount volumes while the docker server storage volume usage exceeds the allowed level.
+// Cache mounts used by running builds are skipped.
+func RunGCForCacheMounts(ctx context.Context, allowedVolumeUsagePercentage, allowedVolumeUsageMarginPercentage float64, dockerServerStoragePath string, dryRun bool) error {
+	if dockerServerStoragePath == "" {
+		return nil
+	}
+
+	vu, err := volumeutils.GetVolumeUsageByPath(ctx, dockerServerStoragePath)
+	if err != nil {
+		return fmt.Errorf("error getting volume usage by path %q: %s", dockerServerStoragePath, err)
+	}
+
+	if vu.Percentage <= allowedVolumeUsagePercentage {
+		return nil
+	}
+
+	targetVolumeUsage := allowedVolumeUsagePercentage - allowedVolumeUsageMarginPercentage
+	if targetVolumeUsage < 0 {
+		targetVolumeUsage = 0
+	}
+	bytesToFree := uint64((float64(vu.TotalBytes) / 100.0) * (vu.Percentage - targetVolumeUsage))
+
+	descs, err := GetCacheMountsDescs(ctx)
+	if err != nil {
+		return err
+	}
+
+	freedBytes, err := removeCacheMountsUntilFreed(descs, bytesToFree, func(desc *CacheMountDesc) (bool, error) {
+		return removeCacheMount(ctx, desc, true, dryRun)
+	})
+	if err != nil {
+		return err
+	}
+
+	logboek.Context(ctx).Default().LogF("Freed by cache mounts: %s\n", humanize.Bytes(freedBytes))
+
+	return nil
+}
+
+// removeCacheMountsUntilFreed removes the cache mounts in the given order and keeps the rest once bytesToFree are freed.
+func removeCacheMountsUntilFreed(descs []*CacheMountDesc, bytesToFree uint64, removeFunc func(desc *CacheMountDesc) (bool, error)) (uint64, error) {
+	var freedBytes uint64
+	for _, desc := range descs {
+		if freedBytes >= bytesToFree {
+			break
+		}
+
+		removed, err := removeFunc(desc)
+		if err != nil {
+			return freedBytes, err
+		}
+
+		if removed {
+			freedBytes += uint64(desc.Size)
+		}
+	}
+
+	return freedBytes, nil
+}
+
+func purgeCacheMounts(ctx context.Context, dryRun bool) error {
+	volumes, err := getCacheMountVolumes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range volumes {
+		if _, err := removeCacheMount(ctx, newCacheMountDesc(volume), false, dryRun); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getCacheMountVolumes(ctx context.Context) ([]*types.Volume, error) {
+	filterSet := filters.NewArgs()
+	filterSet.Add("label", image.WerfCacheMountLabel)
+
+	volumes, err := docker.VolumeList(ctx, filterSet)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get cache mount volumes: %s", err)
+	}
+
+	return volumes, nil
+}
+
+func newCacheMountDesc(volume *types.Volume) *CacheMountDesc {
+	return &CacheMountDesc{
+		Id:         volume.Labels[image.WerfCacheMountLabel],
+		VolumeName: volume.Name,
+		LastUsedAt: getVolumeCreatedAt(volume),
+	}
+}
+
+func removeCacheMount(ctx context.Context, desc *CacheMountDesc, skipLocked, dryRun bool) (bool, error) {
+	lockName := stapel.CacheMountLockName(desc.Id)
+	isLocked, lock, err := werf.AcquireHostLock(ctx, lockName, lockgate.AcquireOptions{NonBlocking: skipLocked})
+	if err != nil {
+		return false, fmt.Errorf("error locking cache mount %q: %s", desc.Id, err)
+	}
+
+	if !isLocked {
+		logboek.Context(ctx).Default().LogFDetails("Cache mount %q is used at the moment: skip removal\n", desc.Id)
+		return false, nil
+	}
+	defer werf.ReleaseHostLock(lock)
+
+	logboek.Context(ctx).Default().LogF("Removing cache mount volume %s (%s, last used at %s)\n", desc.VolumeName, humanize.Bytes(uint64(desc.Size)), desc.LastUsedAt.Format(time.RFC3339))
+	if dryRun {
+		return true, nil
+	}
+
+	if err := docker.VolumeRm(ctx, desc.VolumeName, false); err != nil {
+		logboek.Context(ctx).Warn().LogF("WARNING: failed to remove cache mount volume %s: %s\n", desc.VolumeName, err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func getVolumeCreatedAt(volume *types.Volume) time.Time {
+	t, err := time.Parse(time.RFC3339, volume.CreatedAt)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
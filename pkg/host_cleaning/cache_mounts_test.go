@@ -0,0 +1,125 @@
+package host_cleaning
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+	"github.com/werf/werf/pkg/werf"
+)
+
+var _ = Describe("cache mounts LRU", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "werf-cache-mounts-")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(werf.Init(filepath.Join(tmpDir, "tmp"), filepath.Join(tmpDir, "home"))).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		Ω(os.RemoveAll(tmpDir)).Should(Succeed())
+	})
+
+	newVolume := func(id string, createdAt time.Time) *types.Volume {
+		return &types.Volume{
+			Name:      "werf-cache-mount-" + id,
+			Labels:    map[string]string{image.WerfCacheMountLabel: id},
+			CreatedAt: createdAt.Format(time.RFC3339),
+		}
+	}
+
+	It("sorts the cache mounts from the least recently used", func() {
+		ctx := context.Background()
+		lruCache := lrumeta.NewLRUCacheMountsCache(filepath.Join(tmpDir, "lru_cache_mounts"))
+
+		now := time.Now()
+		volumes := []*types.Volume{
+			newVolume("apt", now.Add(-3*time.Hour)),
+			newVolume("npm", now.Add(-2*time.Hour)),
+			newVolume("go", now.Add(-time.Hour)),
+		}
+
+		// apt is created first, but used last
+		Ω(lruCache.AccessCacheMount(ctx, "werf-cache-mount-apt")).Should(Succeed())
+
+		descs, err := newLruSortedCacheMountsDescs(ctx, volumes, map[string]int64{"werf-cache-mount-npm": 100}, lruCache)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		var ids []string
+		for _, desc := range descs {
+			ids = append(ids, desc.Id)
+		}
+		Ω(ids).Should(Equal([]string{"npm", "go", "apt"}))
+		Ω(descs[0].Size).Should(Equal(int64(100)))
+		Ω(descs[0].LastUsedAt.Unix()).Should(Equal(now.Add(-2 * time.Hour).Unix()))
+	})
+
+	Describe("removeCacheMountsUntilFreed", func() {
+		descs := []*CacheMountDesc{
+			{Id: "a", Size: 100},
+			{Id: "b", Size: 200},
+			{Id: "c", Size: 300},
+			{Id: "d", Size: 400},
+		}
+
+		removeAll := func(removed *[]string, skipIds ...string) func(desc *CacheMountDesc) (bool, error) {
+			return func(desc *CacheMountDesc) (bool, error) {
+				for _, id := range skipIds {
+					if desc.Id == id {
+						return false, nil
+					}
+				}
+
+				*removed = append(*removed, desc.Id)
+				return true, nil
+			}
+		}
+
+		It("removes the least recently used cache mounts and keeps the rest once enough space is freed", func() {
+			var removed []string
+			freedBytes, err := removeCacheMountsUntilFreed(descs, 250, removeAll(&removed))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(freedBytes).Should(Equal(uint64(300)))
+			Ω(removed).Should(Equal([]string{"a", "b"}))
+		})
+
+		It("does not count the skipped cache mounts", func() {
+			var removed []string
+			freedBytes, err := removeCacheMountsUntilFreed(descs, 250, removeAll(&removed, "a"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(freedBytes).Should(Equal(uint64(500)))
+			Ω(removed).Should(Equal([]string{"b", "c"}))
+		})
+
+		It("removes nothing if there is nothing to free", func() {
+			var removed []string
+			freedBytes, err := removeCacheMountsUntilFreed(descs, 0, removeAll(&removed))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(freedBytes).Should(BeZero())
+			Ω(removed).Should(BeEmpty())
+		})
+
+		It("stops on the removal error", func() {
+			freedBytes, err := removeCacheMountsUntilFreed(descs, 1000, func(desc *CacheMountDesc) (bool, error) {
+				if desc.Id == "b" {
+					return false, errors.New("error")
+				}
+				return true, nil
+			})
+			Ω(err).Should(HaveOccurred())
+			Ω(freedBytes).Should(Equal(uint64(100)))
+		})
+	})
+})
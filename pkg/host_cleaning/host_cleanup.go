@@ -56,6 +56,7 @@ func RunAutoHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 		logboek.Context(ctx).Default().LogFDetails(" - old unused files from werf caches (which are stored in the ~/.werf/local_cache);\n")
 		logboek.Context(ctx).Default().LogFDetails(" - old temporary service files /tmp/werf-project-data-* and /tmp/werf-config-render-*;\n")
 		logboek.Context(ctx).Default().LogFDetails(" - least recently used werf images;\n")
+		logboek.Context(ctx).Default().LogFDetails(" - least recently used cache mount volumes;\n")
 		logboek.Context(ctx).Default().LogLn()
 		logboek.Context(ctx).Default().LogFDetails("NOTE: Werf-host-cleanup procedure of v1.2 werf version will not cleanup --stages-storage=:local stages of v1.1 werf version, because this is primary stages storage data, and it can only be cleaned by the regular per-project werf-cleanup command with git-history based algorithm.\n")
 		logboek.Context(ctx).Default().LogLn()
@@ -97,11 +98,20 @@ func RunHostCleanup(ctx context.Context, options HostCleanupOptions) error {
 		return fmt.Errorf("error getting local docker server storage path: %s", err)
 	}
 
-	return logboek.Context(ctx).Default().LogProcess("Running GC for local docker server").DoError(func() error {
+	if err := logboek.Context(ctx).Default().LogProcess("Running GC for local docker server").DoError(func() error {
 		if err := RunGCForLocalDockerServer(ctx, allowedDockerStorageVolumeUsagePercentage, allowedDockerStorageVolumeUsageMarginPercentage, dockerServerStoragePath, options.Force, options.DryRun); err != nil {
 			return fmt.Errorf("local docker server GC failed: %s", err)
 		}
 		return nil
+	}); err != nil {
+		return err
+	}
+
+	return logboek.Context(ctx).Default().LogProcess("Running GC for cache mounts").DoError(func() error {
+		if err := RunGCForCacheMounts(ctx, allowedDockerStorageVolumeUsagePercentage, allowedDockerStorageVolumeUsageMarginPercentage, dockerServerStoragePath, options.DryRun); err != nil {
+			return fmt.Errorf("cache mounts GC failed: %s", err)
+		}
+		return nil
 	})
 }
 
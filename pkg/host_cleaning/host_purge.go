@@ -69,6 +69,12 @@ func HostPurge(ctx context.Context, options HostPurgeOptions) error {
 		return err
 	}
 
+	if err := logboek.Context(ctx).LogProcess("Running werf cache mounts purge").DoError(func() error {
+		return purgeCacheMounts(ctx, commonOptions.DryRun)
+	}); err != nil {
+		return err
+	}
+
 	if err := tmp_manager.Purge(ctx, commonOptions.DryRun); err != nil {
 		return fmt.Errorf("tmp files purge failed: %s", err)
 	}
@@ -123,6 +123,18 @@ func GetLocalDockerServerStorageCheck(ctx context.Context, dockerServerStoragePa
 		images = append(images, imgs...)
 	}
 
+	{
+		// intermediate images of the fine-grained user stages steps
+		filterSet := filters.NewArgs()
+		filterSet.Add("label", image.WerfStageStepLabel)
+
+		imgs, err := docker.Images(ctx, types.ImageListOptions{Filters: filterSet})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get werf stage step docker images: %s", err)
+		}
+		images = append(images, imgs...)
+	}
+
 	{
 		filterSet := filters.NewArgs()
 		filterSet.Add("label", image.WerfLabel)
@@ -7,6 +7,7 @@ const (
 	WerfCacheVersionLabel         = "werf-cache-version"
 	WerfImageLabel                = "werf-image"
 	WerfDevLabel                  = "werf-dev"
+	WerfDevStagesTTLLabel         = "werf-dev-stages-ttl"
 	WerfDockerImageName           = "werf-docker-image-name"
 	WerfStageDigestLabel          = "werf-stage-digest"
 	WerfStageContentDigestLabel   = "werf-stage-content-digest"
@@ -20,8 +21,17 @@ const (
 	WerfMountTmpDirLabel          = "werf-mount-type-tmp-dir"
 	WerfMountBuildDirLabel        = "werf-mount-type-build-dir"
 	WerfMountCustomDirLabelPrefix = "werf-mount-type-custom-dir-"
+	WerfMountCacheLabelPrefix     = "werf-mount-type-cache-"
+
+	WerfCacheMountLabel = "werf-cache-mount"
+
+	WerfStageStepLabel = "werf-stage-step"
 
 	BuildCacheVersion = "1.2"
 
 	StageContainerNamePrefix = "werf.build."
+
+	ScratchImageName = "werf-scratch:latest"
+
+	StageStepImageRepository = "werf-stage-step"
 )
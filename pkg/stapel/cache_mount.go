@@ -0,0 +1,40 @@
+package stapel
+
+import (
+	"context"
+	"fmt"
+
+	volumetypes "github.com/docker/docker/api/types/volume"
+
+	"github.com/werf/werf/pkg/docker"
+	"github.com/werf/werf/pkg/image"
+	"github.com/werf/werf/pkg/storage/lrumeta"
+)
+
+// CacheMountVolumeName returns the name of the host docker volume of the cache mount, the volume is shared by all projects on the host.
+func CacheMountVolumeName(id string) string {
+	return fmt.Sprintf("werf-cache-mount-%s", id)
+}
+
+func CacheMountLockName(id string) string {
+	return fmt.Sprintf("cache_mount.%s", id)
+}
+
+// CreateCacheMountVolume creates the cache mount volume if it does not exist and records the access time for the host cleanup.
+// The cache mount lock should be held by the caller.
+func CreateCacheMountVolume(ctx context.Context, id string) error {
+	volumeName := CacheMountVolumeName(id)
+
+	if _, err := docker.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+		Name:   volumeName,
+		Labels: map[string]string{image.WerfCacheMountLabel: id},
+	}); err != nil {
+		return fmt.Errorf("unable to create cache mount volume %s: %s", volumeName, err)
+	}
+
+	if err := lrumeta.CommonLRUCacheMountsCache.AccessCacheMount(ctx, volumeName); err != nil {
+		return fmt.Errorf("error accessing last recently used cache mounts cache: %s", err)
+	}
+
+	return nil
+}
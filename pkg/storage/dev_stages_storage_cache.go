@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/werf/werf/pkg/image"
+)
+
+// DevStagesStorageCache keeps stages of the dev stages storage apart from the project stages in the same underlying cache.
+type DevStagesStorageCache struct {
+	StagesStorageCache StagesStorageCache
+}
+
+func NewDevStagesStorageCache(stagesStorageCache StagesStorageCache) *DevStagesStorageCache {
+	return &DevStagesStorageCache{StagesStorageCache: stagesStorageCache}
+}
+
+func (cache *DevStagesStorageCache) String() string {
+	return fmt.Sprintf("%s (dev)", cache.StagesStorageCache.String())
+}
+
+func (cache *DevStagesStorageCache) GetAllStages(ctx context.Context, projectName string) (bool, []image.StageID, error) {
+	return cache.StagesStorageCache.GetAllStages(ctx, devProjectName(projectName))
+}
+
+func (cache *DevStagesStorageCache) DeleteAllStages(ctx context.Context, projectName string) error {
+	return cache.StagesStorageCache.DeleteAllStages(ctx, devProjectName(projectName))
+}
+
+func (cache *DevStagesStorageCache) GetStagesByDigest(ctx context.Context, projectName, digest string) (bool, []image.StageID, error) {
+	return cache.StagesStorageCache.GetStagesByDigest(ctx, devProjectName(projectName), digest)
+}
+
+func (cache *DevStagesStorageCache) StoreStagesByDigest(ctx context.Context, projectName, digest string, stages []image.StageID) error {
+	return cache.StagesStorageCache.StoreStagesByDigest(ctx, devProjectName(projectName), digest, stages)
+}
+
+func (cache *DevStagesStorageCache) DeleteStagesByDigest(ctx context.Context, projectName, digest string) error {
+	return cache.StagesStorageCache.DeleteStagesByDigest(ctx, devProjectName(projectName), digest)
+}
+
+func devProjectName(projectName string) string {
+	return projectName + DevStagesStorageAddressSuffix
+}
@@ -0,0 +1,23 @@
+package lrumeta
+
+import (
+	"context"
+	"time"
+)
+
+// LRUCacheMountsCache keeps access timestamps of the cache mount volumes, records are stored the same way as for images.
+type LRUCacheMountsCache struct {
+	records *LRUImagesCache
+}
+
+func NewLRUCacheMountsCache(cacheDir string) *LRUCacheMountsCache {
+	return &LRUCacheMountsCache{records: NewLRUImagesCache(cacheDir)}
+}
+
+func (cache *LRUCacheMountsCache) AccessCacheMount(ctx context.Context, volumeName string) error {
+	return cache.records.AccessImage(ctx, volumeName)
+}
+
+func (cache *LRUCacheMountsCache) GetCacheMountLastAccessTime(ctx context.Context, volumeName string) (time.Time, error) {
+	return cache.records.GetImageLastAccessTime(ctx, volumeName)
+}
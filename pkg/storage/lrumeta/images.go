@@ -15,12 +15,19 @@ import (
 	"github.com/werf/werf/pkg/werf"
 )
 
-const LRUImagesCacheVersion = "1"
+const (
+	LRUImagesCacheVersion      = "1"
+	LRUCacheMountsCacheVersion = "1"
+)
 
-var CommonLRUImagesCache *LRUImagesCache
+var (
+	CommonLRUImagesCache      *LRUImagesCache
+	CommonLRUCacheMountsCache *LRUCacheMountsCache
+)
 
 func Init() error {
 	CommonLRUImagesCache = NewLRUImagesCache(filepath.Join(werf.GetLocalCacheDir(), "lru_images", LRUImagesCacheVersion))
+	CommonLRUCacheMountsCache = NewLRUCacheMountsCache(filepath.Join(werf.GetLocalCacheDir(), "lru_cache_mounts", LRUCacheMountsCacheVersion))
 	return nil
 }
 
@@ -51,6 +51,22 @@ func getDigestAndUniqueIDFromRepoStageImageTag(repoStageImageTag string) (string
 	}
 }
 
+// IsRepoStageOrServiceImageTag returns true if the tag is used by the repo stages storage for stages or service records.
+func IsRepoStageOrServiceImageTag(tag string) bool {
+	for _, prefix := range []string{RepoManagedImageRecord_ImageTagPrefix, RepoImageMetadataByCommitRecord_ImageTagPrefix, RepoImportMetadata_ImageTagPrefix, RepoClientIDRecrod_ImageTagPrefix} {
+		if strings.HasPrefix(tag, prefix) {
+			return true
+		}
+	}
+
+	if strings.HasSuffix(tag, RepoRejectedStageImageRecord_ImageTagSuffix) {
+		return true
+	}
+
+	_, _, err := getDigestAndUniqueIDFromRepoStageImageTag(tag)
+	return err == nil
+}
+
 func isUnexpectedTagFormatError(err error) bool {
 	return strings.HasPrefix(err.Error(), UnexpectedTagFormatErrorPrefix)
 }
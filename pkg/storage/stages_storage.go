@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/werf/werf/pkg/container_runtime"
 	"github.com/werf/werf/pkg/image"
@@ -15,6 +16,9 @@ const (
 	DefaultKubernetesStorageAddress  = "kubernetes://werf-synchronization"
 	DefaultHttpSynchronizationServer = "https://synchronization.werf.io"
 	NamelessImageRecordTag           = "__nameless__"
+
+	DevStagesStorageAddressSuffix = "-dev"
+	DefaultDevStagesTTL           = 24 * time.Hour
 )
 
 var ErrBrokenImage = errors.New("broken image")
@@ -9,13 +9,16 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/filemode"
 
 	"github.com/werf/logboek"
 
 	"github.com/werf/werf/pkg/git_repo/repo_handle"
 	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/true_git/lfs"
 	"github.com/werf/werf/pkg/true_git/ls_tree"
 	"github.com/werf/werf/pkg/util"
 )
@@ -25,6 +28,8 @@ type ArchiveOptions struct {
 	PathScope   string // Determines the directory that will get into the result (similar to <pathspec> in the git commands).
 	PathMatcher path_matcher.PathMatcher
 	FileRenames map[string]string // Files to rename during archiving. Git repo relative paths of original files as keys, new filenames (without base path) as values.
+
+	SparseCheckoutPatterns []string // Limits the files checked out into the work tree, not a part of the archive ID.
 }
 
 // TODO: 1.3 add git mapping type (dir, file, ...) to gitArchive stage digest
@@ -41,7 +46,7 @@ func (opts ArchiveOptions) ID() string {
 			opts.Commit,
 			opts.PathScope,
 			opts.PathMatcher.ID(),
-		)...
+		)...,
 	)
 }
 
@@ -74,7 +79,7 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 		return fmt.Errorf("bad work tree cache dir %s: %s", workTreeCacheDir, err)
 	}
 
-	workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, opts.Commit, withSubmodules)
+	workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, opts.Commit, WithWorkTreeOptions{HasSubmodules: withSubmodules, SparseCheckoutPatterns: opts.SparseCheckoutPatterns})
 	if err != nil {
 		return fmt.Errorf("cannot prepare work tree in cache %s for commit %s: %s", workTreeCacheDir, opts.Commit, err)
 	}
@@ -84,7 +89,9 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 		return fmt.Errorf("git open failed: %s", err)
 	}
 
-	repoHandle, err := repo_handle.NewHandle(repository)
+	repoHandle, err := repo_handle.NewHandleWithMissingBlobReader(repository, func(hash plumbing.Hash) ([]byte, error) {
+		return ReadBlob(gitDir, hash.String())
+	})
 	if err != nil {
 		return err
 	}
@@ -107,6 +114,9 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 	}
 	logProcess.End()
 
+	// the common git dir with the local lfs store is resolved on the first git lfs pointer
+	var gitCommonDir string
+
 	logProcess = logboek.Context(ctx).Debug().LogProcess("ls-tree result walk (%s)", opts.PathMatcher.String())
 	logProcess.Start()
 	if err := result.Walk(func(lsTreeEntry *ls_tree.LsTreeEntry) error {
@@ -129,11 +139,37 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 
 		switch gitFileMode {
 		case filemode.Regular, filemode.Executable, filemode.Deprecated:
+			contentFilepath, contentSize := absFilepath, info.Size()
+
+			// only files marked with filter=lfs are resolved as git lfs pointers
+			var pointer *lfs.Pointer
+			if lsTreeEntry.LfsOid != "" {
+				pointer, err = lfs.ReadPointerFile(absFilepath, info.Size())
+				if err != nil {
+					return err
+				}
+			}
+
+			if pointer != nil {
+				if gitCommonDir == "" {
+					gitCommonDir, err = getGitCommonDir(gitDir)
+					if err != nil {
+						return err
+					}
+				}
+
+				contentFilepath, err = pointer.GetObjectPath(gitCommonDir)
+				if err != nil {
+					return fmt.Errorf("unable to resolve git lfs pointer %s: %s", lsTreeEntry.FullFilepath, err)
+				}
+				contentSize = pointer.Size
+			}
+
 			err = tw.WriteHeader(&tar.Header{
 				Format:     tar.FormatGNU,
 				Name:       tarEntryName,
 				Mode:       int64(gitFileMode),
-				Size:       info.Size(),
+				Size:       contentSize,
 				ModTime:    info.ModTime(),
 				AccessTime: info.ModTime(),
 				ChangeTime: info.ModTime(),
@@ -142,19 +178,19 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 				return fmt.Errorf("unable to write tar header for file %s: %s", tarEntryName, err)
 			}
 
-			f, err := os.Open(absFilepath)
+			f, err := os.Open(contentFilepath)
 			if err != nil {
-				return fmt.Errorf("unable to open file %s: %s", absFilepath, err)
+				return fmt.Errorf("unable to open file %s: %s", contentFilepath, err)
 			}
 
 			_, err = io.Copy(tw, f)
 			if err != nil {
-				return fmt.Errorf("unable to write data to tar archive from file %s: %s", absFilepath, err)
+				return fmt.Errorf("unable to write data to tar archive from file %s: %s", contentFilepath, err)
 			}
 
 			err = f.Close()
 			if err != nil {
-				return fmt.Errorf("error closing file %s: %s", absFilepath, err)
+				return fmt.Errorf("error closing file %s: %s", contentFilepath, err)
 			}
 
 			if debugArchive() {
@@ -216,3 +252,21 @@ func writeArchive(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir s
 
 	return nil
 }
+
+// getGitCommonDir returns the git dir shared by all work trees of the repository.
+func getGitCommonDir(gitDir string) (string, error) {
+	cmd := newGitCmd(append(getCommonGitOptions(), "rev-parse", "--git-common-dir")...)
+	cmd.Dir = gitDir
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%q failed (%s): %s:\n%s", strings.Join(append([]string{cmd.Path}, cmd.Args[1:]...), " "), gitDir, err, output)
+	}
+
+	commonDir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+
+	return commonDir, nil
+}
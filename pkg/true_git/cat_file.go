@@ -0,0 +1,23 @@
+package true_git
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ReadBlob reads the blob content with git, so the blob missing in the partial clone is fetched from the promisor remote
+// with the authorization headers registered by SetHTTPBasicAuth.
+func ReadBlob(gitDir, hash string) ([]byte, error) {
+	gitArgs := append(getCommonGitOptions(), "-C", gitDir, "cat-file", "blob", hash)
+	cmd := newGitCmd(gitArgs...)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("'git cat-file blob %s' failed: %s:\n%s", hash, err, stderr.String())
+	}
+
+	return output, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"os"
 	"os/exec"
 
 	"github.com/werf/logboek"
@@ -26,3 +27,13 @@ func SetCommandRecordingLiveOutput(ctx context.Context, cmd *exec.Cmd) *bytes.Bu
 func getCommonGitOptions() []string {
 	return []string{"-c", "core.autocrlf=false"}
 }
+
+// newGitCmd creates git command with the environment of the werf process and the authorization headers registered by SetHTTPBasicAuth.
+func newGitCmd(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	if env := getHTTPAuthEnv(os.Getenv("GIT_CONFIG_COUNT")); len(env) != 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	return cmd
+}
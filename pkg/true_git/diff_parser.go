@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/true_git/lfs"
 	"github.com/werf/werf/pkg/util"
 )
 
@@ -226,6 +227,9 @@ func (p *diffParser) handleDiffLine(line string) error {
 		if strings.HasPrefix(line, "Submodule ") {
 			return p.handleSubmoduleLine(line)
 		}
+		if len(line) > 0 && line[1:] == lfs.PointerVersionLine {
+			return p.handleLfsPointerLine(line)
+		}
 		return p.writeOutLine(line)
 	}
 
@@ -427,6 +431,16 @@ func (p *diffParser) handleShortBinaryHeader(line string) error {
 	return p.writeOutLine(line)
 }
 
+// handleLfsPointerLine marks the paths of the git lfs pointer as binary:
+// the patch contains pointer changes only, therefore the file content should be taken from the archive.
+func (p *diffParser) handleLfsPointerLine(line string) error {
+	for _, path := range p.LastSeenPaths {
+		p.BinaryPaths = appendUnique(p.BinaryPaths, path)
+	}
+
+	return p.writeOutLine(line)
+}
+
 func (p *diffParser) applyFileRenames(path string) string {
 	if renamedFileName, willRename := p.FileRenames[path]; willRename {
 		return filepath.ToSlash(filepath.Join(p.PathScope, renamedFileName))
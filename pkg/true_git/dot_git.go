@@ -3,7 +3,6 @@ package true_git
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -43,7 +42,7 @@ func IsValidWorkTree(workTree string) (bool, error) {
 func IsValidGitDir(gitDir string) (bool, error) {
 	gitArgs := append(getCommonGitOptions(), []string{"--git-dir", gitDir, "rev-parse"}...)
 
-	cmd := exec.Command("git", gitArgs...)
+	cmd := newGitCmd(gitArgs...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
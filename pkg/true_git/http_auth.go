@@ -0,0 +1,69 @@
+package true_git
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/Masterminds/semver"
+)
+
+const httpAuthMinGitVersion = "2.31.0"
+
+var (
+	httpAuthHeaders      = map[string]string{}
+	httpAuthHeadersMutex sync.Mutex
+)
+
+// SetHTTPBasicAuth registers the basic authorization header for the url, the header is passed to git commands run by true_git.
+// The header is set through the GIT_CONFIG_COUNT environment variables of each command to keep credentials out of the command line,
+// git config files and the environment of the werf process.
+func SetHTTPBasicAuth(url, username, password string) error {
+	if gitVersion.LessThan(semver.MustParse(httpAuthMinGitVersion)) {
+		return fmt.Errorf("git version >= %s required to pass credentials for %s, current git version is %s", httpAuthMinGitVersion, url, gitVersion.String())
+	}
+
+	httpAuthHeadersMutex.Lock()
+	defer httpAuthHeadersMutex.Unlock()
+
+	credentials := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
+	httpAuthHeaders[url] = fmt.Sprintf("Authorization: Basic %s", credentials)
+
+	return nil
+}
+
+// getHTTPAuthEnv returns GIT_CONFIG_* variables with the registered authorization headers,
+// the variables are appended to the ones already passed by the user with the given GIT_CONFIG_COUNT.
+func getHTTPAuthEnv(gitConfigCount string) []string {
+	httpAuthHeadersMutex.Lock()
+	defer httpAuthHeadersMutex.Unlock()
+
+	if len(httpAuthHeaders) == 0 {
+		return nil
+	}
+
+	count := 0
+	if gitConfigCount != "" {
+		// git fails on a bad GIT_CONFIG_COUNT value anyway
+		count, _ = strconv.Atoi(gitConfigCount)
+	}
+
+	var urls []string
+	for url := range httpAuthHeaders {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	var env []string
+	for _, url := range urls {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=http.%s.extraHeader", count, url),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", count, httpAuthHeaders[url]),
+		)
+		count++
+	}
+
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", count))
+}
@@ -0,0 +1,62 @@
+package true_git
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("getHTTPAuthEnv", func() {
+	AfterEach(func() {
+		httpAuthHeaders = map[string]string{}
+	})
+
+	It("should return nothing when no credentials registered", func() {
+		Expect(getHTTPAuthEnv("")).To(BeEmpty())
+		Expect(getHTTPAuthEnv("2")).To(BeEmpty())
+	})
+
+	It("should pass authorization headers of all registered urls", func() {
+		httpAuthHeaders["https://example.com/b.git"] = "Authorization: Basic Yg=="
+		httpAuthHeaders["https://example.com/a.git"] = "Authorization: Basic YQ=="
+
+		Expect(getHTTPAuthEnv("")).To(Equal([]string{
+			"GIT_CONFIG_KEY_0=http.https://example.com/a.git.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic YQ==",
+			"GIT_CONFIG_KEY_1=http.https://example.com/b.git.extraHeader",
+			"GIT_CONFIG_VALUE_1=Authorization: Basic Yg==",
+			"GIT_CONFIG_COUNT=2",
+		}))
+	})
+
+	It("should keep the git config variables passed by the user", func() {
+		httpAuthHeaders["https://example.com/a.git"] = "Authorization: Basic YQ=="
+
+		Expect(getHTTPAuthEnv("3")).To(Equal([]string{
+			"GIT_CONFIG_KEY_3=http.https://example.com/a.git.extraHeader",
+			"GIT_CONFIG_VALUE_3=Authorization: Basic YQ==",
+			"GIT_CONFIG_COUNT=4",
+		}))
+	})
+})
+
+var _ = Describe("newGitCmd", func() {
+	AfterEach(func() {
+		httpAuthHeaders = map[string]string{}
+	})
+
+	It("should inherit the process environment when no credentials registered", func() {
+		Expect(newGitCmd("status").Env).To(BeNil())
+	})
+
+	It("should not change the process environment", func() {
+		httpAuthHeaders["https://example.com/a.git"] = "Authorization: Basic YQ=="
+
+		cmd := newGitCmd("status")
+		Expect(cmd.Env).To(ContainElement("GIT_CONFIG_VALUE_0=Authorization: Basic YQ=="))
+		Expect(cmd.Env).To(ContainElement("GIT_CONFIG_COUNT=1"))
+		Expect(cmd.Args).To(Equal([]string{"git", "status"}))
+		Expect(os.Getenv("GIT_CONFIG_VALUE_0")).To(BeEmpty())
+	})
+})
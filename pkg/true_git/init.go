@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 
 	"github.com/Masterminds/semver"
@@ -62,7 +61,7 @@ func Init(opts Options) error {
 }
 
 func getGitCliVersion() (string, error) {
-	cmd := exec.Command("git", "version")
+	cmd := newGitCmd("version")
 
 	out := bytes.Buffer{}
 	cmd.Stdout = &out
@@ -0,0 +1,274 @@
+package lfs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	PointerVersionLine = "version https://git-lfs.github.com/spec/v1"
+
+	// pointerMaxSize is the size limit of the pointer file from the git lfs specification.
+	pointerMaxSize = 1024
+)
+
+type BlobRepository interface {
+	BlobObject(h plumbing.Hash) (*object.Blob, error)
+}
+
+// ReadMissingBlobFunc reads the blob which is not available in the repository storage, e.g. the blob of the partial clone
+// which is fetched by git on demand.
+type ReadMissingBlobFunc func(hash plumbing.Hash) ([]byte, error)
+
+var oidRegexp = regexp.MustCompile(`^sha256:([0-9a-f]{64})$`)
+
+// Pointer is the git lfs pointer file which is stored in git instead of the large file content.
+type Pointer struct {
+	Oid  string
+	Size int64
+}
+
+// ParsePointer returns nil if the data is not a git lfs pointer.
+func ParsePointer(data []byte) *Pointer {
+	if len(data) > pointerMaxSize || !bytes.HasPrefix(data, []byte(PointerVersionLine+"\n")) {
+		return nil
+	}
+
+	pointer := &Pointer{Size: -1}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")[1:] {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil
+		}
+
+		switch parts[0] {
+		case "oid":
+			match := oidRegexp.FindStringSubmatch(parts[1])
+			if match == nil {
+				return nil
+			}
+			pointer.Oid = match[1]
+		case "size":
+			size, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil || size < 0 {
+				return nil
+			}
+			pointer.Size = size
+		}
+	}
+
+	if pointer.Oid == "" || pointer.Size < 0 {
+		return nil
+	}
+
+	return pointer
+}
+
+// ReadPointerFile returns nil if the file is not a git lfs pointer.
+func ReadPointerFile(path string, size int64) (*Pointer, error) {
+	if size > pointerMaxSize {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %s", path, err)
+	}
+
+	return ParsePointer(data), nil
+}
+
+// ReadPointerBlob returns nil if the blob is not a git lfs pointer. Only blobs with a suitable size are read.
+// The blob missing in the repository storage (e.g. the blob of the partial clone) is read with readMissingBlob,
+// the pointer must be detected regardless of the clone, otherwise the file content would be hashed differently.
+func ReadPointerBlob(repository BlobRepository, hash plumbing.Hash, readMissingBlob ReadMissingBlobFunc) (*Pointer, error) {
+	if size, err := getBlobSize(repository, hash); err == plumbing.ErrObjectNotFound {
+		return readMissingPointerBlob(hash, readMissingBlob)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get blob %s size: %s", hash, err)
+	} else if size > pointerMaxSize {
+		return nil, nil
+	}
+
+	blob, err := repository.BlobObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return readMissingPointerBlob(hash, readMissingBlob)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get blob %s: %s", hash, err)
+	}
+
+	if blob.Size > pointerMaxSize {
+		return nil, nil
+	}
+
+	data, err := readBlobObject(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePointer(data), nil
+}
+
+func readMissingPointerBlob(hash plumbing.Hash, readMissingBlob ReadMissingBlobFunc) (*Pointer, error) {
+	data, err := readMissingBlobData(hash, readMissingBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePointer(data), nil
+}
+
+// IsTreePathFilteredByLfs returns true if the file path relative to the tree is marked with the filter=lfs attribute
+// by the .gitattributes files of the tree, only such files are expected to be git lfs pointers.
+// The .gitattributes blobs missing in the repository storage are read with readMissingBlob.
+func IsTreePathFilteredByLfs(repository BlobRepository, tree *object.Tree, path string, readMissingBlob ReadMissingBlobFunc) (bool, error) {
+	return isPathFilteredByLfs(path, func(attributesFilePath string) ([]byte, error) {
+		entry, err := tree.FindEntry(attributesFilePath)
+		if err == object.ErrDirectoryNotFound || err == object.ErrEntryNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get %s: %s", attributesFilePath, err)
+		}
+
+		if !entry.Mode.IsFile() {
+			return nil, nil
+		}
+
+		if data, ok := attributesFilesCache.Load(entry.Hash); ok {
+			return data.([]byte), nil
+		}
+
+		var content []byte
+		if blob, err := repository.BlobObject(entry.Hash); err == plumbing.ErrObjectNotFound {
+			content, err = readMissingBlobData(entry.Hash, readMissingBlob)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %s", attributesFilePath, err)
+			}
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get %s blob %s: %s", attributesFilePath, entry.Hash, err)
+		} else {
+			content, err = readBlobObject(blob)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %s", attributesFilePath, err)
+			}
+		}
+
+		attributesFilesCache.Store(entry.Hash, content)
+		return content, nil
+	})
+}
+
+// attributesFilesCache contains .gitattributes files content by the blob hash.
+var attributesFilesCache sync.Map
+
+// isPathFilteredByLfs reads the .gitattributes files of the path parent directories with readFile (nil data if the file does not exist)
+// and checks the filter attribute of the path, the deeper .gitattributes file takes precedence.
+func isPathFilteredByLfs(path string, readFile func(attributesFilePath string) ([]byte, error)) (bool, error) {
+	pathParts := strings.Split(filepath.ToSlash(path), "/")
+
+	var stack []gitattributes.MatchAttribute
+	for i := 0; i < len(pathParts); i++ {
+		domain := pathParts[:i]
+
+		data, err := readFile(strings.Join(append(append([]string{}, domain...), ".gitattributes"), "/"))
+		if err != nil {
+			return false, err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		attributes, err := gitattributes.ReadAttributes(bytes.NewReader(data), domain, i == 0)
+		if err != nil {
+			return false, fmt.Errorf("unable to parse %s: %s", strings.Join(append(append([]string{}, domain...), ".gitattributes"), "/"), err)
+		}
+
+		stack = append(stack, attributes...)
+	}
+
+	// the last matching line takes precedence
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].Pattern == nil || !stack[i].Pattern.Match(pathParts) {
+			continue
+		}
+
+		for _, attribute := range stack[i].Attributes {
+			if attribute.Name() == "filter" {
+				return attribute.IsValueSet() && attribute.Value() == "lfs", nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func readMissingBlobData(hash plumbing.Hash, readMissingBlob ReadMissingBlobFunc) ([]byte, error) {
+	if readMissingBlob == nil {
+		return nil, fmt.Errorf("blob %s is not available: fetch the missing objects of the partial clone", hash)
+	}
+
+	return readMissingBlob(hash)
+}
+
+func readBlobObject(blob *object.Blob) ([]byte, error) {
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read blob %s: %s", blob.Hash, err)
+	}
+
+	return data, nil
+}
+
+// getBlobSize returns the blob size without reading the blob if the repository storage supports it, otherwise 0 is returned.
+func getBlobSize(repository BlobRepository, hash plumbing.Hash) (int64, error) {
+	gitRepository, ok := repository.(*git.Repository)
+	if !ok {
+		return 0, nil
+	}
+
+	sizer, ok := gitRepository.Storer.(interface {
+		EncodedObjectSize(plumbing.Hash) (int64, error)
+	})
+	if !ok {
+		return 0, nil
+	}
+
+	return sizer.EncodedObjectSize(hash)
+}
+
+// GetObjectPath returns the path of the object in the local lfs store of the git repository.
+func (p *Pointer) GetObjectPath(gitCommonDir string) (string, error) {
+	path := filepath.Join(gitCommonDir, "lfs", "objects", p.Oid[0:2], p.Oid[2:4], p.Oid)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("git lfs object %s is not found in the local lfs store %s: run git lfs fetch to download it", p.Oid, filepath.Join(gitCommonDir, "lfs"))
+	} else if err != nil {
+		return "", fmt.Errorf("unable to access %s: %s", path, err)
+	}
+
+	if info.Size() != p.Size {
+		return "", fmt.Errorf("git lfs object %s size %d does not match the pointer size %d", path, info.Size(), p.Size)
+	}
+
+	return path, nil
+}
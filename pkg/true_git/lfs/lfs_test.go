@@ -0,0 +1,223 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestParsePointer(t *testing.T) {
+	oid := "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+	tests := []struct {
+		name     string
+		data     string
+		expected *Pointer
+	}{
+		{
+			name:     "pointer",
+			data:     "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n",
+			expected: &Pointer{Oid: oid, Size: 12345},
+		},
+		{
+			name:     "pointerWithExtension",
+			data:     "version https://git-lfs.github.com/spec/v1\next-0-foo sha256:" + oid + "\noid sha256:" + oid + "\nsize 0\n",
+			expected: &Pointer{Oid: oid, Size: 0},
+		},
+		{
+			name: "regularFile",
+			data: "some content\n",
+		},
+		{
+			name: "badOid",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:123\nsize 1\n",
+		},
+		{
+			name: "noSize",
+			data: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pointer := ParsePointer([]byte(test.data))
+			switch {
+			case test.expected == nil && pointer != nil:
+				t.Errorf("expected nil, got %+v", pointer)
+			case test.expected != nil && (pointer == nil || *pointer != *test.expected):
+				t.Errorf("expected %+v, got %+v", test.expected, pointer)
+			}
+		})
+	}
+}
+
+func TestIsPathFilteredByLfs(t *testing.T) {
+	attributesFiles := map[string]string{
+		".gitattributes":            "*.bin filter=lfs diff=lfs merge=lfs -text\nassets/** filter=lfs\nassets/keep.txt -filter\n",
+		"nested/.gitattributes":     "*.bin -filter\n*.dat filter=lfs\n",
+		"assets/raw/.gitattributes": "*.raw filter=other\n",
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "file.bin", expected: true},
+		{path: "dir/file.bin", expected: true},
+		{path: "file.txt", expected: false},
+		{path: "assets/image.png", expected: true},
+		{path: "assets/keep.txt", expected: false},
+		{path: "assets/raw/image.raw", expected: false},
+		{path: "nested/file.bin", expected: false},
+		{path: "nested/file.dat", expected: true},
+		{path: "file.dat", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			isFilteredByLfs, err := isPathFilteredByLfs(test.path, func(attributesFilePath string) ([]byte, error) {
+				if data, ok := attributesFiles[attributesFilePath]; ok {
+					return []byte(data), nil
+				}
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if isFilteredByLfs != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, isFilteredByLfs)
+			}
+		})
+	}
+}
+
+func TestReadPointerBlobMissingInRepository(t *testing.T) {
+	oid := "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	data := "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n"
+
+	repository, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	storedHash := storeBlob(t, repository, data)
+	missingHash := plumbing.ComputeHash(plumbing.BlobObject, []byte(data+"\n"))
+
+	var readMissingBlobCalls int
+	readMissingBlob := func(hash plumbing.Hash) ([]byte, error) {
+		readMissingBlobCalls++
+		if hash != missingHash {
+			t.Fatalf("unexpected missing blob %s", hash)
+		}
+		return []byte(data), nil
+	}
+
+	t.Run("stored", func(t *testing.T) {
+		pointer, err := ReadPointerBlob(repository, storedHash, readMissingBlob)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if pointer == nil || pointer.Oid != oid || readMissingBlobCalls != 0 {
+			t.Errorf("expected the pointer %s read from the repository, got %+v (%d missing blob reads)", oid, pointer, readMissingBlobCalls)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		pointer, err := ReadPointerBlob(repository, missingHash, readMissingBlob)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if pointer == nil || pointer.Oid != oid || readMissingBlobCalls != 1 {
+			t.Errorf("expected the pointer %s read with readMissingBlob, got %+v (%d missing blob reads)", oid, pointer, readMissingBlobCalls)
+		}
+	})
+
+	t.Run("missingWithoutReader", func(t *testing.T) {
+		if _, err := ReadPointerBlob(repository, missingHash, nil); err == nil || !strings.Contains(err.Error(), "is not available") {
+			t.Errorf("expected the blob is not available error, got %v", err)
+		}
+	})
+}
+
+func TestIsTreePathFilteredByLfsMissingAttributesFile(t *testing.T) {
+	attributesData := "*.bin filter=lfs diff=lfs merge=lfs -text\n# missing\n"
+
+	repository, err := git.Init(memory.NewStorage(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	attributesHash := plumbing.ComputeHash(plumbing.BlobObject, []byte(attributesData))
+	tree := storeTree(t, repository, []object.TreeEntry{
+		{Name: ".gitattributes", Mode: filemode.Regular, Hash: attributesHash},
+		{Name: "file.bin", Mode: filemode.Regular, Hash: storeBlob(t, repository, "content")},
+	})
+
+	readMissingBlob := func(hash plumbing.Hash) ([]byte, error) {
+		if hash != attributesHash {
+			t.Fatalf("unexpected missing blob %s", hash)
+		}
+		return []byte(attributesData), nil
+	}
+
+	isFilteredByLfs, err := IsTreePathFilteredByLfs(repository, tree, "file.bin", readMissingBlob)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !isFilteredByLfs {
+		t.Errorf("expected file.bin to be filtered by lfs")
+	}
+}
+
+func storeBlob(t *testing.T, repository *git.Repository, data string) plumbing.Hash {
+	obj := repository.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+
+	w, err := obj.Writer()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hash, err := repository.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return hash
+}
+
+func storeTree(t *testing.T, repository *git.Repository, entries []object.TreeEntry) *object.Tree {
+	obj := repository.Storer.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hash, err := repository.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tree, err := repository.TreeObject(hash)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return tree
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/werf/werf/pkg/git_repo/repo_handle"
 	"github.com/werf/werf/pkg/path_matcher"
+	"github.com/werf/werf/pkg/true_git/lfs"
 	"github.com/werf/werf/pkg/util"
 )
 
@@ -126,7 +127,7 @@ func lsTree(ctx context.Context, repoHandle repo_handle.Handle, commit string, o
 				logboek.Context(ctx).Debug().LogLn("Root tree was checking")
 			}
 
-			lsTreeEntries, submodulesLsTreeEntries, err := lsTreeWalk(ctx, repoHandle, tree, "", "", opts)
+			lsTreeEntries, submodulesLsTreeEntries, err := lsTreeWalk(ctx, repoHandle, tree, tree, "", "", opts)
 			if err != nil {
 				return err
 			}
@@ -212,7 +213,7 @@ func processSpecificEntryFilepath(ctx context.Context, repoHandle repo_handle.Ha
 		return nil, nil, err
 	}
 
-	lsTreeEntries, submodulesLsTreeEntries, err := lsTreeEntryMatch(ctx, repoHandle, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
+	lsTreeEntries, submodulesLsTreeEntries, err := lsTreeEntryMatch(ctx, repoHandle, tree, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -220,14 +221,14 @@ func processSpecificEntryFilepath(ctx context.Context, repoHandle repo_handle.Ha
 	return lsTreeEntries, submodulesLsTreeEntries, nil
 }
 
-func lsTreeWalk(ctx context.Context, repoHandle repo_handle.Handle, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
+func lsTreeWalk(ctx context.Context, repoHandle repo_handle.Handle, rootTree, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
 	for _, treeEntry := range tree.Entries {
 		lsTreeEntry := &LsTreeEntry{
 			FullFilepath: filepath.Join(treeFullFilepath, treeEntry.Name),
 			TreeEntry:    treeEntry,
 		}
 
-		entryTreeEntries, entrySubmodulesTreeEntries, err := lsTreeEntryMatch(ctx, repoHandle, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
+		entryTreeEntries, entrySubmodulesTreeEntries, err := lsTreeEntryMatch(ctx, repoHandle, rootTree, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -239,18 +240,18 @@ func lsTreeWalk(ctx context.Context, repoHandle repo_handle.Handle, tree *object
 	return
 }
 
-func lsTreeEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
+func lsTreeEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, rootTree, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
 	switch lsTreeEntry.Mode {
 	case filemode.Dir:
-		return lsTreeDirEntryMatch(ctx, repoHandle, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
+		return lsTreeDirEntryMatch(ctx, repoHandle, rootTree, tree, repositoryFullFilepath, treeFullFilepath, lsTreeEntry, opts)
 	case filemode.Submodule:
 		return lsTreeSubmoduleEntryMatch(ctx, repoHandle, repositoryFullFilepath, lsTreeEntry, opts)
 	default:
-		return lsTreeFileEntryMatch(ctx, lsTreeEntry, opts)
+		return lsTreeFileEntryMatch(ctx, repoHandle, rootTree, repositoryFullFilepath, lsTreeEntry, opts)
 	}
 }
 
-func lsTreeDirEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
+func lsTreeDirEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, rootTree, tree *object.Tree, repositoryFullFilepath, treeFullFilepath string, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
 	if err := lsTreeDirOrSubmoduleEntryMatchBase(
 		lsTreeEntry.FullFilepath,
 		opts,
@@ -275,7 +276,7 @@ func lsTreeDirEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, tre
 				return err
 			}
 
-			lsTreeEntries, submodulesResults, err = lsTreeWalk(ctx, repoHandle, entryTree, repositoryFullFilepath, lsTreeEntry.FullFilepath, opts)
+			lsTreeEntries, submodulesResults, err = lsTreeWalk(ctx, repoHandle, rootTree, entryTree, repositoryFullFilepath, lsTreeEntry.FullFilepath, opts)
 			if err != nil {
 				return err
 			}
@@ -331,7 +332,7 @@ func lsTreeSubmoduleEntryMatch(ctx context.Context, repoHandle repo_handle.Handl
 				return err
 			}
 
-			submoduleLsTreeEntrees, submoduleSubmoduleResults, err := lsTreeWalk(ctx, submoduleHandle, submoduleTree, lsTreeEntry.FullFilepath, lsTreeEntry.FullFilepath, opts)
+			submoduleLsTreeEntrees, submoduleSubmoduleResults, err := lsTreeWalk(ctx, submoduleHandle, submoduleTree, submoduleTree, lsTreeEntry.FullFilepath, lsTreeEntry.FullFilepath, opts)
 			if err != nil {
 				return err
 			}
@@ -379,17 +380,52 @@ func lsTreeDirOrSubmoduleEntryMatchBase(path string, opts LsTreeOptions, addTree
 	}
 }
 
-func lsTreeFileEntryMatch(ctx context.Context, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
+func lsTreeFileEntryMatch(ctx context.Context, repoHandle repo_handle.Handle, rootTree *object.Tree, repositoryFullFilepath string, lsTreeEntry *LsTreeEntry, opts LsTreeOptions) (lsTreeEntries []*LsTreeEntry, submodulesResults []*SubmoduleResult, err error) {
 	if opts.formattedPathMatcher().IsPathMatched(lsTreeEntry.FullFilepath) {
 		if debug() {
 			logboek.Context(ctx).Debug().LogLn("File entry was added:        ", lsTreeEntry.FullFilepath)
 		}
+
+		if lsTreeEntry.Mode.IsFile() && lsTreeEntry.Mode != filemode.Symlink {
+			if err := setLsTreeEntryLfsOid(repoHandle, rootTree, repositoryFullFilepath, lsTreeEntry); err != nil {
+				return nil, nil, err
+			}
+		}
+
 		lsTreeEntries = append(lsTreeEntries, lsTreeEntry)
 	}
 
 	return
 }
 
+// setLsTreeEntryLfsOid sets the git lfs object id if the file is marked with filter=lfs and stored as the git lfs pointer.
+func setLsTreeEntryLfsOid(repoHandle repo_handle.Handle, rootTree *object.Tree, repositoryFullFilepath string, lsTreeEntry *LsTreeEntry) error {
+	relFilepath, err := filepath.Rel(repositoryFullFilepath, lsTreeEntry.FullFilepath)
+	if err != nil {
+		panic(fmt.Sprintf("unexpected paths: %s, %s", repositoryFullFilepath, lsTreeEntry.FullFilepath))
+	}
+
+	isFilteredByLfs, err := lfs.IsTreePathFilteredByLfs(repoHandle.Repository(), rootTree, relFilepath, repoHandle.ReadMissingBlob)
+	if err != nil {
+		return fmt.Errorf("unable to check git attributes of %s: %s", lsTreeEntry.FullFilepath, err)
+	}
+
+	if !isFilteredByLfs {
+		return nil
+	}
+
+	pointer, err := lfs.ReadPointerBlob(repoHandle.Repository(), lsTreeEntry.Hash, repoHandle.ReadMissingBlob)
+	if err != nil {
+		return fmt.Errorf("unable to check git lfs pointer %s: %s", lsTreeEntry.FullFilepath, err)
+	}
+
+	if pointer != nil {
+		lsTreeEntry.LfsOid = pointer.Oid
+	}
+
+	return nil
+}
+
 func treeFindEntry(_ context.Context, tree *object.Tree, treeFullFilepath, treeEntryFilepath string) (*LsTreeEntry, error) {
 	formattedTreeEntryPath := filepath.ToSlash(treeEntryFilepath)
 	treeEntry, err := tree.FindEntry(formattedTreeEntryPath)
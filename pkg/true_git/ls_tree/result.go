@@ -88,6 +88,17 @@ func (r *SubmoduleResult) submoduleRepository(parentRepoHandle repo_handle.Handl
 type LsTreeEntry struct {
 	FullFilepath string
 	object.TreeEntry
+
+	// LfsOid is set if the file is a git lfs pointer, the oid is used in the checksum instead of the pointer blob hash.
+	LfsOid string
+}
+
+func (e *LsTreeEntry) checksumID() string {
+	if e.LfsOid != "" {
+		return fmt.Sprintf("lfs:%s", e.LfsOid)
+	}
+
+	return e.Hash.String()
 }
 
 func (r *Result) LsTree(ctx context.Context, repoHandle repo_handle.Handle, opts LsTreeOptions) (*Result, error) {
@@ -133,7 +144,7 @@ func (r *Result) lsTree(ctx context.Context, repoHandle repo_handle.Handle, opts
 						logboek.Context(ctx).Debug().LogLn("Root tree was checking")
 					}
 
-					entryLsTreeEntries, entrySubmodulesResults, err = lsTreeWalk(ctx, repoHandle, tree, r.repositoryFullFilepath, r.repositoryFullFilepath, opts)
+					entryLsTreeEntries, entrySubmodulesResults, err = lsTreeWalk(ctx, repoHandle, tree, tree, r.repositoryFullFilepath, r.repositoryFullFilepath, opts)
 					return err
 				},
 				// skip tree func
@@ -148,7 +159,7 @@ func (r *Result) lsTree(ctx context.Context, repoHandle repo_handle.Handle, opts
 				return nil, err
 			}
 		} else {
-			entryLsTreeEntries, entrySubmodulesResults, err = lsTreeEntryMatch(ctx, repoHandle, tree, r.repositoryFullFilepath, r.repositoryFullFilepath, lsTreeEntry, opts)
+			entryLsTreeEntries, entrySubmodulesResults, err = lsTreeEntryMatch(ctx, repoHandle, tree, tree, r.repositoryFullFilepath, r.repositoryFullFilepath, lsTreeEntry, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -212,14 +223,14 @@ func (r *Result) Checksum(ctx context.Context) string {
 	h := sha256.New()
 
 	_ = r.lsTreeEntriesWalk(func(lsTreeEntry *LsTreeEntry) error {
-		h.Write([]byte(lsTreeEntry.Hash.String()))
+		h.Write([]byte(lsTreeEntry.checksumID()))
 
 		logFilepath := lsTreeEntry.FullFilepath
 		if logFilepath == "" {
 			logFilepath = "."
 		}
 
-		logboek.Context(ctx).Debug().LogF("Entry was added: %s -> %s\n", logFilepath, lsTreeEntry.Hash.String())
+		logboek.Context(ctx).Debug().LogF("Entry was added: %s -> %s\n", logFilepath, lsTreeEntry.checksumID())
 
 		return nil
 	})
@@ -331,7 +342,14 @@ func (r *Result) LsTreeEntryContent(mainRepoHandle repo_handle.Handle, relPath s
 	}
 
 	obj, err := entryRepoHandle.Repository().BlobObject(entry.Hash)
-	if err != nil {
+	if err == plumbing.ErrObjectNotFound {
+		data, err := entryRepoHandle.ReadMissingBlob(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tree entry %q content: %s", relPath, err)
+		}
+
+		return data, nil
+	} else if err != nil {
 		return nil, fmt.Errorf("unable to get tree entry %q blob object: %s", entry.FullFilepath, err)
 	}
 
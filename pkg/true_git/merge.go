@@ -12,7 +12,8 @@ import (
 )
 
 type CreateDetachedMergeCommitOptions struct {
-	HasSubmodules bool
+	HasSubmodules          bool
+	SparseCheckoutPatterns []string
 }
 
 func CreateDetachedMergeCommit(ctx context.Context, gitDir, workTreeCacheDir, commitToMerge, mergeIntoCommit string, opts CreateDetachedMergeCommitOptions) (string, error) {
@@ -31,7 +32,7 @@ func CreateDetachedMergeCommit(ctx context.Context, gitDir, workTreeCacheDir, co
 			return fmt.Errorf("bad work tree cache dir %s: %s", workTreeCacheDir, err)
 		}
 
-		if workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, mergeIntoCommit, opts.HasSubmodules); err != nil {
+		if workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, mergeIntoCommit, WithWorkTreeOptions{HasSubmodules: opts.HasSubmodules, SparseCheckoutPatterns: opts.SparseCheckoutPatterns}); err != nil {
 			return fmt.Errorf("unable to prepare worktree for commit %v: %s", mergeIntoCommit, err)
 		} else {
 			var err error
@@ -43,7 +44,7 @@ func CreateDetachedMergeCommit(ctx context.Context, gitDir, workTreeCacheDir, co
 				return fmt.Errorf("unable to remove %s: %s", currentCommitPath, err)
 			}
 
-			cmd = exec.Command("git", append(getCommonGitOptions(), "-c", "user.email=werf@werf.io", "-c", "user.name=werf", "merge", "--no-edit", "--no-ff", commitToMerge)...)
+			cmd = newGitCmd(append(getCommonGitOptions(), "-c", "user.email=werf@werf.io", "-c", "user.name=werf", "merge", "--no-edit", "--no-ff", commitToMerge)...)
 			cmd.Dir = workTreeDir
 			output = SetCommandRecordingLiveOutput(ctx, cmd)
 			err = cmd.Run()
@@ -54,7 +55,7 @@ func CreateDetachedMergeCommit(ctx context.Context, gitDir, workTreeCacheDir, co
 				fmt.Printf("[DEBUG MERGE] %s\n%s\n", strings.Join(append([]string{cmd.Path}, cmd.Args[1:]...), " "), output)
 			}
 
-			cmd = exec.Command("git", append(getCommonGitOptions(), "rev-parse", "HEAD")...)
+			cmd = newGitCmd(append(getCommonGitOptions(), "rev-parse", "HEAD")...)
 			cmd.Dir = workTreeDir
 			output = SetCommandRecordingLiveOutput(ctx, cmd)
 			err = cmd.Run()
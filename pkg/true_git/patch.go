@@ -22,6 +22,8 @@ type PatchOptions struct {
 
 	WithEntireFileContext bool
 	WithBinary            bool
+
+	SparseCheckoutPatterns []string // Limits the files checked out into the work tree, not a part of the patch ID.
 }
 
 func (opts PatchOptions) ID() string {
@@ -40,7 +42,7 @@ func (opts PatchOptions) ID() string {
 			opts.PathMatcher.ID(),
 			fmt.Sprint(opts.WithBinary),
 			fmt.Sprint(opts.WithEntireFileContext),
-		)...
+		)...,
 	)
 }
 
@@ -107,7 +109,7 @@ func writePatch(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir str
 	var cmd *exec.Cmd
 
 	if withSubmodules {
-		workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, opts.ToCommit, withSubmodules)
+		workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, opts.ToCommit, WithWorkTreeOptions{HasSubmodules: withSubmodules, SparseCheckoutPatterns: opts.SparseCheckoutPatterns})
 		if err != nil {
 			return nil, fmt.Errorf("cannot prepare work tree in cache %s for commit %s: %s", workTreeCacheDir, opts.ToCommit, err)
 		}
@@ -121,7 +123,7 @@ func writePatch(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir str
 			fmt.Printf("# git %s\n", strings.Join(gitArgs, " "))
 		}
 
-		cmd = exec.Command("git", gitArgs...)
+		cmd = newGitCmd(gitArgs...)
 
 		cmd.Dir = workTreeDir // required for `git diff` with submodules
 	} else {
@@ -134,7 +136,7 @@ func writePatch(ctx context.Context, out io.Writer, gitDir, workTreeCacheDir str
 			fmt.Printf("# git %s\n", strings.Join(gitArgs, " "))
 		}
 
-		cmd = exec.Command("git", gitArgs...)
+		cmd = newGitCmd(gitArgs...)
 	}
 
 	stdoutPipe, err := cmd.StdoutPipe()
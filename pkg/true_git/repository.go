@@ -2,6 +2,7 @@ package true_git
 
 import (
 	"context"
+	"fmt"
 	"os/exec"
 	"path/filepath"
 	"strings"
@@ -24,6 +25,7 @@ type FetchOptions struct {
 	Prune     bool
 	PruneTags bool
 	Unshallow bool
+	Force     bool
 	RefSpecs  map[string]string
 }
 
@@ -43,6 +45,10 @@ func Fetch(ctx context.Context, path string, options FetchOptions) error {
 		commandArgs = append(commandArgs, "--tags")
 	}
 
+	if options.Force {
+		commandArgs = append(commandArgs, "--force")
+	}
+
 	if options.Prune || options.PruneTags {
 		commandArgs = append(commandArgs, "--prune")
 
@@ -57,7 +63,35 @@ func Fetch(ctx context.Context, path string, options FetchOptions) error {
 
 	logboek.Context(ctx).Debug().LogLnDetails(command, strings.Join(commandArgs, " "))
 
-	cmd := exec.Command(command, commandArgs...)
+	cmd := newGitCmd(commandArgs...)
+	cmd.Stdout = logboek.Context(ctx).OutStream()
+	cmd.Stderr = logboek.Context(ctx).ErrStream()
+
+	return cmd.Run()
+}
+
+type CloneOptions struct {
+	Bare   bool
+	Filter string
+}
+
+func Clone(ctx context.Context, url, path string, options CloneOptions) error {
+	command := "git"
+	commandArgs := append(getCommonGitOptions(), "clone")
+
+	if options.Bare {
+		commandArgs = append(commandArgs, "--bare")
+	}
+
+	if options.Filter != "" {
+		commandArgs = append(commandArgs, fmt.Sprintf("--filter=%s", options.Filter))
+	}
+
+	commandArgs = append(commandArgs, url, path)
+
+	logboek.Context(ctx).Debug().LogLnDetails(command, strings.Join(commandArgs, " "))
+
+	cmd := newGitCmd(commandArgs...)
 	cmd.Stdout = logboek.Context(ctx).OutStream()
 	cmd.Stderr = logboek.Context(ctx).ErrStream()
 
@@ -74,7 +108,7 @@ func IsShallowClone(path string) (bool, error) {
 		return exist, nil
 	}
 
-	cmd := exec.Command("git", "-C", path, "rev-parse", "--is-shallow-repository")
+	cmd := newGitCmd("-C", path, "rev-parse", "--is-shallow-repository")
 
 	res, err := cmd.Output()
 	if err != nil {
@@ -83,3 +117,20 @@ func IsShallowClone(path string) (bool, error) {
 
 	return strings.TrimSpace(string(res)) == "true", nil
 }
+
+// ResolveCommit expands the full or abbreviated commit hash, an empty string is returned if the commit is not found or the hash is ambiguous.
+func ResolveCommit(commit, gitDir string) (string, error) {
+	gitArgs := append(getCommonGitOptions(), "-C", gitDir, "rev-parse", "--verify", "--quiet", commit+"^{commit}")
+	cmd := newGitCmd(gitArgs...)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("'git rev-parse' failed: %s", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,28 @@
+package true_git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CountCommits returns the number of commits reachable from the commit but not from the excluded commit (all reachable commits if the excluded commit is empty).
+func CountCommits(commit, excludedCommit, gitDir string) (int, error) {
+	gitArgs := append(getCommonGitOptions(), "-C", gitDir, "rev-list", "--count", commit)
+	if excludedCommit != "" {
+		gitArgs = append(gitArgs, "^"+excludedCommit)
+	}
+	cmd := newGitCmd(gitArgs...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("'git rev-list' failed: %s:\n%s", err, output)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected 'git rev-list' output %q: %s", output, err)
+	}
+
+	return count, nil
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -32,7 +31,7 @@ func SyncSourceWorktreeWithServiceBranch(ctx context.Context, gitDir, sourceWork
 			return fmt.Errorf("bad work tree cache dir %s: %s", worktreeCacheDir, err)
 		}
 
-		serviceWorktreeDir, err := prepareWorkTree(ctx, gitDir, worktreeCacheDir, commit, true)
+		serviceWorktreeDir, err := prepareWorkTree(ctx, gitDir, worktreeCacheDir, commit, WithWorkTreeOptions{HasSubmodules: true})
 		if err != nil {
 			return fmt.Errorf("unable to prepare worktree for commit %v: %s", commit, err)
 		}
@@ -231,7 +230,7 @@ type runGitCmdOptions struct {
 
 func runGitCmd(ctx context.Context, args []string, dir string, opts runGitCmdOptions) (*bytes.Buffer, error) {
 	allArgs := append(getCommonGitOptions(), args...)
-	cmd := exec.Command("git", allArgs...)
+	cmd := newGitCmd(allArgs...)
 	cmd.Dir = dir
 
 	if opts.stdin != nil {
@@ -3,7 +3,6 @@ package true_git
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/werf/logboek"
@@ -12,7 +11,7 @@ import (
 func syncSubmodules(ctx context.Context, repoDir, workTreeDir string) error {
 	logProcessMsg := fmt.Sprintf("Sync submodules in work tree %q", workTreeDir)
 	return logboek.Context(ctx).Info().LogProcess(logProcessMsg).DoError(func() error {
-		cmd := exec.Command("git", append(getCommonGitOptions(), "submodule", "sync", "--recursive")...)
+		cmd := newGitCmd(append(getCommonGitOptions(), "submodule", "sync", "--recursive")...)
 
 		cmd.Dir = workTreeDir // required for `git submodule` to work
 
@@ -34,8 +33,8 @@ func syncSubmodules(ctx context.Context, repoDir, workTreeDir string) error {
 func updateSubmodules(ctx context.Context, repoDir, workTreeDir string) error {
 	logProcessMsg := fmt.Sprintf("Update submodules in work tree %q", workTreeDir)
 	return logboek.Context(ctx).Info().LogProcess(logProcessMsg).DoError(func() error {
-		cmd := exec.Command(
-			"git", append(getCommonGitOptions(),
+		cmd := newGitCmd(
+			append(getCommonGitOptions(),
 				"submodule", "update", "--checkout", "--force", "--init", "--recursive")...,
 		)
 
@@ -21,6 +21,10 @@ import (
 
 type WithWorkTreeOptions struct {
 	HasSubmodules bool
+
+	// SparseCheckoutPatterns limits the files checked out into the work tree (all files are checked out if empty).
+	// The patterns should not change for the same work tree cache dir.
+	SparseCheckoutPatterns []string
 }
 
 func WithWorkTree(ctx context.Context, gitDir, workTreeCacheDir string, commit string, opts WithWorkTreeOptions, f func(workTreeDir string) error) error {
@@ -37,7 +41,7 @@ func WithWorkTree(ctx context.Context, gitDir, workTreeCacheDir string, commit s
 			return fmt.Errorf("bad work tree cache dir %s: %s", workTreeCacheDir, err)
 		}
 
-		workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, commit, opts.HasSubmodules)
+		workTreeDir, err := prepareWorkTree(ctx, gitDir, workTreeCacheDir, commit, opts)
 		if err != nil {
 			return fmt.Errorf("cannot prepare worktree: %s", err)
 		}
@@ -51,7 +55,7 @@ func withWorkTreeCacheLock(ctx context.Context, workTreeCacheDir string, f func(
 	return werf.WithHostLock(ctx, lockName, lockgate.AcquireOptions{Timeout: 600 * time.Second}, f)
 }
 
-func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, commit string, withSubmodules bool) (string, error) {
+func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, commit string, opts WithWorkTreeOptions) (string, error) {
 	if err := os.MkdirAll(workTreeCacheDir, os.ModePerm); err != nil {
 		return "", fmt.Errorf("unable to create dir %s: %s", workTreeCacheDir, err)
 	}
@@ -136,7 +140,7 @@ func prepareWorkTree(ctx context.Context, repoDir, workTreeCacheDir string, comm
 		if currentCommit != "" {
 			logboek.Context(ctx).Info().LogFDetails("Current commit: %s\n", currentCommit)
 		}
-		return switchWorkTree(ctx, repoDir, workTreeDir, commit, withSubmodules)
+		return switchWorkTree(ctx, repoDir, workTreeDir, commit, opts)
 	}); err != nil {
 		return "", fmt.Errorf("unable to switch work tree %s to commit %s: %s", workTreeDir, commit, err)
 	}
@@ -152,15 +156,20 @@ func debugWorktreeSwitch() bool {
 	return os.Getenv("WERF_TRUE_GIT_DEBUG_WORKTREE_SWITCH") == "1"
 }
 
-func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit string, withSubmodules bool) error {
+func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit string, opts WithWorkTreeOptions) error {
 	var err error
 	var cmd *exec.Cmd
 	var output *bytes.Buffer
 
 	if _, err := os.Stat(workTreeDir); os.IsNotExist(err) {
-		cmd = exec.Command(
-			"git", append(getCommonGitOptions(), "-C", repoDir,
-				"worktree", "add", "--force", "--detach", workTreeDir, commit)...,
+		worktreeAddArgs := []string{"worktree", "add", "--force", "--detach"}
+		if len(opts.SparseCheckoutPatterns) != 0 {
+			// files are checked out by the following reset according to the sparse checkout patterns
+			worktreeAddArgs = append(worktreeAddArgs, "--no-checkout")
+		}
+
+		cmd = newGitCmd(
+			append(append(getCommonGitOptions(), "-C", repoDir), append(worktreeAddArgs, workTreeDir, commit)...)...,
 		)
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -170,10 +179,16 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		if err != nil {
 			return fmt.Errorf("git worktree add failed: %s\n%s", err, output.String())
 		}
+
+		if len(opts.SparseCheckoutPatterns) != 0 {
+			if err := setupWorkTreeSparseCheckout(ctx, repoDir, workTreeDir, opts.SparseCheckoutPatterns); err != nil {
+				return fmt.Errorf("unable to setup sparse checkout: %s", err)
+			}
+		}
 	} else if err != nil {
 		return fmt.Errorf("error accessing %s: %s", workTreeDir, err)
 	} else {
-		cmd = exec.Command("git", append(getCommonGitOptions(), "checkout", "--force", "--detach", commit)...)
+		cmd = newGitCmd(append(getCommonGitOptions(), "checkout", "--force", "--detach", commit)...)
 		cmd.Dir = workTreeDir
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -185,7 +200,7 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		}
 	}
 
-	cmd = exec.Command("git", append(getCommonGitOptions(), "reset", "--hard", commit)...)
+	cmd = newGitCmd(append(getCommonGitOptions(), "reset", "--hard", commit)...)
 	cmd.Dir = workTreeDir
 	output = SetCommandRecordingLiveOutput(ctx, cmd)
 	if debugWorktreeSwitch() {
@@ -196,8 +211,8 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		return fmt.Errorf("git reset failed: %s\n%s", err, output.String())
 	}
 
-	cmd = exec.Command(
-		"git", append(getCommonGitOptions(), "--work-tree", workTreeDir,
+	cmd = newGitCmd(
+		append(getCommonGitOptions(), "--work-tree", workTreeDir,
 			"clean", "-d", "-f", "-f", "-x")...,
 	)
 	cmd.Dir = workTreeDir
@@ -210,7 +225,7 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		return fmt.Errorf("git clean failed: %s\n%s", err, output.String())
 	}
 
-	if withSubmodules {
+	if opts.HasSubmodules {
 		var err error
 
 		err = syncSubmodules(ctx, repoDir, workTreeDir)
@@ -226,7 +241,7 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		gitArgs := append(getCommonGitOptions(), "--work-tree", workTreeDir, "submodule", "foreach", "--recursive")
 		gitArgs = append(append(gitArgs, "git"), append(getCommonGitOptions(), "reset", "--hard")...)
 
-		cmd = exec.Command("git", gitArgs...)
+		cmd = newGitCmd(gitArgs...)
 		cmd.Dir = workTreeDir // required for `git submodule` to work
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -240,7 +255,7 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 		gitArgs = append(getCommonGitOptions(), "--work-tree", workTreeDir, "submodule", "foreach", "--recursive")
 		gitArgs = append(append(gitArgs, "git"), append(getCommonGitOptions(), "clean", "-d", "-f", "-f", "-x")...)
 
-		cmd = exec.Command("git", gitArgs...)
+		cmd = newGitCmd(gitArgs...)
 		cmd.Dir = workTreeDir // required for `git submodule` to work
 		output = SetCommandRecordingLiveOutput(ctx, cmd)
 		if debugWorktreeSwitch() {
@@ -255,10 +270,42 @@ func switchWorkTree(ctx context.Context, repoDir, workTreeDir string, commit str
 	return nil
 }
 
+// setupWorkTreeSparseCheckout writes the patterns into the sparse-checkout file of the work tree private git dir.
+// Sparse checkout is enabled for the whole repo, but work trees without the sparse-checkout file are still checked out entirely.
+func setupWorkTreeSparseCheckout(ctx context.Context, repoDir, workTreeDir string, patterns []string) error {
+	cmd := newGitCmd(append(getCommonGitOptions(), "-C", repoDir, "config", "core.sparseCheckout", "true")...)
+	output := SetCommandRecordingLiveOutput(ctx, cmd)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git config failed: %s\n%s", err, output.String())
+	}
+
+	dotGitPath := filepath.Join(workTreeDir, ".git")
+	data, err := ioutil.ReadFile(dotGitPath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", dotGitPath, err)
+	}
+
+	workTreeGitDir := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+	if !filepath.IsAbs(workTreeGitDir) {
+		workTreeGitDir = filepath.Join(workTreeDir, workTreeGitDir)
+	}
+
+	sparseCheckoutPath := filepath.Join(workTreeGitDir, "info", "sparse-checkout")
+	if err := os.MkdirAll(filepath.Dir(sparseCheckoutPath), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create dir %s: %s", filepath.Dir(sparseCheckoutPath), err)
+	}
+
+	if err := ioutil.WriteFile(sparseCheckoutPath, []byte(strings.Join(patterns, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %s", sparseCheckoutPath, err)
+	}
+
+	return nil
+}
+
 func ResolveRepoDir(repoDir string) (string, error) {
 	gitArgs := append(getCommonGitOptions(), "--git-dir", repoDir, "rev-parse", "--git-dir")
 
-	cmd := exec.Command("git", gitArgs...)
+	cmd := newGitCmd(gitArgs...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -276,7 +323,7 @@ type WorktreeDescriptor struct {
 
 func GetWorkTreeList(repoDir string) ([]WorktreeDescriptor, error) {
 	gitArgs := append(getCommonGitOptions(), "-C", repoDir, "worktree", "list", "--porcelain")
-	cmd := exec.Command("git", gitArgs...)
+	cmd := newGitCmd(gitArgs...)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
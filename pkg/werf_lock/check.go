@@ -0,0 +1,50 @@
+package werf_lock
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Check compares the locked external inputs with the actual ones, the missing lock file and any difference are errors.
+func Check(locked, actual *Lock) error {
+	if locked == nil {
+		return fmt.Errorf("%s not found in the project git repository: run werf lock update and commit the file", FileName)
+	}
+
+	var diffs []string
+	for _, image := range actual.Images {
+		lockedImage := locked.GetImage(image.Name)
+		switch {
+		case lockedImage == nil:
+			diffs = append(diffs, fmt.Sprintf("base image %s is not locked", image.Name))
+		case lockedImage.Digest != image.Digest:
+			diffs = append(diffs, fmt.Sprintf("base image %s digest %s does not match locked %s", image.Name, image.Digest, lockedImage.Digest))
+		}
+	}
+
+	for _, gitRepo := range actual.GitRepos {
+		lockedGitRepo := locked.GetGitRepo(gitRepo.Url, gitRepo.Branch, gitRepo.Tag)
+		switch {
+		case lockedGitRepo == nil:
+			diffs = append(diffs, fmt.Sprintf("git repo %s is not locked", gitRepo.key()))
+		case lockedGitRepo.Commit != gitRepo.Commit:
+			diffs = append(diffs, fmt.Sprintf("git repo %s commit %s does not match locked %s", gitRepo.key(), gitRepo.Commit, lockedGitRepo.Commit))
+		}
+	}
+
+	for _, dependency := range actual.HelmDependencies {
+		lockedDependency := locked.GetHelmDependency(dependency.Name, dependency.Version, dependency.Repository)
+		switch {
+		case lockedDependency == nil:
+			diffs = append(diffs, fmt.Sprintf("helm dependency %s is not locked", dependency.key()))
+		case lockedDependency.Digest != dependency.Digest:
+			diffs = append(diffs, fmt.Sprintf("helm dependency %s digest %s does not match locked %s", dependency.key(), dependency.Digest, lockedDependency.Digest))
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("external inputs do not match %s (run werf lock update to refresh it):\n - %s", FileName, strings.Join(diffs, "\n - "))
+}
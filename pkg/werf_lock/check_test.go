@@ -0,0 +1,139 @@
+package werf_lock
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	locked := NewLock()
+	locked.AddImage(&Image{Name: "alpine:3.14", Digest: "sha256:aaa"})
+	locked.AddGitRepo(&GitRepo{Url: "https://github.com/werf/werf.git", Branch: "main", Commit: "c1"})
+
+	tests := []struct {
+		name      string
+		locked    *Lock
+		actual    *Lock
+		expectErr bool
+	}{
+		{
+			name:      "noLockFile",
+			actual:    NewLock(),
+			expectErr: true,
+		},
+		{
+			name:   "matched",
+			locked: locked,
+			actual: &Lock{
+				Images:   []*Image{{Name: "alpine:3.14", Digest: "sha256:aaa"}},
+				GitRepos: []*GitRepo{{Url: "https://github.com/werf/werf.git", Branch: "main", Commit: "c1"}},
+			},
+			expectErr: false,
+		},
+		{
+			name:      "imageDigestChanged",
+			locked:    locked,
+			actual:    &Lock{Images: []*Image{{Name: "alpine:3.14", Digest: "sha256:bbb"}}},
+			expectErr: true,
+		},
+		{
+			name:      "gitRepoNotLocked",
+			locked:    locked,
+			actual:    &Lock{GitRepos: []*GitRepo{{Url: "https://github.com/werf/werf.git", Tag: "v1.2.0", Commit: "c2"}}},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check(test.locked, test.actual)
+			if test.expectErr && err == nil {
+				t.Errorf("expected error, got nil")
+			} else if !test.expectErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	tests := []struct {
+		name     string
+		image    *Image
+		expected string
+	}{
+		{
+			name:     "tag",
+			image:    &Image{Name: "alpine:3.14", Digest: "sha256:aaa"},
+			expected: "alpine@sha256:aaa",
+		},
+		{
+			name:     "noTag",
+			image:    &Image{Name: "registry.example.com:5000/group/app", Digest: "sha256:aaa"},
+			expected: "registry.example.com:5000/group/app@sha256:aaa",
+		},
+		{
+			name:     "registryPortAndTag",
+			image:    &Image{Name: "registry.example.com:5000/app:v1", Digest: "sha256:aaa"},
+			expected: "registry.example.com:5000/app@sha256:aaa",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ref, err := test.image.Reference()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if ref != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, ref)
+			}
+		})
+	}
+}
+
+func TestGetRepositoryImages(t *testing.T) {
+	lock := NewLock()
+	lock.AddImage(&Image{Name: "alpine:3.14", Digest: "sha256:aaa"})
+	lock.AddImage(&Image{Name: "docker.io/library/alpine:3.13", Digest: "sha256:bbb"})
+	lock.AddImage(&Image{Name: "registry.example.com:5000/alpine:3.14", Digest: "sha256:ccc"})
+
+	tests := []struct {
+		name     string
+		image    string
+		expected []string
+	}{
+		{
+			name:     "shortName",
+			image:    "alpine:3.15",
+			expected: []string{"alpine:3.14", "docker.io/library/alpine:3.13"},
+		},
+		{
+			name:     "registryPort",
+			image:    "registry.example.com:5000/alpine",
+			expected: []string{"registry.example.com:5000/alpine:3.14"},
+		},
+		{
+			name:  "anotherRepository",
+			image: "ubuntu:20.04",
+		},
+		{
+			name:  "invalidName",
+			image: "Alpine:3.14",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, image := range lock.GetRepositoryImages(test.image) {
+				names = append(names, image.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package werf_lock
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/docker/distribution/reference"
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/giterminism_manager/file_reader"
+)
+
+const (
+	FileName = file_reader.LockFileName
+
+	lockVersion = "1"
+	fileHeader  = "# This file is generated by the werf lock update command, do not edit it manually.\n"
+)
+
+// Lock pins the external inputs of the project which are not stored in the project git repository:
+// base images referenced by tag, remote git repositories referenced by branch or tag and helm chart dependencies.
+type Lock struct {
+	LockVersion      string            `json:"lockVersion"`
+	Images           []*Image          `json:"images,omitempty"`
+	GitRepos         []*GitRepo        `json:"gitRepos,omitempty"`
+	HelmDependencies []*HelmDependency `json:"helmDependencies,omitempty"`
+}
+
+type Image struct {
+	Name   string `json:"name"`
+	Digest string `json:"digest"`
+}
+
+// Reference returns the image reference pinned by the digest (e.g. alpine@sha256:... for alpine:3.14).
+func (i *Image) Reference() (string, error) {
+	named, err := reference.ParseNormalizedNamed(i.Name)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse image name %q: %s", i.Name, err)
+	}
+
+	return fmt.Sprintf("%s@%s", reference.FamiliarString(reference.TrimNamed(named)), i.Digest), nil
+}
+
+// GitRepo is the resolved commit of the remote git repository branch or tag (HEAD if both are empty).
+// The references of remote git mappings, Dockerfile remote contexts and ansible requirements are locked.
+type GitRepo struct {
+	Url    string `json:"url"`
+	Branch string `json:"branch,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Commit string `json:"commit"`
+}
+
+// HelmDependency is the digest of the helm chart dependency archive.
+type HelmDependency struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+}
+
+func NewLock() *Lock {
+	return &Lock{LockVersion: lockVersion}
+}
+
+// Load reads werf.lock from the project git repository taking into account the giterminism config, nil is returned if the file does not exist.
+func Load(ctx context.Context, giterminismManager giterminism_manager.Interface) (*Lock, error) {
+	if exist, err := giterminismManager.FileReader().IsLockFileExistAnywhere(ctx); err != nil {
+		return nil, err
+	} else if !exist {
+		return nil, nil
+	}
+
+	data, err := giterminismManager.FileReader().ReadLockFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{}
+	if err := yaml.UnmarshalStrict(data, lock); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", FileName, err)
+	}
+
+	if lock.LockVersion != lockVersion {
+		return nil, fmt.Errorf("unsupported %s lockVersion %q, expected %q", FileName, lock.LockVersion, lockVersion)
+	}
+
+	return lock, nil
+}
+
+// Save writes the lock into the file with sorted entries.
+func (l *Lock) Save(path string) error {
+	sort.Slice(l.Images, func(i, j int) bool {
+		return l.Images[i].Name < l.Images[j].Name
+	})
+	sort.Slice(l.GitRepos, func(i, j int) bool {
+		return l.GitRepos[i].key() < l.GitRepos[j].key()
+	})
+	sort.Slice(l.HelmDependencies, func(i, j int) bool {
+		return l.HelmDependencies[i].key() < l.HelmDependencies[j].key()
+	})
+
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %s", FileName, err)
+	}
+
+	if err := ioutil.WriteFile(path, append([]byte(fileHeader), data...), 0644); err != nil {
+		return fmt.Errorf("unable to write %q: %s", path, err)
+	}
+
+	return nil
+}
+
+func (l *Lock) AddImage(image *Image) {
+	if l.GetImage(image.Name) == nil {
+		l.Images = append(l.Images, image)
+	}
+}
+
+func (l *Lock) GetImage(name string) *Image {
+	for _, image := range l.Images {
+		if image.Name == name {
+			return image
+		}
+	}
+
+	return nil
+}
+
+// GetRepositoryImages returns the locked images of the same repository as the name, e.g. alpine:3.13 for alpine:3.14.
+func (l *Lock) GetRepositoryImages(name string) []*Image {
+	repository := imageRepository(name)
+	if repository == "" {
+		return nil
+	}
+
+	var result []*Image
+	for _, image := range l.Images {
+		if imageRepository(image.Name) == repository {
+			result = append(result, image)
+		}
+	}
+
+	return result
+}
+
+func (l *Lock) AddGitRepo(gitRepo *GitRepo) {
+	if l.GetGitRepo(gitRepo.Url, gitRepo.Branch, gitRepo.Tag) == nil {
+		l.GitRepos = append(l.GitRepos, gitRepo)
+	}
+}
+
+func (l *Lock) GetGitRepo(url, branch, tag string) *GitRepo {
+	for _, gitRepo := range l.GitRepos {
+		if gitRepo.Url == url && gitRepo.Branch == branch && gitRepo.Tag == tag {
+			return gitRepo
+		}
+	}
+
+	return nil
+}
+
+// GetUrlGitRepos returns the locked branches and tags of the remote git repository.
+func (l *Lock) GetUrlGitRepos(url string) []*GitRepo {
+	var result []*GitRepo
+	for _, gitRepo := range l.GitRepos {
+		if gitRepo.Url == url {
+			result = append(result, gitRepo)
+		}
+	}
+
+	return result
+}
+
+func (l *Lock) AddHelmDependency(dependency *HelmDependency) {
+	if l.GetHelmDependency(dependency.Name, dependency.Version, dependency.Repository) == nil {
+		l.HelmDependencies = append(l.HelmDependencies, dependency)
+	}
+}
+
+func (l *Lock) GetHelmDependency(name, version, repository string) *HelmDependency {
+	for _, dependency := range l.HelmDependencies {
+		if dependency.Name == name && dependency.Version == version && dependency.Repository == repository {
+			return dependency
+		}
+	}
+
+	return nil
+}
+
+func imageRepository(name string) string {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return ""
+	}
+
+	return named.Name()
+}
+
+func (r *GitRepo) key() string {
+	return fmt.Sprintf("%s %s", r.Url, r.Ref())
+}
+
+// Ref returns the locked reference: tag, branch or HEAD.
+func (r *GitRepo) Ref() string {
+	switch {
+	case r.Tag != "":
+		return fmt.Sprintf("tag %s", r.Tag)
+	case r.Branch != "":
+		return fmt.Sprintf("branch %s", r.Branch)
+	default:
+		return "HEAD"
+	}
+}
+
+func (d *HelmDependency) key() string {
+	return fmt.Sprintf("%s %s %s", d.Repository, d.Name, d.Version)
+}
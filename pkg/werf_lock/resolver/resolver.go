@@ -0,0 +1,390 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	helm_v3 "helm.sh/helm/v3/cmd/helm"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/cli"
+	"sigs.k8s.io/yaml"
+
+	"github.com/werf/logboek"
+
+	"github.com/werf/werf/pkg/build"
+	"github.com/werf/werf/pkg/build/stage"
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/deploy/helm/chart_extender"
+	"github.com/werf/werf/pkg/deploy/helm/command_helpers"
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/giterminism_manager"
+	"github.com/werf/werf/pkg/util"
+	"github.com/werf/werf/pkg/werf_lock"
+)
+
+type Options struct {
+	WerfConfig *config.WerfConfig
+
+	// HelmChartDir is the absolute path of the project chart, helm dependencies are not resolved if the path is empty.
+	HelmChartDir         string
+	HelmEnvSettings      *cli.EnvSettings
+	RegistryClientHandle *helm_v3.RegistryClientHandle
+}
+
+// Resolve returns the lock with the current state of the project external inputs.
+func Resolve(ctx context.Context, giterminismManager giterminism_manager.Interface, opts Options) (*werf_lock.Lock, error) {
+	r := &resolver{
+		giterminismManager: giterminismManager,
+		werfConfig:         opts.WerfConfig,
+		lock:               werf_lock.NewLock(),
+		remoteGitRepos:     map[string]*git_repo.Remote{},
+	}
+
+	if err := r.resolveGitRepos(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := r.resolveBaseImages(ctx); err != nil {
+		return nil, err
+	}
+
+	if opts.HelmChartDir != "" {
+		if err := resolveHelmDependencies(ctx, r.lock, giterminismManager, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.lock, nil
+}
+
+type resolver struct {
+	giterminismManager giterminism_manager.Interface
+	werfConfig         *config.WerfConfig
+
+	lock           *werf_lock.Lock
+	remoteGitRepos map[string]*git_repo.Remote
+}
+
+// resolveBaseImages adds digests of the base images of stapel images and Dockerfile stages which are not pinned by digest.
+func (r *resolver) resolveBaseImages(ctx context.Context) error {
+	var names []string
+	for _, imageConfig := range stapelImages(r.werfConfig) {
+		if imageConfig.From != "" {
+			names = append(names, imageConfig.From)
+		}
+	}
+
+	for _, imageConfig := range r.werfConfig.ImagesFromDockerfile {
+		dockerfileNames, err := r.getDockerfileBaseImageNames(ctx, imageConfig)
+		if err != nil {
+			return fmt.Errorf("image %q: %s", imageConfig.Name, err)
+		}
+
+		names = append(names, dockerfileNames...)
+	}
+
+	for _, name := range names {
+		if name == "scratch" || strings.Contains(name, "@") || r.lock.GetImage(name) != nil {
+			continue
+		}
+
+		digest, err := docker_registry.API().GetImageDigest(ctx, name)
+		if err != nil {
+			return fmt.Errorf("unable to get base image %s digest: %s", name, err)
+		}
+
+		r.lock.AddImage(&werf_lock.Image{Name: name, Digest: digest})
+	}
+
+	return nil
+}
+
+func (r *resolver) getDockerfileBaseImageNames(ctx context.Context, imageConfig *config.ImageFromDockerfile) ([]string, error) {
+	relDockerfilePath := filepath.Join(imageConfig.Context, imageConfig.Dockerfile)
+
+	var dockerfileData []byte
+	if imageConfig.RemoteContext != nil {
+		remoteGitRepo, commit, err := r.resolveRemoteGitRepoRef(ctx, imageConfig.RemoteContext.Url, imageConfig.RemoteContext.Ref)
+		if err != nil {
+			return nil, err
+		}
+
+		if dockerfileData, err = remoteGitRepo.ReadCommitFile(ctx, commit, filepath.ToSlash(relDockerfilePath)); err != nil {
+			return nil, fmt.Errorf("unable to read %s from the remote git repository %s commit %s: %s", relDockerfilePath, remoteGitRepo.Url, commit, err)
+		}
+	} else {
+		var err error
+		if dockerfileData, err = r.giterminismManager.FileReader().ReadDockerfile(ctx, relDockerfilePath); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := parser.Parse(bytes.NewReader(dockerfileData))
+	if err != nil {
+		return nil, err
+	}
+
+	dockerStages, dockerMetaArgs, err := instructions.Parse(p.AST)
+	if err != nil {
+		return nil, err
+	}
+
+	ds, err := stage.NewDockerStages(dockerStages, util.MapStringInterfaceToMapStringString(imageConfig.Args), dockerMetaArgs, len(dockerStages)-1)
+	if err != nil {
+		return nil, err
+	}
+
+	return ds.BaseImageNames()
+}
+
+// resolveGitRepos adds commits of the remote git repositories referenced by branch or tag:
+// remote git mappings, ansible requirements and remote build contexts of Dockerfile images.
+func (r *resolver) resolveGitRepos(ctx context.Context) error {
+	for _, imageConfig := range stapelImages(r.werfConfig) {
+		if imageConfig.Git != nil {
+			for _, gitConfig := range imageConfig.Git.Remote {
+				if err := r.resolveGitMapping(ctx, gitConfig); err != nil {
+					return err
+				}
+			}
+		}
+
+		if imageConfig.Ansible != nil {
+			requirements, err := build.GetAnsibleGitRequirements(ctx, r.giterminismManager, imageConfig.Ansible)
+			if err != nil {
+				return fmt.Errorf("image %q: %s", imageConfig.Name, err)
+			}
+
+			for _, requirement := range requirements {
+				if _, _, err := r.resolveRemoteGitRepoRef(ctx, requirement.Url, requirement.Version); err != nil {
+					return fmt.Errorf("image %q: %s", imageConfig.Name, err)
+				}
+			}
+		}
+	}
+
+	for _, imageConfig := range r.werfConfig.ImagesFromDockerfile {
+		if imageConfig.RemoteContext == nil {
+			continue
+		}
+
+		if _, _, err := r.resolveRemoteGitRepoRef(ctx, imageConfig.RemoteContext.Url, imageConfig.RemoteContext.Ref); err != nil {
+			return fmt.Errorf("image %q: %s", imageConfig.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *resolver) resolveGitMapping(ctx context.Context, gitConfig *config.GitRemote) error {
+	if gitConfig.Commit != "" || r.lock.GetGitRepo(gitConfig.Url, gitConfig.Branch, gitConfig.Tag) != nil {
+		return nil
+	}
+
+	remoteGitRepo, err := r.getOrOpenRemoteGitRepo(ctx, gitConfig.Name, gitConfig.Url, func() (git_repo.RemoteOptions, error) {
+		return build.GetRemoteGitRepoOptions(r.werfConfig, gitConfig.Name)
+	})
+	if err != nil {
+		return err
+	}
+
+	gitRepo := &werf_lock.GitRepo{Url: gitConfig.Url, Branch: gitConfig.Branch, Tag: gitConfig.Tag}
+
+	switch {
+	case gitConfig.Tag != "":
+		gitRepo.Commit, err = remoteGitRepo.TagCommit(ctx, gitConfig.Tag)
+	case gitConfig.Branch != "":
+		gitRepo.Commit, err = remoteGitRepo.LatestBranchCommit(ctx, gitConfig.Branch)
+	default:
+		gitRepo.Commit, err = remoteGitRepo.HeadCommit(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to resolve remote git repo %s commit: %s", gitConfig.Url, err)
+	}
+
+	r.lock.AddGitRepo(gitRepo)
+
+	return nil
+}
+
+// resolveRemoteGitRepoRef resolves the reference, which is a tag, a branch or a commit (HEAD if empty), the same way as the build does.
+// Tags and branches are added to the lock, commits are deterministic and are not locked.
+func (r *resolver) resolveRemoteGitRepoRef(ctx context.Context, url, ref string) (*git_repo.Remote, string, error) {
+	remoteGitRepo, err := r.getOrOpenRemoteGitRepo(ctx, remoteGitRepoName(url), url, func() (git_repo.RemoteOptions, error) {
+		return git_repo.RemoteOptions{}, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if ref == "" {
+		if lockedGitRepo := r.lock.GetGitRepo(url, "", ""); lockedGitRepo != nil {
+			return remoteGitRepo, lockedGitRepo.Commit, nil
+		}
+
+		commit, err := remoteGitRepo.HeadCommit(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to resolve remote git repo %s HEAD commit: %s", url, err)
+		}
+
+		r.lock.AddGitRepo(&werf_lock.GitRepo{Url: url, Commit: commit})
+
+		return remoteGitRepo, commit, nil
+	}
+
+	for _, lockedGitRepo := range []*werf_lock.GitRepo{r.lock.GetGitRepo(url, "", ref), r.lock.GetGitRepo(url, ref, "")} {
+		if lockedGitRepo != nil {
+			return remoteGitRepo, lockedGitRepo.Commit, nil
+		}
+	}
+
+	commit, refType, err := remoteGitRepo.ResolveReference(ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch refType {
+	case git_repo.TagReference:
+		r.lock.AddGitRepo(&werf_lock.GitRepo{Url: url, Tag: ref, Commit: commit})
+	case git_repo.BranchReference:
+		r.lock.AddGitRepo(&werf_lock.GitRepo{Url: url, Branch: ref, Commit: commit})
+	}
+
+	return remoteGitRepo, commit, nil
+}
+
+// getOrOpenRemoteGitRepo returns the remote git repository by url, the repository is cloned or fetched once.
+func (r *resolver) getOrOpenRemoteGitRepo(ctx context.Context, name, url string, getOptionsFunc func() (git_repo.RemoteOptions, error)) (*git_repo.Remote, error) {
+	if remoteGitRepo, ok := r.remoteGitRepos[url]; ok {
+		return remoteGitRepo, nil
+	}
+
+	opts, err := getOptionsFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteGitRepo, err := git_repo.OpenRemoteRepo(name, url, opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open remote git repo %s by url %s: %s", name, url, err)
+	}
+
+	if err := logboek.Context(ctx).Info().LogProcess("Refreshing %s repository", name).DoError(func() error {
+		return remoteGitRepo.CloneAndFetch(ctx)
+	}); err != nil {
+		return nil, err
+	}
+
+	r.remoteGitRepos[url] = remoteGitRepo
+
+	return remoteGitRepo, nil
+}
+
+func remoteGitRepoName(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	return path.Base(strings.Replace(url, ":", "/", -1))
+}
+
+// resolveHelmDependencies adds digests of the chart dependency archives downloaded by Chart.lock.
+func resolveHelmDependencies(ctx context.Context, lock *werf_lock.Lock, giterminismManager giterminism_manager.Interface, opts Options) error {
+	chartFiles, err := giterminismManager.FileReader().LoadChartDir(ctx, opts.HelmChartDir)
+	if err != nil {
+		return err
+	}
+
+	var metadataFile, metadataLockFile *chart.ChartExtenderBufferedFile
+	for _, f := range chartFiles {
+		switch f.Name {
+		case "Chart.yaml":
+			metadataFile = f
+		case "Chart.lock":
+			metadataLockFile = f
+		}
+	}
+
+	if metadataFile == nil || metadataLockFile == nil {
+		return nil
+	}
+
+	metadata, err := chart_extender.LoadMetadata([]*chart.ChartExtenderBufferedFile{metadataFile})
+	if err != nil {
+		return err
+	}
+
+	metadataLock := new(chart.Lock)
+	if err := yaml.Unmarshal(metadataLockFile.Data, metadataLock); err != nil {
+		return fmt.Errorf("cannot load Chart.lock: %s", err)
+	}
+
+	conf := chart_extender.NewChartDependenciesConfiguration(metadata, metadataLock)
+	haveExternalDependencies, externalMetadataFile, externalMetadataLockFile, err := conf.GetExternalDependenciesFiles()
+	if err != nil {
+		return err
+	} else if !haveExternalDependencies {
+		return nil
+	}
+
+	depsDir, err := chart_extender.GetPreparedChartDependenciesDir(ctx, externalMetadataFile, externalMetadataLockFile, opts.HelmEnvSettings, opts.RegistryClientHandle, command_helpers.BuildChartDependenciesOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, dependency := range metadataLock.Dependencies {
+		if dependency.Repository == "" || strings.HasPrefix(dependency.Repository, "file://") {
+			continue
+		}
+
+		archivePath := filepath.Join(depsDir, "charts", fmt.Sprintf("%s-%s.tgz", dependency.Name, dependency.Version))
+		digest, err := fileSha256(archivePath)
+		if err != nil {
+			return fmt.Errorf("unable to calculate chart dependency %s-%s digest: %s", dependency.Name, dependency.Version, err)
+		}
+
+		lock.AddHelmDependency(&werf_lock.HelmDependency{
+			Name:       dependency.Name,
+			Version:    dependency.Version,
+			Repository: dependency.Repository,
+			Digest:     digest,
+		})
+	}
+
+	return nil
+}
+
+func stapelImages(werfConfig *config.WerfConfig) []*config.StapelImageBase {
+	var result []*config.StapelImageBase
+	for _, imageConfig := range werfConfig.StapelImages {
+		result = append(result, imageConfig.StapelImageBase)
+	}
+
+	for _, artifactConfig := range werfConfig.Artifacts {
+		result = append(result, artifactConfig.StapelImageBase)
+	}
+
+	return result
+}
+
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/config"
+	"github.com/werf/werf/pkg/werf_lock"
+)
+
+var _ = Describe("Resolve", func() {
+	var ctx context.Context
+	var server *httptest.Server
+	var registryHost string
+	var originDir, originUrl string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=werf", "-c", "user.email=werf@werf.io"}, args...)...)
+		cmd.Dir = originDir
+		output, err := cmd.CombinedOutput()
+		Ω(err).ShouldNot(HaveOccurred(), string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	commit := func(message string) string {
+		git("commit", "-q", "--allow-empty", "-m", message)
+		return git("rev-parse", "HEAD")
+	}
+
+	pushImage := func(ref string) string {
+		img, err := random.Image(1024, 1)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		r, err := name.ParseReference(ref)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(remote.Write(r, img)).Should(Succeed())
+
+		digest, err := img.Digest()
+		Ω(err).ShouldNot(HaveOccurred())
+
+		return digest.String()
+	}
+
+	gitRemote := func(branch, tag, commit string) *config.GitRemote {
+		return &config.GitRemote{
+			Name: "origin",
+			Url:  originUrl,
+			GitRemoteExport: &config.GitRemoteExport{
+				GitLocalExport: &config.GitLocalExport{
+					GitExportBase: &config.GitExportBase{
+						GitExport: &config.GitExport{
+							ExportBase: &config.ExportBase{Add: "/", To: "/app"},
+						},
+					},
+				},
+				Branch: branch,
+				Tag:    tag,
+				Commit: commit,
+			},
+		}
+	}
+
+	stapelImage := func(from string, gitConfigs ...*config.GitRemote) *config.StapelImage {
+		return &config.StapelImage{
+			StapelImageBase: &config.StapelImageBase{
+				Name: "app",
+				From: from,
+				Git:  &config.GitManager{Remote: gitConfigs},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		server = httptest.NewServer(registry.New())
+		registryHost = strings.TrimPrefix(server.URL, "http://")
+
+		var err error
+		originDir, err = ioutil.TempDir("", "werf-lock-resolver-origin-")
+		Ω(err).ShouldNot(HaveOccurred())
+		originUrl = "file://" + originDir
+
+		git("init", "-q")
+		git("checkout", "-q", "-b", "main")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Ω(os.RemoveAll(originDir)).Should(Succeed())
+	})
+
+	It("locks base images by digest and remote git references by commit", func() {
+		baseImageDigest := pushImage(registryHost + "/base:1.0")
+		tagCommit := commit("first")
+		git("tag", "-a", "v1.0.0", "-m", "v1.0.0")
+		git("checkout", "-q", "-b", "feature")
+		branchCommit := commit("feature")
+		git("checkout", "-q", "main")
+		headCommit := commit("second")
+
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{
+				stapelImage(
+					registryHost+"/base:1.0",
+					gitRemote("feature", "", ""),
+					gitRemote("", "v1.0.0", ""),
+					gitRemote("", "", ""),
+					gitRemote("", "", tagCommit),
+				),
+			},
+		}
+
+		lock, err := Resolve(ctx, nil, Options{WerfConfig: werfConfig})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(lock.Images).Should(Equal([]*werf_lock.Image{{Name: registryHost + "/base:1.0", Digest: baseImageDigest}}))
+		Ω(lock.GitRepos).Should(Equal([]*werf_lock.GitRepo{
+			{Url: originUrl, Branch: "feature", Commit: branchCommit},
+			{Url: originUrl, Tag: "v1.0.0", Commit: tagCommit},
+			{Url: originUrl, Commit: headCommit},
+		}))
+	})
+
+	It("locks the remote Dockerfile context and the base images of the remote Dockerfile", func() {
+		baseImageDigest := pushImage(registryHost + "/base:1.0")
+		pinnedBaseImage := fmt.Sprintf("%s/pinned@%s", registryHost, baseImageDigest)
+
+		dockerfile := fmt.Sprintf("FROM %s AS pinned\nFROM %s/base:1.0\n", pinnedBaseImage, registryHost)
+		Ω(ioutil.WriteFile(filepath.Join(originDir, "Dockerfile"), []byte(dockerfile), 0644)).Should(Succeed())
+		git("add", "Dockerfile")
+		contextCommit := commit("dockerfile")
+
+		werfConfig := &config.WerfConfig{
+			ImagesFromDockerfile: []*config.ImageFromDockerfile{
+				{
+					Name:          "app",
+					Dockerfile:    "Dockerfile",
+					RemoteContext: &config.DockerfileRemoteContext{Url: originUrl, Ref: "main"},
+				},
+			},
+		}
+
+		lock, err := Resolve(ctx, nil, Options{WerfConfig: werfConfig})
+		Ω(err).ShouldNot(HaveOccurred())
+
+		Ω(lock.Images).Should(Equal([]*werf_lock.Image{{Name: registryHost + "/base:1.0", Digest: baseImageDigest}}))
+		Ω(lock.GitRepos).Should(Equal([]*werf_lock.GitRepo{{Url: originUrl, Branch: "main", Commit: contextCommit}}))
+	})
+
+	It("detects the locked values which are stale after the base image and the branch are updated", func() {
+		pushImage(registryHost + "/base:1.0")
+		commit("first")
+
+		werfConfig := &config.WerfConfig{
+			StapelImages: []*config.StapelImage{stapelImage(registryHost+"/base:1.0", gitRemote("main", "", ""))},
+		}
+
+		locked, err := Resolve(ctx, nil, Options{WerfConfig: werfConfig})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(werf_lock.Check(locked, locked)).Should(Succeed())
+
+		newBaseImageDigest := pushImage(registryHost + "/base:1.0")
+		newCommit := commit("second")
+
+		actual, err := Resolve(ctx, nil, Options{WerfConfig: werfConfig})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(actual.GetImage(registryHost + "/base:1.0").Digest).Should(Equal(newBaseImageDigest))
+		Ω(actual.GetGitRepo(originUrl, "main", "").Commit).Should(Equal(newCommit))
+
+		err = werf_lock.Check(locked, actual)
+		Ω(err).Should(HaveOccurred())
+		Ω(err.Error()).Should(ContainSubstring(fmt.Sprintf("base image %s/base:1.0 digest %s does not match locked", registryHost, newBaseImageDigest)))
+		Ω(err.Error()).Should(ContainSubstring(fmt.Sprintf("git repo %s branch main commit %s does not match locked", originUrl, newCommit)))
+	})
+})
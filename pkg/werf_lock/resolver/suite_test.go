@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/werf/werf/pkg/docker_registry"
+	"github.com/werf/werf/pkg/git_repo"
+	"github.com/werf/werf/pkg/git_repo/gitdata"
+	"github.com/werf/werf/pkg/true_git"
+	"github.com/werf/werf/pkg/werf"
+)
+
+func TestSuite(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Werf Lock Resolver Suite")
+}
+
+var werfHomeDir string
+
+var _ = BeforeSuite(func() {
+	ctx := context.Background()
+
+	var err error
+	werfHomeDir, err = ioutil.TempDir("", "werf-lock-resolver-test-")
+	Ω(err).ShouldNot(HaveOccurred())
+
+	Ω(werf.Init(werfHomeDir, werfHomeDir)).Should(Succeed())
+	Ω(true_git.Init(true_git.Options{})).Should(Succeed())
+
+	gitDataManager, err := gitdata.GetHostGitDataManager(ctx)
+	Ω(err).ShouldNot(HaveOccurred())
+	Ω(git_repo.Init(gitDataManager)).Should(Succeed())
+
+	Ω(docker_registry.Init(ctx, true, false)).Should(Succeed())
+})
+
+var _ = AfterSuite(func() {
+	Ω(os.RemoveAll(werfHomeDir)).Should(Succeed())
+})